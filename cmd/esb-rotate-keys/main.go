@@ -0,0 +1,54 @@
+// esb-rotate-keys перешифровывает секреты connection_authentications основным
+// мастер-ключом. Порядок ротации: новый ключ задается в ESB_MASTER_KEY, прежний —
+// в ESB_MASTER_KEY_PREVIOUS, после выполнения команды прежний ключ можно удалить.
+// Команда также шифрует секреты, сохраненные до включения шифрования.
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go-esb/internal/config"
+	"go-esb/internal/database"
+	"go-esb/internal/encryption"
+	"go-esb/internal/logging"
+	"go-esb/internal/repository"
+)
+
+var logger = logging.For("rotate-keys")
+
+// fatal записывает ошибку ротации и завершает процесс
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+func main() {
+	cfg := config.Load()
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		fatal("❌ Failed to configure logging", "error", err)
+	}
+
+	keyring, err := encryption.LoadKeyring(cfg.MasterKey, cfg.MasterKeyFile, cfg.PreviousMasterKey, cfg.PreviousMasterKeyFile)
+	if err != nil {
+		fatal("❌ Failed to load master key", "error", err)
+	}
+	if keyring == nil {
+		fatal("❌ ESB_MASTER_KEY or ESB_MASTER_KEY_FILE is required")
+	}
+
+	db := database.Connect(cfg)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	connectionRepo := repository.NewConnectionRepository(db, keyring, "")
+	updated, err := connectionRepo.ReencryptConnectionAuths(ctx)
+	if err != nil {
+		fatal("❌ Key rotation failed", "error", err)
+	}
+
+	logger.Info("✅ Re-encrypted connection authentications", "count", updated, "key_id", keyring.PrimaryKeyID())
+}
@@ -2,35 +2,99 @@ package main
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"go-esb/internal/auth"
+	"go-esb/internal/balancer"
+	"go-esb/internal/breaker"
 	"go-esb/internal/config"
 	"go-esb/internal/database"
+	"go-esb/internal/encryption"
 	"go-esb/internal/handler"
+	"go-esb/internal/logging"
+	"go-esb/internal/middleware"
+	"go-esb/internal/models"
 	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
 	"go-esb/internal/service"
+	"go-esb/internal/throttle"
+	"go-esb/internal/tracing"
 )
 
+var logger = logging.For("main")
+
+// fatal записывает ошибку запуска и завершает процесс
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
 func main() {
 	cfg := config.Load()
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		fatal("❌ Failed to configure logging", "error", err)
+	}
+	if cfg.Environment != "" {
+		logger.Info("🌍 Environment profile", "environment", cfg.Environment)
+	}
 	db := database.Connect(cfg)
 	defer db.Close()
 
 	database.RunMigrations(db)
 
-	log.Println("💫 Go ESB is initialized and database is ready")
+	logger.Info("💫 Go ESB is initialized and database is ready")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracesExporter)
+	if err != nil {
+		fatal("❌ Failed to initialize tracing", "error", err)
+	}
+
+	keyring, err := encryption.LoadKeyring(cfg.MasterKey, cfg.MasterKeyFile, cfg.PreviousMasterKey, cfg.PreviousMasterKeyFile)
+	if err != nil {
+		fatal("❌ Failed to load master key", "error", err)
+	}
+	if keyring == nil {
+		logger.Warn("⚠️ ESB_MASTER_KEY is not set, connection credentials are stored unencrypted")
+	} else {
+		logger.Info("🔐 Connection credentials are encrypted", "master_key", keyring.PrimaryKeyID())
+	}
 
 	// Инициализация репозиториев
 	systemRepo := repository.NewSystemRepository(db)
 	routeRepo := repository.NewRouteRepository(db)
 	//threadRepo := repository.NewThreadRepository(db)
 	threadRouteRepo := repository.NewThreadRouteRepository(db)
-	connectionRepo := repository.NewConnectionRepository(db)
+	connectionRepo := repository.NewConnectionRepository(db, keyring, cfg.Environment)
+	ediRepo := repository.NewEDIRepository(db)
+	threadObjectRepo := repository.NewThreadObjectRepository(db)
+	globalRepo := repository.NewGlobalRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+
+	// Провайдеры секретов для ссылок env:, file:, vault: в учетных данных
+	secretResolver := secrets.NewResolver(cfg.SecretsCacheTTL)
+	secretResolver.Register("env", secrets.EnvProvider{})
+	secretResolver.Register("file", secrets.FileProvider{})
+	if cfg.VaultAddr != "" {
+		secretResolver.Register("vault", secrets.NewVaultProvider(cfg.VaultAddr, cfg.VaultToken, cfg.VaultNamespace, nil))
+		logger.Info("🔐 Vault secret provider enabled", "vault_addr", cfg.VaultAddr)
+	}
+
+	// Выключатели подключений к системам (состояние отдается в /api/v1/admin/health)
+	// и ограничители исходящих запросов
+	breakers := breaker.NewRegistry()
+	limiters := throttle.NewRegistry()
+
+	// Выбор подключения системы и активная проверка подключений
+	endpoints := balancer.NewRegistry()
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go service.NewEndpointProber(connectionRepo, systemRepo, secretResolver, endpoints).Run(backgroundCtx)
 
 	// Инициализация сервисов
 	messageService := service.NewMessageService(
@@ -38,6 +102,12 @@ func main() {
 		routeRepo,
 		connectionRepo,
 		systemRepo,
+		ediRepo,
+		globalRepo,
+		secretResolver,
+		breakers,
+		limiters,
+		endpoints,
 	)
 
 	orchestrator := service.NewOrchestrator(
@@ -49,7 +119,48 @@ func main() {
 	)
 
 	// Инициализация HTTP обработчика
-	httpHandler := handler.NewHTTPHandler(messageService, orchestrator)
+	threadRouteService := service.NewThreadRouteService(threadRouteRepo)
+	importService := service.NewImportService(systemRepo, routeRepo, threadObjectRepo, connectionRepo)
+
+	// Аутентификация HTTP API
+	jwtSecret := []byte(cfg.JWTSecret)
+	if len(jwtSecret) == 0 {
+		jwtSecret = make([]byte, 32)
+		if _, err := rand.Read(jwtSecret); err != nil {
+			fatal("❌ Failed to generate JWT secret", "error", err)
+		}
+		logger.Warn("⚠️ ESB_JWT_SECRET is not set, issued tokens are invalidated on restart")
+	}
+	authService := service.NewAuthService(userRepo, apiKeyRepo, threadRouteRepo, auth.NewTokenIssuer(jwtSecret, cfg.JWTTTL))
+	if err := authService.Bootstrap(context.Background(), cfg.AdminUser, cfg.AdminPassword); err != nil {
+		fatal("❌ Failed to initialize API users", "error", err)
+	}
+
+	certificateService := service.NewCertificateService(connectionRepo, secretResolver)
+
+	// Обмен с 1С: вход пользователем ESB с правом отправки в группе thread
+	exchange := handler.NewCommerceMLExchange(
+		messageService,
+		authService,
+		cfg.CommerceMLFileLimit,
+		cfg.CommerceMLSessionLimit,
+	)
+	go exchange.Run(backgroundCtx)
+
+	// Подавление повторных входящих сообщений: повтор webhook Stripe
+	// распознается по id события
+	processIdempotency := make(map[string]models.IdempotencySettings)
+	if cfg.StripeIdempotencyPath != "" {
+		processIdempotency[handler.StripeWebhookProcess] = models.IdempotencySettings{
+			Source:   models.IdempotencyJSONPath,
+			JSONPath: cfg.StripeIdempotencyPath,
+		}
+	}
+	idempotencyService := service.NewIdempotencyService(repository.NewIdempotencyRepository(db), threadRouteRepo, cfg.IdempotencyTTL, processIdempotency)
+	go idempotencyService.Run(backgroundCtx)
+	// Входящие сообщения из очередей AMQP (threads.amqp_consumer)
+	go service.NewAMQPConsumer(threadRouteRepo, connectionRepo, systemRepo, secretResolver, messageService, idempotencyService).Run(backgroundCtx)
+	httpHandler := handler.NewHTTPHandler(messageService, orchestrator, threadRouteService, importService, authService, certificateService, idempotencyService, breakers, endpoints, exchange)
 	router := httpHandler.SetupRoutes()
 
 	// Настройка HTTP сервера
@@ -58,42 +169,62 @@ func main() {
 		port = "8080"
 	}
 
+	// Обработка запроса прерывается за десятую часть WriteTimeout до его
+	// истечения: запрос к системе дольше этого времени завершается ответом
+	// 504, а не обрывом соединения
+	if cfg.HTTPWriteTimeout <= 0 {
+		fatal("❌ HTTP_WRITE_TIMEOUT must be positive")
+	}
+	handlerTimeout := cfg.HTTPWriteTimeout - cfg.HTTPWriteTimeout/10
 	srv := &http.Server{
 		Addr:         ":" + port,
-		Handler:      router,
+		Handler:      middleware.Deadline(handlerTimeout)(router),
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: cfg.HTTPWriteTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
 	// Graceful shutdown
 	go func() {
-		log.Printf("🚀 Go ESB server starting on port %s", port)
+		logger.Info("🚀 Go ESB server starting", "port", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("❌ Server failed to start: %v", err)
+			fatal("❌ Server failed to start", "error", err)
 		}
 	}()
 
-	log.Println("✅ Go ESB server is running")
-	log.Println("📡 Available endpoints:")
-	log.Println("   GET  /health")
-	log.Println("   POST /api/v1/messages/process/{threadId}")
-	log.Println("   POST /api/v1/orchestrate/{processName}")
-	log.Println("   POST /api/v1/webhooks/stripe")
+	logger.Info("✅ Go ESB server is running")
+	logger.Info("📡 Available endpoints", "endpoints", []string{
+		"GET /health",
+		"GET /metrics",
+		"POST /api/v1/auth/login",
+		"POST /api/v1/messages/process/{threadId}",
+		"POST /api/v1/orchestrate/{processName}",
+		"POST /api/v1/webhooks/stripe",
+		"PUT /api/v1/threads/{threadId}/routes/{routeId}/proto-schema",
+		"POST /api/v1/systems/{systemId}/import/wsdl",
+		"POST /api/v1/systems/{systemId}/import/openapi",
+		"GET /api/v1/exchange/1c/{threadId}",
+		"GET /api/v1/admin/log-levels",
+		"GET /api/v1/admin/health",
+	})
 
 	// Ожидание сигнала для graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("🛑 Shutting down server...")
+	logger.Info("🛑 Shutting down server...")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("❌ Server forced to shutdown: %v", err)
+		fatal("❌ Server forced to shutdown", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Warn("⚠️ Failed to flush traces", "error", err)
 	}
 
-	log.Println("✅ Server exited gracefully")
+	logger.Info("✅ Server exited gracefully")
 }
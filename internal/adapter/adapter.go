@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 
+	"go-esb/internal/logging"
 	"go-esb/internal/models"
 )
 
+var logger = logging.For("adapter")
+
 // ProtocolAdapter интерфейс для адаптеров протоколов
 type ProtocolAdapter interface {
 	Send(ctx context.Context, endpoint string, action string, headers map[string]string, body []byte) ([]byte, int, error)
-	Authenticate(auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error)
+	Authenticate(ctx context.Context, auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error)
 }
 
 // AdapterFactory создает адаптеры по типу протокола
@@ -21,10 +24,15 @@ type AdapterFactory struct {
 }
 
 // NewAdapterFactory создает фабрику адаптеров
-func NewAdapterFactory() *AdapterFactory {
+// REST и SOAP адаптеры используют общий кэш токенов OAuth2 и клиентов HTTP подключений.
+// store сохраняет refresh token OAuth2 после ротации (nil — только в памяти).
+func NewAdapterFactory(store RefreshTokenStore) *AdapterFactory {
+	clients := NewClientCache()
+	tokens := NewTokenManager(clients)
+	tokens.store = store
 	return &AdapterFactory{
-		restAdapter: NewRESTAdapter(),
-		soapAdapter: NewSOAPAdapter(),
+		restAdapter: NewRESTAdapter(tokens, clients),
+		soapAdapter: NewSOAPAdapter(tokens, clients),
 		amqpAdapter: NewAMQPAdapter(),
 	}
 }
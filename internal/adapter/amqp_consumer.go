@@ -0,0 +1,108 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go-esb/internal/logging"
+
+	"github.com/streadway/amqp"
+)
+
+// AMQPMessageIDHeader заголовок, в котором передается свойство message_id
+// полученного сообщения (например, для ключа идемпотентности)
+const AMQPMessageIDHeader = "Message-Id"
+
+// AMQPDelivery сообщение, полученное из очереди AMQP
+type AMQPDelivery struct {
+	Body        []byte
+	ContentType string
+	// Headers заголовки сообщения со строковыми значениями, а также
+	// message_id (Message-Id) и correlation_id (X-Correlation-ID)
+	Headers     map[string]string
+	Redelivered bool
+
+	delivery amqp.Delivery
+}
+
+// Ack подтверждает обработку сообщения
+func (d *AMQPDelivery) Ack() error {
+	return d.delivery.Ack(false)
+}
+
+// Nack отклоняет сообщение: с requeue оно возвращается в очередь,
+// иначе удаляется или уходит в dead letter exchange очереди
+func (d *AMQPDelivery) Nack(requeue bool) error {
+	return d.delivery.Nack(false, requeue)
+}
+
+// Consume читает очередь через отдельное соединение с брокером подключения
+// и передает сообщения handle по одному. Обработчик подтверждает или отклоняет
+// каждое сообщение. Возвращается при отмене контекста или разрыве соединения.
+func (a *AMQPAdapter) Consume(ctx context.Context, conn Connection, queue string, handle func(*AMQPDelivery)) error {
+	if conn.Settings == nil || !isBrokerURL(conn.Settings.Path) {
+		return fmt.Errorf("connection has no AMQP broker address")
+	}
+	connection, err := dialBroker(conn)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+
+	ch, err := connection.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	// Следующее сообщение брокер передает после подтверждения текущего
+	if err := ch.Qos(1, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+	deliveries, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume queue: %w", err)
+	}
+	setBrokerUp(conn, true)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("AMQP channel closed")
+			}
+			handle(NewAMQPDelivery(delivery))
+		}
+	}
+}
+
+// NewAMQPDelivery переводит заголовки и свойства доставки в строковые заголовки
+func NewAMQPDelivery(delivery amqp.Delivery) *AMQPDelivery {
+	headers := make(map[string]string, len(delivery.Headers)+2)
+	for key, value := range delivery.Headers {
+		switch v := value.(type) {
+		case string:
+			headers[key] = v
+		case []byte:
+			headers[key] = string(v)
+		case int8, int16, int32, int64, float32, float64, bool:
+			headers[key] = fmt.Sprint(v)
+		}
+	}
+	if delivery.MessageId != "" {
+		headers[AMQPMessageIDHeader] = delivery.MessageId
+	}
+	if delivery.CorrelationId != "" {
+		headers[logging.CorrelationHeader] = delivery.CorrelationId
+	}
+	return &AMQPDelivery{
+		Body:        delivery.Body,
+		ContentType: delivery.ContentType,
+		Headers:     headers,
+		Redelivered: delivery.Redelivered,
+		delivery:    delivery,
+	}
+}
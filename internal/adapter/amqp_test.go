@@ -0,0 +1,88 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-esb/internal/logging"
+	"go-esb/internal/metrics"
+	"go-esb/internal/tracing"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAMQPPublishingCarriesTraceContext(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), tracing.ExporterNone); err != nil {
+		t.Fatal(err)
+	}
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+
+	// Заголовки исходящего сообщения, как их собирает сервис сообщений
+	headers := map[string]string{"Content-Type": "application/xml", logging.CorrelationHeader: "corr-1"}
+	tracing.Inject(ctx, headers)
+	publishing := amqpPublishing(headers, []byte("<order/>"))
+
+	if publishing.ContentType != "application/xml" || publishing.CorrelationId != "corr-1" {
+		t.Fatalf("content type %q, correlation id %q; want application/xml, corr-1", publishing.ContentType, publishing.CorrelationId)
+	}
+	if got, want := publishing.Headers["traceparent"], "00-"+traceID.String()+"-"+spanID.String()+"-01"; got != want {
+		t.Fatalf("traceparent = %v, want %s", got, want)
+	}
+
+	// Получатель восстанавливает контекст трассировки из заголовков доставки
+	delivery := NewAMQPDelivery(amqp.Delivery{Headers: publishing.Headers, CorrelationId: publishing.CorrelationId})
+	remote := trace.SpanContextFromContext(tracing.Extract(context.Background(), propagation.MapCarrier(delivery.Headers)))
+	if remote.TraceID() != traceID || remote.SpanID() != spanID || !remote.IsRemote() {
+		t.Fatalf("extracted span context %s/%s, want remote %s/%s", remote.TraceID(), remote.SpanID(), traceID, spanID)
+	}
+}
+
+func TestAMQPBrokerCloseResetsConnectionUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		replaced bool
+		want     float64
+	}{
+		{"closed channel", false, 0},
+		{"channel replaced by a new connection", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAMQPAdapter()
+			ref := uuid.New()
+			broker := &amqpBroker{system: "rabbit", connection: tt.name}
+			a.brokers[ref] = broker
+			gauge := metrics.AMQPConnectionUp.WithLabelValues("rabbit", tt.name)
+			gauge.Set(1)
+			if tt.replaced {
+				a.brokers[ref] = &amqpBroker{system: "rabbit", connection: tt.name}
+			}
+
+			closed := make(chan *amqp.Error, 1)
+			done := make(chan struct{})
+			go func() {
+				a.watch(ref, broker, closed)
+				close(done)
+			}()
+			closed <- &amqp.Error{Code: amqp.ChannelError, Reason: "channel closed"}
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("watch did not return after the channel closed")
+			}
+
+			if got := testutil.ToFloat64(gauge); got != tt.want {
+				t.Fatalf("esb_amqp_connection_up = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Exclusive XML Canonicalization 1.0 (http://www.w3.org/2001/10/xml-exc-c14n#)
+// для подписи и проверки XML-DSig. encoding/xml не сохраняет префиксы
+// при сериализации, поэтому документ разбирается в собственное дерево.
+
+const excC14NAlgorithm = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+// xmlNode элемент XML с исходными префиксами
+type xmlNode struct {
+	prefix   string
+	local    string
+	attrs    []xml.Attr
+	children []interface{} // *xmlNode, xml.CharData, xml.ProcInst
+	parent   *xmlNode
+}
+
+// parseXMLTree разбирает документ и возвращает корневой элемент
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var root, current *xmlNode
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{prefix: t.Name.Space, local: t.Name.Local, parent: current}
+			node.attrs = append(node.attrs, t.Attr...)
+			if current == nil {
+				if root != nil {
+					return nil, fmt.Errorf("multiple root elements")
+				}
+				root = node
+			} else {
+				current.children = append(current.children, node)
+			}
+			current = node
+		case xml.EndElement:
+			if current == nil || current.prefix != t.Name.Space || current.local != t.Name.Local {
+				return nil, fmt.Errorf("unexpected end element %s", t.Name.Local)
+			}
+			current = current.parent
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, xml.CharData(append([]byte(nil), t...)))
+			}
+		case xml.ProcInst:
+			if current != nil {
+				current.children = append(current.children, xml.ProcInst{Target: t.Target, Inst: append([]byte(nil), t.Inst...)})
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("unclosed element %s", current.local)
+	}
+	return root, nil
+}
+
+// namespace возвращает URI пространства имен префикса в области видимости элемента
+func (n *xmlNode) namespace(prefix string) string {
+	if prefix == "xml" {
+		return "http://www.w3.org/XML/1998/namespace"
+	}
+	for node := n; node != nil; node = node.parent {
+		for _, attr := range node.attrs {
+			if prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+				return attr.Value
+			}
+			if prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
+
+// is проверяет пространство имен и локальное имя элемента
+func (n *xmlNode) is(namespace, local string) bool {
+	return n.local == local && n.namespace(n.prefix) == namespace
+}
+
+// child возвращает первый дочерний элемент с заданным именем
+func (n *xmlNode) child(namespace, local string) *xmlNode {
+	for _, c := range n.children {
+		if node, ok := c.(*xmlNode); ok && node.is(namespace, local) {
+			return node
+		}
+	}
+	return nil
+}
+
+// childrenNamed возвращает дочерние элементы с заданным именем
+func (n *xmlNode) childrenNamed(namespace, local string) []*xmlNode {
+	var result []*xmlNode
+	for _, c := range n.children {
+		if node, ok := c.(*xmlNode); ok && node.is(namespace, local) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// attr возвращает значение атрибута по локальному имени (без учета префикса)
+func (n *xmlNode) attr(local string) string {
+	for _, attr := range n.attrs {
+		if attr.Name.Space != "xmlns" && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// text возвращает текстовое содержимое элемента
+func (n *xmlNode) text() string {
+	var sb strings.Builder
+	for _, c := range n.children {
+		switch v := c.(type) {
+		case xml.CharData:
+			sb.Write(v)
+		case *xmlNode:
+			sb.WriteString(v.text())
+		}
+	}
+	return sb.String()
+}
+
+// walk обходит элемент и всех потомков
+func (n *xmlNode) walk(fn func(*xmlNode)) {
+	fn(n)
+	for _, c := range n.children {
+		if node, ok := c.(*xmlNode); ok {
+			node.walk(fn)
+		}
+	}
+}
+
+// canonicalize сериализует поддерево по Exclusive C14N без комментариев.
+// inclusive — префиксы из InclusiveNamespaces PrefixList; exclude — поддерево,
+// исключаемое из вывода (transform enveloped-signature).
+func canonicalize(n *xmlNode, inclusive []string, exclude *xmlNode) []byte {
+	var buf bytes.Buffer
+	c14nElement(&buf, n, map[string]string{"": ""}, inclusive, exclude)
+	return buf.Bytes()
+}
+
+func c14nElement(buf *bytes.Buffer, n *xmlNode, rendered map[string]string, inclusive []string, exclude *xmlNode) {
+	if n == exclude {
+		return
+	}
+
+	// Видимо используемые префиксы: префикс элемента и префиксы атрибутов
+	used := map[string]bool{n.prefix: true}
+	var attrs []xml.Attr
+	for _, attr := range n.attrs {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		if attr.Name.Space != "" && attr.Name.Space != "xml" {
+			used[attr.Name.Space] = true
+		}
+		attrs = append(attrs, attr)
+	}
+	for _, prefix := range inclusive {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if prefix == "" || n.namespace(prefix) != "" {
+			used[prefix] = true
+		}
+	}
+
+	// Объявления пространств имен, которые еще не выведены предками
+	scope := make(map[string]string, len(rendered)+len(used))
+	for prefix, uri := range rendered {
+		scope[prefix] = uri
+	}
+	var prefixes []string
+	for prefix := range used {
+		uri := n.namespace(prefix)
+		if current, ok := rendered[prefix]; ok && current == uri {
+			continue
+		}
+		if prefix != "" && uri == "" {
+			continue
+		}
+		scope[prefix] = uri
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	sort.SliceStable(attrs, func(i, j int) bool {
+		nsI, nsJ := n.attrNamespace(attrs[i]), n.attrNamespace(attrs[j])
+		if nsI != nsJ {
+			return nsI < nsJ
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+
+	buf.WriteByte('<')
+	buf.WriteString(qualifiedName(n.prefix, n.local))
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + prefix + `="`)
+		}
+		buf.WriteString(escapeC14NAttr(scope[prefix]))
+		buf.WriteByte('"')
+	}
+	for _, attr := range attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(qualifiedName(attr.Name.Space, attr.Name.Local))
+		buf.WriteString(`="`)
+		buf.WriteString(escapeC14NAttr(attr.Value))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+
+	for _, c := range n.children {
+		switch v := c.(type) {
+		case *xmlNode:
+			c14nElement(buf, v, scope, inclusive, exclude)
+		case xml.CharData:
+			buf.WriteString(escapeC14NText(string(v)))
+		case xml.ProcInst:
+			buf.WriteString("<?" + v.Target)
+			if len(v.Inst) > 0 {
+				buf.WriteByte(' ')
+				buf.Write(v.Inst)
+			}
+			buf.WriteString("?>")
+		}
+	}
+
+	buf.WriteString("</" + qualifiedName(n.prefix, n.local) + ">")
+}
+
+// attrNamespace возвращает URI атрибута (атрибуты без префикса — без пространства имен)
+func (n *xmlNode) attrNamespace(attr xml.Attr) string {
+	if attr.Name.Space == "" {
+		return ""
+	}
+	return n.namespace(attr.Name.Space)
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var c14nTextReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+var c14nAttrReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;",
+	"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeC14NText(s string) string {
+	return c14nTextReplacer.Replace(s)
+}
+
+func escapeC14NAttr(s string) string {
+	return c14nAttrReplacer.Replace(s)
+}
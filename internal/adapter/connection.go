@@ -0,0 +1,28 @@
+package adapter
+
+import (
+	"context"
+
+	"go-esb/internal/models"
+)
+
+type connectionKey struct{}
+
+// Connection настройки подключения, передаваемые адаптеру вместе с запросом
+type Connection struct {
+	Settings *models.ConnectionSetting
+	Auth     *models.ConnectionAuthentication
+	// System имя системы подключения (метки метрик)
+	System string
+}
+
+// WithConnection добавляет настройки подключения в контекст запроса Send
+func WithConnection(ctx context.Context, conn Connection) context.Context {
+	return context.WithValue(ctx, connectionKey{}, conn)
+}
+
+// ConnectionFromContext возвращает настройки подключения из контекста
+func ConnectionFromContext(ctx context.Context) (Connection, bool) {
+	conn, ok := ctx.Value(connectionKey{}).(Connection)
+	return conn, ok
+}
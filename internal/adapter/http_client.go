@@ -0,0 +1,201 @@
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Значения по умолчанию клиента HTTP подключения
+const (
+	defaultTimeout             = 30 * time.Second
+	defaultConnectTimeout      = 10 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+const (
+	// clientIdleTTL время, через которое удаляется неиспользуемый клиент
+	// (например, удаленного подключения)
+	clientIdleTTL = 30 * time.Minute
+	// clientSweepInterval период поиска неиспользуемых клиентов
+	clientSweepInterval = time.Minute
+)
+
+// ClientCache хранит клиентов HTTP подключений. Клиент создается по настройкам
+// http_client подключения и пересоздается, когда они меняются, поэтому
+// соединения с системой переиспользуются между запросами. Клиент, не
+// использованный clientIdleTTL, удаляется вместе с соединениями.
+type ClientCache struct {
+	mu        sync.Mutex
+	clients   map[uuid.UUID]*cachedClient
+	fallback  *http.Client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type cachedClient struct {
+	fingerprint string
+	client      *http.Client
+	lastUsed    time.Time
+}
+
+// NewClientCache создает кэш клиентов HTTP
+func NewClientCache() *ClientCache {
+	fallback, _ := NewHTTPClient(models.HTTPClientSettings{}, nil)
+	return &ClientCache{clients: make(map[uuid.UUID]*cachedClient), fallback: fallback, now: time.Now}
+}
+
+// Client возвращает клиента подключения из контекста запроса
+// (или клиента с настройками по умолчанию, если подключения нет)
+func (c *ClientCache) Client(ctx context.Context) (*http.Client, error) {
+	conn, ok := ConnectionFromContext(ctx)
+	if !ok || conn.Settings == nil {
+		return c.fallback, nil
+	}
+	return c.Get(conn.Settings, conn.Auth)
+}
+
+// Get возвращает клиента подключения. Для аутентификации Certificate клиент
+// предъявляет сертификат при установке TLS соединения; замена сертификата
+// (в том числе в хранилище секретов) создает нового клиента.
+func (c *ClientCache) Get(settings *models.ConnectionSetting, auth *models.ConnectionAuthentication) (*http.Client, error) {
+	if !isCertificateAuth(auth) {
+		auth = nil
+	}
+	fingerprint, err := clientFingerprint(settings.HTTPClient, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.sweepLocked(now)
+	cached, ok := c.clients[settings.Ref]
+	if ok && cached.fingerprint == fingerprint {
+		cached.lastUsed = now
+		return cached.client, nil
+	}
+
+	client, err := NewHTTPClient(settings.HTTPClient, auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http client settings of %s: %w", settings.Name, err)
+	}
+	if ok {
+		// Настройки изменились: соединения прежнего клиента закрываются
+		cached.client.CloseIdleConnections()
+	}
+	c.clients[settings.Ref] = &cachedClient{fingerprint: fingerprint, client: client, lastUsed: now}
+	return client, nil
+}
+
+// sweepLocked не чаще clientSweepInterval удаляет клиентов, не использованных
+// clientIdleTTL, и закрывает их соединения
+func (c *ClientCache) sweepLocked(now time.Time) {
+	if now.Sub(c.lastSweep) < clientSweepInterval {
+		return
+	}
+	c.lastSweep = now
+	for ref, cached := range c.clients {
+		if now.Sub(cached.lastUsed) > clientIdleTTL {
+			cached.client.CloseIdleConnections()
+			delete(c.clients, ref)
+		}
+	}
+}
+
+// clientFingerprint отпечаток настроек клиента: при его изменении клиент пересоздается
+func clientFingerprint(settings models.HTTPClientSettings, auth *models.ConnectionAuthentication) (string, error) {
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode http client settings: %w", err)
+	}
+	if auth == nil {
+		return string(encoded), nil
+	}
+	authTLS, err := json.Marshal(auth.TLS)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tls settings: %w", err)
+	}
+	hash := sha256.New()
+	for _, part := range []string{string(encoded), auth.Ref.String(), string(authTLS), auth.Certificate, auth.PrivateKey} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// NewHTTPClient создает клиента HTTP с таймаутами, пулом соединений, прокси и TLS
+// подключения. Если задана аутентификация Certificate, клиент использует mTLS.
+func NewHTTPClient(settings models.HTTPClientSettings, auth *models.ConnectionAuthentication) (*http.Client, error) {
+	tlsConfig, err := ClientTLSConfig(settings.TLS, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if settings.ProxyURL != "" {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q", settings.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   milliseconds(settings.ConnectTimeoutMs, defaultConnectTimeout),
+		KeepAlive: milliseconds(settings.KeepAliveMs, defaultKeepAlive),
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		ResponseHeaderTimeout: milliseconds(settings.ReadTimeoutMs, 0),
+		DisableKeepAlives:     settings.DisableKeepAlives,
+		IdleConnTimeout:       milliseconds(settings.IdleConnTimeoutMs, defaultIdleConnTimeout),
+		MaxIdleConns:          positive(settings.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   positive(settings.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !settings.DisableHTTP2,
+	}
+	if settings.DisableHTTP2 {
+		// Непустой TLSNextProto без h2 отключает HTTP/2 в http.Transport
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   milliseconds(settings.TimeoutMs, defaultTimeout),
+	}, nil
+}
+
+func milliseconds(value int, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+	return time.Duration(value) * time.Millisecond
+}
+
+func positive(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,210 @@
+package adapter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestHTTPClientTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		settings models.HTTPClientSettings
+		wantErr  bool
+	}{
+		{"default", models.HTTPClientSettings{}, false},
+		{"total timeout", models.HTTPClientSettings{TimeoutMs: 50}, true},
+		{"response header timeout", models.HTTPClientSettings{ReadTimeoutMs: 50}, true},
+		{"timeouts above response time", models.HTTPClientSettings{TimeoutMs: 2000, ReadTimeoutMs: 1000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.settings, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Прокси получает абсолютный адрес запроса
+		proxied = r.URL.String()
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(models.HTTPClientSettings{ProxyURL: proxy.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://sap.invalid/orders?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy" || proxied != "http://sap.invalid/orders?id=1" {
+		t.Fatalf("response %q, proxied %q; want the request sent through the proxy", body, proxied)
+	}
+
+	for _, proxyURL := range []string{"proxy.corp:3128", "://proxy"} {
+		if _, err := NewHTTPClient(models.HTTPClientSettings{ProxyURL: proxyURL}, nil); err == nil {
+			t.Fatalf("proxy url %q accepted", proxyURL)
+		}
+	}
+}
+
+func TestHTTPClientHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		name         string
+		disableHTTP2 bool
+		want         string
+	}{
+		{"negotiated", false, "HTTP/2.0"},
+		{"disabled", true, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(models.HTTPClientSettings{
+				DisableHTTP2: tt.disableHTTP2,
+				TLS:          models.TLSSettings{CACertificate: ca},
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != tt.want {
+				t.Fatalf("protocol = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCacheRebuildsOnSettingsChange(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM := testKeyPair(t, key)
+	otherCertPEM, otherKeyPEM := testKeyPair(t, key)
+
+	cache := NewClientCache()
+	settings := &models.ConnectionSetting{Ref: uuid.New(), Name: "sap", HTTPClient: models.HTTPClientSettings{TimeoutMs: 1000}}
+	certificate := &models.ConnectionAuthentication{Ref: uuid.New(), Type: models.AuthCertificate, Certificate: certPEM, PrivateKey: keyPEM}
+	basic := &models.ConnectionAuthentication{Ref: uuid.New(), Type: models.AuthBasic, Username: "esb", Password: "secret"}
+
+	get := func(auth *models.ConnectionAuthentication) *http.Client {
+		t.Helper()
+		client, err := cache.Get(settings, auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	first := get(nil)
+	if get(nil) != first {
+		t.Fatal("client rebuilt without settings change")
+	}
+	// Аутентификация без сертификата не влияет на клиента
+	if get(basic) != first {
+		t.Fatal("client rebuilt for basic authentication")
+	}
+	basic.Password = "changed"
+	if get(basic) != first {
+		t.Fatal("client rebuilt after password change")
+	}
+
+	settings.HTTPClient.TimeoutMs = 2000
+	second := get(nil)
+	if second == first || second.Timeout != 2*time.Second {
+		t.Fatalf("client not rebuilt after timeout change (timeout %v)", second.Timeout)
+	}
+
+	withCertificate := get(certificate)
+	if withCertificate == second {
+		t.Fatal("client not rebuilt for certificate authentication")
+	}
+	if get(certificate) != withCertificate {
+		t.Fatal("client rebuilt with the same certificate")
+	}
+	// Замена сертификата (например, в хранилище секретов) создает нового клиента
+	certificate.Certificate, certificate.PrivateKey = otherCertPEM, otherKeyPEM
+	if get(certificate) == withCertificate {
+		t.Fatal("client not rebuilt after certificate change")
+	}
+
+	settings.HTTPClient.ProxyURL = "not a url"
+	if _, err := cache.Get(settings, nil); err == nil {
+		t.Fatal("invalid settings accepted")
+	}
+}
+
+func TestClientCacheEvictsIdleClients(t *testing.T) {
+	now := time.Now()
+	cache := NewClientCache()
+	cache.now = func() time.Time { return now }
+	active := &models.ConnectionSetting{Ref: uuid.New(), Name: "active"}
+	deleted := &models.ConnectionSetting{Ref: uuid.New(), Name: "deleted"}
+
+	activeClient, _ := cache.Get(active, nil)
+	deletedClient, _ := cache.Get(deleted, nil)
+
+	// Используется только одно подключение
+	for elapsed := time.Duration(0); elapsed <= clientIdleTTL; elapsed += 10 * time.Minute {
+		now = now.Add(10 * time.Minute)
+		if client, _ := cache.Get(active, nil); client != activeClient {
+			t.Fatal("active client evicted")
+		}
+	}
+
+	cache.mu.Lock()
+	_, deletedCached := cache.clients[deleted.Ref]
+	cached := len(cache.clients)
+	cache.mu.Unlock()
+	if deletedCached || cached != 1 {
+		t.Fatalf("idle client kept: %d clients cached", cached)
+	}
+	if client, _ := cache.Get(deleted, nil); client == deletedClient {
+		t.Fatal("evicted client returned again")
+	}
+}
@@ -0,0 +1,277 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+const (
+	// oauth2RefreshBefore за сколько до истечения токен обновляется в фоне
+	oauth2RefreshBefore = 2 * time.Minute
+	// oauth2DefaultTTL срок жизни токена, если сервер не вернул expires_in
+	oauth2DefaultTTL = time.Hour
+	// oauth2RefreshTimeout время фонового обновления токена
+	oauth2RefreshTimeout = 30 * time.Second
+)
+
+// Reauthenticator реализуется адаптерами, которые умеют сбрасывать
+// закэшированный токен и получать новый (повтор запроса после 401)
+type Reauthenticator interface {
+	Reauthenticate(ctx context.Context, auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error)
+}
+
+// RefreshTokenStore сохраняет refresh token, выданный token endpoint взамен
+// прежнего (ротация): после перезапуска шины прежний токен уже недействителен
+type RefreshTokenStore interface {
+	SaveRefreshToken(ctx context.Context, auth *models.ConnectionAuthentication, refreshToken string) error
+}
+
+// TokenManager получает токены OAuth2 (client_credentials и refresh_token),
+// кэширует их до истечения и обновляет заранее. Запросы к token endpoint
+// выполняются клиентом HTTP подключения (таймауты, прокси, TLS).
+type TokenManager struct {
+	clients *ClientCache
+	// store сохраняет новый refresh token; nil — только в памяти
+	store RefreshTokenStore
+
+	mu     sync.Mutex
+	tokens map[string]*oauth2Token
+}
+
+type oauth2Token struct {
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+	refreshing   bool
+	// fetch сериализует запросы к token endpoint для одной записи
+	fetch sync.Mutex
+}
+
+// oauth2Response ответ token endpoint (RFC 6749, раздел 5.1)
+type oauth2Response struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewTokenManager создает менеджер токенов OAuth2
+func NewTokenManager(clients *ClientCache) *TokenManager {
+	return &TokenManager{
+		clients: clients,
+		tokens:  make(map[string]*oauth2Token),
+	}
+}
+
+// cacheKey ключ кэша: запись аутентификации и параметры, от которых зависит токен
+func (m *TokenManager) cacheKey(auth *models.ConnectionAuthentication) string {
+	return strings.Join([]string{auth.Ref.String(), auth.TokenURL, auth.ClientID, auth.Scope}, "|")
+}
+
+func (m *TokenManager) entry(auth *models.ConnectionAuthentication) *oauth2Token {
+	key := m.cacheKey(auth)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[key]
+	if !ok {
+		token = &oauth2Token{}
+		m.tokens[key] = token
+	}
+	return token
+}
+
+// Token возвращает действующий access token. Истекший токен получается заново
+// синхронно, токен, который скоро истечет, обновляется в фоне. Подключение
+// берется из контекста запроса (WithConnection).
+func (m *TokenManager) Token(ctx context.Context, auth *models.ConnectionAuthentication) (string, error) {
+	token := m.entry(auth)
+
+	m.mu.Lock()
+	accessToken, expiresAt := token.accessToken, token.expiresAt
+	now := time.Now()
+	if accessToken != "" && now.Before(expiresAt) {
+		if now.Add(oauth2RefreshBefore).After(expiresAt) && !token.refreshing {
+			token.refreshing = true
+			go m.refreshInBackground(context.WithoutCancel(ctx), auth, token)
+		}
+		m.mu.Unlock()
+		return accessToken, nil
+	}
+	m.mu.Unlock()
+
+	token.fetch.Lock()
+	defer token.fetch.Unlock()
+
+	// Токен мог получить другой запрос, пока мы ждали
+	m.mu.Lock()
+	if token.accessToken != "" && time.Now().Before(token.expiresAt) {
+		accessToken = token.accessToken
+		m.mu.Unlock()
+		return accessToken, nil
+	}
+	m.mu.Unlock()
+
+	if err := m.fetchToken(ctx, auth, token); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return token.accessToken, nil
+}
+
+// Invalidate сбрасывает закэшированный access token (например, после 401).
+// Refresh token сохраняется.
+func (m *TokenManager) Invalidate(auth *models.ConnectionAuthentication) {
+	token := m.entry(auth)
+	m.mu.Lock()
+	token.accessToken = ""
+	token.expiresAt = time.Time{}
+	m.mu.Unlock()
+}
+
+// refreshInBackground обновляет токен после завершения запроса, который его
+// инициировал; ctx сохраняет подключение запроса, но не его отмену
+func (m *TokenManager) refreshInBackground(ctx context.Context, auth *models.ConnectionAuthentication, token *oauth2Token) {
+	defer func() {
+		m.mu.Lock()
+		token.refreshing = false
+		m.mu.Unlock()
+	}()
+
+	token.fetch.Lock()
+	defer token.fetch.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, oauth2RefreshTimeout)
+	defer cancel()
+	if err := m.fetchToken(ctx, auth, token); err != nil {
+		logger.WarnContext(ctx, "⚠️ OAuth2 token refresh failed", "auth", auth.Name, "error", err)
+	}
+}
+
+// fetchToken запрашивает токен: refresh_token, если он есть, иначе client_credentials.
+// Если refresh token отклонен, выполняется повтор с client_credentials.
+func (m *TokenManager) fetchToken(ctx context.Context, auth *models.ConnectionAuthentication, token *oauth2Token) error {
+	if auth.TokenURL == "" {
+		return fmt.Errorf("oauth2 token_url is not configured")
+	}
+
+	m.mu.Lock()
+	refreshToken := token.refreshToken
+	m.mu.Unlock()
+	if refreshToken == "" {
+		refreshToken = auth.RefreshToken
+	}
+
+	var resp *oauth2Response
+	var err error
+	if refreshToken != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+		resp, err = m.requestToken(ctx, auth, form)
+		if err != nil && auth.ClientSecret == "" {
+			return err
+		}
+		if err != nil {
+			logger.WarnContext(ctx, "⚠️ OAuth2 refresh token rejected, falling back to client credentials", "auth", auth.Name, "error", err)
+			refreshToken = ""
+		}
+	}
+	if resp == nil {
+		resp, err = m.requestToken(ctx, auth, url.Values{"grant_type": {"client_credentials"}})
+		if err != nil {
+			return err
+		}
+	}
+
+	ttl := oauth2DefaultTTL
+	if resp.ExpiresIn > 0 {
+		ttl = time.Duration(resp.ExpiresIn) * time.Second
+	}
+
+	m.mu.Lock()
+	token.accessToken = resp.AccessToken
+	token.expiresAt = time.Now().Add(ttl)
+	// Сервер может выдать новый refresh token (ротация)
+	rotated := resp.RefreshToken != "" && resp.RefreshToken != refreshToken
+	if resp.RefreshToken != "" {
+		token.refreshToken = resp.RefreshToken
+	} else {
+		token.refreshToken = refreshToken
+	}
+	m.mu.Unlock()
+
+	if rotated && m.store != nil {
+		if err := m.store.SaveRefreshToken(ctx, auth, resp.RefreshToken); err != nil {
+			logger.WarnContext(ctx, "⚠️ Failed to save rotated OAuth2 refresh token", "auth", auth.Name, "error", err)
+		}
+	}
+
+	logger.InfoContext(ctx, "🔑 OAuth2 token obtained", "auth", auth.Name, "expires_in", ttl.String())
+	return nil
+}
+
+func (m *TokenManager) requestToken(ctx context.Context, auth *models.ConnectionAuthentication, form url.Values) (*oauth2Response, error) {
+	if auth.ClientID != "" {
+		form.Set("client_id", auth.ClientID)
+	}
+	if auth.ClientSecret != "" {
+		form.Set("client_secret", auth.ClientSecret)
+	}
+	if auth.Scope != "" {
+		form.Set("scope", auth.Scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client, err := m.clients.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var tokenResp oauth2Response
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 400 || tokenResp.Error != "" {
+		return nil, fmt.Errorf("token endpoint error %d: %s %s", resp.StatusCode, tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	return &tokenResp, nil
+}
+
+// oauth2Headers возвращает заголовок Authorization с токеном OAuth2
+func oauth2Headers(ctx context.Context, tokens *TokenManager, auth *models.ConnectionAuthentication) (map[string]string, error) {
+	accessToken, err := tokens.Token(ctx, auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+	return map[string]string{"Authorization": "Bearer " + accessToken}, nil
+}
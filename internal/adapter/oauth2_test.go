@@ -0,0 +1,205 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// tokenServer token endpoint, который отвечает ответами responses по очереди
+// (последний повторяется) и запоминает grant запросов
+type tokenServer struct {
+	mu        sync.Mutex
+	responses []tokenReply
+	grants    []string
+	refreshed []string
+}
+
+type tokenReply struct {
+	status int
+	body   oauth2Response
+}
+
+func newTokenServer(t *testing.T, responses ...tokenReply) (*tokenServer, *httptest.Server) {
+	ts := &tokenServer{responses: responses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("token request: %v", err)
+		}
+		ts.mu.Lock()
+		ts.grants = append(ts.grants, r.PostForm.Get("grant_type"))
+		ts.refreshed = append(ts.refreshed, r.PostForm.Get("refresh_token"))
+		reply := ts.responses[len(ts.responses)-1]
+		if len(ts.grants) <= len(ts.responses) {
+			reply = ts.responses[len(ts.grants)-1]
+		}
+		ts.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if reply.status != 0 {
+			w.WriteHeader(reply.status)
+		}
+		json.NewEncoder(w).Encode(reply.body)
+	}))
+	return ts, server
+}
+
+func (ts *tokenServer) requests() ([]string, []string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.grants...), append([]string(nil), ts.refreshed...)
+}
+
+// recordingTokenStore запоминает сохраненные refresh token
+type recordingTokenStore struct {
+	mu    sync.Mutex
+	saved []string
+}
+
+func (s *recordingTokenStore) SaveRefreshToken(_ context.Context, _ *models.ConnectionAuthentication, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, refreshToken)
+	return nil
+}
+
+func (s *recordingTokenStore) tokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.saved...)
+}
+
+func oauth2Auth(tokenURL string) *models.ConnectionAuthentication {
+	return &models.ConnectionAuthentication{
+		Ref: uuid.New(), Name: "sap-oauth", Type: models.AuthOAuth2,
+		TokenURL: tokenURL, ClientID: "esb", ClientSecret: "secret",
+	}
+}
+
+func mustToken(t *testing.T, tokens *TokenManager, auth *models.ConnectionAuthentication, want string) {
+	t.Helper()
+	got, err := tokens.Token(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if got != want {
+		t.Fatalf("Token = %q, want %q", got, want)
+	}
+}
+
+func TestTokenManagerCachesToken(t *testing.T) {
+	ts, server := newTokenServer(t, tokenReply{body: oauth2Response{AccessToken: "a1", ExpiresIn: 3600}})
+	defer server.Close()
+	tokens := NewTokenManager(NewClientCache())
+	auth := oauth2Auth(server.URL)
+
+	for i := 0; i < 3; i++ {
+		mustToken(t, tokens, auth, "a1")
+	}
+	if grants, _ := ts.requests(); len(grants) != 1 || grants[0] != "client_credentials" {
+		t.Fatalf("token requests %v, want a single client_credentials grant", grants)
+	}
+
+	// Токен сбрасывается после 401 и запрашивается заново
+	tokens.Invalidate(auth)
+	mustToken(t, tokens, auth, "a1")
+	if grants, _ := ts.requests(); len(grants) != 2 {
+		t.Fatalf("token requests after Invalidate %v, want 2", grants)
+	}
+}
+
+func TestTokenManagerRefreshesBeforeExpiry(t *testing.T) {
+	ts, server := newTokenServer(t,
+		// Токен истекает раньше oauth2RefreshBefore: обновляется в фоне
+		tokenReply{body: oauth2Response{AccessToken: "a1", ExpiresIn: 60, RefreshToken: "r1"}},
+		tokenReply{body: oauth2Response{AccessToken: "a2", ExpiresIn: 3600, RefreshToken: "r2"}},
+	)
+	defer server.Close()
+	store := &recordingTokenStore{}
+	tokens := NewTokenManager(NewClientCache())
+	tokens.store = store
+	auth := oauth2Auth(server.URL)
+
+	mustToken(t, tokens, auth, "a1")
+	// Токен, который скоро истечет, возвращается сразу
+	mustToken(t, tokens, auth, "a1")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if grants, _ := ts.requests(); len(grants) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("token was not refreshed in background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Дожидаемся сохранения результата фонового обновления
+	for tokens.refreshingFor(auth) {
+		time.Sleep(time.Millisecond)
+	}
+
+	mustToken(t, tokens, auth, "a2")
+	grants, refreshed := ts.requests()
+	if grants[1] != "refresh_token" || refreshed[1] != "r1" {
+		t.Fatalf("background refresh used %s with %q, want refresh_token r1", grants[1], refreshed[1])
+	}
+	if saved := store.tokens(); len(saved) != 2 || saved[0] != "r1" || saved[1] != "r2" {
+		t.Fatalf("saved refresh tokens %v, want [r1 r2]", saved)
+	}
+}
+
+func TestTokenManagerFallsBackToClientCredentials(t *testing.T) {
+	rejected := tokenReply{status: http.StatusBadRequest, body: oauth2Response{Error: "invalid_grant"}}
+	issued := tokenReply{body: oauth2Response{AccessToken: "a1", ExpiresIn: 3600}}
+
+	t.Run("with client secret", func(t *testing.T) {
+		ts, server := newTokenServer(t, rejected, issued)
+		defer server.Close()
+		store := &recordingTokenStore{}
+		tokens := NewTokenManager(NewClientCache())
+		tokens.store = store
+		auth := oauth2Auth(server.URL)
+		auth.RefreshToken = "stale"
+
+		mustToken(t, tokens, auth, "a1")
+		grants, refreshed := ts.requests()
+		if len(grants) != 2 || grants[0] != "refresh_token" || refreshed[0] != "stale" || grants[1] != "client_credentials" {
+			t.Fatalf("token requests %v (%v), want refresh_token then client_credentials", grants, refreshed)
+		}
+		if saved := store.tokens(); len(saved) != 0 {
+			t.Fatalf("saved refresh tokens %v without rotation", saved)
+		}
+	})
+
+	t.Run("without client secret", func(t *testing.T) {
+		ts, server := newTokenServer(t, rejected, issued)
+		defer server.Close()
+		tokens := NewTokenManager(NewClientCache())
+		auth := oauth2Auth(server.URL)
+		auth.ClientSecret, auth.RefreshToken = "", "stale"
+
+		if _, err := tokens.Token(context.Background(), auth); err == nil {
+			t.Fatal("rejected refresh token accepted")
+		}
+		if grants, _ := ts.requests(); len(grants) != 1 {
+			t.Fatalf("token requests %v, want only the refresh_token grant", grants)
+		}
+	})
+}
+
+// refreshingFor проверяет, что фоновое обновление токена еще выполняется
+func (m *TokenManager) refreshingFor(auth *models.ConnectionAuthentication) bool {
+	token := m.entry(auth)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return token.refreshing
+}
@@ -6,22 +6,21 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 
 	"go-esb/internal/models"
 )
 
 // RESTAdapter реализует REST протокол
 type RESTAdapter struct {
-	client *http.Client
+	clients *ClientCache
+	tokens  *TokenManager
 }
 
 // NewRESTAdapter создает новый REST адаптер
-func NewRESTAdapter() *RESTAdapter {
+func NewRESTAdapter(tokens *TokenManager, clients *ClientCache) *RESTAdapter {
 	return &RESTAdapter{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		clients: clients,
+		tokens:  tokens,
 	}
 }
 
@@ -51,7 +50,11 @@ func (r *RESTAdapter) Send(ctx context.Context, endpoint string, action string,
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := r.client.Do(req)
+	client, err := r.clients.Client(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, 0, fmt.Errorf("request failed: %w", err)
 	}
@@ -63,14 +66,14 @@ func (r *RESTAdapter) Send(ctx context.Context, endpoint string, action string,
 	}
 
 	if resp.StatusCode >= 400 {
-		return respBody, resp.StatusCode, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(respBody))
+		return respBody, resp.StatusCode, newStatusError("HTTP", resp, respBody)
 	}
 
 	return respBody, resp.StatusCode, nil
 }
 
 // Authenticate выполняет аутентификацию для REST API
-func (r *RESTAdapter) Authenticate(auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error) {
+func (r *RESTAdapter) Authenticate(ctx context.Context, auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error) {
 	headers := make(map[string]string)
 
 	switch auth.Type {
@@ -85,8 +88,22 @@ func (r *RESTAdapter) Authenticate(auth *models.ConnectionAuthentication, endpoi
 		if auth.Token != "" {
 			headers["Authorization"] = "Bearer " + auth.Token
 		}
+
+	case models.AuthOAuth2:
+		return oauth2Headers(ctx, r.tokens, auth)
+
+	case models.AuthCertificate:
+		// Сертификат клиента предъявляется при установке TLS соединения (ClientCache)
 	}
 
 	return headers, nil
 }
 
+// Reauthenticate сбрасывает токен OAuth2 и получает новый (после ответа 401)
+func (r *RESTAdapter) Reauthenticate(ctx context.Context, auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error) {
+	if auth.Type == models.AuthOAuth2 {
+		r.tokens.Invalidate(auth)
+	}
+	return r.Authenticate(ctx, auth, endpoint)
+}
+
@@ -7,57 +7,82 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 
 	"go-esb/internal/models"
 )
 
+// Пространства имен конверта SOAP 1.1 и SOAP 1.2
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
 // SOAPAdapter реализует SOAP протокол
 type SOAPAdapter struct {
-	client *http.Client
+	clients *ClientCache
+	tokens  *TokenManager
 }
 
 // NewSOAPAdapter создает новый SOAP адаптер
-func NewSOAPAdapter() *SOAPAdapter {
+func NewSOAPAdapter(tokens *TokenManager, clients *ClientCache) *SOAPAdapter {
 	return &SOAPAdapter{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		clients: clients,
+		tokens:  tokens,
 	}
 }
 
-// SOAPEnvelope представляет SOAP обертку
+// SOAPEnvelope представляет SOAP обертку ответа. Теги без пространства имен
+// подходят и для SOAP 1.1, и для SOAP 1.2.
 type SOAPEnvelope struct {
-	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	XMLName xml.Name `xml:"Envelope"`
 	Header  *SOAPHeader
 	Body    SOAPBody
 }
 
 // SOAPHeader представляет SOAP заголовок
 type SOAPHeader struct {
-	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Header"`
+	XMLName xml.Name `xml:"Header"`
 	Content []byte   `xml:",innerxml"`
 }
 
 // SOAPBody представляет SOAP тело
 type SOAPBody struct {
-	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
-	Content []byte   `xml:",innerxml"`
+	XMLName xml.Name          `xml:"Body"`
+	Content []byte            `xml:",innerxml"`
+	Fault   *SOAPFaultElement `xml:"Fault"`
 }
 
-// Send отправляет SOAP запрос (action содержит SOAPAction)
+// Send отправляет SOAP запрос (action содержит SOAPAction).
+// Версия SOAP берется из настроек подключения (по умолчанию 1.1).
+// SOAP Fault в ответе возвращается как ошибка *SOAPFault.
 func (s *SOAPAdapter) Send(ctx context.Context, endpoint string, action string, headers map[string]string, body []byte) ([]byte, int, error) {
 	soapAction := action
-	// Обертка SOAP тела в Envelope
-	envelope := SOAPEnvelope{
-		Body: SOAPBody{
-			Content: body,
-		},
+	// XML декларация полезной нагрузки недопустима внутри Body
+	body = stripXMLDeclaration(body)
+
+	var auth *models.ConnectionAuthentication
+	version := models.SOAPVersion11
+	if conn, ok := ConnectionFromContext(ctx); ok {
+		auth = conn.Auth
+		if conn.Settings != nil && conn.Settings.SOAPVersion == models.SOAPVersion12 {
+			version = models.SOAPVersion12
+		}
+	}
+	envNamespace := soap11Namespace
+	if version == models.SOAPVersion12 {
+		envNamespace = soap12Namespace
 	}
 
-	xmlData, err := xml.MarshalIndent(envelope, "", "  ")
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to marshal SOAP envelope: %w", err)
+	var xmlData []byte
+	var err error
+	if wsSecurityEnabled(auth) {
+		xmlData, err = buildSecuredEnvelope(envNamespace, body, auth)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to build WS-Security header: %w", err)
+		}
+	} else {
+		// Обертка SOAP тела в Envelope
+		xmlData = []byte(fmt.Sprintf(`<soap:Envelope xmlns:soap="%s"><soap:Body>%s</soap:Body></soap:Envelope>`, envNamespace, body))
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(xmlData))
@@ -65,10 +90,18 @@ func (s *SOAPAdapter) Send(ctx context.Context, endpoint string, action string,
 		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set SOAP headers
-	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
-	if soapAction != "" {
-		req.Header.Set("SOAPAction", soapAction)
+	// Set SOAP headers: SOAP 1.1 передает action в SOAPAction, SOAP 1.2 — в Content-Type
+	if version == models.SOAPVersion12 {
+		contentType := "application/soap+xml; charset=utf-8"
+		if soapAction != "" {
+			contentType += fmt.Sprintf(`; action="%s"`, soapAction)
+		}
+		req.Header.Set("Content-Type", contentType)
+	} else {
+		req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		if soapAction != "" {
+			req.Header.Set("SOAPAction", soapAction)
+		}
 	}
 
 	// Set additional headers
@@ -76,7 +109,11 @@ func (s *SOAPAdapter) Send(ctx context.Context, endpoint string, action string,
 		req.Header.Set(key, value)
 	}
 
-	resp, err := s.client.Do(req)
+	client, err := s.clients.Client(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, 0, fmt.Errorf("SOAP request failed: %w", err)
 	}
@@ -87,31 +124,56 @@ func (s *SOAPAdapter) Send(ctx context.Context, endpoint string, action string,
 		return nil, resp.StatusCode, fmt.Errorf("failed to read SOAP response: %w", err)
 	}
 
+	// Извлечение тела из SOAP ответа
+	envelope, parseErr := parseSOAPResponse(respBody)
+	if parseErr == nil && envelope.Body.Fault != nil {
+		fault := envelope.Body.Fault.toError(envelope.XMLName.Space, resp.StatusCode)
+		return respBody, resp.StatusCode, fault
+	}
+
 	if resp.StatusCode >= 400 {
-		return respBody, resp.StatusCode, fmt.Errorf("SOAP error %d: %s", resp.StatusCode, string(respBody))
+		return respBody, resp.StatusCode, newStatusError("SOAP", resp, respBody)
 	}
 
-	// Извлечение тела из SOAP ответа
-	bodyContent, err := s.extractBodyFromSOAPResponse(respBody)
-	if err != nil {
+	if wsSecurityEnabled(auth) && auth.WSSecurity.VerifyResponse {
+		if err := verifySecuredResponse(respBody, auth); err != nil {
+			return nil, resp.StatusCode, fmt.Errorf("SOAP response signature verification failed: %w", err)
+		}
+	}
+
+	if parseErr != nil {
 		// Если не удалось извлечь, возвращаем весь ответ
 		return respBody, resp.StatusCode, nil
 	}
 
-	return bodyContent, resp.StatusCode, nil
+	return envelope.Body.Content, resp.StatusCode, nil
+}
+
+// stripXMLDeclaration удаляет XML декларацию в начале данных
+func stripXMLDeclaration(data []byte) []byte {
+	trimmed := bytes.TrimLeft(data, "\ufeff \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		if end := bytes.Index(trimmed, []byte("?>")); end >= 0 {
+			return bytes.TrimLeft(trimmed[end+2:], " \t\r\n")
+		}
+	}
+	return data
 }
 
-// extractBodyFromSOAPResponse извлекает тело из SOAP ответа
-func (s *SOAPAdapter) extractBodyFromSOAPResponse(soapResp []byte) ([]byte, error) {
+// parseSOAPResponse разбирает конверт SOAP 1.1 или SOAP 1.2
+func parseSOAPResponse(soapResp []byte) (*SOAPEnvelope, error) {
 	var envelope SOAPEnvelope
 	if err := xml.Unmarshal(soapResp, &envelope); err != nil {
 		return nil, err
 	}
-	return envelope.Body.Content, nil
+	if envelope.XMLName.Space != soap11Namespace && envelope.XMLName.Space != soap12Namespace {
+		return nil, fmt.Errorf("unknown SOAP envelope namespace %q", envelope.XMLName.Space)
+	}
+	return &envelope, nil
 }
 
 // Authenticate выполняет аутентификацию для SOAP API
-func (s *SOAPAdapter) Authenticate(auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error) {
+func (s *SOAPAdapter) Authenticate(ctx context.Context, auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error) {
 	headers := make(map[string]string)
 
 	switch auth.Type {
@@ -121,8 +183,25 @@ func (s *SOAPAdapter) Authenticate(auth *models.ConnectionAuthentication, endpoi
 			req.SetBasicAuth(auth.Username, auth.Password)
 			headers["Authorization"] = req.Header.Get("Authorization")
 		}
+
+	case models.AuthOAuth2:
+		return oauth2Headers(ctx, s.tokens, auth)
+
+	case models.AuthWSSecurity:
+		// Заголовок WS-Security добавляется в Envelope при отправке (Send)
+
+	case models.AuthCertificate:
+		// Сертификат клиента предъявляется при установке TLS соединения (ClientCache)
 	}
 
 	return headers, nil
 }
 
+// Reauthenticate сбрасывает токен OAuth2 и получает новый (после ответа 401)
+func (s *SOAPAdapter) Reauthenticate(ctx context.Context, auth *models.ConnectionAuthentication, endpoint string) (map[string]string, error) {
+	if auth.Type == models.AuthOAuth2 {
+		s.tokens.Invalidate(auth)
+	}
+	return s.Authenticate(ctx, auth, endpoint)
+}
+
@@ -0,0 +1,103 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SOAPFaultElement элемент Fault ответа SOAP 1.1 или SOAP 1.2
+type SOAPFaultElement struct {
+	// SOAP 1.1
+	FaultCode   string        `xml:"faultcode"`
+	FaultString string        `xml:"faultstring"`
+	FaultActor  string        `xml:"faultactor"`
+	Detail11    *faultContent `xml:"detail"`
+	// SOAP 1.2
+	Code     string        `xml:"Code>Value"`
+	Subcode  string        `xml:"Code>Subcode>Value"`
+	Reason   []string      `xml:"Reason>Text"`
+	Node     string        `xml:"Node"`
+	Role     string        `xml:"Role"`
+	Detail12 *faultContent `xml:"Detail"`
+}
+
+type faultContent struct {
+	Content string `xml:",innerxml"`
+}
+
+// SOAPFault ошибка, возвращаемая адаптером при получении SOAP Fault.
+// Коды хранятся без префикса пространства имен: Client/Server для SOAP 1.1,
+// Sender/Receiver/VersionMismatch/MustUnderstand для SOAP 1.2.
+type SOAPFault struct {
+	Version    string
+	Code       string
+	Subcode    string
+	Reason     string
+	Actor      string
+	Node       string
+	Detail     string
+	StatusCode int
+}
+
+// Error реализует error
+func (f *SOAPFault) Error() string {
+	code := f.Code
+	if f.Subcode != "" {
+		code += "/" + f.Subcode
+	}
+	return fmt.Sprintf("SOAP %s fault %s: %s", f.Version, code, f.Reason)
+}
+
+// IsSenderFault проверяет, что ошибка в запросе (Client в SOAP 1.1, Sender в SOAP 1.2):
+// повторная отправка того же сообщения не поможет
+func (f *SOAPFault) IsSenderFault() bool {
+	return faultCodeIs(f.Code, "Client") || faultCodeIs(f.Code, "Sender")
+}
+
+// Retryable проверяет, что ошибка на стороне сервиса (Server/Receiver)
+// и сообщение можно отправить повторно
+func (f *SOAPFault) Retryable() bool {
+	return faultCodeIs(f.Code, "Server") || faultCodeIs(f.Code, "Receiver")
+}
+
+// faultCodeIs сравнивает код с учетом уточнений SOAP 1.1 вида Server.Timeout
+func faultCodeIs(code, base string) bool {
+	return code == base || strings.HasPrefix(code, base+".")
+}
+
+// toError преобразует элемент Fault в *SOAPFault
+func (e *SOAPFaultElement) toError(envNamespace string, statusCode int) *SOAPFault {
+	fault := &SOAPFault{StatusCode: statusCode}
+	if envNamespace == soap12Namespace {
+		fault.Version = "1.2"
+		fault.Code = localName(e.Code)
+		fault.Subcode = localName(e.Subcode)
+		if len(e.Reason) > 0 {
+			fault.Reason = strings.TrimSpace(e.Reason[0])
+		}
+		fault.Actor = strings.TrimSpace(e.Role)
+		fault.Node = strings.TrimSpace(e.Node)
+		if e.Detail12 != nil {
+			fault.Detail = strings.TrimSpace(e.Detail12.Content)
+		}
+		return fault
+	}
+
+	fault.Version = "1.1"
+	fault.Code = localName(e.FaultCode)
+	fault.Reason = strings.TrimSpace(e.FaultString)
+	fault.Actor = strings.TrimSpace(e.FaultActor)
+	if e.Detail11 != nil {
+		fault.Detail = strings.TrimSpace(e.Detail11.Content)
+	}
+	return fault
+}
+
+// localName отбрасывает префикс QName (soap:Server → Server)
+func localName(qname string) string {
+	qname = strings.TrimSpace(qname)
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
@@ -0,0 +1,172 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const soap11Fault = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <soap:Fault>
+      <faultcode>soap:Server.Timeout</faultcode>
+      <faultstring> Backend timeout </faultstring>
+      <faultactor>http://sap.example/orders</faultactor>
+      <detail><err:code xmlns:err="urn:err">E42</err:code></detail>
+    </soap:Fault>
+  </soap:Body>
+</soap:Envelope>`
+
+const soap12Fault = `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body>
+    <env:Fault>
+      <env:Code>
+        <env:Value>env:Sender</env:Value>
+        <env:Subcode><env:Value>m:InvalidOrder</env:Value></env:Subcode>
+      </env:Code>
+      <env:Reason>
+        <env:Text xml:lang="en">Order number is missing</env:Text>
+        <env:Text xml:lang="ru">Нет номера заказа</env:Text>
+      </env:Reason>
+      <env:Node>http://sap.example/node</env:Node>
+      <env:Role>http://sap.example/role</env:Role>
+      <env:Detail><m:field xmlns:m="urn:m">order_id</m:field></env:Detail>
+    </env:Fault>
+  </env:Body>
+</env:Envelope>`
+
+func TestSOAPFaultParsing(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		status   int
+		response string
+		want     SOAPFault
+		sender   bool
+		retry    bool
+	}{
+		{
+			name:     "SOAP 1.1 server fault",
+			version:  models.SOAPVersion11,
+			status:   http.StatusInternalServerError,
+			response: soap11Fault,
+			want: SOAPFault{
+				Version: "1.1", Code: "Server.Timeout", Reason: "Backend timeout",
+				Actor:  "http://sap.example/orders",
+				Detail: `<err:code xmlns:err="urn:err">E42</err:code>`, StatusCode: http.StatusInternalServerError,
+			},
+			retry: true,
+		},
+		{
+			name:     "SOAP 1.2 sender fault with status 200",
+			version:  models.SOAPVersion12,
+			status:   http.StatusOK,
+			response: soap12Fault,
+			want: SOAPFault{
+				Version: "1.2", Code: "Sender", Subcode: "InvalidOrder", Reason: "Order number is missing",
+				Actor: "http://sap.example/role", Node: "http://sap.example/node",
+				Detail: `<m:field xmlns:m="urn:m">order_id</m:field>`, StatusCode: http.StatusOK,
+			},
+			sender: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			_, status, err := sendSOAP(t, server.URL, tt.version, "urn:CreateOrder", "<order/>")
+			var fault *SOAPFault
+			if !errors.As(err, &fault) {
+				t.Fatalf("Send error = %v, want *SOAPFault", err)
+			}
+			if *fault != tt.want {
+				t.Fatalf("fault = %+v\nwant    %+v", *fault, tt.want)
+			}
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if fault.IsSenderFault() != tt.sender || fault.Retryable() != tt.retry {
+				t.Fatalf("IsSenderFault = %v, Retryable = %v; want %v, %v", fault.IsSenderFault(), fault.Retryable(), tt.sender, tt.retry)
+			}
+		})
+	}
+}
+
+func TestSOAPVersionRequest(t *testing.T) {
+	tests := []struct {
+		version         string
+		wantNamespace   string
+		wantContentType string
+		wantSOAPAction  string
+	}{
+		{models.SOAPVersion11, soap11Namespace, "text/xml; charset=utf-8", "urn:CreateOrder"},
+		{models.SOAPVersion12, soap12Namespace, `application/soap+xml; charset=utf-8; action="urn:CreateOrder"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if got := r.Header.Get("Content-Type"); got != tt.wantContentType {
+					t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+				}
+				if got := r.Header.Get("SOAPAction"); got != tt.wantSOAPAction {
+					t.Errorf("SOAPAction = %q, want %q", got, tt.wantSOAPAction)
+				}
+				if !strings.Contains(string(body), `xmlns:soap="`+tt.wantNamespace+`"`) {
+					t.Errorf("request envelope %s has no namespace %s", body, tt.wantNamespace)
+				}
+				io.WriteString(w, `<e:Envelope xmlns:e="`+tt.wantNamespace+`"><e:Body><ok/></e:Body></e:Envelope>`)
+			}))
+			defer server.Close()
+
+			body, _, err := sendSOAP(t, server.URL, tt.version, "urn:CreateOrder", `<?xml version="1.0"?><order/>`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != "<ok/>" {
+				t.Fatalf("body = %q, want <ok/>", body)
+			}
+		})
+	}
+}
+
+func TestSOAPHTTPErrorWithoutFault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, status, err := sendSOAP(t, server.URL, models.SOAPVersion11, "", "<order/>")
+	var fault *SOAPFault
+	if errors.As(err, &fault) {
+		t.Fatalf("plain HTTP error returned as SOAP fault: %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || status != http.StatusServiceUnavailable {
+		t.Fatalf("Send = %d, %v; want StatusError 503", status, err)
+	}
+	if RetryAfter(err).Seconds() != 7 {
+		t.Fatalf("RetryAfter = %v, want 7s", RetryAfter(err))
+	}
+}
+
+func sendSOAP(t *testing.T, endpoint, version, action, body string) ([]byte, int, error) {
+	t.Helper()
+	settings := &models.ConnectionSetting{Ref: uuid.New(), Name: "sap", Path: endpoint, SOAPVersion: version}
+	ctx := WithConnection(context.Background(), Connection{Settings: settings})
+	return NewSOAPAdapter(NewTokenManager(NewClientCache()), NewClientCache()).Send(ctx, endpoint, action, nil, []byte(body))
+}
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError ответ целевой системы с кодом 4xx/5xx
+type StatusError struct {
+	// Kind вид ответа в тексте ошибки: HTTP или SOAP
+	Kind       string
+	StatusCode int
+	Body       []byte
+	// RetryAfter время из заголовка Retry-After (0 — заголовка нет)
+	RetryAfter time.Duration
+}
+
+// Error реализует error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.Kind, e.StatusCode, string(e.Body))
+}
+
+func newStatusError(kind string, resp *http.Response, body []byte) *StatusError {
+	return &StatusError{
+		Kind:       kind,
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter разбирает Retry-After: число секунд или дата HTTP
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// RetryAfter возвращает время Retry-After из ошибки отправки (0 — не задано)
+func RetryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"go-esb/internal/models"
+)
+
+// tlsVersions минимальные версии TLS
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ClientTLSConfig создает параметры TLS подключения. Для аутентификации
+// Certificate добавляется сертификат клиента, а ее параметры TLS (УЦ,
+// имя сервера, минимальная версия) переопределяют параметры подключения.
+func ClientTLSConfig(settings models.TLSSettings, auth *models.ConnectionAuthentication) (*tls.Config, error) {
+	if isCertificateAuth(auth) {
+		settings = mergeTLSSettings(settings, auth.TLS)
+	}
+
+	minVersion, ok := tlsVersions[settings.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tls min_version %q", settings.MinVersion)
+	}
+	config := &tls.Config{
+		MinVersion:         minVersion,
+		ServerName:         settings.ServerName,
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+	if settings.CACertificate != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(settings.CACertificate)) {
+			return nil, fmt.Errorf("no certificates found in tls ca_certificate")
+		}
+		config.RootCAs = pool
+	}
+
+	if isCertificateAuth(auth) {
+		if auth.Certificate == "" || auth.PrivateKey == "" {
+			return nil, fmt.Errorf("certificate authentication %s requires certificate and private_key", auth.Name)
+		}
+		// Цепочка промежуточных сертификатов передается вместе с сертификатом клиента
+		cert, err := tls.X509KeyPair([]byte(auth.Certificate), []byte(auth.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate of %s: %w", auth.Name, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// mergeTLSSettings заменяет параметры подключения заданными параметрами аутентификации
+func mergeTLSSettings(base, override models.TLSSettings) models.TLSSettings {
+	if override.CACertificate != "" {
+		base.CACertificate = override.CACertificate
+	}
+	if override.ServerName != "" {
+		base.ServerName = override.ServerName
+	}
+	if override.MinVersion != "" {
+		base.MinVersion = override.MinVersion
+	}
+	if override.InsecureSkipVerify {
+		base.InsecureSkipVerify = true
+	}
+	return base
+}
+
+func isCertificateAuth(auth *models.ConnectionAuthentication) bool {
+	return auth != nil && auth.Type == models.AuthCertificate
+}
@@ -0,0 +1,220 @@
+package adapter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// testIssuer тестовый УЦ, выпускающий сертификаты сервера и клиента
+type testIssuer struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM string
+	serial  int64
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{}
+	issuer.cert, issuer.key, issuer.certPEM, _ = issuer.issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "esb-test-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return issuer
+}
+
+// issue подписывает сертификат по шаблону (самоподписанный, если УЦ еще не создан)
+func (i *testIssuer) issue(t *testing.T, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.serial++
+	template.SerialNumber = big.NewInt(i.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, parentKey := template, key
+	if i.cert != nil {
+		parent, parentKey = i.cert, i.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key,
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+// clientAuth аутентификация Certificate с сертификатом клиента, выпущенным УЦ
+func (i *testIssuer) clientAuth(t *testing.T, name string) *models.ConnectionAuthentication {
+	t.Helper()
+	_, _, certPEM, keyPEM := i.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return &models.ConnectionAuthentication{Name: name, Type: models.AuthCertificate, Certificate: certPEM, PrivateKey: keyPEM}
+}
+
+// newMutualTLSServer запускает сервер с сертификатом УЦ, требующий сертификат
+// клиента того же УЦ; сервер отвечает CN сертификата клиента
+func newMutualTLSServer(t *testing.T, issuer *testIssuer) *httptest.Server {
+	t.Helper()
+	_, _, certPEM, keyPEM := issuer.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "esb-test-server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	serverCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(issuer.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	// Ошибки рукопожатия ожидаемы и не нужны в выводе теста
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPClientMutualTLS(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := newMutualTLSServer(t, issuer)
+	other := newTestIssuer(t)
+
+	tests := []struct {
+		name     string
+		settings models.TLSSettings
+		auth     *models.ConnectionAuthentication
+		wantCN   string
+		wantErr  string
+	}{
+		{
+			name:     "client certificate with CA bundle of connection",
+			settings: models.TLSSettings{CACertificate: issuer.certPEM},
+			auth:     issuer.clientAuth(t, "esb-client"),
+			wantCN:   "esb-client",
+		},
+		{
+			name:     "CA bundle of authentication overrides connection",
+			settings: models.TLSSettings{CACertificate: other.certPEM},
+			auth: func() *models.ConnectionAuthentication {
+				auth := issuer.clientAuth(t, "esb-override")
+				auth.TLS = models.TLSSettings{CACertificate: issuer.certPEM}
+				return auth
+			}(),
+			wantCN: "esb-override",
+		},
+		{
+			name:     "server not trusted without CA bundle",
+			settings: models.TLSSettings{},
+			auth:     issuer.clientAuth(t, "esb-client"),
+			wantErr:  "certificate",
+		},
+		{
+			name:     "server rejects request without client certificate",
+			settings: models.TLSSettings{CACertificate: issuer.certPEM},
+			wantErr:  "certificate",
+		},
+		{
+			name:     "server rejects client certificate of other CA",
+			settings: models.TLSSettings{CACertificate: issuer.certPEM},
+			auth:     other.clientAuth(t, "esb-stranger"),
+			wantErr:  "certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(models.HTTPClientSettings{TLS: tt.settings, TimeoutMs: 5000}, tt.auth)
+			if err != nil {
+				t.Fatalf("NewHTTPClient: %v", err)
+			}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get(server.URL)
+			if tt.wantErr != "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("request succeeded, want handshake error")
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.wantCN {
+				t.Fatalf("server saw client %q, want %q", body, tt.wantCN)
+			}
+		})
+	}
+}
+
+func TestClientTLSConfigErrors(t *testing.T) {
+	issuer := newTestIssuer(t)
+	valid := issuer.clientAuth(t, "esb-client")
+
+	tests := []struct {
+		name     string
+		settings models.TLSSettings
+		auth     *models.ConnectionAuthentication
+		wantErr  string
+	}{
+		{"CA bundle without certificates", models.TLSSettings{CACertificate: "not a certificate"}, nil, "no certificates found"},
+		{"unsupported min version", models.TLSSettings{MinVersion: "1.1"}, nil, "unsupported tls min_version"},
+		{"certificate auth without key", models.TLSSettings{},
+			&models.ConnectionAuthentication{Name: "sap", Type: models.AuthCertificate, Certificate: valid.Certificate}, "requires certificate and private_key"},
+		{"key of other certificate", models.TLSSettings{},
+			&models.ConnectionAuthentication{Name: "sap", Type: models.AuthCertificate, Certificate: valid.Certificate, PrivateKey: issuer.clientAuth(t, "other").PrivateKey},
+			"failed to load client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ClientTLSConfig(tt.settings, tt.auth)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
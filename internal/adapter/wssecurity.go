@@ -0,0 +1,496 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// Пространства имен и идентификаторы алгоритмов WS-Security и XML-DSig
+const (
+	wsseNamespace = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	wsuNamespace  = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	dsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+
+	wssPasswordText   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordText"
+	wssPasswordDigest = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest"
+	wssBase64Binary   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary"
+	wssX509v3         = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-x509-token-profile-1.0#X509v3"
+
+	dsigEnvelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	dsigSHA1               = "http://www.w3.org/2000/09/xmldsig#sha1"
+	dsigSHA256             = "http://www.w3.org/2001/04/xmlenc#sha256"
+	dsigSHA512             = "http://www.w3.org/2001/04/xmlenc#sha512"
+	dsigRSASHA1            = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	dsigRSASHA256          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	dsigRSASHA512          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	dsigECDSASHA256        = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+
+	wsuTimeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// wsSecurityEnabled проверяет, что для аутентификации нужен заголовок WS-Security
+func wsSecurityEnabled(auth *models.ConnectionAuthentication) bool {
+	return auth != nil && auth.Type == models.AuthWSSecurity
+}
+
+// buildSecuredEnvelope формирует SOAP Envelope с заголовком wsse:Security:
+// UsernameToken, Timestamp и, если настроено, подписью Body и Timestamp
+func buildSecuredEnvelope(envNamespace string, body []byte, auth *models.ConnectionAuthentication) ([]byte, error) {
+	settings := auth.WSSecurity
+	now := time.Now().UTC()
+	bodyID := "id-" + randomHex(8)
+	// SOAP 1.2 использует для mustUnderstand xs:boolean
+	mustUnderstand := "1"
+	if envNamespace == soap12Namespace {
+		mustUnderstand = "true"
+	}
+
+	var security bytes.Buffer
+	tsID := ""
+	if settings.TimestampTTL > 0 {
+		tsID = "ts-" + randomHex(8)
+		fmt.Fprintf(&security, `<wsu:Timestamp wsu:Id="%s"><wsu:Created>%s</wsu:Created><wsu:Expires>%s</wsu:Expires></wsu:Timestamp>`,
+			tsID, now.Format(wsuTimeFormat), now.Add(time.Duration(settings.TimestampTTL)*time.Second).Format(wsuTimeFormat))
+	}
+	if auth.Username != "" {
+		security.WriteString(usernameToken(auth.Username, auth.Password, settings.PasswordType, now))
+	}
+
+	var signer crypto.Signer
+	var certDER []byte
+	certID := ""
+	if settings.SignBody {
+		var err error
+		signer, certDER, err = loadKeyPair(auth.Certificate, auth.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		certID = "x509-" + randomHex(8)
+		fmt.Fprintf(&security, `<wsse:BinarySecurityToken EncodingType="%s" ValueType="%s" wsu:Id="%s">%s</wsse:BinarySecurityToken>`,
+			wssBase64Binary, wssX509v3, certID, base64.StdEncoding.EncodeToString(certDER))
+	}
+
+	compose := func(signature string) []byte {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, `<soap:Envelope xmlns:soap="%s"><soap:Header>`, envNamespace)
+		fmt.Fprintf(&buf, `<wsse:Security xmlns:wsse="%s" xmlns:wsu="%s" soap:mustUnderstand="%s">`,
+			wsseNamespace, wsuNamespace, mustUnderstand)
+		buf.Write(security.Bytes())
+		buf.WriteString(signature)
+		fmt.Fprintf(&buf, `</wsse:Security></soap:Header><soap:Body xmlns:wsu="%s" wsu:Id="%s">`, wsuNamespace, bodyID)
+		buf.Write(body)
+		buf.WriteString(`</soap:Body></soap:Envelope>`)
+		return buf.Bytes()
+	}
+
+	if signer == nil {
+		return compose(""), nil
+	}
+
+	// Дайджесты подписываемых элементов не зависят от подписи
+	unsigned, err := parseXMLTree(compose(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SOAP envelope: %w", err)
+	}
+	ids := []string{bodyID}
+	if tsID != "" {
+		ids = append(ids, tsID)
+	}
+	var references strings.Builder
+	for _, id := range ids {
+		node := findByID(unsigned, id)
+		if node == nil {
+			return nil, fmt.Errorf("element %s not found", id)
+		}
+		digest := sha256.Sum256(canonicalize(node, nil, nil))
+		fmt.Fprintf(&references, `<ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"></ds:Transform></ds:Transforms>`+
+			`<ds:DigestMethod Algorithm="%s"></ds:DigestMethod><ds:DigestValue>%s</ds:DigestValue></ds:Reference>`,
+			id, excC14NAlgorithm, dsigSHA256, base64.StdEncoding.EncodeToString(digest[:]))
+	}
+
+	signatureMethod := dsigRSASHA256
+	if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		signatureMethod = dsigECDSASHA256
+	}
+	const placeholder = "__ESB_SIGNATURE_VALUE__"
+	signature := fmt.Sprintf(`<ds:Signature xmlns:ds="%s"><ds:SignedInfo>`+
+		`<ds:CanonicalizationMethod Algorithm="%s"></ds:CanonicalizationMethod>`+
+		`<ds:SignatureMethod Algorithm="%s"></ds:SignatureMethod>%s</ds:SignedInfo>`+
+		`<ds:SignatureValue>%s</ds:SignatureValue>`+
+		`<ds:KeyInfo><wsse:SecurityTokenReference><wsse:Reference URI="#%s" ValueType="%s"></wsse:Reference>`+
+		`</wsse:SecurityTokenReference></ds:KeyInfo></ds:Signature>`,
+		dsigNamespace, excC14NAlgorithm, signatureMethod, references.String(), placeholder, certID, wssX509v3)
+
+	document := compose(signature)
+	tree, err := parseXMLTree(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SOAP envelope: %w", err)
+	}
+	var signedInfo *xmlNode
+	tree.walk(func(n *xmlNode) {
+		if signedInfo == nil && n.is(dsigNamespace, "SignedInfo") {
+			signedInfo = n
+		}
+	})
+
+	signatureValue, err := signXML(signer, canonicalize(signedInfo, nil, nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign SOAP body: %w", err)
+	}
+	return bytes.Replace(document, []byte(placeholder), []byte(base64.StdEncoding.EncodeToString(signatureValue)), 1), nil
+}
+
+// usernameToken формирует wsse:UsernameToken. Для PasswordDigest пароль передается
+// как Base64(SHA-1(nonce + created + password))
+func usernameToken(username, password, passwordType string, now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<wsse:UsernameToken><wsse:Username>%s</wsse:Username>`, escapeC14NText(username))
+
+	created := now.Format(wsuTimeFormat)
+	if passwordType == models.WSPasswordDigest {
+		nonce := make([]byte, 16)
+		rand.Read(nonce)
+		digest := sha1.Sum(append(append(append([]byte(nil), nonce...), created...), password...))
+		fmt.Fprintf(&sb, `<wsse:Password Type="%s">%s</wsse:Password>`, wssPasswordDigest, base64.StdEncoding.EncodeToString(digest[:]))
+		fmt.Fprintf(&sb, `<wsse:Nonce EncodingType="%s">%s</wsse:Nonce>`, wssBase64Binary, base64.StdEncoding.EncodeToString(nonce))
+		fmt.Fprintf(&sb, `<wsu:Created>%s</wsu:Created>`, created)
+	} else if password != "" {
+		fmt.Fprintf(&sb, `<wsse:Password Type="%s">%s</wsse:Password>`, wssPasswordText, escapeC14NText(password))
+	}
+
+	sb.WriteString(`</wsse:UsernameToken>`)
+	return sb.String()
+}
+
+// verifySecuredResponse проверяет подпись XML-DSig ответа сертификатом сервера
+// из настроек WS-Security. Подписанным должен быть Body конверта; истекший
+// Timestamp и алгоритмы SHA-1 отклоняются.
+func verifySecuredResponse(response []byte, auth *models.ConnectionAuthentication) error {
+	settings := auth.WSSecurity
+	if settings.ServerCertificate == "" {
+		return fmt.Errorf("server_certificate is required to verify response signatures")
+	}
+	cert, err := parseCertificate(settings.ServerCertificate)
+	if err != nil {
+		return fmt.Errorf("invalid server certificate: %w", err)
+	}
+
+	tree, err := parseXMLTree(response)
+	if err != nil {
+		return fmt.Errorf("failed to parse SOAP response: %w", err)
+	}
+
+	header, body, err := soapEnvelopeParts(tree)
+	if err != nil {
+		return err
+	}
+	var security *xmlNode
+	if header != nil {
+		security = header.child(wsseNamespace, "Security")
+	}
+	if security == nil {
+		return fmt.Errorf("SOAP response is not signed: no wsse:Security header")
+	}
+	signature := security.child(dsigNamespace, "Signature")
+	if signature == nil {
+		return fmt.Errorf("SOAP response is not signed: no ds:Signature")
+	}
+
+	if ts := security.child(wsuNamespace, "Timestamp"); ts != nil {
+		if expires := ts.child(wsuNamespace, "Expires"); expires != nil {
+			t, err := time.Parse(time.RFC3339, strings.TrimSpace(expires.text()))
+			if err != nil {
+				return fmt.Errorf("invalid wsu:Expires in SOAP response timestamp: %w", err)
+			}
+			if time.Now().After(t) {
+				return fmt.Errorf("SOAP response timestamp expired at %s", t.Format(time.RFC3339))
+			}
+		}
+	}
+
+	signedInfo := signature.child(dsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return fmt.Errorf("ds:SignedInfo is missing")
+	}
+	c14nMethod := signedInfo.child(dsigNamespace, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.attr("Algorithm") != excC14NAlgorithm {
+		return fmt.Errorf("unsupported canonicalization method")
+	}
+
+	bodySigned := false
+	for _, reference := range signedInfo.childrenNamed(dsigNamespace, "Reference") {
+		target, err := verifyReference(tree, signature, reference)
+		if err != nil {
+			return err
+		}
+		if target == body {
+			bodySigned = true
+		}
+	}
+	if !bodySigned {
+		return fmt.Errorf("SOAP response Body is not covered by the signature")
+	}
+
+	signatureMethod := signedInfo.child(dsigNamespace, "SignatureMethod")
+	signatureValue := signature.child(dsigNamespace, "SignatureValue")
+	if signatureMethod == nil || signatureValue == nil {
+		return fmt.Errorf("ds:SignatureMethod or ds:SignatureValue is missing")
+	}
+	value, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(signatureValue.text()), ""))
+	if err != nil {
+		return fmt.Errorf("malformed signature value: %w", err)
+	}
+	prefixes := inclusivePrefixes(c14nMethod)
+	return verifyXMLSignature(cert.PublicKey, signatureMethod.attr("Algorithm"), canonicalize(signedInfo, prefixes, nil), value)
+}
+
+// soapEnvelopeParts возвращает Header и Body конверта SOAP 1.1 или SOAP 1.2.
+// Другие элементы в конверте не допускаются: иначе разбор ответа может взять
+// не тот Body, подпись которого проверена.
+func soapEnvelopeParts(tree *xmlNode) (header, body *xmlNode, err error) {
+	envNamespace := tree.namespace(tree.prefix)
+	if tree.local != "Envelope" || (envNamespace != soap11Namespace && envNamespace != soap12Namespace) {
+		return nil, nil, fmt.Errorf("SOAP response is not a SOAP 1.1 or 1.2 envelope")
+	}
+	for _, c := range tree.children {
+		node, ok := c.(*xmlNode)
+		if !ok {
+			continue
+		}
+		switch {
+		case header == nil && body == nil && node.is(envNamespace, "Header"):
+			header = node
+		case body == nil && node.is(envNamespace, "Body"):
+			body = node
+		default:
+			return nil, nil, fmt.Errorf("unexpected element %s in SOAP envelope", qualifiedName(node.prefix, node.local))
+		}
+	}
+	if body == nil {
+		return nil, nil, fmt.Errorf("SOAP response has no Body")
+	}
+	return header, body, nil
+}
+
+// verifyReference проверяет дайджест элемента, на который ссылается ds:Reference
+func verifyReference(tree, signature, reference *xmlNode) (*xmlNode, error) {
+	uri := reference.attr("URI")
+	if !strings.HasPrefix(uri, "#") {
+		return nil, fmt.Errorf("unsupported reference URI %q", uri)
+	}
+	id := strings.TrimPrefix(uri, "#")
+
+	// Повторяющиеся Id — признак атаки подменой (signature wrapping)
+	var target *xmlNode
+	count := 0
+	tree.walk(func(n *xmlNode) {
+		if nodeID(n) == id {
+			target = n
+			count++
+		}
+	})
+	if count != 1 {
+		return nil, fmt.Errorf("reference %s matches %d elements", uri, count)
+	}
+
+	var exclude *xmlNode
+	var prefixes []string
+	if transforms := reference.child(dsigNamespace, "Transforms"); transforms != nil {
+		for _, transform := range transforms.childrenNamed(dsigNamespace, "Transform") {
+			switch transform.attr("Algorithm") {
+			case dsigEnvelopedSignature:
+				exclude = signature
+			case excC14NAlgorithm:
+				prefixes = inclusivePrefixes(transform)
+			default:
+				return nil, fmt.Errorf("unsupported transform %s", transform.attr("Algorithm"))
+			}
+		}
+	}
+
+	digestMethod := reference.child(dsigNamespace, "DigestMethod")
+	digestValue := reference.child(dsigNamespace, "DigestValue")
+	if digestMethod == nil || digestValue == nil {
+		return nil, fmt.Errorf("reference %s has no digest", uri)
+	}
+	var h hash.Hash
+	switch digestMethod.attr("Algorithm") {
+	case dsigSHA1:
+		return nil, fmt.Errorf("digest method sha1 of %s is not accepted", uri)
+	case dsigSHA256:
+		h = sha256.New()
+	case dsigSHA512:
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported digest method %s", digestMethod.attr("Algorithm"))
+	}
+	h.Write(canonicalize(target, prefixes, exclude))
+
+	expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(digestValue.text()))
+	if err != nil {
+		return nil, fmt.Errorf("malformed digest of %s: %w", uri, err)
+	}
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return nil, fmt.Errorf("digest mismatch for %s", uri)
+	}
+	return target, nil
+}
+
+// nodeID возвращает wsu:Id, Id или ID элемента
+func nodeID(n *xmlNode) string {
+	for _, attr := range n.attrs {
+		if attr.Name.Space != "xmlns" && (attr.Name.Local == "Id" || attr.Name.Local == "ID") {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// findByID возвращает элемент с заданным Id
+func findByID(tree *xmlNode, id string) *xmlNode {
+	var found *xmlNode
+	tree.walk(func(n *xmlNode) {
+		if found == nil && nodeID(n) == id {
+			found = n
+		}
+	})
+	return found
+}
+
+// inclusivePrefixes читает ec:InclusiveNamespaces PrefixList
+func inclusivePrefixes(n *xmlNode) []string {
+	for _, c := range n.children {
+		if child, ok := c.(*xmlNode); ok && child.local == "InclusiveNamespaces" {
+			return strings.Fields(child.attr("PrefixList"))
+		}
+	}
+	return nil
+}
+
+// signXML подписывает каноническую форму SignedInfo
+func signXML(signer crypto.Signer, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		// XML-DSig использует для ECDSA конкатенацию r||s, а не ASN.1
+		key, ok := signer.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported ECDSA key")
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	}
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// verifyXMLSignature проверяет значение подписи SignedInfo
+func verifyXMLSignature(publicKey interface{}, method string, data, signature []byte) error {
+	var hashFunc crypto.Hash
+	switch method {
+	case dsigRSASHA1:
+		return fmt.Errorf("signature method rsa-sha1 is not accepted")
+	case dsigRSASHA256, dsigECDSASHA256:
+		hashFunc = crypto.SHA256
+	case dsigRSASHA512:
+		hashFunc = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signature method %s", method)
+	}
+	h := hashFunc.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if method == dsigECDSASHA256 {
+			return fmt.Errorf("signature method %s does not match RSA key", method)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hashFunc, digest, signature); err != nil {
+			return fmt.Errorf("invalid response signature: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		if method != dsigECDSASHA256 || len(signature)%2 != 0 {
+			return fmt.Errorf("signature method %s does not match ECDSA key", method)
+		}
+		half := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid response signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
+
+// loadKeyPair разбирает сертификат X.509 и закрытый ключ в PEM
+func loadKeyPair(certPEM, keyPEM string) (crypto.Signer, []byte, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, nil, fmt.Errorf("invalid private key: no PEM data")
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, cert.Raw, nil
+	case *ecdsa.PrivateKey:
+		return k, cert.Raw, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// parseCertificate разбирает первый сертификат X.509 в PEM
+func parseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,314 @@
+package adapter
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// testKeyPair создает самоподписанный сертификат и ключ в PEM
+func testKeyPair(t *testing.T, key crypto.Signer) (certPEM, keyPEM string) {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "esb-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func signingAuth(t *testing.T, key crypto.Signer) *models.ConnectionAuthentication {
+	t.Helper()
+	certPEM, keyPEM := testKeyPair(t, key)
+	return &models.ConnectionAuthentication{
+		Type:        models.AuthWSSecurity,
+		Username:    "esb",
+		Password:    "secret",
+		Certificate: certPEM,
+		PrivateKey:  keyPEM,
+		WSSecurity: models.WSSecuritySettings{
+			TimestampTTL:      300,
+			SignBody:          true,
+			VerifyResponse:    true,
+			ServerCertificate: certPEM,
+		},
+	}
+}
+
+// signedEnvelope подписывает тело ключом ECDSA и возвращает конверт и настройки проверки
+func signedEnvelope(t *testing.T, envNamespace string) (string, *models.ConnectionAuthentication) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := signingAuth(t, key)
+	envelope, err := buildSecuredEnvelope(envNamespace, []byte(`<m:Order xmlns:m="urn:orders"><m:Id>1</m:Id></m:Order>`), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(envelope), auth
+}
+
+var (
+	signedBodyPattern = regexp.MustCompile(`<soap:Body [^>]*>.*</soap:Body>`)
+	expiresPattern    = regexp.MustCompile(`<wsu:Expires>[^<]*</wsu:Expires>`)
+)
+
+func TestWSSecuritySignVerifyRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		envNamespace string
+		key          crypto.Signer
+	}{
+		{"SOAP 1.1 RSA", soap11Namespace, rsaKey},
+		{"SOAP 1.2 RSA", soap12Namespace, rsaKey},
+		{"SOAP 1.1 ECDSA", soap11Namespace, ecKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := signingAuth(t, tt.key)
+			envelope, err := buildSecuredEnvelope(tt.envNamespace, []byte(`<order id="1">Ёлка &amp; шар</order>`), auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verifySecuredResponse(envelope, auth); err != nil {
+				t.Fatalf("verifySecuredResponse: %v\n%s", err, envelope)
+			}
+		})
+	}
+}
+
+func TestWSSecurityVerifyRejectsTampering(t *testing.T) {
+	envelope, auth := signedEnvelope(t, soap11Namespace)
+	original := signedBodyPattern.FindString(envelope)
+	if original == "" {
+		t.Fatalf("signed Body not found in %s", envelope)
+	}
+	bodyID := regexp.MustCompile(`wsu:Id="([^"]+)"`).FindStringSubmatch(original)[1]
+	evilBody := func(id string) string {
+		attrs := ""
+		if id != "" {
+			attrs = ` xmlns:wsu="` + wsuNamespace + `" wsu:Id="` + id + `"`
+		}
+		return `<soap:Body` + attrs + `><m:Order xmlns:m="urn:orders"><m:Id>666</m:Id></m:Order></soap:Body>`
+	}
+	// wrap переносит подписанный Body в заголовок, а на его место ставит body
+	wrap := func(body string) string {
+		wrapped := strings.Replace(envelope, original, body, 1)
+		return strings.Replace(wrapped, "</soap:Header>", `<w:Wrapper xmlns:w="urn:wrapper">`+original+`</w:Wrapper></soap:Header>`, 1)
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		wantErr  string
+	}{
+		{"tampered body", strings.Replace(envelope, "<m:Id>1</m:Id>", "<m:Id>2</m:Id>", 1), "digest mismatch"},
+		{"tampered signature value", regexp.MustCompile(`<ds:SignatureValue>[^<]{4}`).ReplaceAllString(envelope, "<ds:SignatureValue>AAAA"), "invalid response signature"},
+		{"signed body moved to header", wrap(evilBody("")), "not covered by the signature"},
+		{"duplicate Id", wrap(evilBody(bodyID)), "matches 2 elements"},
+		{"foreign namespace Body before signed Body", strings.Replace(envelope, "</soap:Header>",
+			`</soap:Header><x:Body xmlns:x="urn:evil"><m:Order xmlns:m="urn:orders"><m:Id>666</m:Id></m:Order></x:Body>`, 1), "unexpected element x:Body"},
+		{"second SOAP Body", strings.Replace(envelope, "</soap:Envelope>", evilBody("")+"</soap:Envelope>", 1), "unexpected element soap:Body"},
+		{"not a SOAP envelope", strings.ReplaceAll(envelope, soap11Namespace, "urn:not-soap"), "not a SOAP 1.1 or 1.2 envelope"},
+		{"malformed Expires", expiresPattern.ReplaceAllString(envelope, "<wsu:Expires>tomorrow</wsu:Expires>"), "invalid wsu:Expires"},
+		{"expired timestamp", expiresPattern.ReplaceAllString(envelope, "<wsu:Expires>2000-01-01T00:00:00Z</wsu:Expires>"), "timestamp expired"},
+		{"unsigned", `<soap:Envelope xmlns:soap="` + soap11Namespace + `"><soap:Body><ok/></soap:Body></soap:Envelope>`, "not signed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySecuredResponse([]byte(tt.response), auth)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verifySecuredResponse = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWSSecurityVerifyRejectsSHA1(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := signingAuth(t, key)
+	envelope, err := buildSecuredEnvelope(soap11Namespace, []byte(`<ok/>`), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Та же подпись с SHA-1: дайджесты и SignatureValue пересчитаны, подпись верна
+	weak := string(envelope)
+	tree, err := parseXMLTree(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range regexp.MustCompile(`URI="#((?:id|ts)-[^"]+)"`).FindAllStringSubmatch(weak, -1) {
+		digest := sha1.Sum(canonicalize(findByID(tree, id[1]), nil, nil))
+		weak = regexp.MustCompile(`(URI="#`+id[1]+`">.*?<ds:DigestValue>)[^<]+`).
+			ReplaceAllString(weak, "${1}"+base64.StdEncoding.EncodeToString(digest[:]))
+	}
+	weak = strings.ReplaceAll(weak, dsigSHA256, dsigSHA1)
+	weak = strings.Replace(weak, dsigRSASHA256, dsigRSASHA1, 1)
+	weakTree, err := parseXMLTree([]byte(weak))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signedInfo *xmlNode
+	weakTree.walk(func(n *xmlNode) {
+		if n.is(dsigNamespace, "SignedInfo") {
+			signedInfo = n
+		}
+	})
+	digest := sha1.Sum(canonicalize(signedInfo, nil, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak = regexp.MustCompile(`<ds:SignatureValue>[^<]+`).
+		ReplaceAllString(weak, "<ds:SignatureValue>"+base64.StdEncoding.EncodeToString(value))
+
+	err = verifySecuredResponse([]byte(weak), auth)
+	if err == nil || !strings.Contains(err.Error(), "sha1") {
+		t.Fatalf("verifySecuredResponse = %v, want sha1 rejected", err)
+	}
+
+	// rsa-sha1 отклоняется и с дайджестами SHA-256
+	if err := verifyXMLSignature(&key.PublicKey, dsigRSASHA1, []byte("data"), value); err == nil {
+		t.Fatal("rsa-sha1 signature method accepted")
+	}
+}
+
+// Векторы W3C Exclusive XML Canonicalization 1.0 (раздел 2.2) и XML
+// Canonicalization 1.0 (примеры 3.3 и 3.4) для документов без DTD
+func TestExclusiveC14NVectors(t *testing.T) {
+	const excDoc1 = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n0:local>`
+	const excDoc2 = `<n2:pdu xmlns:n1="http://example.com" xmlns:n2="http://foo.example" xml:lang="fr" xml:space="retain">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n2:pdu>`
+	const excElem2 = `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`
+
+	const startEndDoc = `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+	const startEndC14N = `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6>
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+
+	const charsDoc = `<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+   <normNames attr='   A   &#x20;&#13;&#xa;&#9;   B   '/>
+</doc>`
+	const charsC14N = "<doc>\n" +
+		"   <text>First line&#xD;\nSecond line</text>\n" +
+		"   <value>2</value>\n" +
+		`   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>` + "\n" +
+		`   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>` + "\n" +
+		`   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>` + "\n" +
+		`   <normNames attr="   A    &#xD;&#xA;&#x9;   B   "></normNames>` + "\n" +
+		"</doc>"
+
+	tests := []struct {
+		name      string
+		doc       string
+		element   string
+		inclusive []string
+		want      string
+	}{
+		{"exc-c14n 2.2 first document", excDoc1, "elem2", nil, excElem2},
+		{"exc-c14n 2.2 second document", excDoc2, "elem2", nil, excElem2},
+		{"exc-c14n InclusiveNamespaces", excDoc1, "elem2", []string{"n0"},
+			strings.Replace(excElem2, `xmlns:n1`, `xmlns:n0="foo:bar" xmlns:n1`, 1)},
+		{"c14n 3.3 start and end tags", startEndDoc, "", nil, startEndC14N},
+		{"c14n 3.4 character modifications", charsDoc, "", nil, charsC14N},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := parseXMLTree([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			node := tree
+			if tt.element != "" {
+				node = nil
+				tree.walk(func(n *xmlNode) {
+					if node == nil && n.local == tt.element {
+						node = n
+					}
+				})
+			}
+			if got := string(canonicalize(node, tt.inclusive, nil)); got != tt.want {
+				t.Fatalf("canonicalize =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyPrefix префикс API ключей шины, по нему ключ отличается от JWT
+const APIKeyPrefix = "esb_"
+
+// apiKeyPrefixLength длина видимой части ключа, сохраняемой для его опознания
+const apiKeyPrefixLength = 12
+
+// GenerateAPIKey создает новый API ключ. Возвращает ключ (показывается один раз)
+// и его видимый префикс.
+func GenerateAPIKey() (key, prefix string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return key, key[:apiKeyPrefixLength], nil
+}
+
+// HashAPIKey возвращает SHA-256 ключа в hex, под которым ключ хранится в БД
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey проверяет, что значение имеет формат API ключа шины
+func IsAPIKey(value string) bool {
+	return strings.HasPrefix(value, APIKeyPrefix)
+}
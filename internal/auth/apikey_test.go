@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey: %v", err)
+	}
+	if !IsAPIKey(key) {
+		t.Fatalf("key %q has no %s prefix", key, APIKeyPrefix)
+	}
+	if len(prefix) != apiKeyPrefixLength || !strings.HasPrefix(key, prefix) {
+		t.Fatalf("prefix %q is not the first %d characters of the key", prefix, apiKeyPrefixLength)
+	}
+	other, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == other {
+		t.Fatal("two generated keys are equal")
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	key, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := HashAPIKey(key)
+
+	tests := []struct {
+		name      string
+		candidate string
+		wantMatch bool
+	}{
+		{"same key", key, true},
+		{"last character changed", key[:len(key)-1] + string(key[len(key)-1]^1), false},
+		{"trailing space", key + " ", false},
+		{"prefix only", key[:apiKeyPrefixLength], false},
+		{"upper case", strings.ToUpper(key), false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if match := HashAPIKey(tt.candidate) == hash; match != tt.wantMatch {
+				t.Fatalf("hash match = %v, want %v", match, tt.wantMatch)
+			}
+		})
+	}
+
+	if len(hash) != 64 || strings.Contains(hash, key) {
+		t.Fatalf("hash %q is not a hex SHA-256 of the key", hash)
+	}
+	// Известное значение SHA-256, хэш не должен зависеть от соли или времени
+	if got := HashAPIKey("esb_test"); got != "b5fb5bf4839025799a69bbaf751fcf356dc1636fd14aefcfe26b08af0305c66c" {
+		t.Fatalf("HashAPIKey(esb_test) = %s", got)
+	}
+}
+
+func TestIsAPIKey(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"esb_abc", true},
+		{"eyJhbGciOiJIUzI1NiJ9.e30.sig", false},
+		{"ESB_abc", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsAPIKey(tt.value); got != tt.want {
+			t.Errorf("IsAPIKey(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
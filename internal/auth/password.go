@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength минимальная длина пароля пользователя
+const minPasswordLength = 8
+
+// ValidatePassword проверяет требования к новому паролю
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// HashPassword возвращает хэш bcrypt пароля
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return "", errors.New("password must be at most 72 bytes")
+		}
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сравнивает пароль с хэшем bcrypt
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// IsPasswordHash проверяет, что значение является хэшем bcrypt,
+// а не паролем в открытом виде, сохраненным до включения хэширования
+func IsPasswordHash(value string) bool {
+	if len(value) != 60 {
+		return false
+	}
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Permission действие в HTTP API шины
+type Permission string
+
+const (
+	// PermRead просмотр конфигурации и состояния
+	PermRead Permission = "read"
+	// PermSend отправка сообщений через threads и запуск процессов
+	PermSend Permission = "send"
+	// PermConfigure импорт маршрутов и изменение схем сообщений
+	PermConfigure Permission = "configure"
+	// PermManageUsers управление пользователями и API ключами
+	PermManageUsers Permission = "manage_users"
+	// PermAdminister настройка работающего сервера (уровни логирования)
+	PermAdminister Permission = "administer"
+)
+
+// rolePermissions разрешения ролей
+var rolePermissions = map[models.UserRole][]Permission{
+	models.RoleAdmin:      {PermRead, PermSend, PermConfigure, PermManageUsers, PermAdminister},
+	models.RoleIntegrator: {PermRead, PermSend, PermConfigure},
+	models.RoleOperator:   {PermRead, PermSend},
+	models.RoleReadOnly:   {PermRead},
+}
+
+// ValidRole проверяет, что роль существует
+func ValidRole(role models.UserRole) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows проверяет, что роль дает разрешение
+func RoleAllows(role models.UserRole, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal аутентифицированный пользователь запроса
+type Principal struct {
+	UserID   uuid.UUID       `json:"user"`
+	Username string          `json:"username"`
+	Role     models.UserRole `json:"role"`
+	// GroupRoles роли пользователя в отдельных группах threads
+	GroupRoles map[uuid.UUID]models.UserRole `json:"group_roles,omitempty"`
+	// APIKey ID ключа, если запрос аутентифицирован API ключом
+	APIKey *uuid.UUID `json:"api_key,omitempty"`
+}
+
+// Can проверяет разрешение по роли пользователя
+func (p *Principal) Can(perm Permission) bool {
+	return RoleAllows(p.Role, perm)
+}
+
+// CanInGroup проверяет разрешение для threads группы: роль пользователя
+// дополняется ролью, назначенной ему в этой группе
+func (p *Principal) CanInGroup(groupID uuid.UUID, perm Permission) bool {
+	if p.Can(perm) {
+		return true
+	}
+	role, ok := p.GroupRoles[groupID]
+	return ok && RoleAllows(role, perm)
+}
+
+// CanInAnyGroup проверяет, что разрешение дает роль пользователя или его роль
+// хотя бы в одной группе threads
+func (p *Principal) CanInAnyGroup(perm Permission) bool {
+	if p.Can(perm) {
+		return true
+	}
+	for _, role := range p.GroupRoles {
+		if RoleAllows(role, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionError пользователь не имеет разрешения для thread
+type PermissionError struct {
+	Username   string
+	Permission Permission
+	Thread     uuid.UUID
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("user %s has no %s permission for thread %s", e.Username, e.Permission, e.Thread)
+}
+
+type principalKey struct{}
+
+// WithPrincipal добавляет пользователя запроса в контекст
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext возвращает пользователя запроса или nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
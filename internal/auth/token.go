@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// tokenIssuer значение claim iss токенов шины
+const tokenIssuer = "go-esb"
+
+// jwtHeader заголовок JWT с алгоритмом HS256
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// ErrInvalidToken токен поврежден, подписан другим ключом или истек
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims содержимое токена доступа
+type Claims struct {
+	Subject   string          `json:"sub"`
+	Username  string          `json:"name"`
+	Role      models.UserRole `json:"role"`
+	Issuer    string          `json:"iss"`
+	IssuedAt  int64           `json:"iat"`
+	ExpiresAt int64           `json:"exp"`
+	// Version номер токенов пользователя (models.User.TokenVersion) при выпуске
+	Version int `json:"ver"`
+}
+
+// TokenIssuer выпускает и проверяет токены доступа JWT (HS256)
+type TokenIssuer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewTokenIssuer создает TokenIssuer с ключом подписи и временем жизни токенов
+func NewTokenIssuer(secret []byte, ttl time.Duration) *TokenIssuer {
+	return &TokenIssuer{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Issue выпускает токен доступа пользователя
+func (t *TokenIssuer) Issue(user *models.User) (string, time.Time, error) {
+	now := t.now()
+	expiresAt := now.Add(t.ttl)
+	payload, err := json.Marshal(Claims{
+		Subject:   user.Ref.String(),
+		Username:  user.Username,
+		Role:      user.Role,
+		Version:   user.TokenVersion,
+		Issuer:    tokenIssuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to marshal claims: %w", err)
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + t.sign(signingInput), expiresAt, nil
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает ID пользователя
+// и номер его токенов. Роль, группы и номер токенов пользователя сверяются
+// с БД, чтобы изменения действовали сразу.
+func (t *TokenIssuer) Verify(token string) (uuid.UUID, int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+	expected := t.sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+	if claims.Issuer != tokenIssuer || t.now().Unix() >= claims.ExpiresAt {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+	return userID, claims.Version, nil
+}
+
+func (t *TokenIssuer) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var testUser = &models.User{Ref: uuid.MustParse("6f1c2d3e-4b5a-4c7d-8e9f-0a1b2c3d4e5f"), Username: "operator", Role: models.RoleOperator, TokenVersion: 3}
+
+// signedToken подписывает произвольные claims ключом issuer
+func signedToken(t *testing.T, issuer *TokenIssuer, header string, claims Claims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + issuer.sign(signingInput)
+}
+
+func TestTokenIssueVerify(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("secret"), time.Hour)
+	token, expiresAt, err := issuer.Issue(testUser)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if d := time.Until(expiresAt); d <= 59*time.Minute || d > time.Hour {
+		t.Fatalf("expiresAt in %v, want about 1h", d)
+	}
+	userID, version, err := issuer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if userID != testUser.Ref || version != testUser.TokenVersion {
+		t.Fatalf("Verify = %s, %d; want %s, %d", userID, version, testUser.Ref, testUser.TokenVersion)
+	}
+}
+
+func TestTokenVerifyRejects(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("secret"), time.Hour)
+	token, _, err := issuer.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	otherKey := NewTokenIssuer([]byte("other-secret"), time.Hour)
+	otherToken, _, err := otherKey.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Повышение роли в payload без новой подписи
+	escalated := Claims{Subject: testUser.Ref.String(), Role: models.RoleAdmin, Issuer: tokenIssuer,
+		ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	escalatedPayload, _ := json.Marshal(escalated)
+
+	now := time.Now()
+	valid := Claims{Subject: testUser.Ref.String(), Issuer: tokenIssuer, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"two parts", parts[0] + "." + parts[1]},
+		{"four parts", token + ".x"},
+		{"signed with other key", otherToken},
+		{"payload changed", parts[0] + "." + base64.RawURLEncoding.EncodeToString(escalatedPayload) + "." + parts[2]},
+		{"signature removed", parts[0] + "." + parts[1] + "."},
+		{"alg none", noneHeader + "." + parts[1] + "."},
+		{"alg none signed", signedToken(t, issuer, noneHeader, valid)},
+		{"expired", signedToken(t, issuer, jwtHeader, Claims{Subject: valid.Subject, Issuer: tokenIssuer,
+			IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()})},
+		{"expires now", signedToken(t, issuer, jwtHeader, Claims{Subject: valid.Subject, Issuer: tokenIssuer, ExpiresAt: now.Unix()})},
+		{"other issuer", signedToken(t, issuer, jwtHeader, Claims{Subject: valid.Subject, Issuer: "other", ExpiresAt: valid.ExpiresAt})},
+		{"bad subject", signedToken(t, issuer, jwtHeader, Claims{Subject: "admin", Issuer: tokenIssuer, ExpiresAt: valid.ExpiresAt})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, _, err := issuer.Verify(tt.token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify = %s, %v; want ErrInvalidToken", userID, err)
+			}
+		})
+	}
+}
+
+func TestTokenExpiresAfterTTL(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("secret"), time.Minute)
+	start := time.Now()
+	issuer.now = func() time.Time { return start }
+	token, _, err := issuer.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		wantErr bool
+	}{
+		{"just issued", 0, false},
+		{"before expiry", 59 * time.Second, false},
+		{"at expiry", time.Minute, true},
+		{"after expiry", time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.now = func() time.Time { return start.Add(tt.elapsed) }
+			_, _, err := issuer.Verify(token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package balancer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-esb/internal/metrics"
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Значения по умолчанию активной проверки подключения
+const (
+	DefaultUnhealthyThreshold = 2
+	DefaultHealthyThreshold   = 2
+	DefaultCheckTimeout       = 5 * time.Second
+)
+
+// Status результат активной проверки подключения для health check
+type Status struct {
+	Connection uuid.UUID `json:"connection"`
+	Name       string    `json:"name"`
+	System     string    `json:"system"`
+	Healthy    bool      `json:"healthy"`
+	LastCheck  time.Time `json:"last_check"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// endpoint состояние подключения системы
+type endpoint struct {
+	name      string
+	system    string
+	healthy   bool
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastError string
+	// current текущий вес плавного взвешенного round-robin
+	current int
+}
+
+// Registry выбирает подключение системы, если их несколько. Подключения
+// с меньшим priority используются первыми, среди них выбор зависит от
+// стратегии системы; остальные — резервные на случай ошибки. Подключения,
+// не прошедшие активную проверку, выводятся из ротации и используются,
+// только если все остальные подключения уже не ответили.
+type Registry struct {
+	mu        sync.Mutex
+	endpoints map[uuid.UUID]*endpoint
+	counters  map[string]uint64
+}
+
+// NewRegistry создает реестр подключений
+func NewRegistry() *Registry {
+	return &Registry{
+		endpoints: make(map[uuid.UUID]*endpoint),
+		counters:  make(map[string]uint64),
+	}
+}
+
+// Order возвращает подключения системы в порядке попыток отправки.
+// Подключения должны быть отсортированы по priority.
+func (r *Registry) Order(system *models.System, connections []models.ConnectionSetting) []*models.ConnectionSetting {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var healthy, unhealthy []*models.ConnectionSetting
+	for i := range connections {
+		connection := &connections[i]
+		e := r.endpoint(connection, system.Name)
+		if connection.HealthCheck.IntervalMs > 0 && !e.healthy {
+			unhealthy = append(unhealthy, connection)
+			continue
+		}
+		healthy = append(healthy, connection)
+	}
+
+	ordered := make([]*models.ConnectionSetting, 0, len(connections))
+	for start := 0; start < len(healthy); {
+		end := start + 1
+		for end < len(healthy) && healthy[end].Priority == healthy[start].Priority {
+			end++
+		}
+		group := healthy[start:end]
+		switch system.LoadBalancing {
+		case models.BalancingRoundRobin:
+			ordered = append(ordered, r.roundRobin(system, group)...)
+		case models.BalancingWeighted:
+			ordered = append(ordered, r.weighted(group)...)
+		default:
+			ordered = append(ordered, group...)
+		}
+		start = end
+	}
+	return append(ordered, unhealthy...)
+}
+
+// roundRobin чередует подключения одного priority
+func (r *Registry) roundRobin(system *models.System, group []*models.ConnectionSetting) []*models.ConnectionSetting {
+	key := fmt.Sprintf("%s/%d", system.Ref, group[0].Priority)
+	offset := int(r.counters[key] % uint64(len(group)))
+	r.counters[key]++
+
+	ordered := make([]*models.ConnectionSetting, 0, len(group))
+	ordered = append(ordered, group[offset:]...)
+	return append(ordered, group[:offset]...)
+}
+
+// weighted выбирает подключение плавным взвешенным round-robin (как nginx):
+// подключения чередуются равномерно, каждое получает долю weight от суммы весов
+func (r *Registry) weighted(group []*models.ConnectionSetting) []*models.ConnectionSetting {
+	total, chosen := 0, 0
+	for i, connection := range group {
+		e := r.endpoints[connection.Ref]
+		e.current += weight(connection)
+		total += weight(connection)
+		if e.current > r.endpoints[group[chosen].Ref].current {
+			chosen = i
+		}
+	}
+	r.endpoints[group[chosen].Ref].current -= total
+
+	ordered := make([]*models.ConnectionSetting, 0, len(group))
+	ordered = append(ordered, group[chosen])
+	for i, connection := range group {
+		if i != chosen {
+			ordered = append(ordered, connection)
+		}
+	}
+	return ordered
+}
+
+func weight(connection *models.ConnectionSetting) int {
+	if connection.Weight <= 0 {
+		return 1
+	}
+	return connection.Weight
+}
+
+// Report учитывает результат активной проверки подключения. Возвращает true,
+// если подключение выведено из ротации или возвращено в нее.
+func (r *Registry) Report(connection *models.ConnectionSetting, system string, err error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.endpoint(connection, system)
+	wasHealthy := e.healthy
+	e.lastCheck = time.Now()
+	if err == nil {
+		e.lastError = ""
+		e.failures = 0
+		e.successes++
+		if e.successes >= threshold(connection.HealthCheck.HealthyThreshold, DefaultHealthyThreshold) {
+			e.healthy = true
+		}
+	} else {
+		e.lastError = err.Error()
+		e.successes = 0
+		e.failures++
+		if e.failures >= threshold(connection.HealthCheck.UnhealthyThreshold, DefaultUnhealthyThreshold) {
+			e.healthy = false
+		}
+	}
+
+	value := 0.0
+	if e.healthy {
+		value = 1
+	}
+	metrics.EndpointHealthy.WithLabelValues(e.system, e.name).Set(value)
+	return e.healthy != wasHealthy
+}
+
+func threshold(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Retain сбрасывает результаты проверок подключений, которые больше не проверяются
+// (проверка выключена или подключение удалено)
+func (r *Registry) Retain(checked map[uuid.UUID]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ref, e := range r.endpoints {
+		if checked[ref] || e.lastCheck.IsZero() {
+			continue
+		}
+		metrics.EndpointHealthy.DeleteLabelValues(e.system, e.name)
+		e.healthy, e.successes, e.failures = true, 0, 0
+		e.lastCheck, e.lastError = time.Time{}, ""
+	}
+}
+
+// Statuses возвращает результаты активных проверок: сначала недоступные подключения
+func (r *Registry) Statuses() []Status {
+	r.mu.Lock()
+	statuses := make([]Status, 0, len(r.endpoints))
+	for ref, e := range r.endpoints {
+		if e.lastCheck.IsZero() {
+			continue
+		}
+		statuses = append(statuses, Status{
+			Connection: ref,
+			Name:       e.name,
+			System:     e.system,
+			Healthy:    e.healthy,
+			LastCheck:  e.lastCheck,
+			LastError:  e.lastError,
+		})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Healthy != statuses[j].Healthy {
+			return !statuses[i].Healthy
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// endpoint возвращает состояние подключения; новое подключение считается доступным
+func (r *Registry) endpoint(connection *models.ConnectionSetting, system string) *endpoint {
+	e, ok := r.endpoints[connection.Ref]
+	if !ok {
+		e = &endpoint{healthy: true}
+		r.endpoints[connection.Ref] = e
+	}
+	e.name, e.system = connection.Name, system
+	return e
+}
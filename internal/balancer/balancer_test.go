@@ -0,0 +1,152 @@
+package balancer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func testSystem(strategy models.LoadBalancing) *models.System {
+	return &models.System{Ref: uuid.New(), Name: "sap", LoadBalancing: strategy}
+}
+
+// testConnection создает подключение name с priority и weight
+func testConnection(name string, priority, weight int) models.ConnectionSetting {
+	return models.ConnectionSetting{Ref: uuid.New(), Name: name, Priority: priority, Weight: weight}
+}
+
+func names(connections []*models.ConnectionSetting) string {
+	result := make([]string, len(connections))
+	for i, connection := range connections {
+		result[i] = connection.Name
+	}
+	return strings.Join(result, ",")
+}
+
+func TestOrderFailover(t *testing.T) {
+	registry := NewRegistry()
+	connections := []models.ConnectionSetting{testConnection("a", 1, 0), testConnection("b", 1, 0), testConnection("c", 2, 0)}
+	for _, strategy := range []models.LoadBalancing{"", models.BalancingFailover} {
+		system := testSystem(strategy)
+		for i := 0; i < 3; i++ {
+			if got := names(registry.Order(system, connections)); got != "a,b,c" {
+				t.Fatalf("strategy %q: order %s, want a,b,c", strategy, got)
+			}
+		}
+	}
+}
+
+func TestOrderRoundRobin(t *testing.T) {
+	registry := NewRegistry()
+	system := testSystem(models.BalancingRoundRobin)
+	connections := []models.ConnectionSetting{
+		testConnection("a", 1, 0), testConnection("b", 1, 0), testConnection("c", 1, 0), testConnection("reserve", 2, 0),
+	}
+	// Подключения одного priority чередуются, резервное остается последним
+	for _, want := range []string{"a,b,c,reserve", "b,c,a,reserve", "c,a,b,reserve", "a,b,c,reserve"} {
+		if got := names(registry.Order(system, connections)); got != want {
+			t.Fatalf("order %s, want %s", got, want)
+		}
+	}
+
+	// Счетчик у каждой системы свой
+	if got := names(registry.Order(testSystem(models.BalancingRoundRobin), connections)); got != "a,b,c,reserve" {
+		t.Fatalf("order for another system %s, want a,b,c,reserve", got)
+	}
+}
+
+func TestOrderWeighted(t *testing.T) {
+	registry := NewRegistry()
+	system := testSystem(models.BalancingWeighted)
+	connections := []models.ConnectionSetting{
+		testConnection("a", 1, 5), testConnection("b", 1, 1), testConnection("c", 1, 0), testConnection("reserve", 2, 10),
+	}
+
+	var first []string
+	counts := make(map[string]int)
+	for i := 0; i < 14; i++ {
+		ordered := registry.Order(system, connections)
+		if len(ordered) != 4 || ordered[3].Name != "reserve" {
+			t.Fatalf("order %s, want reserve last", names(ordered))
+		}
+		first = append(first, ordered[0].Name)
+		counts[ordered[0].Name]++
+	}
+	// Плавный взвешенный round-robin: вес 0 считается как 1, выбор равномерный
+	if got := strings.Join(first[:7], ","); got != "a,a,b,a,c,a,a" {
+		t.Fatalf("first choices %s, want a,a,b,a,c,a,a", got)
+	}
+	if counts["a"] != 10 || counts["b"] != 2 || counts["c"] != 2 {
+		t.Fatalf("choices %v, want a=10 b=2 c=2", counts)
+	}
+}
+
+func TestEndpointHealth(t *testing.T) {
+	registry := NewRegistry()
+	system := testSystem(models.BalancingFailover)
+	checked := testConnection("a", 1, 0)
+	checked.HealthCheck = models.HealthCheckSettings{IntervalMs: 1000, UnhealthyThreshold: 2, HealthyThreshold: 3}
+	connections := []models.ConnectionSetting{checked, testConnection("b", 1, 0), testConnection("c", 2, 0)}
+	failure := errors.New("connection refused")
+
+	if registry.Report(&checked, "sap", failure) {
+		t.Fatal("single failure took the connection out of rotation")
+	}
+	if got := names(registry.Order(system, connections)); got != "a,b,c" {
+		t.Fatalf("order %s, want a,b,c", got)
+	}
+	if !registry.Report(&checked, "sap", failure) {
+		t.Fatal("Report did not report the connection as unhealthy")
+	}
+	// Недоступное подключение используется только после резервных
+	if got := names(registry.Order(system, connections)); got != "b,c,a" {
+		t.Fatalf("order %s, want b,c,a", got)
+	}
+	statuses := registry.Statuses()
+	if len(statuses) != 1 || statuses[0].Healthy || statuses[0].LastError != "connection refused" {
+		t.Fatalf("statuses %+v, want a unhealthy", statuses)
+	}
+
+	// Возврат в ротацию после HealthyThreshold успехов подряд
+	registry.Report(&checked, "sap", nil)
+	registry.Report(&checked, "sap", failure)
+	registry.Report(&checked, "sap", nil)
+	registry.Report(&checked, "sap", nil)
+	if got := names(registry.Order(system, connections)); got != "b,c,a" {
+		t.Fatalf("order %s before healthy threshold, want b,c,a", got)
+	}
+	if !registry.Report(&checked, "sap", nil) {
+		t.Fatal("Report did not report the connection as recovered")
+	}
+	if got := names(registry.Order(system, connections)); got != "a,b,c" {
+		t.Fatalf("order %s after recovery, want a,b,c", got)
+	}
+}
+
+func TestEndpointHealthIgnoredWithoutCheck(t *testing.T) {
+	registry := NewRegistry()
+	system := testSystem(models.BalancingFailover)
+	connection := testConnection("a", 1, 0)
+	connection.HealthCheck.IntervalMs = 1000
+	connections := []models.ConnectionSetting{connection, testConnection("b", 1, 0)}
+	for i := 0; i < DefaultUnhealthyThreshold; i++ {
+		registry.Report(&connection, "sap", errors.New("timeout"))
+	}
+	if got := names(registry.Order(system, connections)); got != "b,a" {
+		t.Fatalf("order %s, want b,a", got)
+	}
+
+	// Проверка выключена: результаты сбрасываются, подключение возвращается в ротацию
+	connections[0].HealthCheck.IntervalMs = 0
+	registry.Retain(map[uuid.UUID]bool{})
+	if got := names(registry.Order(system, connections)); got != "a,b" {
+		t.Fatalf("order %s after disabling the check, want a,b", got)
+	}
+	if statuses := registry.Statuses(); len(statuses) != 0 {
+		t.Fatalf("statuses %+v after Retain, want none", statuses)
+	}
+}
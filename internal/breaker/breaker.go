@@ -0,0 +1,251 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-esb/internal/metrics"
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Значения по умолчанию параметров выключателя
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenDuration     = 30 * time.Second
+	DefaultHalfOpenRequests = 1
+)
+
+// ErrOpen возвращается без отправки запроса, пока выключатель системы разомкнут
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError ошибка отправки в систему с разомкнутым выключателем
+type OpenError struct {
+	System     string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%v for %s, retry after %s", ErrOpen, e.System, e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrOpen)
+func (e *OpenError) Unwrap() error {
+	return ErrOpen
+}
+
+// State состояние выключателя
+type State string
+
+const (
+	// StateClosed запросы отправляются, ошибки подряд считаются
+	StateClosed State = "closed"
+	// StateOpen запросы не отправляются до истечения времени размыкания
+	StateOpen State = "open"
+	// StateHalfOpen отправляются только пробные запросы
+	StateHalfOpen State = "half_open"
+)
+
+// stateValues значения метрики esb_circuit_breaker_state
+var stateValues = map[State]float64{StateClosed: 0, StateHalfOpen: 1, StateOpen: 2}
+
+// Status состояние выключателя подключения для health check
+type Status struct {
+	Connection uuid.UUID  `json:"connection"`
+	Name       string     `json:"name"`
+	System     string     `json:"system"`
+	State      State      `json:"state"`
+	Failures   int        `json:"failures"`
+	OpenedAt   *time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker автоматический выключатель (circuit breaker) подключения к системе.
+// После FailureThreshold ошибок подряд запросы не отправляются OpenDuration,
+// затем пропускаются HalfOpenRequests пробных запросов: успех замыкает
+// выключатель, ошибка снова размыкает его.
+type Breaker struct {
+	mu       sync.Mutex
+	name     string
+	system   string
+	settings settings
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+	now      func() time.Time
+}
+
+type settings struct {
+	failureThreshold int
+	openDuration     time.Duration
+	halfOpenRequests int
+	disabled         bool
+}
+
+func newSettings(s models.CircuitBreakerSettings) settings {
+	result := settings{
+		failureThreshold: s.FailureThreshold,
+		openDuration:     time.Duration(s.OpenSeconds) * time.Second,
+		halfOpenRequests: s.HalfOpenRequests,
+		disabled:         s.Disabled,
+	}
+	if result.failureThreshold <= 0 {
+		result.failureThreshold = DefaultFailureThreshold
+	}
+	if result.openDuration <= 0 {
+		result.openDuration = DefaultOpenDuration
+	}
+	if result.halfOpenRequests <= 0 {
+		result.halfOpenRequests = DefaultHalfOpenRequests
+	}
+	return result
+}
+
+// Allow проверяет, можно ли отправить запрос; иначе возвращает *OpenError.
+// Результат запроса передается в возвращаемую функцию done (true — система
+// ответила успешно).
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.settings.disabled {
+		return func(bool) {}, nil
+	}
+	if b.state == StateOpen {
+		if remaining := b.settings.openDuration - b.now().Sub(b.openedAt); remaining > 0 {
+			return nil, b.reject(remaining)
+		}
+		b.setState(StateHalfOpen)
+	}
+
+	halfOpen := b.state == StateHalfOpen
+	if halfOpen {
+		if b.probes >= b.settings.halfOpenRequests {
+			return nil, b.reject(0)
+		}
+		b.probes++
+	}
+	return func(success bool) { b.record(halfOpen, success) }, nil
+}
+
+func (b *Breaker) reject(retryAfter time.Duration) error {
+	metrics.CircuitBreakerRejections.WithLabelValues(b.system, b.name).Inc()
+	// Во время пробы повторить можно не раньше, чем через секунду
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &OpenError{System: b.system, RetryAfter: retryAfter}
+}
+
+func (b *Breaker) record(probe, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probes--
+	}
+	if success {
+		b.failures = 0
+		// Успешная проба замыкает выключатель; ответ на запрос, отправленный
+		// до размыкания, его не замыкает
+		if b.state == StateHalfOpen && probe {
+			b.setState(StateClosed)
+		}
+		return
+	}
+
+	b.failures++
+	switch {
+	case b.state == StateHalfOpen && probe:
+		b.open()
+	case b.state == StateClosed && b.failures >= b.settings.failureThreshold:
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.setState(StateOpen)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if state == StateClosed {
+		b.failures = 0
+	}
+	metrics.CircuitBreakerState.WithLabelValues(b.system, b.name).Set(stateValues[state])
+	if state == StateOpen {
+		metrics.CircuitBreakerOpened.WithLabelValues(b.system, b.name).Inc()
+	}
+}
+
+func (b *Breaker) status(connection uuid.UUID) Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := Status{Connection: connection, Name: b.name, System: b.system, State: b.state, Failures: b.failures}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// Registry хранит выключатели подключений к системам
+type Registry struct {
+	mu       sync.Mutex
+	breakers map[uuid.UUID]*Breaker
+}
+
+// NewRegistry создает реестр выключателей
+func NewRegistry() *Registry {
+	return &Registry{breakers: make(map[uuid.UUID]*Breaker)}
+}
+
+// Get возвращает выключатель подключения. Параметры подключения применяются
+// при каждом вызове, поэтому изменения в connection_settings действуют без перезапуска.
+func (r *Registry) Get(connection *models.ConnectionSetting, system string) *Breaker {
+	r.mu.Lock()
+	b, ok := r.breakers[connection.Ref]
+	if !ok {
+		b = &Breaker{state: StateClosed, now: time.Now}
+		r.breakers[connection.Ref] = b
+	}
+	r.mu.Unlock()
+
+	b.mu.Lock()
+	b.name, b.system = connection.Name, system
+	b.settings = newSettings(connection.CircuitBreaker)
+	if b.settings.disabled && b.state != StateClosed {
+		b.setState(StateClosed)
+	}
+	if !ok {
+		metrics.CircuitBreakerState.WithLabelValues(system, connection.Name).Set(stateValues[StateClosed])
+	}
+	b.mu.Unlock()
+	return b
+}
+
+// Statuses возвращает состояния выключателей, разомкнутые первыми
+func (r *Registry) Statuses() []Status {
+	r.mu.Lock()
+	statuses := make([]Status, 0, len(r.breakers))
+	for connection, b := range r.breakers {
+		statuses = append(statuses, b.status(connection))
+	}
+	r.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].State != statuses[j].State {
+			return stateValues[statuses[i].State] > stateValues[statuses[j].State]
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
@@ -0,0 +1,168 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// testClock время выключателя, которое двигает тест
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestBreaker(t *testing.T, settings models.CircuitBreakerSettings) (*Registry, *models.ConnectionSetting, *Breaker, *testClock) {
+	t.Helper()
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	registry := NewRegistry()
+	connection := &models.ConnectionSetting{Ref: uuid.New(), Name: "primary", CircuitBreaker: settings}
+	b := registry.Get(connection, "sap")
+	b.now = clock.Now
+	return registry, connection, b, clock
+}
+
+// call выполняет запрос через выключатель с результатом success
+func call(t *testing.T, b *Breaker, success bool) {
+	t.Helper()
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Allow in state %s: %v", b.state, err)
+	}
+	done(success)
+}
+
+func expectOpen(t *testing.T, b *Breaker, wantRetryAfter time.Duration) {
+	t.Helper()
+	_, err := b.Allow()
+	var openErr *OpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow = %v, want *OpenError", err)
+	}
+	if openErr.System != "sap" || openErr.RetryAfter != wantRetryAfter {
+		t.Fatalf("OpenError = %+v, want system sap, retry after %s", openErr, wantRetryAfter)
+	}
+}
+
+func TestBreakerStateTransitions(t *testing.T) {
+	_, _, b, clock := newTestBreaker(t, models.CircuitBreakerSettings{FailureThreshold: 3, OpenSeconds: 10})
+
+	// Успех сбрасывает счетчик ошибок подряд
+	call(t, b, false)
+	call(t, b, false)
+	call(t, b, true)
+	call(t, b, false)
+	call(t, b, false)
+	if b.state != StateClosed || b.failures != 2 {
+		t.Fatalf("state %s with %d failures, want closed with 2", b.state, b.failures)
+	}
+
+	call(t, b, false)
+	if b.state != StateOpen {
+		t.Fatalf("state %s after threshold, want open", b.state)
+	}
+	expectOpen(t, b, 10*time.Second)
+	clock.advance(4 * time.Second)
+	expectOpen(t, b, 6*time.Second)
+
+	// Неудачная проба снова размыкает выключатель на полное время
+	clock.advance(6 * time.Second)
+	call(t, b, false)
+	if b.state != StateOpen {
+		t.Fatalf("state %s after failed probe, want open", b.state)
+	}
+	expectOpen(t, b, 10*time.Second)
+
+	// Успешная проба замыкает выключатель
+	clock.advance(10 * time.Second)
+	call(t, b, true)
+	if b.state != StateClosed || b.failures != 0 {
+		t.Fatalf("state %s with %d failures after successful probe, want closed", b.state, b.failures)
+	}
+	call(t, b, true)
+}
+
+func TestBreakerHalfOpenProbeLimit(t *testing.T) {
+	_, _, b, clock := newTestBreaker(t, models.CircuitBreakerSettings{FailureThreshold: 1, OpenSeconds: 5, HalfOpenRequests: 2})
+	call(t, b, false)
+	clock.advance(5 * time.Second)
+
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.state != StateHalfOpen {
+		t.Fatalf("state %s during probes, want half_open", b.state)
+	}
+	// Пока пробы не завершились, остальные запросы отклоняются (повтор через секунду)
+	expectOpen(t, b, time.Second)
+
+	// Завершенная проба освобождает место для следующей
+	first(true)
+	if b.state != StateClosed {
+		t.Fatalf("state %s after successful probe, want closed", b.state)
+	}
+	second(true)
+	call(t, b, true)
+}
+
+func TestBreakerLateResponseDoesNotClose(t *testing.T) {
+	_, _, b, clock := newTestBreaker(t, models.CircuitBreakerSettings{FailureThreshold: 1, OpenSeconds: 5})
+
+	// Запрос отправлен до размыкания, ответ пришел во время пробы
+	late, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(t, b, false)
+	clock.advance(5 * time.Second)
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	late(true)
+	if b.state != StateHalfOpen {
+		t.Fatalf("state %s after response sent before opening, want half_open", b.state)
+	}
+	probe(true)
+	if b.state != StateClosed {
+		t.Fatalf("state %s after probe, want closed", b.state)
+	}
+}
+
+func TestBreakerSettingsReloaded(t *testing.T) {
+	registry, connection, b, _ := newTestBreaker(t, models.CircuitBreakerSettings{FailureThreshold: 1})
+	call(t, b, false)
+	if b.state != StateOpen {
+		t.Fatalf("state %s, want open", b.state)
+	}
+	if statuses := registry.Statuses(); len(statuses) != 1 || statuses[0].State != StateOpen || statuses[0].OpenedAt == nil {
+		t.Fatalf("statuses %+v, want one open breaker", statuses)
+	}
+
+	// Отключение выключателя в настройках подключения сразу замыкает его
+	connection.CircuitBreaker.Disabled = true
+	if registry.Get(connection, "sap") != b {
+		t.Fatal("registry returned another breaker for the connection")
+	}
+	if b.state != StateClosed {
+		t.Fatalf("state %s after disabling, want closed", b.state)
+	}
+	for i := 0; i < DefaultFailureThreshold+1; i++ {
+		call(t, b, false)
+	}
+	if b.state != StateClosed {
+		t.Fatalf("disabled breaker opened")
+	}
+}
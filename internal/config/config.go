@@ -3,25 +3,104 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
+	// Профиль окружения (dev, test, prod): выбирает подключения и аутентификации
+	// систем; маршруты, threads и схемы общие для всех профилей
+	Environment string
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
+
+	// HTTPWriteTimeout время обработки запроса HTTP API, включая запросы
+	// к системам и их повторы; должно превышать timeout_ms подключений
+	HTTPWriteTimeout time.Duration
+
+	// Протокол обмена с 1С (CommerceML); 1С входит пользователем ESB
+	CommerceMLFileLimit int64
+	// CommerceMLSessionLimit объем файлов, загруженных за сессию и еще не импортированных
+	CommerceMLSessionLimit int64
+
+	// Мастер-ключ шифрования секретов подключений (32 байта в base64 или hex)
+	// и прежний ключ, используемый только для расшифровки при ротации
+	MasterKey             string
+	MasterKeyFile         string
+	PreviousMasterKey     string
+	PreviousMasterKeyFile string
+
+	// Провайдеры секретов: Vault и время кэширования разрешенных значений
+	VaultAddr       string
+	VaultToken      string
+	VaultNamespace  string
+	SecretsCacheTTL time.Duration
+
+	// Аутентификация HTTP API: ключ подписи JWT, время жизни токенов
+	// и администратор, создаваемый при первом запуске
+	JWTSecret     string
+	JWTTTL        time.Duration
+	AdminUser     string
+	AdminPassword string
+
+	// Экспортер трассировки OpenTelemetry: none, otlp или stdout.
+	// Адрес OTLP задается стандартными переменными OTEL_EXPORTER_OTLP_*.
+	TracesExporter string
+
+	// Идемпотентность входящих сообщений: время хранения результатов
+	// и JSONPath ключа события Stripe (пустой — без подавления повторов)
+	IdempotencyTTL        time.Duration
+	StripeIdempotencyPath string
+
+	// Логирование: формат (json или text) и уровни пакетов,
+	// например "info,service=debug,adapter=warn"
+	LogFormat string
+	LogLevel  string
 }
 
 func Load() *Config {
 	return &Config{
+		Environment: getEnv("ESB_ENV", ""),
+
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "esb"),
 		DBPassword: getEnv("DB_PASSWORD", "esb"),
 		DBName:     getEnv("DB_NAME", "esb"),
 		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		HTTPWriteTimeout: time.Duration(getEnvInt64("HTTP_WRITE_TIMEOUT", 120)) * time.Second,
+
+		CommerceMLFileLimit:    getEnvInt64("CML_EXCHANGE_FILE_LIMIT", 10*1024*1024),
+		CommerceMLSessionLimit: getEnvInt64("CML_EXCHANGE_SESSION_LIMIT", 100*1024*1024),
+
+		MasterKey:             getEnv("ESB_MASTER_KEY", ""),
+		MasterKeyFile:         getEnv("ESB_MASTER_KEY_FILE", ""),
+		PreviousMasterKey:     getEnv("ESB_MASTER_KEY_PREVIOUS", ""),
+		PreviousMasterKeyFile: getEnv("ESB_MASTER_KEY_PREVIOUS_FILE", ""),
+
+		VaultAddr:       getEnv("VAULT_ADDR", ""),
+		VaultToken:      getEnv("VAULT_TOKEN", ""),
+		VaultNamespace:  getEnv("VAULT_NAMESPACE", ""),
+		SecretsCacheTTL: time.Duration(getEnvInt64("SECRETS_CACHE_TTL", 300)) * time.Second,
+
+		JWTSecret:     getEnv("ESB_JWT_SECRET", ""),
+		JWTTTL:        time.Duration(getEnvInt64("ESB_JWT_TTL", 3600)) * time.Second,
+		AdminUser:     getEnv("ESB_ADMIN_USER", ""),
+		AdminPassword: getEnv("ESB_ADMIN_PASSWORD", ""),
+
+		TracesExporter: getEnv("OTEL_TRACES_EXPORTER", "none"),
+
+		IdempotencyTTL:        time.Duration(getEnvInt64("ESB_IDEMPOTENCY_TTL", 86400)) * time.Second,
+		StripeIdempotencyPath: getEnv("ESB_STRIPE_IDEMPOTENCY_PATH", "$.id"),
+
+		LogFormat: getEnv("ESB_LOG_FORMAT", "json"),
+		LogLevel:  getEnv("ESB_LOG_LEVEL", "info"),
 	}
 }
 
@@ -36,3 +115,12 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt64(key string, fallback int64) int64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+
+	// xmlDeclEncoding находит атрибут encoding в XML декларации
+	xmlDeclEncoding = regexp.MustCompile(`^(\s*<\?xml[^>]*?encoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+)
+
+// isUTF8 проверяет, обозначает ли имя кодировки UTF-8 (пустое имя считается UTF-8)
+func isUTF8(charset string) bool {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8":
+		return true
+	}
+	return false
+}
+
+// lookupEncoding возвращает кодировку по имени (windows-1251, cp866, koi8-r и т.д.)
+func lookupEncoding(charset string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(strings.TrimSpace(charset))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+	return enc, nil
+}
+
+// DecodeCharset перекодирует данные в UTF-8.
+// BOM имеет приоритет над указанной кодировкой, а если кодировка не указана,
+// используется encoding из XML декларации.
+func DecodeCharset(data []byte, charset string) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return setXMLDeclEncoding(data[len(bomUTF8):], "UTF-8"), nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), data)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), data)
+	}
+
+	if charset == "" {
+		charset = XMLDeclEncoding(data)
+	}
+	if isUTF8(charset) {
+		return data, nil
+	}
+
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWith(enc, data)
+}
+
+func decodeWith(enc encoding.Encoding, data []byte) ([]byte, error) {
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode charset: %w", err)
+	}
+	return setXMLDeclEncoding(decoded, "UTF-8"), nil
+}
+
+// EncodeCharset перекодирует данные из UTF-8 в указанную кодировку
+// и исправляет encoding в XML декларации
+func EncodeCharset(data []byte, charset string) ([]byte, error) {
+	if isUTF8(charset) {
+		return data, nil
+	}
+
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	encoded, _, err := transform.Bytes(enc.NewEncoder(), setXMLDeclEncoding(data, charset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode charset %s: %w", charset, err)
+	}
+	return encoded, nil
+}
+
+// XMLDeclEncoding возвращает encoding из XML декларации или пустую строку
+func XMLDeclEncoding(data []byte) string {
+	if m := xmlDeclEncoding.FindSubmatch(data); m != nil {
+		return string(m[2])
+	}
+	return ""
+}
+
+func setXMLDeclEncoding(data []byte, charset string) []byte {
+	if !xmlDeclEncoding.Match(data) {
+		return data
+	}
+	return xmlDeclEncoding.ReplaceAll(data, []byte("${1}"+charset+"${3}"))
+}
+
+// charsetReader используется xml.Decoder для документов не в UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	if isUTF8(charset) {
+		return input, nil
+	}
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// dbfCharset возвращает кодировку по байту language driver в заголовке DBF
+func dbfCharset(ldid byte) string {
+	switch ldid {
+	case 0x26, 0x65:
+		return "ibm866"
+	case 0xC9:
+		return "windows-1251"
+	}
+	return ""
+}
+
+// dbfLanguageDriver возвращает байт language driver для кодировки DBF
+func dbfLanguageDriver(charset string) byte {
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return 0
+	}
+	name, _ := htmlindex.Name(enc)
+	switch name {
+	case "ibm866":
+		return 0x65
+	case "windows-1251":
+		return 0xC9
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// "Привет" в однобайтовых кириллических кодировках
+var (
+	privetCP1251 = []byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2}
+	privetKOI8R  = []byte{0xF0, 0xD2, 0xC9, 0xD7, 0xC5, 0xD4}
+)
+
+func TestDecodeCharsetBOM(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		charset string
+		want    string
+	}{
+		{
+			name: "UTF-8 BOM stripped",
+			data: append([]byte{0xEF, 0xBB, 0xBF}, "Привет"...),
+			want: "Привет",
+		},
+		{
+			name:    "UTF-8 BOM wins over charset",
+			data:    append([]byte{0xEF, 0xBB, 0xBF}, "Привет"...),
+			charset: "windows-1251",
+			want:    "Привет",
+		},
+		{
+			name: "UTF-8 BOM fixes declaration",
+			data: append([]byte{0xEF, 0xBB, 0xBF}, `<?xml version="1.0" encoding="windows-1251"?><a>Привет</a>`...),
+			want: `<?xml version="1.0" encoding="UTF-8"?><a>Привет</a>`,
+		},
+		{
+			name: "UTF-16LE BOM",
+			data: []byte{0xFF, 0xFE, 0x1F, 0x04, 0x40, 0x04, 0x38, 0x04},
+			want: "При",
+		},
+		{
+			name:    "UTF-16BE BOM wins over charset",
+			data:    []byte{0xFE, 0xFF, 0x04, 0x1F, 0x04, 0x40, 0x04, 0x38},
+			charset: "koi8-r",
+			want:    "При",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCharset(tt.data, tt.charset)
+			if err != nil {
+				t.Fatalf("DecodeCharset: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("DecodeCharset = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCharsetXMLDeclaration(t *testing.T) {
+	doc := func(encoding string, text []byte) []byte {
+		return append(append([]byte(`<?xml version="1.0" encoding="`+encoding+`"?><a>`), text...), "</a>"...)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		charset string
+		want    string
+	}{
+		{"windows-1251 declaration", doc("windows-1251", privetCP1251), "", `<?xml version="1.0" encoding="UTF-8"?><a>Привет</a>`},
+		{"koi8-r declaration", doc("KOI8-R", privetKOI8R), "", `<?xml version="1.0" encoding="UTF-8"?><a>Привет</a>`},
+		{"single quotes", []byte(`<?xml version='1.0' encoding='cp1251'?><a>` + string(privetCP1251) + `</a>`), "", `<?xml version='1.0' encoding='UTF-8'?><a>Привет</a>`},
+		{"charset wins over declaration", doc("windows-1251", privetKOI8R), "koi8-r", `<?xml version="1.0" encoding="UTF-8"?><a>Привет</a>`},
+		{"UTF-8 declaration kept", doc("utf-8", []byte("Привет")), "", `<?xml version="1.0" encoding="utf-8"?><a>Привет</a>`},
+		{"no declaration", []byte("Привет"), "", "Привет"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCharset(tt.data, tt.charset)
+			if err != nil {
+				t.Fatalf("DecodeCharset: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("DecodeCharset = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := XMLDeclEncoding([]byte(`  <?xml version="1.0" encoding = "koi8-r" standalone="yes"?><a/>`)); got != "koi8-r" {
+		t.Fatalf("XMLDeclEncoding = %q, want koi8-r", got)
+	}
+	if got := XMLDeclEncoding([]byte(`<a encoding="koi8-r"/>`)); got != "" {
+		t.Fatalf("XMLDeclEncoding outside declaration = %q, want empty", got)
+	}
+}
+
+func TestCharsetRoundTrip(t *testing.T) {
+	tests := []struct {
+		charset string
+		encoded []byte
+	}{
+		{"windows-1251", privetCP1251},
+		{"cp1251", privetCP1251},
+		{"koi8-r", privetKOI8R},
+	}
+	for _, tt := range tests {
+		t.Run(tt.charset, func(t *testing.T) {
+			encoded, err := EncodeCharset([]byte("Привет"), tt.charset)
+			if err != nil {
+				t.Fatalf("EncodeCharset: %v", err)
+			}
+			if !bytes.Equal(encoded, tt.encoded) {
+				t.Fatalf("EncodeCharset = % X, want % X", encoded, tt.encoded)
+			}
+			decoded, err := DecodeCharset(encoded, tt.charset)
+			if err != nil {
+				t.Fatalf("DecodeCharset: %v", err)
+			}
+			if string(decoded) != "Привет" {
+				t.Fatalf("DecodeCharset = %q, want Привет", decoded)
+			}
+
+			// XML декларация переписывается в обе стороны
+			xmlDoc := []byte(`<?xml version="1.0" encoding="UTF-8"?><a>Привет</a>`)
+			encoded, err = EncodeCharset(xmlDoc, tt.charset)
+			if err != nil {
+				t.Fatalf("EncodeCharset: %v", err)
+			}
+			if got := XMLDeclEncoding(encoded); got != tt.charset {
+				t.Fatalf("encoded declaration = %q, want %q", got, tt.charset)
+			}
+			decoded, err = DecodeCharset(encoded, "")
+			if err != nil {
+				t.Fatalf("DecodeCharset: %v", err)
+			}
+			if !bytes.Equal(decoded, xmlDoc) {
+				t.Fatalf("round-trip = %q, want %q", decoded, xmlDoc)
+			}
+		})
+	}
+}
+
+func TestXMLToJSONCharset(t *testing.T) {
+	data := append(append([]byte(`<?xml version="1.0" encoding="windows-1251"?><order><name>`), privetCP1251...), "</name></order>"...)
+	out, err := XMLToJSON(data)
+	if err != nil {
+		t.Fatalf("XMLToJSON: %v", err)
+	}
+	if !strings.Contains(string(out), "Привет") {
+		t.Fatalf("XMLToJSON = %s, want decoded text", out)
+	}
+	if !json.Valid(out) {
+		t.Fatalf("XMLToJSON returned invalid JSON: %s", out)
+	}
+}
+
+func TestCharsetErrors(t *testing.T) {
+	if _, err := DecodeCharset([]byte("data"), "x-unknown"); err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+		t.Fatalf("DecodeCharset error = %v, want unsupported charset", err)
+	}
+	if _, err := EncodeCharset([]byte("data"), "x-unknown"); err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+		t.Fatalf("EncodeCharset error = %v, want unsupported charset", err)
+	}
+	// Символ без представления в windows-1251
+	if _, err := EncodeCharset([]byte("日本"), "windows-1251"); err == nil {
+		t.Fatal("EncodeCharset of unmappable text succeeded")
+	}
+	if _, err := EncodeCharset([]byte("Привет"), "utf-8"); err != nil {
+		t.Fatalf("EncodeCharset utf-8: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// CommerceMLNamespace пространство имен CommerceML 2.x
+const CommerceMLNamespace = "urn:1C.ru:commerceml_2"
+
+// CommerceML документ обмена 1С:Предприятие (КоммерческаяИнформация).
+// Теги xml соответствуют схеме CommerceML 2.x, теги json задают
+// каноническое JSON представление. Числа хранятся строками, как в 1С,
+// чтобы не терять точность сумм.
+type CommerceML struct {
+	XMLName    xml.Name              `xml:"КоммерческаяИнформация" json:"-"`
+	Xmlns      string                `xml:"xmlns,attr,omitempty" json:"-"`
+	Version    string                `xml:"ВерсияСхемы,attr" json:"version"`
+	CreatedAt  string                `xml:"ДатаФормирования,attr" json:"created_at"`
+	Classifier *CommerceMLClassifier `xml:"Классификатор,omitempty" json:"classifier,omitempty"`
+	Catalog    *CommerceMLCatalog    `xml:"Каталог,omitempty" json:"catalog,omitempty"`
+	Offers     *CommerceMLOffers     `xml:"ПакетПредложений,omitempty" json:"offers,omitempty"`
+	Documents  []CommerceMLDocument  `xml:"Документ" json:"documents,omitempty"`
+}
+
+// CommerceMLClassifier классификатор (группы товаров)
+type CommerceMLClassifier struct {
+	ID     string           `xml:"Ид" json:"id"`
+	Name   string           `xml:"Наименование" json:"name"`
+	Groups CommerceMLGroups `xml:"Группы,omitempty" json:"groups,omitempty"`
+}
+
+// CommerceMLGroup группа товаров
+type CommerceMLGroup struct {
+	ID     string           `xml:"Ид" json:"id"`
+	Name   string           `xml:"Наименование" json:"name"`
+	Groups CommerceMLGroups `xml:"Группы,omitempty" json:"groups,omitempty"`
+}
+
+// CommerceMLCatalog каталог товаров
+type CommerceMLCatalog struct {
+	OnlyChanges  string             `xml:"СодержитТолькоИзменения,attr,omitempty" json:"only_changes,omitempty"`
+	ID           string             `xml:"Ид" json:"id"`
+	ClassifierID string             `xml:"ИдКлассификатора" json:"classifier_id"`
+	Name         string             `xml:"Наименование" json:"name"`
+	Products     CommerceMLProducts `xml:"Товары,omitempty" json:"products,omitempty"`
+}
+
+// CommerceMLProduct товар каталога или строка документа
+type CommerceMLProduct struct {
+	ID          string               `xml:"Ид" json:"id"`
+	Barcode     string               `xml:"Штрихкод,omitempty" json:"barcode,omitempty"`
+	SKU         string               `xml:"Артикул,omitempty" json:"sku,omitempty"`
+	Name        string               `xml:"Наименование" json:"name"`
+	Unit        *CommerceMLUnit      `xml:"БазоваяЕдиница,omitempty" json:"unit,omitempty"`
+	Description string               `xml:"Описание,omitempty" json:"description,omitempty"`
+	Groups      CommerceMLGroupIDs   `xml:"Группы,omitempty" json:"groups,omitempty"`
+	Price       string               `xml:"ЦенаЗаЕдиницу,omitempty" json:"price,omitempty"`
+	Quantity    string               `xml:"Количество,omitempty" json:"quantity,omitempty"`
+	Total       string               `xml:"Сумма,omitempty" json:"total,omitempty"`
+	Requisites  CommerceMLRequisites `xml:"ЗначенияРеквизитов,omitempty" json:"requisites,omitempty"`
+}
+
+// CommerceMLUnit единица измерения
+type CommerceMLUnit struct {
+	Code          string `xml:"Код,attr,omitempty" json:"code,omitempty"`
+	FullName      string `xml:"НаименованиеПолное,attr,omitempty" json:"full_name,omitempty"`
+	International string `xml:"МеждународноеСокращение,attr,omitempty" json:"international,omitempty"`
+	Name          string `xml:",chardata" json:"name"`
+}
+
+// CommerceMLRequisite значение реквизита
+type CommerceMLRequisite struct {
+	Name  string `xml:"Наименование" json:"name"`
+	Value string `xml:"Значение" json:"value"`
+}
+
+// CommerceMLOffers пакет предложений (цены и остатки)
+type CommerceMLOffers struct {
+	OnlyChanges  string                 `xml:"СодержитТолькоИзменения,attr,omitempty" json:"only_changes,omitempty"`
+	ID           string                 `xml:"Ид" json:"id"`
+	Name         string                 `xml:"Наименование" json:"name"`
+	CatalogID    string                 `xml:"ИдКаталога" json:"catalog_id"`
+	ClassifierID string                 `xml:"ИдКлассификатора" json:"classifier_id"`
+	PriceTypes   CommerceMLPriceTypes   `xml:"ТипыЦен,omitempty" json:"price_types,omitempty"`
+	Offers       CommerceMLOfferDetails `xml:"Предложения,omitempty" json:"items,omitempty"`
+}
+
+// CommerceMLPriceType тип цены
+type CommerceMLPriceType struct {
+	ID       string `xml:"Ид" json:"id"`
+	Name     string `xml:"Наименование" json:"name"`
+	Currency string `xml:"Валюта,omitempty" json:"currency,omitempty"`
+}
+
+// CommerceMLOfferDetail предложение по товару
+type CommerceMLOfferDetail struct {
+	ID       string           `xml:"Ид" json:"id"`
+	SKU      string           `xml:"Артикул,omitempty" json:"sku,omitempty"`
+	Name     string           `xml:"Наименование" json:"name"`
+	Unit     *CommerceMLUnit  `xml:"БазоваяЕдиница,omitempty" json:"unit,omitempty"`
+	Prices   CommerceMLPrices `xml:"Цены,omitempty" json:"prices,omitempty"`
+	Quantity string           `xml:"Количество,omitempty" json:"quantity,omitempty"`
+}
+
+// CommerceMLPrice цена предложения
+type CommerceMLPrice struct {
+	Presentation string `xml:"Представление,omitempty" json:"presentation,omitempty"`
+	PriceTypeID  string `xml:"ИдТипаЦены" json:"price_type_id"`
+	Price        string `xml:"ЦенаЗаЕдиницу" json:"price"`
+	Currency     string `xml:"Валюта,omitempty" json:"currency,omitempty"`
+	Unit         string `xml:"Единица,omitempty" json:"unit,omitempty"`
+}
+
+// CommerceMLDocument документ (заказ, реализация, оплата)
+type CommerceMLDocument struct {
+	ID             string                   `xml:"Ид" json:"id"`
+	Number         string                   `xml:"Номер" json:"number"`
+	Date           string                   `xml:"Дата" json:"date"`
+	Time           string                   `xml:"Время,omitempty" json:"time,omitempty"`
+	Operation      string                   `xml:"ХозОперация" json:"operation"`
+	Role           string                   `xml:"Роль" json:"role"`
+	Currency       string                   `xml:"Валюта" json:"currency"`
+	Rate           string                   `xml:"Курс,omitempty" json:"rate,omitempty"`
+	Total          string                   `xml:"Сумма" json:"total"`
+	Comment        string                   `xml:"Комментарий,omitempty" json:"comment,omitempty"`
+	Counterparties CommerceMLCounterparties `xml:"Контрагенты,omitempty" json:"counterparties,omitempty"`
+	Items          CommerceMLProducts       `xml:"Товары,omitempty" json:"items,omitempty"`
+	Requisites     CommerceMLRequisites     `xml:"ЗначенияРеквизитов,omitempty" json:"requisites,omitempty"`
+}
+
+// CommerceMLCounterparty контрагент документа
+type CommerceMLCounterparty struct {
+	ID       string `xml:"Ид" json:"id"`
+	Name     string `xml:"Наименование" json:"name"`
+	FullName string `xml:"ПолноеНаименование,omitempty" json:"full_name,omitempty"`
+	Role     string `xml:"Роль" json:"role"`
+	INN      string `xml:"ИНН,omitempty" json:"inn,omitempty"`
+	KPP      string `xml:"КПП,omitempty" json:"kpp,omitempty"`
+}
+
+// Списки CommerceML выводятся внутри элемента-обертки (<Группы><Группа>...).
+// Пустой список не выводится вовсе: путь тега "Группы>Группа" дал бы пустую
+// обертку, поэтому списки сами кодируют свои элементы.
+type (
+	// CommerceMLGroups группы классификатора (Группа)
+	CommerceMLGroups []CommerceMLGroup
+	// CommerceMLGroupIDs идентификаторы групп товара (Ид)
+	CommerceMLGroupIDs []string
+	// CommerceMLProducts товары каталога или строки документа (Товар)
+	CommerceMLProducts []CommerceMLProduct
+	// CommerceMLRequisites значения реквизитов (ЗначениеРеквизита)
+	CommerceMLRequisites []CommerceMLRequisite
+	// CommerceMLPriceTypes типы цен (ТипЦены)
+	CommerceMLPriceTypes []CommerceMLPriceType
+	// CommerceMLOfferDetails предложения (Предложение)
+	CommerceMLOfferDetails []CommerceMLOfferDetail
+	// CommerceMLPrices цены предложения (Цена)
+	CommerceMLPrices []CommerceMLPrice
+	// CommerceMLCounterparties контрагенты документа (Контрагент)
+	CommerceMLCounterparties []CommerceMLCounterparty
+)
+
+func (l CommerceMLGroups) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "Группа", l)
+}
+
+func (l *CommerceMLGroups) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "Группа", (*[]CommerceMLGroup)(l))
+}
+
+func (l CommerceMLGroupIDs) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "Ид", l)
+}
+
+func (l *CommerceMLGroupIDs) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "Ид", (*[]string)(l))
+}
+
+func (l CommerceMLProducts) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "Товар", l)
+}
+
+func (l *CommerceMLProducts) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "Товар", (*[]CommerceMLProduct)(l))
+}
+
+func (l CommerceMLRequisites) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "ЗначениеРеквизита", l)
+}
+
+func (l *CommerceMLRequisites) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "ЗначениеРеквизита", (*[]CommerceMLRequisite)(l))
+}
+
+func (l CommerceMLPriceTypes) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "ТипЦены", l)
+}
+
+func (l *CommerceMLPriceTypes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "ТипЦены", (*[]CommerceMLPriceType)(l))
+}
+
+func (l CommerceMLOfferDetails) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "Предложение", l)
+}
+
+func (l *CommerceMLOfferDetails) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "Предложение", (*[]CommerceMLOfferDetail)(l))
+}
+
+func (l CommerceMLPrices) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "Цена", l)
+}
+
+func (l *CommerceMLPrices) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "Цена", (*[]CommerceMLPrice)(l))
+}
+
+func (l CommerceMLCounterparties) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalCommerceMLList(e, start, "Контрагент", l)
+}
+
+func (l *CommerceMLCounterparties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return unmarshalCommerceMLList(d, "Контрагент", (*[]CommerceMLCounterparty)(l))
+}
+
+// marshalCommerceMLList выводит элементы item внутри обертки start
+func marshalCommerceMLList[T any](e *xml.Encoder, start xml.StartElement, item string, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i := range items {
+		if err := e.EncodeElement(items[i], xml.StartElement{Name: xml.Name{Local: item}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// unmarshalCommerceMLList читает элементы item обертки; прочие элементы пропускаются
+func unmarshalCommerceMLList[T any](d *xml.Decoder, item string, items *[]T) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local != item {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			var value T
+			if err := d.DecodeElement(&value, &t); err != nil {
+				return err
+			}
+			*items = append(*items, value)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// CommerceMLToJSON конвертирует документ CommerceML в каноническое JSON представление
+func CommerceMLToJSON(xmlData []byte) ([]byte, error) {
+	var doc CommerceML
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse CommerceML: %w", err)
+	}
+
+	output, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return output, nil
+}
+
+// JSONToCommerceML конвертирует каноническое JSON представление в документ CommerceML
+func JSONToCommerceML(jsonData []byte) ([]byte, error) {
+	var doc CommerceML
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	doc.Xmlns = CommerceMLNamespace
+	if doc.Version == "" {
+		doc.Version = "2.10"
+	}
+	if doc.CreatedAt == "" {
+		doc.CreatedAt = time.Now().Format("2006-01-02T15:04:05")
+	}
+
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CommerceML: %w", err)
+	}
+	return []byte(xml.Header + string(output)), nil
+}
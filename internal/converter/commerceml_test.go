@@ -0,0 +1,193 @@
+package converter
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testCommerceML выгрузка 1С: классификатор, каталог, пакет предложений и
+// документ со всеми списками
+const testCommerceML = `<?xml version="1.0" encoding="UTF-8"?>
+<КоммерческаяИнформация xmlns="urn:1C.ru:commerceml_2" ВерсияСхемы="2.10" ДатаФормирования="2024-03-01T10:00:00">
+  <Классификатор>
+    <Ид>cls-1</Ид>
+    <Наименование>Классификатор</Наименование>
+    <Группы>
+      <Группа>
+        <Ид>grp-1</Ид>
+        <Наименование>Инструменты</Наименование>
+        <Группы>
+          <Группа>
+            <Ид>grp-2</Ид>
+            <Наименование>Дрели</Наименование>
+          </Группа>
+        </Группы>
+      </Группа>
+    </Группы>
+  </Классификатор>
+  <Каталог СодержитТолькоИзменения="false">
+    <Ид>cat-1</Ид>
+    <ИдКлассификатора>cls-1</ИдКлассификатора>
+    <Наименование>Основной каталог</Наименование>
+    <Товары>
+      <Товар>
+        <Ид>prd-1</Ид>
+        <Артикул>DR-500</Артикул>
+        <Наименование>Дрель ударная</Наименование>
+        <БазоваяЕдиница Код="796" НаименованиеПолное="Штука">шт</БазоваяЕдиница>
+        <Группы>
+          <Ид>grp-2</Ид>
+        </Группы>
+        <ЗначенияРеквизитов>
+          <ЗначениеРеквизита>
+            <Наименование>ВидНоменклатуры</Наименование>
+            <Значение>Товар</Значение>
+          </ЗначениеРеквизита>
+        </ЗначенияРеквизитов>
+      </Товар>
+    </Товары>
+  </Каталог>
+  <ПакетПредложений>
+    <Ид>cat-1#</Ид>
+    <Наименование>Пакет предложений</Наименование>
+    <ИдКаталога>cat-1</ИдКаталога>
+    <ИдКлассификатора>cls-1</ИдКлассификатора>
+    <ТипыЦен>
+      <ТипЦены>
+        <Ид>price-retail</Ид>
+        <Наименование>Розничная</Наименование>
+        <Валюта>RUB</Валюта>
+      </ТипЦены>
+    </ТипыЦен>
+    <Предложения>
+      <Предложение>
+        <Ид>prd-1</Ид>
+        <Наименование>Дрель ударная</Наименование>
+        <Цены>
+          <Цена>
+            <Представление>4 990 RUB за шт</Представление>
+            <ИдТипаЦены>price-retail</ИдТипаЦены>
+            <ЦенаЗаЕдиницу>4990.00</ЦенаЗаЕдиницу>
+            <Валюта>RUB</Валюта>
+          </Цена>
+        </Цены>
+        <Количество>12</Количество>
+      </Предложение>
+    </Предложения>
+  </ПакетПредложений>
+  <Документ>
+    <Ид>ord-1</Ид>
+    <Номер>1001</Номер>
+    <Дата>2024-03-01</Дата>
+    <ХозОперация>Заказ товара</ХозОперация>
+    <Роль>Продавец</Роль>
+    <Валюта>RUB</Валюта>
+    <Сумма>9980.00</Сумма>
+    <Контрагенты>
+      <Контрагент>
+        <Ид>cp-1</Ид>
+        <Наименование>ООО Ромашка</Наименование>
+        <Роль>Покупатель</Роль>
+        <ИНН>7701234567</ИНН>
+      </Контрагент>
+    </Контрагенты>
+    <Товары>
+      <Товар>
+        <Ид>prd-1</Ид>
+        <Наименование>Дрель ударная</Наименование>
+        <ЦенаЗаЕдиницу>4990.00</ЦенаЗаЕдиницу>
+        <Количество>2</Количество>
+        <Сумма>9980.00</Сумма>
+      </Товар>
+    </Товары>
+    <ЗначенияРеквизитов>
+      <ЗначениеРеквизита>
+        <Наименование>Статус заказа</Наименование>
+        <Значение>Новый</Значение>
+      </ЗначениеРеквизита>
+    </ЗначенияРеквизитов>
+  </Документ>
+</КоммерческаяИнформация>`
+
+// commerceMLWrappers элементы-обертки списков CommerceML
+var commerceMLWrappers = []string{"Группы", "Товары", "ЗначенияРеквизитов", "Контрагенты", "ТипыЦен", "Предложения", "Цены"}
+
+func TestCommerceMLRoundTrip(t *testing.T) {
+	first, err := CommerceMLToJSON([]byte(testCommerceML))
+	if err != nil {
+		t.Fatalf("CommerceMLToJSON: %v", err)
+	}
+
+	var doc CommerceML
+	if err := json.Unmarshal(first, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Classifier == nil || len(doc.Classifier.Groups) != 1 || doc.Classifier.Groups[0].Groups[0].Name != "Дрели" {
+		t.Errorf("classifier groups = %+v", doc.Classifier)
+	}
+	if doc.Catalog == nil || len(doc.Catalog.Products) != 1 {
+		t.Fatalf("catalog = %+v", doc.Catalog)
+	}
+	product := doc.Catalog.Products[0]
+	if !reflect.DeepEqual([]string(product.Groups), []string{"grp-2"}) || product.Unit.Name != "шт" || product.Requisites[0].Value != "Товар" {
+		t.Errorf("product = %+v", product)
+	}
+	if doc.Offers == nil || doc.Offers.PriceTypes[0].ID != "price-retail" || doc.Offers.Offers[0].Prices[0].Price != "4990.00" {
+		t.Errorf("offers = %+v", doc.Offers)
+	}
+	if len(doc.Documents) != 1 || doc.Documents[0].Counterparties[0].INN != "7701234567" ||
+		doc.Documents[0].Items[0].Quantity != "2" || doc.Documents[0].Requisites[0].Value != "Новый" {
+		t.Errorf("documents = %+v", doc.Documents)
+	}
+
+	xmlData, err := JSONToCommerceML(first)
+	if err != nil {
+		t.Fatalf("JSONToCommerceML: %v", err)
+	}
+	second, err := CommerceMLToJSON(xmlData)
+	if err != nil {
+		t.Fatalf("CommerceMLToJSON of generated document: %v", err)
+	}
+	if string(first) != string(second) {
+		t.Fatalf("round trip changed the document:\n%s\nwant:\n%s", second, first)
+	}
+}
+
+func TestJSONToCommerceMLOmitsEmptyLists(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"document without lists", `{"documents":[{"id":"ord-1","number":"1001","date":"2024-03-01","operation":"Заказ товара","role":"Продавец","currency":"RUB","total":"0"}]}`},
+		{"empty lists", `{"classifier":{"id":"cls-1","name":"Классификатор","groups":[]},` +
+			`"catalog":{"id":"cat-1","classifier_id":"cls-1","name":"Каталог","products":[{"id":"prd-1","name":"Дрель","groups":[],"requisites":[]}]},` +
+			`"offers":{"id":"cat-1#","name":"Пакет","catalog_id":"cat-1","classifier_id":"cls-1","price_types":[],"items":[{"id":"prd-1","name":"Дрель","prices":[]}]},` +
+			`"documents":[{"id":"ord-1","number":"1001","date":"2024-03-01","operation":"Заказ товара","role":"Продавец","currency":"RUB","total":"0","counterparties":[],"items":[],"requisites":[]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xmlData, err := JSONToCommerceML([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("JSONToCommerceML: %v", err)
+			}
+			for _, wrapper := range commerceMLWrappers {
+				empty := regexp.MustCompile(`<` + wrapper + `(/>|>\s*</` + wrapper + `>)`)
+				if empty.Match(xmlData) {
+					t.Errorf("empty list wrapper %s in output:\n%s", wrapper, xmlData)
+				}
+			}
+
+			// Обратное преобразование не добавляет списков
+			jsonData, err := CommerceMLToJSON(xmlData)
+			if err != nil {
+				t.Fatalf("CommerceMLToJSON: %v", err)
+			}
+			if strings.Contains(string(jsonData), "[]") {
+				t.Errorf("empty list in JSON:\n%s", jsonData)
+			}
+		})
+	}
+}
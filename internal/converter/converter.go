@@ -1,10 +1,19 @@
 package converter
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"strings"
+	"time"
+
+	"go-esb/internal/metrics"
+	"go-esb/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // FormatConverter интерфейс для конвертации между форматами
@@ -12,53 +21,231 @@ type FormatConverter interface {
 	Convert(data []byte, fromFormat, toFormat string) ([]byte, error)
 }
 
-// Converter реализует FormatConverter
-type Converter struct{}
+// Options дополнительные параметры конвертации
+type Options struct {
+	// SourceCharset кодировка исходных данных (пусто — UTF-8 или по BOM/XML декларации)
+	SourceCharset string
+	// TargetCharset кодировка результата (пусто — UTF-8)
+	TargetCharset string
+	// SourceProto и TargetProto схемы Protobuf исходных данных и результата
+	SourceProto ProtoSchema
+	TargetProto ProtoSchema
+	// SourceEDI и TargetEDI настройки EDI исходных данных и результата
+	SourceEDI EDIOptions
+	TargetEDI EDIOptions
+}
+
+// codecFunc преобразует данные между форматом и JSON
+type codecFunc func(data []byte, opts Options) ([]byte, error)
+
+func withoutOptions(fn func([]byte) ([]byte, error)) codecFunc {
+	return func(data []byte, _ Options) ([]byte, error) {
+		return fn(data)
+	}
+}
+
+// binaryFormats форматы, которые перекодируются кодеком, а не целиком
+var binaryFormats = map[string]bool{
+	"DBF":         true,
+	"MessagePack": true,
+	"Protobuf":    true,
+}
+
+// Converter реализует FormatConverter.
+// Конвертация выполняется через JSON: исходный формат декодируется в JSON,
+// затем JSON кодируется в целевой формат.
+type Converter struct {
+	decoders map[string]codecFunc
+	encoders map[string]codecFunc
+}
 
 // NewConverter создает новый конвертер
 func NewConverter() *Converter {
-	return &Converter{}
+	return &Converter{
+		decoders: map[string]codecFunc{
+			"XML":         withoutOptions(XMLToJSON),
+			"CSV":         withoutOptions(CSVToJSON),
+			"DBF":         dbfToJSON,
+			"TXT":         withoutOptions(TXTToJSON),
+			"CommerceML":  withoutOptions(CommerceMLToJSON),
+			"YAML":        withoutOptions(YAMLToJSON),
+			"MessagePack": withoutOptions(MessagePackToJSON),
+			"Protobuf": func(data []byte, opts Options) ([]byte, error) {
+				return ProtobufToJSON(data, opts.SourceProto)
+			},
+			"EDIFACT": func(data []byte, opts Options) ([]byte, error) {
+				return EDIFACTToJSON(data, opts.SourceEDI)
+			},
+			"X12": func(data []byte, opts Options) ([]byte, error) {
+				return X12ToJSON(data, opts.SourceEDI)
+			},
+		},
+		encoders: map[string]codecFunc{
+			"XML":         withoutOptions(JSONToXML),
+			"CSV":         withoutOptions(JSONToCSV),
+			"DBF":         jsonToDBF,
+			"TXT":         withoutOptions(JSONToTXT),
+			"CommerceML":  withoutOptions(JSONToCommerceML),
+			"YAML":        withoutOptions(JSONToYAML),
+			"MessagePack": withoutOptions(JSONToMessagePack),
+			"Protobuf": func(data []byte, opts Options) ([]byte, error) {
+				return JSONToProtobuf(data, opts.TargetProto)
+			},
+			"EDIFACT": func(data []byte, opts Options) ([]byte, error) {
+				return JSONToEDIFACT(data, opts.TargetEDI)
+			},
+			"X12": func(data []byte, opts Options) ([]byte, error) {
+				return JSONToX12(data, opts.TargetEDI)
+			},
+		},
+	}
 }
 
 // Convert конвертирует данные между форматами
 func (c *Converter) Convert(data []byte, fromFormat, toFormat string) ([]byte, error) {
-	if fromFormat == toFormat {
-		return data, nil
-	}
+	return c.ConvertWithOptions(data, fromFormat, toFormat, Options{})
+}
 
-	// JSON ↔ XML
-	if (fromFormat == "JSON" && toFormat == "XML") || (fromFormat == "XML" && toFormat == "JSON") {
-		if fromFormat == "JSON" {
-			return JSONToXML(data)
+// ConvertWithOptions конвертирует данные между форматами с перекодировкой символов
+func (c *Converter) ConvertWithOptions(data []byte, fromFormat, toFormat string, opts Options) ([]byte, error) {
+	start := time.Now()
+	output, err := c.convert(data, fromFormat, toFormat, opts)
+	metrics.ObserveConversion(fromFormat, toFormat, err, time.Since(start))
+	return output, err
+}
+
+// ConvertContext конвертирует данные с записью span трассировки
+func (c *Converter) ConvertContext(ctx context.Context, data []byte, fromFormat, toFormat string, opts Options) ([]byte, error) {
+	_, span := tracing.Start(ctx, "convert "+fromFormat+" → "+toFormat, trace.WithAttributes(
+		attribute.String("esb.convert.from", fromFormat),
+		attribute.String("esb.convert.to", toFormat),
+		attribute.Int("esb.convert.input_bytes", len(data)),
+	))
+	output, err := c.ConvertWithOptions(data, fromFormat, toFormat, opts)
+	span.SetAttributes(attribute.Int("esb.convert.output_bytes", len(output)))
+	tracing.End(span, err)
+	return output, err
+}
+
+func (c *Converter) convert(data []byte, fromFormat, toFormat string, opts Options) ([]byte, error) {
+	var err error
+	if !binaryFormats[fromFormat] {
+		if data, err = DecodeCharset(data, opts.SourceCharset); err != nil {
+			return nil, err
 		}
-		return XMLToJSON(data)
 	}
 
-	// JSON ↔ CSV
-	if fromFormat == "JSON" && toFormat == "CSV" {
-		return JSONToCSV(data)
-	}
-	if fromFormat == "CSV" && toFormat == "JSON" {
-		return CSVToJSON(data)
+	if fromFormat == toFormat {
+		if binaryFormats[toFormat] {
+			if isUTF8(opts.SourceCharset) && isUTF8(opts.TargetCharset) {
+				return data, nil
+			}
+		} else {
+			return EncodeCharset(data, opts.TargetCharset)
+		}
 	}
 
-	// XML ↔ CSV (через JSON)
-	if fromFormat == "XML" && toFormat == "CSV" {
-		jsonData, err := XMLToJSON(data)
-		if err != nil {
+	jsonData := data
+	if fromFormat != "JSON" {
+		decode, ok := c.decoders[fromFormat]
+		if !ok {
+			return nil, fmt.Errorf("unsupported conversion: %s -> %s", fromFormat, toFormat)
+		}
+		if jsonData, err = decode(data, opts); err != nil {
 			return nil, err
 		}
-		return JSONToCSV(jsonData)
 	}
-	if fromFormat == "CSV" && toFormat == "XML" {
-		jsonData, err := CSVToJSON(data)
-		if err != nil {
+
+	output := jsonData
+	if toFormat != "JSON" {
+		encode, ok := c.encoders[toFormat]
+		if !ok {
+			return nil, fmt.Errorf("unsupported conversion: %s -> %s", fromFormat, toFormat)
+		}
+		if output, err = encode(jsonData, opts); err != nil {
 			return nil, err
 		}
-		return JSONToXML(jsonData)
 	}
 
-	return nil, fmt.Errorf("unsupported conversion: %s -> %s", fromFormat, toFormat)
+	if binaryFormats[toFormat] {
+		return output, nil
+	}
+	return EncodeCharset(output, opts.TargetCharset)
+}
+
+// FormatFromContentType определяет формат данных по заголовку Content-Type.
+// Возвращает пустую строку, если формат определить не удалось.
+func FormatFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json"):
+		return "JSON"
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return "XML"
+	case mediaType == "text/csv":
+		return "CSV"
+	case mediaType == "application/dbf" || mediaType == "application/x-dbf" ||
+		mediaType == "application/dbase" || mediaType == "application/x-dbase":
+		return "DBF"
+	case mediaType == "text/plain":
+		return "TXT"
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" || mediaType == "text/yaml":
+		return "YAML"
+	case mediaType == "application/msgpack" || mediaType == "application/x-msgpack" || mediaType == "application/vnd.msgpack":
+		return "MessagePack"
+	case mediaType == "application/protobuf" || mediaType == "application/x-protobuf" ||
+		mediaType == "application/vnd.google.protobuf":
+		return "Protobuf"
+	case mediaType == "application/edifact":
+		return "EDIFACT"
+	case mediaType == "application/edi-x12":
+		return "X12"
+	}
+	return ""
+}
+
+// CharsetFromContentType возвращает параметр charset заголовка Content-Type
+func CharsetFromContentType(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+// ContentTypeForFormat возвращает Content-Type для формата данных и кодировки
+func ContentTypeForFormat(format, charset string) string {
+	contentType := "application/octet-stream"
+	switch format {
+	case "JSON":
+		contentType = "application/json"
+	case "XML", "CommerceML":
+		contentType = "application/xml"
+	case "CSV":
+		contentType = "text/csv"
+	case "DBF":
+		contentType = "application/x-dbf"
+	case "TXT":
+		contentType = "text/plain"
+	case "YAML":
+		contentType = "application/yaml"
+	case "MessagePack":
+		contentType = "application/msgpack"
+	case "Protobuf":
+		contentType = "application/x-protobuf"
+	case "EDIFACT":
+		contentType = "application/EDIFACT"
+	case "X12":
+		contentType = "application/EDI-X12"
+	}
+	if charset != "" && !binaryFormats[format] {
+		contentType += "; charset=" + charset
+	}
+	return contentType
 }
 
 // JSONToCSV конвертирует JSON в CSV
@@ -89,7 +276,7 @@ func JSONToCSV(jsonData []byte) ([]byte, error) {
 
 	var buf strings.Builder
 	writer := csv.NewWriter(&buf)
-	
+
 	// Write headers
 	if err := writer.Write(headers); err != nil {
 		return nil, err
@@ -145,7 +332,7 @@ func flattenJSON(obj interface{}, rows *[][]string, headers *[]string, headerSet
 		for key, value := range v {
 			currentPath := append(path, key)
 			pathKey := strings.Join(currentPath, ".")
-			
+
 			switch val := value.(type) {
 			case map[string]interface{}:
 				flattenJSON(val, rows, headers, headerSet, currentPath)
@@ -190,4 +377,3 @@ func flattenJSON(obj interface{}, rows *[][]string, headers *[]string, headerSet
 		*rows = append(*rows, row)
 	}
 }
-
@@ -0,0 +1,379 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+// dbfMaxLength наибольшая длина поля dBase в байтах
+const dbfMaxLength = 254
+
+// dbfField описывает поле таблицы dBase
+type dbfField struct {
+	Name     string
+	Type     byte
+	Length   int
+	Decimals int
+	// key исходное имя поля в JSON (Name усекается до 10 символов)
+	key string
+}
+
+// DBFToJSON конвертирует таблицу dBase (III/IV) в JSON массив объектов.
+// Кодировка строк определяется по байту language driver в заголовке.
+func DBFToJSON(dbfData []byte) ([]byte, error) {
+	return dbfToJSON(dbfData, Options{})
+}
+
+func dbfToJSON(dbfData []byte, opts Options) ([]byte, error) {
+	if len(dbfData) < 32 {
+		return nil, fmt.Errorf("failed to parse DBF: file too short")
+	}
+
+	charset := opts.SourceCharset
+	if charset == "" {
+		charset = dbfCharset(dbfData[29])
+	}
+	var decoder *encoding.Decoder
+	if !isUTF8(charset) {
+		enc, err := lookupEncoding(charset)
+		if err != nil {
+			return nil, err
+		}
+		decoder = enc.NewDecoder()
+	}
+	decodeText := func(raw []byte) string {
+		if decoder == nil {
+			return string(raw)
+		}
+		decoded, err := decoder.Bytes(raw)
+		if err != nil {
+			return string(raw)
+		}
+		return string(decoded)
+	}
+
+	recordCount := int(binary.LittleEndian.Uint32(dbfData[4:8]))
+	headerLen := int(binary.LittleEndian.Uint16(dbfData[8:10]))
+	recordLen := int(binary.LittleEndian.Uint16(dbfData[10:12]))
+	if headerLen < 33 || headerLen > len(dbfData) {
+		return nil, fmt.Errorf("failed to parse DBF: invalid header length %d", headerLen)
+	}
+	if recordLen < 1 {
+		return nil, fmt.Errorf("failed to parse DBF: invalid record length %d", recordLen)
+	}
+	// Число записей в заголовке не может превышать размер файла
+	if maxCount := (len(dbfData) - headerLen) / recordLen; recordCount > maxCount {
+		recordCount = maxCount
+	}
+
+	// Дескрипторы полей по 32 байта до терминатора 0x0D
+	var fields []dbfField
+	for offset := 32; offset+32 <= headerLen && dbfData[offset] != 0x0D; offset += 32 {
+		desc := dbfData[offset : offset+32]
+		name := desc[:11]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		fields = append(fields, dbfField{
+			Name:     strings.TrimSpace(decodeText(name)),
+			Type:     desc[11],
+			Length:   int(desc[16]),
+			Decimals: int(desc[17]),
+		})
+	}
+
+	result := make([]map[string]interface{}, 0, recordCount)
+	for i := 0; i < recordCount; i++ {
+		start := headerLen + i*recordLen
+		if start+recordLen > len(dbfData) {
+			break
+		}
+		record := dbfData[start : start+recordLen]
+		// Пропускаем удаленные записи
+		if record[0] == '*' {
+			continue
+		}
+
+		row := make(map[string]interface{}, len(fields))
+		pos := 1
+		for _, field := range fields {
+			if pos+field.Length > len(record) {
+				break
+			}
+			row[field.Name] = parseDBFValue(field, decodeText(record[pos:pos+field.Length]))
+			pos += field.Length
+		}
+		result = append(result, row)
+	}
+
+	return json.Marshal(result)
+}
+
+func parseDBFValue(field dbfField, raw string) interface{} {
+	value := strings.TrimSpace(raw)
+
+	switch field.Type {
+	case 'N', 'F':
+		if value == "" {
+			return nil
+		}
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+		return value
+	case 'L':
+		switch value {
+		case "T", "t", "Y", "y":
+			return true
+		case "F", "f", "N", "n":
+			return false
+		}
+		return nil
+	case 'D':
+		if len(value) == 8 {
+			return value[:4] + "-" + value[4:6] + "-" + value[6:]
+		}
+		if value == "" {
+			return nil
+		}
+		return value
+	default:
+		return strings.TrimRight(raw, " \x00")
+	}
+}
+
+// JSONToDBF конвертирует JSON (объект или массив объектов) в таблицу dBase III
+func JSONToDBF(jsonData []byte) ([]byte, error) {
+	return jsonToDBF(jsonData, Options{})
+}
+
+func jsonToDBF(jsonData []byte, opts Options) ([]byte, error) {
+	var jsonObj interface{}
+	if err := json.Unmarshal(jsonData, &jsonObj); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var rows []map[string]interface{}
+	switch v := jsonObj.(type) {
+	case map[string]interface{}:
+		rows = append(rows, v)
+	case []interface{}:
+		for _, item := range v {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("DBF requires an array of objects")
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, fmt.Errorf("DBF requires an object or an array of objects")
+	}
+
+	// Строки перекодируются до расчета длин полей, т.к. длины задаются в байтах
+	if !isUTF8(opts.TargetCharset) {
+		enc, err := lookupEncoding(opts.TargetCharset)
+		if err != nil {
+			return nil, err
+		}
+		encoder := enc.NewEncoder()
+		for i, row := range rows {
+			encodedRow := make(map[string]interface{}, len(row))
+			for key, value := range row {
+				encodedKey, err := encoder.String(key)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encode charset %s: %w", opts.TargetCharset, err)
+				}
+				if text, ok := value.(string); ok {
+					if value, err = encoder.String(text); err != nil {
+						return nil, fmt.Errorf("failed to encode charset %s: %w", opts.TargetCharset, err)
+					}
+				}
+				encodedRow[encodedKey] = value
+			}
+			rows[i] = encodedRow
+		}
+	}
+
+	utf8Text := isUTF8(opts.TargetCharset)
+	fields, err := buildDBFFields(rows, utf8Text)
+	if err != nil {
+		return nil, err
+	}
+
+	recordLen := 1
+	for _, field := range fields {
+		recordLen += field.Length
+	}
+	headerLen := 32 + 32*len(fields) + 1
+
+	var buf bytes.Buffer
+	header := make([]byte, 32)
+	now := time.Now()
+	header[0] = 0x03 // dBase III без memo
+	header[1] = byte(now.Year() - 1900)
+	header[2] = byte(now.Month())
+	header[3] = byte(now.Day())
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(rows)))
+	binary.LittleEndian.PutUint16(header[8:10], uint16(headerLen))
+	binary.LittleEndian.PutUint16(header[10:12], uint16(recordLen))
+	header[29] = dbfLanguageDriver(opts.TargetCharset)
+	buf.Write(header)
+
+	for _, field := range fields {
+		desc := make([]byte, 32)
+		copy(desc[:10], field.Name)
+		desc[11] = field.Type
+		desc[16] = byte(field.Length)
+		desc[17] = byte(field.Decimals)
+		buf.Write(desc)
+	}
+	buf.WriteByte(0x0D)
+
+	for _, row := range rows {
+		buf.WriteByte(' ')
+		for _, field := range fields {
+			buf.WriteString(formatDBFValue(field, row[field.key], utf8Text))
+		}
+	}
+	buf.WriteByte(0x1A)
+
+	return buf.Bytes(), nil
+}
+
+// buildDBFFields определяет структуру таблицы по значениям строк.
+// utf8Names — имена полей в UTF-8 и усекаются по границе символа.
+func buildDBFFields(rows []map[string]interface{}, utf8Names bool) ([]dbfField, error) {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		keys := make([]string, 0, len(row))
+		for key := range row {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				names = append(names, key)
+			}
+		}
+	}
+
+	fields := make([]dbfField, 0, len(names))
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		field := dbfField{Name: dbfFieldName(name, used, utf8Names), Type: 'C', Length: 1, key: name}
+		isNumber, isBool := true, true
+		// intWidth ширина целой части чисел со знаком, textWidth — значений в текстовом поле
+		intWidth, textWidth := 1, 1
+		for _, row := range rows {
+			switch v := row[name].(type) {
+			case nil:
+			case float64:
+				isBool = false
+				text := strconv.FormatFloat(v, 'f', -1, 64)
+				textWidth = max(textWidth, len(text))
+				intPart, fraction, _ := strings.Cut(text, ".")
+				intWidth = max(intWidth, len(intPart))
+				field.Decimals = max(field.Decimals, len(fraction))
+			case bool:
+				isNumber = false
+			default:
+				isNumber, isBool = false, false
+				text := fmt.Sprintf("%v", v)
+				if _, ok := v.(string); !ok {
+					encoded, _ := json.Marshal(v)
+					text = string(encoded)
+				}
+				textWidth = max(textWidth, len(text))
+			}
+		}
+
+		switch {
+		case isNumber && !isBool:
+			// Все числа записываются с Decimals знаками после точки, поэтому
+			// ширина поля — самая длинная целая часть, точка и Decimals
+			field.Type, field.Length = 'N', intWidth
+			if field.Decimals > 0 {
+				field.Length += 1 + field.Decimals
+			}
+			if field.Length > dbfMaxLength {
+				return nil, fmt.Errorf("numeric field %s needs %d characters, more than %d", name, field.Length, dbfMaxLength)
+			}
+		case isBool && !isNumber:
+			field.Type, field.Length, field.Decimals = 'L', 1, 0
+		default:
+			// Длинный текст усекается до наибольшей длины поля
+			field.Length, field.Decimals = min(textWidth, dbfMaxLength), 0
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// dbfFieldName усекает имя поля до 10 байт и, если усеченное имя уже занято
+// (имена dBase не различают регистр), заменяет окончание номером: ORDER_NUM~1
+func dbfFieldName(name string, used map[string]bool, utf8Names bool) string {
+	candidate := truncateDBFText(name, 10, utf8Names)
+	for n := 1; used[strings.ToUpper(candidate)]; n++ {
+		suffix := "~" + strconv.Itoa(n)
+		candidate = truncateDBFText(name, 10-len(suffix), utf8Names) + suffix
+	}
+	used[strings.ToUpper(candidate)] = true
+	return candidate
+}
+
+// truncateDBFText усекает имя или значение до limit байт; текст в UTF-8
+// (utf8Text) усекается по границе символа
+func truncateDBFText(text string, limit int, utf8Text bool) string {
+	if len(text) <= limit {
+		return text
+	}
+	if utf8Text {
+		for limit > 0 && !utf8.RuneStart(text[limit]) {
+			limit--
+		}
+	}
+	return text[:limit]
+}
+
+// formatDBFValue форматирует значение по ширине поля. utf8Text — текст
+// в UTF-8 и усекается по границе символа.
+func formatDBFValue(field dbfField, value interface{}, utf8Text bool) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+	case float64:
+		// В текстовом поле число записывается без округления
+		precision := -1
+		if field.Type == 'N' {
+			precision = field.Decimals
+		}
+		text = strconv.FormatFloat(v, 'f', precision, 64)
+	case bool:
+		text = "F"
+		if v {
+			text = "T"
+		}
+	case string:
+		text = v
+	default:
+		encoded, _ := json.Marshal(v)
+		text = string(encoded)
+	}
+
+	text = truncateDBFText(text, field.Length, utf8Text)
+	if field.Type == 'N' {
+		return strings.Repeat(" ", field.Length-len(text)) + text
+	}
+	return text + strings.Repeat(" ", field.Length-len(text))
+}
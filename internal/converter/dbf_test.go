@@ -0,0 +1,212 @@
+package converter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// testDBF собирает таблицу dBase с одним полем NAME C(5) и заданными
+// значениями заголовка; records — записи без байта удаления
+func testDBF(recordCount uint32, headerLen, recordLen uint16, records ...string) []byte {
+	header := make([]byte, 32)
+	header[0] = 0x03
+	binary.LittleEndian.PutUint32(header[4:8], recordCount)
+	binary.LittleEndian.PutUint16(header[8:10], headerLen)
+	binary.LittleEndian.PutUint16(header[10:12], recordLen)
+
+	field := make([]byte, 32)
+	copy(field, "NAME")
+	field[11] = 'C'
+	field[16] = 5
+
+	data := append(header, field...)
+	data = append(data, 0x0D)
+	for _, record := range records {
+		data = append(data, record...)
+	}
+	return append(data, 0x1A)
+}
+
+func TestDBFToJSONMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"empty", nil, "file too short"},
+		{"shorter than header", make([]byte, 31), "file too short"},
+		{"zero header length", testDBF(1, 0, 6, " alpha"), "invalid header length 0"},
+		{"header length without fields", testDBF(1, 32, 6, " alpha"), "invalid header length 32"},
+		{"header longer than file", testDBF(1, 4096, 6, " alpha"), "invalid header length 4096"},
+		{"zero record length", testDBF(1, 65, 0, " alpha"), "invalid record length 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DBFToJSON(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("DBFToJSON = %s, %v; want error %q", got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDBFToJSONRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"valid", testDBF(2, 65, 6, " alpha", " beta "), `[{"NAME":"alpha"},{"NAME":"beta"}]`},
+		{"deleted record skipped", testDBF(2, 65, 6, "*alpha", " beta "), `[{"NAME":"beta"}]`},
+		{"record count larger than file", testDBF(0xFFFFFFFF, 65, 6, " alpha"), `[{"NAME":"alpha"}]`},
+		{"truncated last record", testDBF(2, 65, 6, " alpha", " be"), `[{"NAME":"alpha"}]`},
+		{"record shorter than fields", testDBF(1, 65, 3, " al"), `[{}]`},
+		{"no records", testDBF(0, 65, 6), `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DBFToJSON(tt.data)
+			if err != nil {
+				t.Fatalf("DBFToJSON: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("DBFToJSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONToDBFFieldNames(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"short names kept", `{"id":1,"name":"x"}`, []string{"id", "name"}},
+		{"long name truncated", `{"order_number":1}`, []string{"order_numb"}},
+		{"truncated names collide", `{"order_number":1,"order_numbers":2}`, []string{"order_numb", "order_nu~1"}},
+		{"case-insensitive collision", `{"ID":1,"id":2}`, []string{"ID", "id~1"}},
+		{"cyrillic on rune boundary", `{"количество":1}`, []string{"колич"}},
+		{"cyrillic collision", `{"количество_1":1,"количество_2":2}`, []string{"коли~1", "колич"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbf, err := JSONToDBF([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("JSONToDBF: %v", err)
+			}
+			back, err := DBFToJSON(dbf)
+			if err != nil {
+				t.Fatalf("DBFToJSON: %v", err)
+			}
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(back, &rows); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for name := range rows[0] {
+				if len(name) > 10 || !utf8.ValidString(name) {
+					t.Errorf("field name %q is not a valid 10 byte name", name)
+				}
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("field names = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+// dbfFieldDescriptor возвращает тип, длину и число знаков после точки первого поля
+func dbfFieldDescriptor(t *testing.T, dbf []byte) (byte, int, int) {
+	t.Helper()
+	if len(dbf) < 64 {
+		t.Fatalf("DBF of %d bytes has no field descriptor", len(dbf))
+	}
+	return dbf[32+11], int(dbf[32+16]), int(dbf[32+17])
+}
+
+func TestJSONToDBFFieldWidth(t *testing.T) {
+	tests := []struct {
+		name         string
+		json         string
+		wantType     byte
+		wantLength   int
+		wantDecimals int
+	}{
+		{"integers", `[{"v":7},{"v":-120}]`, 'N', 4, 0},
+		{"decimals widen integer part", `[{"v":1.5},{"v":123},{"v":-7.25}]`, 'N', 6, 2},
+		{"long fraction", `[{"v":0.125},{"v":99999}]`, 'N', 9, 3},
+		{"numbers and text", `[{"v":1.5},{"v":"abc"},{"v":12345.678}]`, 'C', 9, 0},
+		{"booleans", `[{"v":true},{"v":false}]`, 'L', 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbf, err := JSONToDBF([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("JSONToDBF: %v", err)
+			}
+			fieldType, length, decimals := dbfFieldDescriptor(t, dbf)
+			if fieldType != tt.wantType || length != tt.wantLength || decimals != tt.wantDecimals {
+				t.Fatalf("field %c(%d,%d), want %c(%d,%d)", fieldType, length, decimals, tt.wantType, tt.wantLength, tt.wantDecimals)
+			}
+
+			// Значения не усекаются и не округляются
+			back, err := DBFToJSON(dbf)
+			if err != nil {
+				t.Fatalf("DBFToJSON: %v", err)
+			}
+			var want, got []map[string]interface{}
+			json.Unmarshal([]byte(tt.json), &want)
+			json.Unmarshal(back, &got)
+			for i := range want {
+				wantValue := want[i]["v"]
+				if number, ok := wantValue.(float64); ok && tt.wantType == 'C' {
+					wantValue = strconv.FormatFloat(number, 'f', -1, 64)
+				}
+				if got[i]["v"] != wantValue {
+					t.Errorf("row %d: %v, want %v", i, got[i]["v"], wantValue)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONToDBFNumericOverflow(t *testing.T) {
+	_, err := JSONToDBF([]byte(`[{"amount":1e300}]`))
+	if err == nil || !strings.Contains(err.Error(), "numeric field amount") {
+		t.Fatalf("JSONToDBF = %v, want numeric width error", err)
+	}
+}
+
+func TestJSONToDBFTextTruncatedOnRuneBoundary(t *testing.T) {
+	// 300 символов по 2 байта: в поле 254 байта помещается 127 символов
+	text := strings.Repeat("ж", 300)
+	dbf, err := JSONToDBF([]byte(`[{"name":"` + text + `"},{"name":"a"}]`))
+	if err != nil {
+		t.Fatalf("JSONToDBF: %v", err)
+	}
+	if _, length, _ := dbfFieldDescriptor(t, dbf); length != 254 {
+		t.Fatalf("field length %d, want 254", length)
+	}
+	back, err := DBFToJSON(dbf)
+	if err != nil {
+		t.Fatalf("DBFToJSON: %v", err)
+	}
+	var rows []map[string]string
+	if err := json.Unmarshal(back, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if got := rows[0]["name"]; !utf8.ValidString(got) || got != strings.Repeat("ж", 127) {
+		t.Fatalf("truncated value has %d bytes (valid UTF-8 %v), want 127 runes", len(got), utf8.ValidString(got))
+	}
+	if rows[1]["name"] != "a" {
+		t.Fatalf("next record = %q, want a", rows[1]["name"])
+	}
+}
@@ -0,0 +1,617 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EDIOptions настройки разбора и генерации EDI (EDIFACT, ANSI X12).
+// Пустые разделители заменяются значениями по умолчанию стандарта.
+type EDIOptions struct {
+	SegmentTerminator   string
+	ElementSeparator    string
+	ComponentSeparator  string
+	ReleaseCharacter    string
+	RepetitionSeparator string
+
+	// Участники обмена для генерации конверта (UNB/ISA).
+	// Если SenderID не задан, конверт не генерируется.
+	SenderID          string
+	SenderQualifier   string
+	ReceiverID        string
+	ReceiverQualifier string
+	TestIndicator     bool
+
+	// ControlNumber контрольный номер конверта (UNB/UNZ, ISA/IEA, GS/GE)
+	ControlNumber int64
+}
+
+// EDISegment сегмент EDI в JSON представлении.
+// Элемент — строка (простой элемент) или массив строк (составной элемент).
+type EDISegment struct {
+	Tag      string        `json:"tag"`
+	Elements []interface{} `json:"elements"`
+}
+
+// EDIDocument JSON представление обмена EDI
+type EDIDocument struct {
+	Standard string       `json:"standard"`
+	Segments []EDISegment `json:"segments"`
+}
+
+// ediSeparators разделители EDI
+type ediSeparators struct {
+	segment    byte
+	element    byte
+	component  byte
+	release    byte // 0 — символ освобождения не используется (X12)
+	repetition byte
+}
+
+type ediSegment struct {
+	tag      string
+	elements [][]string
+}
+
+func (s ediSegment) element(i int) string {
+	if i < len(s.elements) && len(s.elements[i]) > 0 {
+		return s.elements[i][0]
+	}
+	return ""
+}
+
+func (s ediSegment) component(i, j int) string {
+	if i < len(s.elements) && j < len(s.elements[i]) {
+		return s.elements[i][j]
+	}
+	return ""
+}
+
+func firstByte(value string, fallback byte) byte {
+	if value != "" {
+		return value[0]
+	}
+	return fallback
+}
+
+func edifactSeparators(opts EDIOptions) ediSeparators {
+	return ediSeparators{
+		segment:    firstByte(opts.SegmentTerminator, '\''),
+		element:    firstByte(opts.ElementSeparator, '+'),
+		component:  firstByte(opts.ComponentSeparator, ':'),
+		release:    firstByte(opts.ReleaseCharacter, '?'),
+		repetition: firstByte(opts.RepetitionSeparator, '*'),
+	}
+}
+
+func x12Separators(opts EDIOptions) ediSeparators {
+	return ediSeparators{
+		segment:    firstByte(opts.SegmentTerminator, '~'),
+		element:    firstByte(opts.ElementSeparator, '*'),
+		component:  firstByte(opts.ComponentSeparator, '>'),
+		repetition: firstByte(opts.RepetitionSeparator, '^'),
+	}
+}
+
+//
+// === Разбор ===
+//
+
+// parseEDIFACT разбирает обмен EDIFACT; разделители берутся из UNA, если он есть
+func parseEDIFACT(data []byte, opts EDIOptions) ([]ediSegment, ediSeparators, error) {
+	seps := edifactSeparators(opts)
+	data = bytes.TrimLeft(data, " \r\n\t")
+	if bytes.HasPrefix(data, []byte("UNA")) {
+		if len(data) < 9 {
+			return nil, seps, fmt.Errorf("failed to parse EDIFACT: invalid UNA segment")
+		}
+		seps = ediSeparators{
+			component:  data[3],
+			element:    data[4],
+			release:    data[6],
+			repetition: data[7],
+			segment:    data[8],
+		}
+		data = data[9:]
+	}
+	segments, err := splitEDI(data, seps)
+	if err != nil {
+		return nil, seps, fmt.Errorf("failed to parse EDIFACT: %w", err)
+	}
+	return segments, seps, nil
+}
+
+// parseX12 разбирает обмен X12; разделители берутся из сегмента ISA
+func parseX12(data []byte, opts EDIOptions) ([]ediSegment, ediSeparators, error) {
+	seps := x12Separators(opts)
+	data = bytes.TrimLeft(data, " \r\n\t")
+	if bytes.HasPrefix(data, []byte("ISA")) {
+		// ISA имеет фиксированную длину: 106 символов включая терминатор
+		if len(data) < 106 {
+			return nil, seps, fmt.Errorf("failed to parse X12: ISA segment too short")
+		}
+		seps.element = data[3]
+		seps.component = data[104]
+		seps.segment = data[105]
+		isa := strings.Split(string(data[:105]), string(seps.element))
+		if len(isa) > 11 && len(isa[11]) == 1 && isa[11] != "U" {
+			seps.repetition = isa[11][0]
+		}
+	}
+	segments, err := splitEDI(data, seps)
+	if err != nil {
+		return nil, seps, fmt.Errorf("failed to parse X12: %w", err)
+	}
+	return segments, seps, nil
+}
+
+// splitEDI разбивает данные на сегменты, элементы и компоненты с учетом символа освобождения
+func splitEDI(data []byte, seps ediSeparators) ([]ediSegment, error) {
+	var segments []ediSegment
+	var elements [][]string
+	var components []string
+	var current strings.Builder
+
+	flushComponent := func() {
+		components = append(components, current.String())
+		current.Reset()
+	}
+	flushElement := func() {
+		flushComponent()
+		elements = append(elements, components)
+		components = nil
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case seps.release != 0 && c == seps.release:
+			if i+1 >= len(data) {
+				return nil, fmt.Errorf("dangling release character at end of data")
+			}
+			i++
+			current.WriteByte(data[i])
+		case c == seps.segment:
+			flushElement()
+			tag := strings.TrimSpace(elements[0][0])
+			if tag != "" {
+				segments = append(segments, ediSegment{tag: tag, elements: elements[1:]})
+			}
+			elements = nil
+		case c == seps.element:
+			flushElement()
+		case c == seps.component:
+			flushComponent()
+		case (c == '\r' || c == '\n') && len(elements) == 0 && len(components) == 0 && current.Len() == 0:
+			// Переводы строк между сегментами
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	if strings.TrimSpace(current.String()) != "" || len(elements) > 0 {
+		return nil, fmt.Errorf("missing segment terminator")
+	}
+	return segments, nil
+}
+
+func segmentsToDocument(standard string, segments []ediSegment) EDIDocument {
+	doc := EDIDocument{Standard: standard, Segments: make([]EDISegment, 0, len(segments))}
+	for _, seg := range segments {
+		jsonSeg := EDISegment{Tag: seg.tag, Elements: make([]interface{}, 0, len(seg.elements))}
+		for _, element := range seg.elements {
+			if len(element) == 1 {
+				jsonSeg.Elements = append(jsonSeg.Elements, element[0])
+			} else {
+				jsonSeg.Elements = append(jsonSeg.Elements, element)
+			}
+		}
+		doc.Segments = append(doc.Segments, jsonSeg)
+	}
+	return doc
+}
+
+// EDIFACTToJSON конвертирует обмен EDIFACT в JSON
+func EDIFACTToJSON(data []byte, opts EDIOptions) ([]byte, error) {
+	segments, _, err := parseEDIFACT(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(segmentsToDocument("EDIFACT", segments), "", "  ")
+}
+
+// X12ToJSON конвертирует обмен ANSI X12 в JSON
+func X12ToJSON(data []byte, opts EDIOptions) ([]byte, error) {
+	segments, _, err := parseX12(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(segmentsToDocument("X12", segments), "", "  ")
+}
+
+//
+// === Генерация ===
+//
+
+func documentToSegments(jsonData []byte) ([]ediSegment, error) {
+	var doc EDIDocument
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	segments := make([]ediSegment, 0, len(doc.Segments))
+	for _, jsonSeg := range doc.Segments {
+		if jsonSeg.Tag == "" {
+			return nil, fmt.Errorf("EDI segment without tag")
+		}
+		seg := ediSegment{tag: jsonSeg.Tag}
+		for _, element := range jsonSeg.Elements {
+			switch v := element.(type) {
+			case nil:
+				seg.elements = append(seg.elements, []string{""})
+			case string:
+				seg.elements = append(seg.elements, []string{v})
+			case float64:
+				seg.elements = append(seg.elements, []string{strconv.FormatFloat(v, 'f', -1, 64)})
+			case []interface{}:
+				components := make([]string, 0, len(v))
+				for _, component := range v {
+					if component == nil {
+						components = append(components, "")
+					} else {
+						components = append(components, fmt.Sprintf("%v", component))
+					}
+				}
+				seg.elements = append(seg.elements, components)
+			default:
+				return nil, fmt.Errorf("unsupported EDI element in segment %s: %v", jsonSeg.Tag, element)
+			}
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// writeEDI сериализует сегменты, экранируя разделители символом освобождения
+func writeEDI(segments []ediSegment, seps ediSeparators) []byte {
+	special := string([]byte{seps.segment, seps.element, seps.component})
+	if seps.release != 0 {
+		special += string(seps.release)
+	}
+	// Пробел в UNA означает, что разделитель повторений не используется
+	if seps.repetition != 0 && seps.repetition != ' ' {
+		special += string(seps.repetition)
+	}
+
+	var buf bytes.Buffer
+	for _, seg := range segments {
+		buf.WriteString(seg.tag)
+		for _, element := range seg.elements {
+			buf.WriteByte(seps.element)
+			for j, component := range element {
+				if j > 0 {
+					buf.WriteByte(seps.component)
+				}
+				for k := 0; k < len(component); k++ {
+					if seps.release != 0 && strings.IndexByte(special, component[k]) >= 0 {
+						buf.WriteByte(seps.release)
+					}
+					buf.WriteByte(component[k])
+				}
+			}
+		}
+		buf.WriteByte(seps.segment)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// fillSegmentCounts проставляет число сегментов в пустые UNT/SE.
+// refElement — номер элемента заголовка с контрольным номером сообщения.
+func fillSegmentCounts(segments []ediSegment, header, trailer string, refElement int) {
+	start := -1
+	for i, seg := range segments {
+		switch seg.tag {
+		case header:
+			start = i
+		case trailer:
+			if start >= 0 && seg.element(0) == "" {
+				if len(seg.elements) == 0 {
+					seg.elements = append(seg.elements, []string{""})
+				}
+				seg.elements[0] = []string{strconv.Itoa(i - start + 1)}
+				if len(seg.elements) < 2 {
+					seg.elements = append(seg.elements, []string{segments[start].element(refElement)})
+				}
+				segments[i] = seg
+			}
+			start = -1
+		}
+	}
+}
+
+func countSegments(segments []ediSegment, tag string) int {
+	count := 0
+	for _, seg := range segments {
+		if seg.tag == tag {
+			count++
+		}
+	}
+	return count
+}
+
+func hasSegment(segments []ediSegment, tag string) bool {
+	return countSegments(segments, tag) > 0
+}
+
+// JSONToEDIFACT конвертирует JSON в обмен EDIFACT.
+// Если в сегментах нет UNB и задан отправитель, генерируется конверт UNB/UNZ.
+func JSONToEDIFACT(jsonData []byte, opts EDIOptions) ([]byte, error) {
+	segments, err := documentToSegments(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	fillSegmentCounts(segments, "UNH", "UNT", 0)
+
+	seps := edifactSeparators(opts)
+	if !hasSegment(segments, "UNB") && opts.SenderID != "" {
+		segments = wrapEDIFACT(segments, opts)
+	}
+
+	return writeEDIFACT(segments, seps), nil
+}
+
+// writeEDIFACT сериализует обмен EDIFACT. Если разделители отличаются
+// от стандартных, обмен начинается с UNA.
+func writeEDIFACT(segments []ediSegment, seps ediSeparators) []byte {
+	var buf bytes.Buffer
+	if seps != edifactSeparators(EDIOptions{}) {
+		buf.Write([]byte{'U', 'N', 'A', seps.component, seps.element, '.', seps.release, seps.repetition, seps.segment})
+		buf.WriteByte('\n')
+	}
+	buf.Write(writeEDI(segments, seps))
+	return buf.Bytes()
+}
+
+func wrapEDIFACT(segments []ediSegment, opts EDIOptions) []ediSegment {
+	now := time.Now()
+	control := strconv.FormatInt(opts.ControlNumber, 10)
+
+	unb := ediSegment{tag: "UNB", elements: [][]string{
+		{"UNOC", "3"},
+		qualifiedParty(opts.SenderID, opts.SenderQualifier),
+		qualifiedParty(opts.ReceiverID, opts.ReceiverQualifier),
+		{now.Format("060102"), now.Format("1504")},
+		{control},
+	}}
+	if opts.TestIndicator {
+		unb.elements = append(unb.elements, []string{""}, []string{""}, []string{""}, []string{""}, []string{""}, []string{"1"})
+	}
+	unz := ediSegment{tag: "UNZ", elements: [][]string{
+		{strconv.Itoa(countSegments(segments, "UNH"))},
+		{control},
+	}}
+
+	result := make([]ediSegment, 0, len(segments)+2)
+	result = append(result, unb)
+	result = append(result, segments...)
+	return append(result, unz)
+}
+
+func qualifiedParty(id, qualifier string) []string {
+	if qualifier == "" {
+		return []string{id}
+	}
+	return []string{id, qualifier}
+}
+
+// JSONToX12 конвертирует JSON в обмен ANSI X12.
+// Если в сегментах нет ISA и задан отправитель, генерируются конверты ISA/IEA и GS/GE.
+func JSONToX12(jsonData []byte, opts EDIOptions) ([]byte, error) {
+	segments, err := documentToSegments(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	fillSegmentCounts(segments, "ST", "SE", 1)
+
+	seps := x12Separators(opts)
+	if !hasSegment(segments, "ISA") && opts.SenderID != "" {
+		segments = wrapX12(segments, opts, seps)
+	}
+	return writeEDI(segments, seps), nil
+}
+
+// x12FunctionalIDs код функциональной группы (GS01) по типу транзакции (ST01)
+var x12FunctionalIDs = map[string]string{
+	"810": "IN",
+	"820": "RA",
+	"850": "PO",
+	"855": "PR",
+	"856": "SH",
+	"860": "PC",
+	"997": "FA",
+}
+
+func wrapX12(segments []ediSegment, opts EDIOptions, seps ediSeparators) []ediSegment {
+	now := time.Now()
+	control := fmt.Sprintf("%09d", opts.ControlNumber%1000000000)
+	groupControl := strconv.FormatInt(opts.ControlNumber, 10)
+
+	functionalID := "PO"
+	for _, seg := range segments {
+		if seg.tag == "ST" {
+			if id, ok := x12FunctionalIDs[seg.element(0)]; ok {
+				functionalID = id
+			}
+			break
+		}
+	}
+
+	usage := "P"
+	if opts.TestIndicator {
+		usage = "T"
+	}
+	senderQualifier := opts.SenderQualifier
+	if senderQualifier == "" {
+		senderQualifier = "ZZ"
+	}
+	receiverQualifier := opts.ReceiverQualifier
+	if receiverQualifier == "" {
+		receiverQualifier = "ZZ"
+	}
+
+	isa := ediSegment{tag: "ISA", elements: [][]string{
+		{"00"}, {fmt.Sprintf("%-10s", "")},
+		{"00"}, {fmt.Sprintf("%-10s", "")},
+		{senderQualifier}, {fmt.Sprintf("%-15.15s", opts.SenderID)},
+		{receiverQualifier}, {fmt.Sprintf("%-15.15s", opts.ReceiverID)},
+		{now.Format("060102")}, {now.Format("1504")},
+		{string(seps.repetition)}, {"00501"}, {control}, {"0"}, {usage},
+		{string(seps.component)},
+	}}
+	gs := ediSegment{tag: "GS", elements: [][]string{
+		{functionalID}, {opts.SenderID}, {opts.ReceiverID},
+		{now.Format("20060102")}, {now.Format("1504")},
+		{groupControl}, {"X"}, {"005010"},
+	}}
+	ge := ediSegment{tag: "GE", elements: [][]string{
+		{strconv.Itoa(countSegments(segments, "ST"))}, {groupControl},
+	}}
+	iea := ediSegment{tag: "IEA", elements: [][]string{{"1"}, {control}}}
+
+	result := make([]ediSegment, 0, len(segments)+4)
+	result = append(result, isa, gs)
+	result = append(result, segments...)
+	return append(result, ge, iea)
+}
+
+//
+// === Функциональные квитанции ===
+//
+
+// EDIAcknowledgement формирует функциональную квитанцию на полученный обмен:
+// CONTRL для EDIFACT и 997 для X12. Отправитель и получатель квитанции —
+// получатель и отправитель исходного обмена, если они не заданы в opts.
+// Если accepted = false, квитанция сообщает, что обмен отклонен.
+func EDIAcknowledgement(data []byte, format string, opts EDIOptions, accepted bool) ([]byte, error) {
+	switch format {
+	case "EDIFACT":
+		return edifactCONTRL(data, opts, accepted)
+	case "X12":
+		return x12FunctionalAck(data, opts, accepted)
+	}
+	return nil, fmt.Errorf("acknowledgements are not supported for %s", format)
+}
+
+func edifactCONTRL(data []byte, opts EDIOptions, accepted bool) ([]byte, error) {
+	segments, seps, err := parseEDIFACT(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var unb ediSegment
+	for _, seg := range segments {
+		if seg.tag == "UNB" {
+			unb = seg
+			break
+		}
+	}
+	if len(unb.elements) < 5 {
+		return nil, fmt.Errorf("EDIFACT interchange has no valid UNB segment")
+	}
+
+	if opts.SenderID == "" {
+		opts.SenderID, opts.SenderQualifier = unb.component(2, 0), unb.component(2, 1)
+	}
+	if opts.ReceiverID == "" {
+		opts.ReceiverID, opts.ReceiverQualifier = unb.component(1, 0), unb.component(1, 1)
+	}
+
+	// 7 — обмен принят, 4 — отклонен
+	action := "7"
+	if !accepted {
+		action = "4"
+	}
+	ack := []ediSegment{
+		{tag: "UNH", elements: [][]string{{"1"}, {"CONTRL", "D", "3", "UN"}}},
+		{tag: "UCI", elements: [][]string{
+			{unb.element(4)},
+			unb.elements[1],
+			unb.elements[2],
+			{action},
+		}},
+		{tag: "UNT", elements: [][]string{{"3"}, {"1"}}},
+	}
+	ack = wrapEDIFACT(ack, opts)
+
+	// Квитанция использует разделители исходного обмена
+	return writeEDIFACT(ack, seps), nil
+}
+
+func x12FunctionalAck(data []byte, opts EDIOptions, accepted bool) ([]byte, error) {
+	segments, seps, err := parseX12(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var isa ediSegment
+	for _, seg := range segments {
+		if seg.tag == "ISA" {
+			isa = seg
+			break
+		}
+	}
+	if isa.tag == "" {
+		return nil, fmt.Errorf("X12 interchange has no ISA segment")
+	}
+
+	if opts.SenderID == "" {
+		opts.SenderID = strings.TrimSpace(isa.element(7))
+		opts.SenderQualifier = isa.element(6)
+	}
+	if opts.ReceiverID == "" {
+		opts.ReceiverID = strings.TrimSpace(isa.element(5))
+		opts.ReceiverQualifier = isa.element(4)
+	}
+
+	// Квитанция 997: AK1 на каждую группу, AK2/AK5 на каждую транзакцию;
+	// A — принято, R — отклонено
+	status := "A"
+	if !accepted {
+		status = "R"
+	}
+	ack := []ediSegment{{tag: "ST", elements: [][]string{{"997"}, {"0001"}}}}
+	var groupSets int
+	for _, seg := range segments {
+		switch seg.tag {
+		case "GS":
+			ack = append(ack, ediSegment{tag: "AK1", elements: [][]string{{seg.element(0)}, {seg.element(5)}}})
+			groupSets = 0
+		case "ST":
+			ack = append(ack,
+				ediSegment{tag: "AK2", elements: [][]string{{seg.element(0)}, {seg.element(1)}}},
+				ediSegment{tag: "AK5", elements: [][]string{{status}}})
+			groupSets++
+		case "GE":
+			n, acceptedSets := strconv.Itoa(groupSets), strconv.Itoa(groupSets)
+			if !accepted {
+				acceptedSets = "0"
+			}
+			ack = append(ack, ediSegment{tag: "AK9", elements: [][]string{{status}, {n}, {n}, {acceptedSets}}})
+		}
+	}
+	ack = append(ack, ediSegment{tag: "SE", elements: [][]string{{""}, {"0001"}}})
+	fillSegmentCounts(ack, "ST", "SE", 1)
+
+	ackSeps := x12Separators(EDIOptions{
+		SegmentTerminator:   string(seps.segment),
+		ElementSeparator:    string(seps.element),
+		ComponentSeparator:  string(seps.component),
+		RepetitionSeparator: string(seps.repetition),
+	})
+	return writeEDI(wrapX12(ack, opts, ackSeps), ackSeps), nil
+}
@@ -0,0 +1,191 @@
+package converter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testEDIFACT = "UNB+UNOC:3+SENDER:14+RECEIVER:14+240101:1200+42'\n" +
+	"UNH+1+ORDERS:D:96A:UN'\n" +
+	"BGM+220+PO-1+9'\n" +
+	"UNT+3+1'\n" +
+	"UNZ+1+42'\n"
+
+// testX12 формирует обмен X12 с конвертом ISA/GS из JSON документа
+func testX12(t *testing.T) []byte {
+	t.Helper()
+	doc := `{"segments":[{"tag":"ST","elements":["850","0001"]},{"tag":"BEG","elements":["00","SA","PO-1"]},{"tag":"SE","elements":[]}]}`
+	data, err := JSONToX12([]byte(doc), EDIOptions{SenderID: "SENDER", ReceiverID: "RECEIVER", ControlNumber: 7})
+	if err != nil {
+		t.Fatalf("JSONToX12: %v", err)
+	}
+	return data
+}
+
+func TestEDIParseMalformed(t *testing.T) {
+	x12 := string(testX12(t))
+
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{"EDIFACT missing terminator", "EDIFACT", "UNB+UNOC:3+A+B+240101:1200+1'\nUNH+1+ORDERS", "missing segment terminator"},
+		{"EDIFACT dangling release", "EDIFACT", "UNB+UNOC:3+A+B+240101:1200+1?", "dangling release character"},
+		{"EDIFACT short UNA", "EDIFACT", "UNA:+.", "invalid UNA segment"},
+		{"X12 short ISA", "X12", "ISA*00*          *00*~", "ISA segment too short"},
+		{"X12 truncated", "X12", x12[:len(x12)-3], "missing segment terminator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.format == "EDIFACT" {
+				_, err = EDIFACTToJSON([]byte(tt.data), EDIOptions{})
+			} else {
+				_, err = X12ToJSON([]byte(tt.data), EDIOptions{})
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEDIGenerateMalformedJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"invalid JSON", `{"segments":[`, "failed to parse JSON"},
+		{"segment without tag", `{"segments":[{"elements":["1"]}]}`, "EDI segment without tag"},
+		{"object element", `{"segments":[{"tag":"BGM","elements":[{"code":"220"}]}]}`, "unsupported EDI element in segment BGM"},
+		{"boolean element", `{"segments":[{"tag":"BGM","elements":[true]}]}`, "unsupported EDI element"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, generate := range []func([]byte, EDIOptions) ([]byte, error){JSONToEDIFACT, JSONToX12} {
+				if _, err := generate([]byte(tt.json), EDIOptions{}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestEDIFACTEscapesSeparators(t *testing.T) {
+	doc := `{"segments":[{"tag":"FTX","elements":["AAI","","",["a+b:c","d'e?f*g"]]}]}`
+	data, err := JSONToEDIFACT([]byte(doc), EDIOptions{})
+	if err != nil {
+		t.Fatalf("JSONToEDIFACT: %v", err)
+	}
+	if want := "FTX+AAI+++a?+b?:c:d?'e??f?*g'\n"; string(data) != want {
+		t.Fatalf("JSONToEDIFACT = %q, want %q", data, want)
+	}
+
+	back, err := EDIFACTToJSON(data, EDIOptions{})
+	if err != nil {
+		t.Fatalf("EDIFACTToJSON: %v", err)
+	}
+	var parsed EDIDocument
+	if err := json.Unmarshal(back, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	components := parsed.Segments[0].Elements[3].([]interface{})
+	if components[0] != "a+b:c" || components[1] != "d'e?f*g" {
+		t.Fatalf("round trip = %v", components)
+	}
+}
+
+func TestEDIFACTCustomSeparatorsWriteUNA(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    EDIOptions
+		wantUNA string
+	}{
+		{"default separators", EDIOptions{}, ""},
+		{"custom segment terminator", EDIOptions{SegmentTerminator: "!"}, "UNA:+.?*!"},
+		{"custom repetition separator", EDIOptions{RepetitionSeparator: "^"}, "UNA:+.?^'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := JSONToEDIFACT([]byte(`{"segments":[{"tag":"BGM","elements":["220"]}]}`), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hasUNA := strings.HasPrefix(string(data), "UNA")
+			if tt.wantUNA == "" && hasUNA || tt.wantUNA != "" && !strings.HasPrefix(string(data), tt.wantUNA) {
+				t.Fatalf("JSONToEDIFACT = %q, want UNA %q", data, tt.wantUNA)
+			}
+		})
+	}
+}
+
+func TestEDIAcknowledgement(t *testing.T) {
+	x12 := testX12(t)
+	customSeparators := strings.NewReplacer("'", "!", ":", "|").Replace(testEDIFACT)
+
+	tests := []struct {
+		name     string
+		format   string
+		data     string
+		accepted bool
+		want     []string
+		notWant  []string
+	}{
+		{name: "CONTRL accepted", format: "EDIFACT", data: testEDIFACT, accepted: true,
+			want:    []string{"UNB+UNOC:3+RECEIVER:14+SENDER:14+", "UNH+1+CONTRL:D:3:UN'", "UCI+42+SENDER:14+RECEIVER:14+7'", "UNT+3+1'"},
+			notWant: []string{"UNA"}},
+		{name: "CONTRL rejected", format: "EDIFACT", data: testEDIFACT, accepted: false,
+			want: []string{"UCI+42+SENDER:14+RECEIVER:14+4'"}},
+		{name: "CONTRL keeps separators of the interchange", format: "EDIFACT", data: "UNA|+.? !" + customSeparators, accepted: true,
+			want: []string{"UNA|+.? !", "UCI+42+SENDER|14+RECEIVER|14+7!"}},
+		{name: "997 accepted", format: "X12", data: string(x12), accepted: true,
+			want: []string{"ST*997*0001~", "AK1*PO*7~", "AK2*850*0001~", "AK5*A~", "AK9*A*1*1*1~", "SE*6*0001~"}},
+		{name: "997 rejected", format: "X12", data: string(x12), accepted: false,
+			want: []string{"AK5*R~", "AK9*R*1*1*0~"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack, err := EDIAcknowledgement([]byte(tt.data), tt.format, EDIOptions{ControlNumber: 5}, tt.accepted)
+			if err != nil {
+				t.Fatalf("EDIAcknowledgement: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(ack), want) {
+					t.Errorf("acknowledgement has no %q:\n%s", want, ack)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(ack), notWant) {
+					t.Errorf("acknowledgement has %q:\n%s", notWant, ack)
+				}
+			}
+		})
+	}
+}
+
+func TestEDIAcknowledgementMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{"EDIFACT without UNB", "EDIFACT", "UNH+1+ORDERS:D:96A:UN'\nUNT+2+1'\n", "no valid UNB segment"},
+		{"EDIFACT short UNB", "EDIFACT", "UNB+UNOC:3+SENDER'\n", "no valid UNB segment"},
+		{"EDIFACT not terminated", "EDIFACT", "UNB+UNOC:3+SENDER+RECEIVER+240101:1200+42", "missing segment terminator"},
+		{"X12 without ISA", "X12", "ST*850*0001~SE*2*0001~", "no ISA segment"},
+		{"unsupported format", "JSON", "{}", "not supported for JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack, err := EDIAcknowledgement([]byte(tt.data), tt.format, EDIOptions{}, true)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("EDIAcknowledgement = %q, %v; want error %q", ack, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -27,7 +28,9 @@ func JSONToXML(jsonData []byte) ([]byte, error) {
 // XMLToJSON конвертирует XML в JSON
 func XMLToJSON(xmlData []byte) ([]byte, error) {
 	var xmlObj XMLNode
-	if err := xml.Unmarshal(xmlData, &xmlObj); err != nil {
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&xmlObj); err != nil {
 		return nil, fmt.Errorf("failed to parse XML: %w", err)
 	}
 
@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtoSchema схема Protobuf: FileDescriptorSet (protoc --descriptor_set_out --include_imports)
+// и полное имя сообщения, например "acme.orders.v1.Order"
+type ProtoSchema struct {
+	DescriptorSet []byte
+	Message       string
+}
+
+// protoFiles кэш разобранных FileDescriptorSet по хэшу содержимого
+var protoFiles sync.Map
+
+// messageDescriptor находит описание сообщения в FileDescriptorSet
+func (s ProtoSchema) messageDescriptor() (protoreflect.MessageDescriptor, error) {
+	if len(s.DescriptorSet) == 0 || s.Message == "" {
+		return nil, fmt.Errorf("protobuf schema is not configured")
+	}
+
+	key := sha256.Sum256(s.DescriptorSet)
+	var files *protoregistry.Files
+	if cached, ok := protoFiles.Load(key); ok {
+		files = cached.(*protoregistry.Files)
+	} else {
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(s.DescriptorSet, &set); err != nil {
+			return nil, fmt.Errorf("failed to parse descriptor set: %w", err)
+		}
+		parsed, err := protodesc.NewFiles(&set)
+		if err != nil {
+			return nil, fmt.Errorf("invalid descriptor set: %w", err)
+		}
+		protoFiles.Store(key, parsed)
+		files = parsed
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(s.Message))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found in descriptor set: %w", s.Message, err)
+	}
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", s.Message)
+	}
+	return msgDesc, nil
+}
+
+// ValidateProtoSchema проверяет, что схема разбирается и содержит сообщение
+func ValidateProtoSchema(schema ProtoSchema) error {
+	_, err := schema.messageDescriptor()
+	return err
+}
+
+// ProtobufToJSON конвертирует бинарное сообщение Protobuf в JSON по схеме
+func ProtobufToJSON(data []byte, schema ProtoSchema) ([]byte, error) {
+	msgDesc, err := schema.messageDescriptor()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(msgDesc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("failed to parse Protobuf: %w", err)
+	}
+
+	output, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return output, nil
+}
+
+// JSONToProtobuf конвертирует JSON в бинарное сообщение Protobuf по схеме.
+// Неизвестные поля JSON игнорируются.
+func JSONToProtobuf(jsonData []byte, schema ProtoSchema) ([]byte, error) {
+	msgDesc, err := schema.messageDescriptor()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(msgDesc)
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(jsonData, msg); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	output, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Protobuf: %w", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// testDescriptorSet собирает FileDescriptorSet для схемы:
+//
+//	package acme.orders.v1;
+//	message Order { string id = 1; int64 amount = 2; repeated Line lines = 3; }
+//	message Line { string sku = 1; int32 qty = 2; }
+func testDescriptorSet(t *testing.T) []byte {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("acme/orders/v1/order.proto"),
+		Package: proto.String("acme.orders.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+					field("lines", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, ".acme.orders.v1.Line"),
+				},
+			},
+			{
+				Name: proto.String("Line"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("sku", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("qty", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				},
+			},
+		},
+	}}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshal descriptor set: %v", err)
+	}
+	return data
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	schema := ProtoSchema{DescriptorSet: testDescriptorSet(t), Message: "acme.orders.v1.Order"}
+	input := `{"id":"PO-1","amount":"9007199254740993","lines":[{"sku":"A-1","qty":2},{"sku":"B-2","qty":1}],"comment":"ignored"}`
+
+	data, err := JSONToProtobuf([]byte(input), schema)
+	if err != nil {
+		t.Fatalf("JSONToProtobuf: %v", err)
+	}
+	output, err := ProtobufToJSON(data, schema)
+	if err != nil {
+		t.Fatalf("ProtobufToJSON: %v", err)
+	}
+
+	var got struct {
+		ID     string `json:"id"`
+		Amount string `json:"amount"`
+		Lines  []struct {
+			SKU string `json:"sku"`
+			Qty int    `json:"qty"`
+		} `json:"lines"`
+		Comment *string `json:"comment"`
+	}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", output, err)
+	}
+	// protojson передает int64 строкой, чтобы не терять точность
+	if got.ID != "PO-1" || got.Amount != "9007199254740993" {
+		t.Fatalf("round-trip = %s", output)
+	}
+	if len(got.Lines) != 2 || got.Lines[0].SKU != "A-1" || got.Lines[0].Qty != 2 || got.Lines[1].SKU != "B-2" {
+		t.Fatalf("lines = %+v", got.Lines)
+	}
+	if got.Comment != nil {
+		t.Fatalf("unknown field kept: %s", output)
+	}
+
+	// Повторная конвертация дает те же байты (схема берется из кэша)
+	again, err := JSONToProtobuf(output, schema)
+	if err != nil {
+		t.Fatalf("JSONToProtobuf: %v", err)
+	}
+	if !proto.Equal(mustProtoMessage(t, data, schema), mustProtoMessage(t, again, schema)) {
+		t.Fatal("second round-trip changed the message")
+	}
+}
+
+// mustProtoMessage разбирает бинарное сообщение по схеме
+func mustProtoMessage(t *testing.T, data []byte, schema ProtoSchema) proto.Message {
+	t.Helper()
+	msgDesc, err := schema.messageDescriptor()
+	if err != nil {
+		t.Fatalf("messageDescriptor: %v", err)
+	}
+	msg := dynamicpb.NewMessage(msgDesc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return msg
+}
+
+func TestProtobufErrors(t *testing.T) {
+	descriptorSet := testDescriptorSet(t)
+
+	tests := []struct {
+		name    string
+		schema  ProtoSchema
+		wantErr string
+	}{
+		{"not configured", ProtoSchema{Message: "acme.orders.v1.Order"}, "protobuf schema is not configured"},
+		{"unknown message", ProtoSchema{DescriptorSet: descriptorSet, Message: "acme.orders.v1.Invoice"}, "message acme.orders.v1.Invoice not found in descriptor set"},
+		{"not a message", ProtoSchema{DescriptorSet: descriptorSet, Message: "acme.orders.v1.Order.id"}, "is not a message"},
+		{"broken descriptor set", ProtoSchema{DescriptorSet: []byte{0xFF, 0xFF}, Message: "acme.orders.v1.Order"}, "failed to parse descriptor set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProtoSchema(tt.schema)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateProtoSchema = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	schema := ProtoSchema{DescriptorSet: descriptorSet, Message: "acme.orders.v1.Order"}
+	if err := ValidateProtoSchema(schema); err != nil {
+		t.Fatalf("ValidateProtoSchema: %v", err)
+	}
+	if _, err := JSONToProtobuf([]byte(`{"amount":"many"}`), schema); err == nil || !strings.Contains(err.Error(), "failed to parse JSON") {
+		t.Fatalf("JSONToProtobuf error = %v, want failed to parse JSON", err)
+	}
+	if _, err := ProtobufToJSON([]byte{0x0A, 0x10, 'P'}, schema); err == nil || !strings.Contains(err.Error(), "failed to parse Protobuf") {
+		t.Fatalf("ProtobufToJSON error = %v, want failed to parse Protobuf", err)
+	}
+}
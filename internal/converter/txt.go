@@ -0,0 +1,30 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TXTToJSON оборачивает текст в JSON объект вида {"text": "..."}
+func TXTToJSON(txtData []byte) ([]byte, error) {
+	return json.Marshal(map[string]string{"text": string(txtData)})
+}
+
+// JSONToTXT извлекает текст из JSON.
+// Строка и объект {"text": "..."} выводятся как есть, остальное — как отформатированный JSON.
+func JSONToTXT(jsonData []byte) ([]byte, error) {
+	var jsonObj interface{}
+	if err := json.Unmarshal(jsonData, &jsonObj); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	switch v := jsonObj.(type) {
+	case string:
+		return []byte(v), nil
+	case map[string]interface{}:
+		if text, ok := v["text"].(string); ok && len(v) == 1 {
+			return []byte(text), nil
+		}
+	}
+	return json.MarshalIndent(jsonObj, "", "  ")
+}
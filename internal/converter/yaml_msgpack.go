@@ -0,0 +1,129 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLToJSON конвертирует YAML в JSON
+func YAMLToJSON(yamlData []byte) ([]byte, error) {
+	var yamlObj interface{}
+	if err := yaml.Unmarshal(yamlData, &yamlObj); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	output, err := json.MarshalIndent(normalizeKeys(yamlObj), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return output, nil
+}
+
+// JSONToYAML конвертирует JSON в YAML
+func JSONToYAML(jsonData []byte) ([]byte, error) {
+	jsonObj, err := decodeJSONNumbers(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := yaml.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	return output, nil
+}
+
+// MessagePackToJSON конвертирует MessagePack в JSON
+func MessagePackToJSON(msgpackData []byte) ([]byte, error) {
+	// По умолчанию msgpack разбирает только map со строковыми ключами;
+	// ключи других типов приводятся к строкам в normalizeKeys
+	decoder := msgpack.NewDecoder(bytes.NewReader(msgpackData))
+	decoder.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+
+	var msgpackObj interface{}
+	if err := decoder.Decode(&msgpackObj); err != nil {
+		return nil, fmt.Errorf("failed to parse MessagePack: %w", err)
+	}
+
+	output, err := json.MarshalIndent(normalizeKeys(msgpackObj), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return output, nil
+}
+
+// JSONToMessagePack конвертирует JSON в MessagePack
+func JSONToMessagePack(jsonData []byte) ([]byte, error) {
+	jsonObj, err := decodeJSONNumbers(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := msgpack.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal MessagePack: %w", err)
+	}
+	return output, nil
+}
+
+// decodeJSONNumbers разбирает JSON, сохраняя целые числа целыми (int64),
+// чтобы YAML и MessagePack не получали их как float
+func decodeJSONNumbers(jsonData []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+
+	var jsonObj interface{}
+	if err := decoder.Decode(&jsonObj); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return convertNumbers(jsonObj), nil
+}
+
+func convertNumbers(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = convertNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	}
+	return obj
+}
+
+// normalizeKeys приводит ключи map[interface{}]interface{} к строкам для JSON
+func normalizeKeys(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[fmt.Sprintf("%v", key)] = normalizeKeys(value)
+		}
+		return result
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeKeys(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeKeys(value)
+		}
+	}
+	return obj
+}
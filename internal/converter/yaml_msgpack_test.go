@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// bigInt больше 2^53: при разборе в float64 последняя цифра теряется
+const bigInt = 9007199254740993
+
+func TestDecodeJSONNumbers(t *testing.T) {
+	obj, err := decodeJSONNumbers([]byte(`{"id":9007199254740993,"price":12.5,"huge":1e400,"items":[{"qty":3}],"name":"A"}`))
+	if err != nil {
+		t.Fatalf("decodeJSONNumbers: %v", err)
+	}
+	doc := obj.(map[string]interface{})
+	if doc["id"] != int64(bigInt) {
+		t.Fatalf("id = %#v, want int64 %d", doc["id"], int64(bigInt))
+	}
+	if doc["price"] != 12.5 {
+		t.Fatalf("price = %#v, want float64 12.5", doc["price"])
+	}
+	// Число вне диапазона float64 сохраняется строкой
+	if doc["huge"] != "1e400" {
+		t.Fatalf("huge = %#v, want string 1e400", doc["huge"])
+	}
+	if qty := doc["items"].([]interface{})[0].(map[string]interface{})["qty"]; qty != int64(3) {
+		t.Fatalf("items[0].qty = %#v, want int64 3", qty)
+	}
+	if doc["name"] != "A" {
+		t.Fatalf("name = %#v, want A", doc["name"])
+	}
+
+	if _, err := decodeJSONNumbers([]byte(`{"id":`)); err == nil || !strings.Contains(err.Error(), "failed to parse JSON") {
+		t.Fatalf("decodeJSONNumbers error = %v, want failed to parse JSON", err)
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	input := `{"id":9007199254740993,"price":12.5,"tags":["a","b"],"active":true}`
+
+	data, err := JSONToYAML([]byte(input))
+	if err != nil {
+		t.Fatalf("JSONToYAML: %v", err)
+	}
+	if !strings.Contains(string(data), "id: 9007199254740993\n") {
+		t.Fatalf("JSONToYAML lost int64 precision:\n%s", data)
+	}
+
+	output, err := YAMLToJSON(data)
+	if err != nil {
+		t.Fatalf("YAMLToJSON: %v", err)
+	}
+	assertJSONEqual(t, output, input)
+}
+
+func TestYAMLToJSONNonStringKeys(t *testing.T) {
+	output, err := YAMLToJSON([]byte("codes:\n  1: one\n  true: yes\n"))
+	if err != nil {
+		t.Fatalf("YAMLToJSON: %v", err)
+	}
+	assertJSONEqual(t, output, `{"codes":{"1":"one","true":"yes"}}`)
+
+	if _, err := YAMLToJSON([]byte("a: [1, 2")); err == nil || !strings.Contains(err.Error(), "failed to parse YAML") {
+		t.Fatalf("YAMLToJSON error = %v, want failed to parse YAML", err)
+	}
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	input := `{"id":9007199254740993,"price":12.5,"lines":[{"sku":"A-1","qty":2}],"note":null}`
+
+	data, err := JSONToMessagePack([]byte(input))
+	if err != nil {
+		t.Fatalf("JSONToMessagePack: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := msgpack.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("msgpack.Unmarshal: %v", err)
+	}
+	if decoded["id"] != int64(bigInt) {
+		t.Fatalf("id = %#v, want int64 %d", decoded["id"], int64(bigInt))
+	}
+	if decoded["price"] != 12.5 {
+		t.Fatalf("price = %#v, want float64 12.5", decoded["price"])
+	}
+
+	output, err := MessagePackToJSON(data)
+	if err != nil {
+		t.Fatalf("MessagePackToJSON: %v", err)
+	}
+	assertJSONEqual(t, output, input)
+
+	if _, err := MessagePackToJSON([]byte{0xC1}); err == nil || !strings.Contains(err.Error(), "failed to parse MessagePack") {
+		t.Fatalf("MessagePackToJSON error = %v, want failed to parse MessagePack", err)
+	}
+}
+
+func TestMessagePackToJSONIntegerKeys(t *testing.T) {
+	data, err := msgpack.Marshal(map[interface{}]interface{}{1: "one", "nested": map[interface{}]interface{}{int64(2): "two"}})
+	if err != nil {
+		t.Fatalf("msgpack.Marshal: %v", err)
+	}
+	output, err := MessagePackToJSON(data)
+	if err != nil {
+		t.Fatalf("MessagePackToJSON: %v", err)
+	}
+	assertJSONEqual(t, output, `{"1":"one","nested":{"2":"two"}}`)
+}
+
+// assertJSONEqual сравнивает JSON документы без учета форматирования,
+// сохраняя большие целые числа без округления
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	gotObj, err := decodeJSONNumbers(got)
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	wantObj, err := decodeJSONNumbers([]byte(want))
+	if err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	gotYAML, _ := yaml.Marshal(gotObj)
+	wantYAML, _ := yaml.Marshal(wantObj)
+	if string(gotYAML) != string(wantYAML) {
+		gotJSON, _ := json.Marshal(gotObj)
+		t.Fatalf("JSON = %s, want %s", gotJSON, want)
+	}
+}
@@ -1,19 +1,23 @@
 package database
 
 import (
-	"log"
+	"os"
 
 	"go-esb/internal/config"
+	"go-esb/internal/logging"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
+var logger = logging.For("database")
+
 func Connect(cfg *config.Config) *sqlx.DB {
 	db, err := sqlx.Connect("postgres", cfg.DSN())
 	if err != nil {
-		log.Fatalf("❌ Database connection failed: %v", err)
+		logger.Error("❌ Database connection failed", "error", err)
+		os.Exit(1)
 	}
-	log.Println("✅ Database connected")
+	logger.Info("✅ Database connected")
 	return db
 }
@@ -1,7 +1,6 @@
 package database
 
 import (
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,8 +14,7 @@ func RunMigrations(db *sqlx.DB) {
 	// Читаем файлы миграций из директории
 	migrationFiles, err := os.ReadDir(migrationDir)
 	if err != nil {
-		log.Printf("⚠️ Warning: Could not read migrations directory: %v", err)
-		log.Println("⚠️ Skipping migrations - make sure database schema is up to date")
+		logger.Warn("⚠️ Could not read migrations directory, skipping migrations - make sure database schema is up to date", "error", err)
 		return
 	}
 
@@ -29,11 +27,11 @@ func RunMigrations(db *sqlx.DB) {
 		migrationPath := filepath.Join(migrationDir, file.Name())
 		content, err := os.ReadFile(migrationPath)
 		if err != nil {
-			log.Printf("⚠️ Could not read migration file %s: %v", file.Name(), err)
+			logger.Warn("⚠️ Could not read migration file", "migration", file.Name(), "error", err)
 			continue
 		}
 
-		log.Printf("📝 Running migration: %s", file.Name())
+		logger.Info("📝 Running migration", "migration", file.Name())
 		
 		// Выполняем миграцию
 		if _, err := db.Exec(string(content)); err != nil {
@@ -42,12 +40,12 @@ func RunMigrations(db *sqlx.DB) {
 			if strings.Contains(errStr, "already exists") || 
 			   strings.Contains(errStr, "duplicate key") ||
 			   strings.Contains(errStr, "already in") {
-				log.Printf("ℹ️ Migration %s: objects already exist (skipped)", file.Name())
+				logger.Info("ℹ️ Migration objects already exist (skipped)", "migration", file.Name())
 			} else {
-				log.Printf("⚠️ Warning: Migration %s returned error: %v", file.Name(), err)
+				logger.Warn("⚠️ Migration returned error", "migration", file.Name(), "error", err)
 			}
 		}
 	}
 
-	log.Println("✅ Migrations check completed")
+	logger.Info("✅ Migrations check completed")
 }
@@ -0,0 +1,212 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix помечает зашифрованные значения: enc:v1:<key id>:<wrapped DEK>:<ciphertext>.
+// Значения без префикса считаются открытыми (записи до включения шифрования).
+const prefix = "enc:v1:"
+
+// ErrUnknownKey значение зашифровано мастер-ключом, которого нет в Keyring
+var ErrUnknownKey = errors.New("value is encrypted with unknown master key")
+
+// Keyring набор мастер-ключей. Новые значения шифруются основным ключом,
+// расшифровка выполняется любым известным ключом (для ротации).
+//
+// Используется конвертное шифрование: каждое значение шифруется собственным
+// ключом данных (DEK, AES-256-GCM), а DEK шифруется мастер-ключом.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring создает набор ключей. primary — основной мастер-ключ (32 байта),
+// previous — прежние ключи, которые используются только для расшифровки.
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range append([][]byte{primary}, previous...) {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+		}
+		id := KeyID(key)
+		if i == 0 {
+			k.primary = id
+		}
+		if _, ok := k.keys[id]; !ok {
+			k.keys[id] = key
+		}
+	}
+	return k, nil
+}
+
+// KeyID идентификатор мастер-ключа: первые 8 байт SHA-256 в hex
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PrimaryKeyID возвращает идентификатор основного мастер-ключа
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// IsEncrypted проверяет, что значение зашифровано
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// NeedsRotation проверяет, что значение открыто или зашифровано не основным ключом
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, prefix+k.primary+":")
+}
+
+// Encrypt шифрует значение основным мастер-ключом. Пустая строка не шифруется.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := seal(k.keys[k.primary], dek)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	ciphertext, err := seal(dek, []byte(plaintext))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt value: %w", err)
+	}
+
+	return prefix + k.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt расшифровывает значение. Открытые значения возвращаются как есть.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	master, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed data key: %w", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %w", err)
+	}
+
+	dek, err := open(master, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := open(dek, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// seal шифрует AES-256-GCM, nonce записывается перед шифротекстом
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ParseKey разбирает мастер-ключ: 32 байта в base64 или hex
+func ParseKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be 32 bytes encoded as base64 or hex")
+}
+
+// LoadKey читает мастер-ключ из значения или файла. Возвращает nil, если оба пусты.
+func LoadKey(value, file string) ([]byte, error) {
+	if value == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		value = string(data)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return ParseKey(value)
+}
+
+// LoadKeyring создает Keyring из основного и прежнего мастер-ключей (значение или файл).
+// Возвращает nil без ошибки, если основной ключ не задан.
+func LoadKeyring(key, keyFile, previousKey, previousKeyFile string) (*Keyring, error) {
+	primary, err := LoadKey(key, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if primary == nil {
+		return nil, nil
+	}
+	previous, err := LoadKey(previousKey, previousKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("previous key: %w", err)
+	}
+	if previous == nil {
+		return NewKeyring(primary)
+	}
+	return NewKeyring(primary, previous)
+}
@@ -0,0 +1,205 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func testKeyring(t *testing.T, primary []byte, previous ...[]byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(primary, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	k := testKeyring(t, testKey(1))
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"empty", ""},
+		{"ascii", "client-secret"},
+		{"unicode", "пароль 🔑"},
+		{"colons", "a:b:c:enc:v1:"},
+		{"long", strings.Repeat("x", 64*1024)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := k.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if tt.plaintext == "" {
+				if encrypted != "" {
+					t.Fatalf("empty value encrypted to %q", encrypted)
+				}
+				return
+			}
+			if !IsEncrypted(encrypted) {
+				t.Fatalf("value %q has no encryption prefix", encrypted)
+			}
+			if strings.Contains(encrypted, tt.plaintext) {
+				t.Fatal("encrypted value contains plaintext")
+			}
+			decrypted, err := k.Decrypt(encrypted)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if decrypted != tt.plaintext {
+				t.Fatalf("Decrypt = %q, want %q", decrypted, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestEncryptUsesFreshDataKey(t *testing.T) {
+	k := testKeyring(t, testKey(1))
+	first, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("same plaintext encrypted to the same value")
+	}
+}
+
+func TestDecryptPlaintextPassthrough(t *testing.T) {
+	k := testKeyring(t, testKey(1))
+	got, err := k.Decrypt("legacy-password")
+	if err != nil || got != "legacy-password" {
+		t.Fatalf("Decrypt = %q, %v; want plaintext as is", got, err)
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	k := testKeyring(t, testKey(1))
+	encrypted, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(encrypted, prefix), ":")
+	keyID, wrapped, ciphertext := parts[0], parts[1], parts[2]
+
+	// flip меняет один байт значения base64
+	flip := func(encoded string, i int) string {
+		data, err := base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[i] ^= 0xFF
+		return base64.RawStdEncoding.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr error
+	}{
+		{"ciphertext byte", prefix + keyID + ":" + wrapped + ":" + flip(ciphertext, len(ciphertext)/2), nil},
+		{"ciphertext nonce", prefix + keyID + ":" + wrapped + ":" + flip(ciphertext, 0), nil},
+		{"wrapped data key", prefix + keyID + ":" + flip(wrapped, 20) + ":" + ciphertext, nil},
+		{"swapped parts", prefix + keyID + ":" + ciphertext + ":" + wrapped, nil},
+		{"truncated ciphertext", prefix + keyID + ":" + wrapped + ":" + ciphertext[:8], nil},
+		{"unknown key id", prefix + KeyID(testKey(2)) + ":" + wrapped + ":" + ciphertext, ErrUnknownKey},
+		{"missing part", prefix + keyID + ":" + wrapped, nil},
+		{"extra part", encrypted + ":x", nil},
+		{"bad base64", prefix + keyID + ":" + wrapped + ":!!!", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Decrypt(tt.value)
+			if err == nil {
+				t.Fatalf("Decrypt = %q, want error", got)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decrypt error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, newKey := testKey(1), testKey(2)
+	encrypted, err := testKeyring(t, oldKey).Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		keyring      *Keyring
+		wantErr      bool
+		needRotation bool
+	}{
+		{"same primary key", testKeyring(t, oldKey), false, false},
+		{"old key as previous", testKeyring(t, newKey, oldKey), false, true},
+		{"old key removed", testKeyring(t, newKey), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(encrypted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != "secret" {
+				t.Fatalf("Decrypt = %q, want secret", got)
+			}
+			if rotate := tt.keyring.NeedsRotation(encrypted); rotate != tt.needRotation {
+				t.Fatalf("NeedsRotation = %v, want %v", rotate, tt.needRotation)
+			}
+		})
+	}
+}
+
+func TestNewKeyringRejectsShortKey(t *testing.T) {
+	if _, err := NewKeyring(make([]byte, 16)); err == nil {
+		t.Fatal("NewKeyring accepted a 16 byte key")
+	}
+	if _, err := NewKeyring(testKey(1), make([]byte, 31)); err == nil {
+		t.Fatal("NewKeyring accepted a 31 byte previous key")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := testKey(7)
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"base64", base64.StdEncoding.EncodeToString(key), false},
+		{"hex", hex.EncodeToString(key), false},
+		{"surrounding whitespace", " " + hex.EncodeToString(key) + "\n", false},
+		{"short base64", base64.StdEncoding.EncodeToString(key[:16]), true},
+		{"short hex", hex.EncodeToString(key[:31]), true},
+		{"not encoded", "not-a-key", true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, key) {
+				t.Fatalf("ParseKey = %x, want %x", got, key)
+			}
+		})
+	}
+}
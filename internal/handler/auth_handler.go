@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+// Login выдает токен доступа по логину и паролю
+func (h *HTTPHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.authService.Login(r.Context(), credentials.Username, credentials.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		logger.WarnContext(r.Context(), "🚫 Failed login", "user", credentials.Username)
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "❌ Login failed", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, token)
+}
+
+// CurrentUser возвращает пользователя запроса и его роли
+func (h *HTTPHandler) CurrentUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, auth.PrincipalFromContext(r.Context()))
+}
+
+// GetUsers возвращает пользователей HTTP API
+func (h *HTTPHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.authService.GetUsers(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// CreateUser создает пользователя с ролью
+func (h *HTTPHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string          `json:"username"`
+		Password string          `json:"password"`
+		Role     models.UserRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.authService.CreateUser(r.Context(), request.Username, request.Password, request.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// UpdateUser меняет пароль и/или роль пользователя
+func (h *HTTPHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var update service.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.UpdateUser(r.Context(), mux.Vars(r)["userId"], update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser удаляет пользователя вместе с его API ключами
+func (h *HTTPHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.authService.DeleteUser(r.Context(), mux.Vars(r)["userId"]); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserThreadGroups возвращает роли пользователя в группах threads
+func (h *HTTPHandler) GetUserThreadGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.authService.GetUserThreadGroups(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// SetUserThreadGroups заменяет роли пользователя в группах threads
+func (h *HTTPHandler) SetUserThreadGroups(w http.ResponseWriter, r *http.Request) {
+	var groups []models.UserThreadGroup
+	if err := json.NewDecoder(r.Body).Decode(&groups); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.SetUserThreadGroups(r.Context(), mux.Vars(r)["userId"], groups); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAPIKeys возвращает API ключи пользователя (без самих ключей)
+func (h *HTTPHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.authService.GetAPIKeys(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// CreateAPIKey создает API ключ пользователя. Ключ возвращается только в этом ответе.
+func (h *HTTPHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name      string     `json:"name"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.authService.CreateAPIKey(r.Context(), mux.Vars(r)["userId"], request.Name, request.ExpiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// DeleteAPIKey отзывает API ключ
+func (h *HTTPHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.authService.DeleteAPIKey(r.Context(), mux.Vars(r)["keyId"]); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
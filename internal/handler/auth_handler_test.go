@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-esb/internal/models"
+)
+
+func TestStripeWebhookRequiresCredentials(t *testing.T) {
+	authService := newFakeAuthService()
+	authService.addUser("viewer", "secret", models.RoleReadOnly, nil)
+	h := &HTTPHandler{authService: authService}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"without credentials", "", http.StatusUnauthorized},
+		{"without send permission", "viewer", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/stripe", strings.NewReader(`{"id":"evt_1"}`))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.SetupRoutes().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	commerceMLCookieName = "esb_1c_session"
+	commerceMLSessionTTL = time.Hour
+	// commerceMLIdleTimeout время, через которое удаляется сессия без запросов
+	commerceMLIdleTimeout = 10 * time.Minute
+	// commerceMLSweepInterval период удаления просроченных сессий
+	commerceMLSweepInterval = time.Minute
+	// commerceMLMaxFiles число файлов, загруженных за сессию и еще не импортированных
+	commerceMLMaxFiles = 1000
+)
+
+// CommerceMLExchange реализует HTTP протокол обмена 1С:Предприятие
+// (mode=checkauth/init/file/import). Загруженные файлы CommerceML
+// передаются в thread как входящие сообщения. 1С входит пользователем ESB
+// (или API ключом вместо пароля) с правом отправки в группе thread.
+type CommerceMLExchange struct {
+	messageService service.MessageService
+	authService    service.AuthService
+	fileLimit      int64
+	sessionLimit   int64
+
+	mu       sync.Mutex
+	sessions map[string]*commerceMLSession
+	now      func() time.Time
+}
+
+type commerceMLSession struct {
+	// threadID thread, для которого открыта сессия
+	threadID  string
+	expiresAt time.Time
+	lastUsed  time.Time
+	files     map[string][]byte
+	// size объем загруженных и еще не импортированных файлов
+	size int64
+}
+
+// expired проверяет, что сессия истекла или простаивает дольше commerceMLIdleTimeout
+func (s *commerceMLSession) expired(now time.Time) bool {
+	return now.After(s.expiresAt) || now.Sub(s.lastUsed) > commerceMLIdleTimeout
+}
+
+// NewCommerceMLExchange создает обработчик обмена с 1С. fileLimit — размер
+// одного файла, sessionLimit — объем файлов, которые сессия хранит до импорта.
+func NewCommerceMLExchange(messageService service.MessageService, authService service.AuthService, fileLimit, sessionLimit int64) *CommerceMLExchange {
+	return &CommerceMLExchange{
+		messageService: messageService,
+		authService:    authService,
+		fileLimit:      fileLimit,
+		sessionLimit:   sessionLimit,
+		sessions:       make(map[string]*commerceMLSession),
+		now:            time.Now,
+	}
+}
+
+// Run удаляет просроченные и простаивающие сессии с загруженными файлами
+// до отмены контекста
+func (e *CommerceMLExchange) Run(ctx context.Context) {
+	ticker := time.NewTicker(commerceMLSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		e.mu.Lock()
+		removed := e.cleanupLocked()
+		e.mu.Unlock()
+		if removed > 0 {
+			logger.DebugContext(ctx, "🧹 1C exchange: removed expired sessions", "count", removed)
+		}
+	}
+}
+
+// ServeHTTP обрабатывает запросы 1С вида ?type=catalog&mode=...
+func (e *CommerceMLExchange) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "checkauth" {
+		e.checkAuth(w, r)
+		return
+	}
+
+	session := e.session(r)
+	if session == nil {
+		fmt.Fprint(w, "failure\nnot authorized")
+		return
+	}
+
+	switch mode {
+	case "init":
+		fmt.Fprintf(w, "zip=no\nfile_limit=%d\n", e.fileLimit)
+	case "file":
+		e.uploadFile(w, r, session)
+	case "import":
+		e.importFile(w, r, session)
+	default:
+		fmt.Fprintf(w, "failure\nunsupported mode: %s", mode)
+	}
+}
+
+// checkAuth проверяет Basic авторизацию и право отправки в thread
+// и открывает сессию обмена
+func (e *CommerceMLExchange) checkAuth(w http.ResponseWriter, r *http.Request) {
+	threadID := mux.Vars(r)["threadId"]
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		fmt.Fprint(w, "failure\ninvalid credentials")
+		return
+	}
+	principal, err := e.authenticate(r.Context(), user, password)
+	if err != nil {
+		logger.WarnContext(r.Context(), "⚠️ 1C exchange: authorization failed", "user", user, "error", err)
+		fmt.Fprint(w, "failure\ninvalid credentials")
+		return
+	}
+	if !principal.Can(auth.PermSend) {
+		groupID, err := e.authService.ThreadGroup(r.Context(), threadID)
+		if err != nil || !principal.CanInGroup(groupID, auth.PermSend) {
+			logger.WarnContext(r.Context(), "⚠️ 1C exchange: access denied", "user", principal.Username, "thread_id", threadID)
+			fmt.Fprint(w, "failure\naccess denied")
+			return
+		}
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		fmt.Fprint(w, "failure\nfailed to create session")
+		return
+	}
+	sessionID := hex.EncodeToString(buf)
+
+	now := e.now()
+	e.mu.Lock()
+	e.cleanupLocked()
+	e.sessions[sessionID] = &commerceMLSession{
+		threadID:  threadID,
+		expiresAt: now.Add(commerceMLSessionTTL),
+		lastUsed:  now,
+		files:     make(map[string][]byte),
+	}
+	e.mu.Unlock()
+
+	fmt.Fprintf(w, "success\n%s\n%s\n", commerceMLCookieName, sessionID)
+}
+
+// authenticate проверяет логин и пароль пользователя ESB; API ключ
+// передается вместо пароля с произвольным логином
+func (e *CommerceMLExchange) authenticate(ctx context.Context, user, password string) (*auth.Principal, error) {
+	if auth.IsAPIKey(password) {
+		return e.authService.AuthenticateAPIKey(ctx, password)
+	}
+	token, err := e.authService.Login(ctx, user, password)
+	if err != nil {
+		return nil, err
+	}
+	return e.authService.AuthenticateToken(ctx, token.AccessToken)
+}
+
+// session возвращает сессию по cookie или nil. Сессия действует
+// только для thread, в котором открыта.
+func (e *CommerceMLExchange) session(r *http.Request) *commerceMLSession {
+	cookie, err := r.Cookie(commerceMLCookieName)
+	if err != nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	session, ok := e.sessions[cookie.Value]
+	if !ok || session.expired(now) || session.threadID != mux.Vars(r)["threadId"] {
+		return nil
+	}
+	session.lastUsed = now
+	return session
+}
+
+// uploadFile принимает файл (или его часть) от 1С
+func (e *CommerceMLExchange) uploadFile(w http.ResponseWriter, r *http.Request, session *commerceMLSession) {
+	filename := path.Clean("/" + r.URL.Query().Get("filename"))
+	if filename == "/" {
+		fmt.Fprint(w, "failure\nfilename is required")
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, e.fileLimit+1))
+	if err != nil {
+		fmt.Fprintf(w, "failure\nfailed to read file: %v", err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Большие файлы 1С присылает частями, их нужно склеить
+	previous, exists := session.files[filename]
+	content := append(previous, data...)
+	if int64(len(content)) > e.fileLimit {
+		session.drop(filename)
+		fmt.Fprint(w, "failure\nfile limit exceeded")
+		return
+	}
+	if !exists && len(session.files) >= commerceMLMaxFiles {
+		fmt.Fprint(w, "failure\ntoo many files in session")
+		return
+	}
+	if session.size+int64(len(data)) > e.sessionLimit {
+		session.drop(filename)
+		fmt.Fprint(w, "failure\nsession limit exceeded")
+		return
+	}
+	session.files[filename] = content
+	session.size += int64(len(data))
+	fmt.Fprint(w, "success")
+}
+
+// importFile передает загруженный файл CommerceML в thread. Файл
+// удаляется из сессии только после успешной обработки: при ошибке 1С
+// может повторить импорт без повторной загрузки.
+func (e *CommerceMLExchange) importFile(w http.ResponseWriter, r *http.Request, session *commerceMLSession) {
+	filename := path.Clean("/" + r.URL.Query().Get("filename"))
+
+	e.mu.Lock()
+	data, ok := session.files[filename]
+	// Изображения и прочие файлы каталога не импортируются
+	if !strings.EqualFold(path.Ext(filename), ".xml") {
+		session.drop(filename)
+		e.mu.Unlock()
+		fmt.Fprint(w, "success")
+		return
+	}
+	e.mu.Unlock()
+
+	if !ok {
+		fmt.Fprintf(w, "failure\nfile not found: %s", filename)
+		return
+	}
+
+	threadID := mux.Vars(r)["threadId"]
+	msg := &models.Message{Data: data, Format: models.FileFormatCommerceML}
+	if _, err := e.messageService.ProcessMessage(r.Context(), threadID, models.DirectionIn, msg); err != nil {
+		logger.ErrorContext(r.Context(), "❌ 1C exchange: failed to import", "file", filename, "error", err)
+		fmt.Fprintf(w, "failure\n%v", err)
+		return
+	}
+
+	e.mu.Lock()
+	session.drop(filename)
+	e.mu.Unlock()
+
+	logger.InfoContext(r.Context(), "✅ 1C exchange: imported", "file", filename, "thread_id", threadID)
+	fmt.Fprint(w, "success")
+}
+
+// drop удаляет файл сессии и освобождает его объем
+func (s *commerceMLSession) drop(filename string) {
+	s.size -= int64(len(s.files[filename]))
+	delete(s.files, filename)
+}
+
+// cleanupLocked удаляет просроченные и простаивающие сессии
+func (e *CommerceMLExchange) cleanupLocked() int {
+	now := e.now()
+	removed := 0
+	for id, session := range e.sessions {
+		if session.expired(now) {
+			delete(e.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
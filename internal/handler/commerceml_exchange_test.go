@@ -0,0 +1,369 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// fakeAuthService хранит пользователей, API ключи и группы threads в памяти
+type fakeAuthService struct {
+	service.AuthService
+	passwords map[string]string
+	users     map[string]*auth.Principal
+	apiKeys   map[string]*auth.Principal
+	groups    map[string]uuid.UUID
+}
+
+func newFakeAuthService() *fakeAuthService {
+	return &fakeAuthService{
+		passwords: make(map[string]string),
+		users:     make(map[string]*auth.Principal),
+		apiKeys:   make(map[string]*auth.Principal),
+		groups:    make(map[string]uuid.UUID),
+	}
+}
+
+func (s *fakeAuthService) addUser(username, password string, role models.UserRole, groupRoles map[uuid.UUID]models.UserRole) *auth.Principal {
+	principal := &auth.Principal{UserID: uuid.New(), Username: username, Role: role, GroupRoles: groupRoles}
+	s.passwords[username] = password
+	s.users[username] = principal
+	return principal
+}
+
+func (s *fakeAuthService) Login(_ context.Context, username, password string) (*service.Token, error) {
+	if expected, ok := s.passwords[username]; !ok || expected != password {
+		return nil, errors.New("invalid credentials")
+	}
+	// Токеном служит имя пользователя
+	return &service.Token{AccessToken: username}, nil
+}
+
+func (s *fakeAuthService) AuthenticateToken(_ context.Context, token string) (*auth.Principal, error) {
+	principal, ok := s.users[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return principal, nil
+}
+
+func (s *fakeAuthService) AuthenticateAPIKey(_ context.Context, key string) (*auth.Principal, error) {
+	principal, ok := s.apiKeys[key]
+	if !ok {
+		return nil, errors.New("invalid API key")
+	}
+	return principal, nil
+}
+
+func (s *fakeAuthService) ThreadGroup(_ context.Context, threadID string) (uuid.UUID, error) {
+	groupID, ok := s.groups[threadID]
+	if !ok {
+		return uuid.Nil, errors.New("thread not found")
+	}
+	return groupID, nil
+}
+
+// exchangeClient выполняет запросы 1С к обработчику обмена
+type exchangeClient struct {
+	t      *testing.T
+	router *mux.Router
+	cookie string
+}
+
+func newExchangeClient(t *testing.T, exchange *CommerceMLExchange) *exchangeClient {
+	router := mux.NewRouter()
+	router.Handle("/exchange/1c/{threadId}", exchange)
+	return &exchangeClient{t: t, router: router}
+}
+
+func (c *exchangeClient) do(method, threadID, query, body string, setup func(*http.Request)) string {
+	c.t.Helper()
+	req := httptest.NewRequest(method, "/exchange/1c/"+threadID+"?type=catalog&"+query, strings.NewReader(body))
+	if c.cookie != "" {
+		req.AddCookie(&http.Cookie{Name: commerceMLCookieName, Value: c.cookie})
+	}
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+	data, _ := io.ReadAll(rec.Body)
+	return string(data)
+}
+
+// checkAuth открывает сессию и запоминает cookie при успехе
+func (c *exchangeClient) checkAuth(threadID, user, password string) string {
+	c.t.Helper()
+	response := c.do(http.MethodGet, threadID, "mode=checkauth", "", func(r *http.Request) {
+		r.SetBasicAuth(user, password)
+	})
+	if lines := strings.Split(response, "\n"); lines[0] == "success" && len(lines) > 2 {
+		c.cookie = lines[2]
+	}
+	return response
+}
+
+func TestCommerceMLCheckAuthThreadGroupScope(t *testing.T) {
+	authService := newFakeAuthService()
+	orders, other := uuid.New().String(), uuid.New().String()
+	ordersGroup := uuid.New()
+	authService.groups[orders] = ordersGroup
+	authService.groups[other] = uuid.New()
+	authService.addUser("operator", "secret", models.RoleOperator, nil)
+	authService.addUser("shop", "secret", models.RoleReadOnly, map[uuid.UUID]models.UserRole{ordersGroup: models.RoleOperator})
+	authService.addUser("viewer", "secret", models.RoleReadOnly, nil)
+	authService.apiKeys[auth.APIKeyPrefix+"1c"] = &auth.Principal{Username: "1c", Role: models.RoleReadOnly,
+		GroupRoles: map[uuid.UUID]models.UserRole{ordersGroup: models.RoleOperator}}
+
+	tests := []struct {
+		name     string
+		thread   string
+		user     string
+		password string
+		want     string
+	}{
+		{"operator role", other, "operator", "secret", "success"},
+		{"operator in thread group", orders, "shop", "secret", "success"},
+		{"operator in another group", other, "shop", "secret", "failure\naccess denied"},
+		{"read only", orders, "viewer", "secret", "failure\naccess denied"},
+		{"unknown thread", uuid.New().String(), "shop", "secret", "failure\naccess denied"},
+		{"wrong password", orders, "shop", "wrong", "failure\ninvalid credentials"},
+		{"API key as password", orders, "1c", auth.APIKeyPrefix + "1c", "success"},
+		{"API key in another group", other, "1c", auth.APIKeyPrefix + "1c", "failure\naccess denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newExchangeClient(t, NewCommerceMLExchange(nil, authService, 1024, 4096))
+			if got := client.checkAuth(tt.thread, tt.user, tt.password); !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("checkauth = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommerceMLSessionBoundToThread(t *testing.T) {
+	authService := newFakeAuthService()
+	orders, other := uuid.New().String(), uuid.New().String()
+	authService.groups[orders] = uuid.New()
+	authService.groups[other] = uuid.New()
+	authService.addUser("operator", "secret", models.RoleOperator, nil)
+
+	client := newExchangeClient(t, NewCommerceMLExchange(nil, authService, 1024, 4096))
+	if got := client.checkAuth(orders, "operator", "secret"); !strings.HasPrefix(got, "success") {
+		t.Fatalf("checkauth = %q", got)
+	}
+	if got := client.do(http.MethodGet, orders, "mode=init", "", nil); !strings.HasPrefix(got, "zip=no") {
+		t.Fatalf("init in session thread = %q", got)
+	}
+	if got := client.do(http.MethodGet, other, "mode=init", "", nil); got != "failure\nnot authorized" {
+		t.Fatalf("init in another thread = %q, want not authorized", got)
+	}
+}
+
+// importingMessageService запоминает импортированные файлы
+type importingMessageService struct {
+	service.MessageService
+	imported []string
+	err      error
+}
+
+func (s *importingMessageService) ProcessMessage(_ context.Context, threadID string, direction models.Directions, msg *models.Message) (*models.Response, error) {
+	if direction != models.DirectionIn || msg.Format != models.FileFormatCommerceML {
+		return nil, errors.New("unexpected message")
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.imported = append(s.imported, threadID+":"+string(msg.Data))
+	return &models.Response{}, nil
+}
+
+// newTestExchange создает обмен для thread с пользователем operator и
+// открывает сессию
+func newTestExchange(t *testing.T, fileLimit, sessionLimit int64) (*exchangeClient, *CommerceMLExchange, *importingMessageService, string) {
+	t.Helper()
+	authService := newFakeAuthService()
+	threadID := uuid.New().String()
+	authService.groups[threadID] = uuid.New()
+	authService.addUser("operator", "secret", models.RoleOperator, nil)
+
+	messages := &importingMessageService{}
+	exchange := NewCommerceMLExchange(messages, authService, fileLimit, sessionLimit)
+	client := newExchangeClient(t, exchange)
+	if got := client.checkAuth(threadID, "operator", "secret"); !strings.HasPrefix(got, "success\n"+commerceMLCookieName+"\n") {
+		t.Fatalf("checkauth = %q", got)
+	}
+	return client, exchange, messages, threadID
+}
+
+func (c *exchangeClient) upload(threadID, filename, data string) string {
+	c.t.Helper()
+	return c.do(http.MethodPost, threadID, "mode=file&filename="+filename, data, nil)
+}
+
+func (c *exchangeClient) importFile(threadID, filename string) string {
+	c.t.Helper()
+	return c.do(http.MethodGet, threadID, "mode=import&filename="+filename, "", nil)
+}
+
+func TestCommerceMLExchangeFlow(t *testing.T) {
+	client, _, messages, threadID := newTestExchange(t, 1024, 4096)
+
+	if got := client.do(http.MethodGet, threadID, "mode=init", "", nil); got != "zip=no\nfile_limit=1024\n" {
+		t.Fatalf("init = %q", got)
+	}
+	// Файл приходит двумя частями
+	for _, part := range []string{"<Commerce", "Information/>"} {
+		if got := client.upload(threadID, "import.xml", part); got != "success" {
+			t.Fatalf("file = %q", got)
+		}
+	}
+	if got := client.upload(threadID, "import_files/1.jpg", "jpeg"); got != "success" {
+		t.Fatalf("image file = %q", got)
+	}
+	if got := client.importFile(threadID, "import_files/1.jpg"); got != "success" {
+		t.Fatalf("image import = %q", got)
+	}
+	if got := client.importFile(threadID, "import.xml"); got != "success" {
+		t.Fatalf("import = %q", got)
+	}
+	if len(messages.imported) != 1 || messages.imported[0] != threadID+":<CommerceInformation/>" {
+		t.Fatalf("imported %q, want the joined XML file once", messages.imported)
+	}
+	// Импортированный файл удален из сессии
+	if got := client.importFile(threadID, "import.xml"); got != "failure\nfile not found: /import.xml" {
+		t.Fatalf("second import = %q", got)
+	}
+}
+
+func TestCommerceMLExchangeRequiresSession(t *testing.T) {
+	client, _, _, threadID := newTestExchange(t, 1024, 4096)
+	client.cookie = "unknown"
+	for _, mode := range []string{"init", "file&filename=import.xml", "import&filename=import.xml"} {
+		if got := client.do(http.MethodPost, threadID, "mode="+mode, "<x/>", nil); got != "failure\nnot authorized" {
+			t.Fatalf("mode=%s without session = %q", mode, got)
+		}
+	}
+}
+
+func TestCommerceMLImportFailureKeepsFile(t *testing.T) {
+	client, exchange, messages, threadID := newTestExchange(t, 1024, 4096)
+	client.upload(threadID, "import.xml", "<CommerceInformation/>")
+
+	messages.err = errors.New("thread is disabled")
+	if got := client.importFile(threadID, "import.xml"); got != "failure\nthread is disabled" {
+		t.Fatalf("import = %q", got)
+	}
+	if session := onlySession(t, exchange); session.size != int64(len("<CommerceInformation/>")) {
+		t.Fatalf("session size after failed import = %d, want the file kept", session.size)
+	}
+
+	// Повтор импорта не требует повторной загрузки
+	messages.err = nil
+	if got := client.importFile(threadID, "import.xml"); got != "success" {
+		t.Fatalf("retried import = %q", got)
+	}
+	if session := onlySession(t, exchange); session.size != 0 || len(session.files) != 0 {
+		t.Fatalf("session keeps %d files (%d bytes) after import", len(session.files), session.size)
+	}
+}
+
+func TestCommerceMLUploadLimits(t *testing.T) {
+	client, exchange, _, threadID := newTestExchange(t, 10, 16)
+
+	if got := client.upload(threadID, "a.xml", "12345678901"); got != "failure\nfile limit exceeded" {
+		t.Fatalf("oversized file = %q", got)
+	}
+	if got := client.upload(threadID, "a.xml", "123456"); got != "success" {
+		t.Fatalf("file = %q", got)
+	}
+	// Продолжение файла сверх лимита удаляет и уже загруженную часть
+	if got := client.upload(threadID, "a.xml", "78901"); got != "failure\nfile limit exceeded" {
+		t.Fatalf("oversized continuation = %q", got)
+	}
+	if session := onlySession(t, exchange); session.size != 0 {
+		t.Fatalf("session size = %d after dropped file, want 0", session.size)
+	}
+
+	for _, name := range []string{"a.xml", "b.xml"} {
+		if got := client.upload(threadID, name, "12345678"); got != "success" {
+			t.Fatalf("file %s = %q", name, got)
+		}
+	}
+	if got := client.upload(threadID, "c.xml", "1"); got != "failure\nsession limit exceeded" {
+		t.Fatalf("file over session limit = %q", got)
+	}
+	// Импорт освобождает объем сессии
+	if got := client.importFile(threadID, "a.xml"); got != "success" {
+		t.Fatalf("import = %q", got)
+	}
+	if got := client.upload(threadID, "c.xml", "1"); got != "success" {
+		t.Fatalf("file after import = %q", got)
+	}
+}
+
+func TestCommerceMLSessionExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []time.Duration
+		expired bool
+	}{
+		{"active session", []time.Duration{9 * time.Minute, 9 * time.Minute, 9 * time.Minute}, false},
+		{"idle session", []time.Duration{commerceMLIdleTimeout + time.Second}, true},
+		{"session lifetime", []time.Duration{9 * time.Minute, 9 * time.Minute, 9 * time.Minute, 9 * time.Minute, 9 * time.Minute, 9 * time.Minute, 9 * time.Minute}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			client, exchange, _, threadID := newTestExchange(t, 1024, 4096)
+			exchange.now = func() time.Time { return now }
+			exchange.mu.Lock()
+			for _, session := range exchange.sessions {
+				session.expiresAt, session.lastUsed = now.Add(commerceMLSessionTTL), now
+			}
+			exchange.mu.Unlock()
+
+			got := ""
+			for _, step := range tt.steps {
+				now = now.Add(step)
+				got = client.do(http.MethodGet, threadID, "mode=init", "", nil)
+			}
+			if expired := got == "failure\nnot authorized"; expired != tt.expired {
+				t.Fatalf("init = %q, want expired %v", got, tt.expired)
+			}
+
+			exchange.mu.Lock()
+			removed := exchange.cleanupLocked()
+			exchange.mu.Unlock()
+			if (removed == 1) != tt.expired {
+				t.Fatalf("cleanup removed %d sessions, want expired %v", removed, tt.expired)
+			}
+		})
+	}
+}
+
+// onlySession возвращает единственную сессию обмена
+func onlySession(t *testing.T, exchange *CommerceMLExchange) *commerceMLSession {
+	t.Helper()
+	exchange.mu.Lock()
+	defer exchange.mu.Unlock()
+	if len(exchange.sessions) != 1 {
+		t.Fatalf("%d sessions, want 1", len(exchange.sessions))
+	}
+	for _, session := range exchange.sessions {
+		return session
+	}
+	return nil
+}
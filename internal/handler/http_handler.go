@@ -1,29 +1,71 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"go-esb/internal/auth"
+	"go-esb/internal/balancer"
+	"go-esb/internal/breaker"
+	"go-esb/internal/converter"
+	"go-esb/internal/importer"
+	"go-esb/internal/logging"
+	"go-esb/internal/metrics"
 	"go-esb/internal/middleware"
 	"go-esb/internal/models"
 	"go-esb/internal/service"
+	"go-esb/internal/throttle"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+var logger = logging.For("handler")
+
 // HTTPHandler обрабатывает HTTP запросы
 type HTTPHandler struct {
-	messageService service.MessageService
-	orchestrator   service.Orchestrator
+	messageService     service.MessageService
+	orchestrator       service.Orchestrator
+	threadRouteService service.ThreadRouteService
+	importService      service.ImportService
+	authService        service.AuthService
+	certificateService service.CertificateService
+	idempotencyService service.IdempotencyService
+	breakers           *breaker.Registry
+	endpoints          *balancer.Registry
+	exchange           *CommerceMLExchange
 }
 
 // NewHTTPHandler создает новый HTTP обработчик
-func NewHTTPHandler(messageService service.MessageService, orchestrator service.Orchestrator) *HTTPHandler {
+func NewHTTPHandler(
+	messageService service.MessageService,
+	orchestrator service.Orchestrator,
+	threadRouteService service.ThreadRouteService,
+	importService service.ImportService,
+	authService service.AuthService,
+	certificateService service.CertificateService,
+	idempotencyService service.IdempotencyService,
+	breakers *breaker.Registry,
+	endpoints *balancer.Registry,
+	exchange *CommerceMLExchange,
+) *HTTPHandler {
 	return &HTTPHandler{
-		messageService: messageService,
-		orchestrator:   orchestrator,
+		messageService:     messageService,
+		orchestrator:       orchestrator,
+		threadRouteService: threadRouteService,
+		importService:      importService,
+		authService:        authService,
+		certificateService: certificateService,
+		idempotencyService: idempotencyService,
+		breakers:           breakers,
+		endpoints:          endpoints,
+		exchange:           exchange,
 	}
 }
 
@@ -31,38 +73,184 @@ func NewHTTPHandler(messageService service.MessageService, orchestrator service.
 func (h *HTTPHandler) SetupRoutes() *mux.Router {
 	router := mux.NewRouter()
 
-	// Применяем middleware
+	// Применяем middleware. Logger и Recovery выполняются внутри span запроса:
+	// запись лога запроса содержит trace_id, а паника отмечает span кодом 500.
+	router.Use(middleware.CorrelationID)
+	router.Use(middleware.Tracing)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recovery)
 
-	// Health check
+	// Health check (только общий статус; подробности — в /api/v1/admin/health)
 	router.HandleFunc("/health", h.HealthCheck).Methods("GET")
 
+	// Метрики Prometheus
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
 	// API endpoints
 	api := router.PathPrefix("/api/v1").Subrouter()
 
+	// Вход по логину и паролю (выдает JWT)
+	api.HandleFunc("/auth/login", h.Login).Methods("POST")
+
+	// Обмен с 1С:Предприятие по протоколу CommerceML (Basic аутентификация
+	// пользователем ESB с правом отправки в группе thread)
+	api.Handle("/exchange/1c/{threadId}", h.exchange).Methods("GET", "POST")
+
+	// Остальные endpoints требуют JWT или API ключ
+	secured := api.NewRoute().Subrouter()
+	secured.Use(middleware.Auth(h.authService))
+
+	// Webhook для Stripe (JWT или API ключ с правом отправки)
+	secured.Handle("/webhooks/stripe", permission(auth.PermSend, h.StripeWebhook)).Methods("POST")
+
+	secured.HandleFunc("/auth/me", h.CurrentUser).Methods("GET")
+
 	// Обработка сообщений через thread
-	api.HandleFunc("/messages/process/{threadId}", h.ProcessMessage).Methods("POST")
+	secured.Handle("/messages/process/{threadId}", h.threadPermission(auth.PermSend, h.ProcessMessage)).Methods("POST")
+
+	// Оркестрация бизнес-процессов: право отправки проверяется для каждого
+	// thread процесса с учетом ролей пользователя в группах, как при отправке в thread
+	secured.Handle("/orchestrate/{processName}", groupPermission(auth.PermSend, h.OrchestrateProcess)).Methods("POST")
 
-	// Оркестрация бизнес-процессов
-	api.HandleFunc("/orchestrate/{processName}", h.OrchestrateProcess).Methods("POST")
+	// Загрузка схемы Protobuf (FileDescriptorSet) для маршрута thread
+	secured.Handle("/threads/{threadId}/routes/{routeId}/proto-schema", h.threadPermission(auth.PermConfigure, h.UploadProtoSchema)).Methods("PUT")
 
-	// Webhook для Stripe (специальный endpoint)
-	api.HandleFunc("/webhooks/stripe", h.StripeWebhook).Methods("POST")
+	// Импорт маршрутов и схем сообщений системы из WSDL и OpenAPI
+	secured.Handle("/systems/{systemId}/import/wsdl", permission(auth.PermConfigure, h.ImportWSDL)).Methods("POST")
+	secured.Handle("/systems/{systemId}/import/openapi", permission(auth.PermConfigure, h.ImportOpenAPI)).Methods("POST")
+
+	// Пользователи, их роли в группах threads и API ключи
+	secured.Handle("/users", permission(auth.PermManageUsers, h.GetUsers)).Methods("GET")
+	secured.Handle("/users", permission(auth.PermManageUsers, h.CreateUser)).Methods("POST")
+	secured.Handle("/users/{userId}", permission(auth.PermManageUsers, h.UpdateUser)).Methods("PATCH")
+	secured.Handle("/users/{userId}", permission(auth.PermManageUsers, h.DeleteUser)).Methods("DELETE")
+	secured.Handle("/users/{userId}/thread-groups", permission(auth.PermManageUsers, h.GetUserThreadGroups)).Methods("GET")
+	secured.Handle("/users/{userId}/thread-groups", permission(auth.PermManageUsers, h.SetUserThreadGroups)).Methods("PUT")
+	secured.Handle("/users/{userId}/api-keys", permission(auth.PermManageUsers, h.GetAPIKeys)).Methods("GET")
+	secured.Handle("/users/{userId}/api-keys", permission(auth.PermManageUsers, h.CreateAPIKey)).Methods("POST")
+	secured.Handle("/api-keys/{keyId}", permission(auth.PermManageUsers, h.DeleteAPIKey)).Methods("DELETE")
+
+	// Уровни логирования пакетов (меняются без перезапуска)
+	secured.Handle("/admin/log-levels", permission(auth.PermAdminister, h.GetLogLevels)).Methods("GET")
+	secured.Handle("/admin/log-levels", permission(auth.PermAdminister, h.SetLogLevels)).Methods("PUT")
+
+	// Состояние выключателей, проверок подключений и сертификатов
+	secured.Handle("/admin/health", permission(auth.PermAdminister, h.HealthDetails)).Methods("GET")
 
 	return router
 }
 
-// HealthCheck проверка работоспособности
+// permission оборачивает обработчик проверкой разрешения роли
+func permission(perm auth.Permission, handler http.HandlerFunc) http.Handler {
+	return middleware.RequirePermission(perm)(handler)
+}
+
+// groupPermission оборачивает обработчик проверкой разрешения роли пользователя
+// или его роли хотя бы в одной группе threads
+func groupPermission(perm auth.Permission, handler http.HandlerFunc) http.Handler {
+	return middleware.RequireGroupPermission(perm)(handler)
+}
+
+// threadPermission оборачивает обработчик проверкой разрешения для thread {threadId}
+func (h *HTTPHandler) threadPermission(perm auth.Permission, handler http.HandlerFunc) http.Handler {
+	return middleware.RequireThreadPermission(perm, h.authService.ThreadGroup)(handler)
+}
+
+// HealthCheck проверка работоспособности без аутентификации. Отдает только
+// общий статус: имена систем, адреса подключений и сертификаты не раскрываются.
 func (h *HTTPHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	report := h.health(r.Context())
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "ok",
-		"timestamp": time.Now().Unix(),
-		"service":   "Go ESB",
+		"status":    report["status"],
+		"timestamp": report["timestamp"],
+		"service":   report["service"],
 	})
 }
 
+// HealthDetails проверка работоспособности с состоянием выключателей,
+// активных проверок подключений и сертификатов (для администраторов)
+func (h *HTTPHandler) HealthDetails(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.health(r.Context()))
+}
+
+// health собирает состояние шины. Если выключатель какой-либо системы
+// разомкнут, статус degraded: шина работает, но не отправляет в эту систему.
+func (h *HTTPHandler) health(ctx context.Context) map[string]interface{} {
+	status := "ok"
+	circuitBreakers := h.breakers.Statuses()
+	for _, breakerStatus := range circuitBreakers {
+		if breakerStatus.State != breaker.StateClosed {
+			status = "degraded"
+			break
+		}
+	}
+
+	// Подключение, не прошедшее активную проверку, выведено из ротации
+	endpoints := h.endpoints.Statuses()
+	for _, endpoint := range endpoints {
+		if !endpoint.Healthy {
+			status = "degraded"
+			break
+		}
+	}
+
+	// Истекший или нечитаемый сертификат подключения ломает TLS соединения с системой
+	certificates, err := h.certificateService.Certificates(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "⚠️ Failed to check certificates", "error", err)
+		status = "degraded"
+	}
+	for _, certificate := range certificates {
+		if certificate.Status == service.CertificateExpired || certificate.Status == service.CertificateInvalid {
+			status = "degraded"
+		}
+	}
+
+	return map[string]interface{}{
+		"status":           status,
+		"timestamp":        time.Now().Unix(),
+		"service":          "Go ESB",
+		"circuit_breakers": circuitBreakers,
+		"endpoints":        endpoints,
+		"certificates":     certificates,
+	}
+}
+
+// processingError отвечает ошибкой обработки сообщения. Если выключатель
+// целевой системы разомкнут, возвращается 503, если превышены ограничения
+// исходящих запросов — 429; в обоих случаях с Retry-After, чтобы отправитель
+// повторил сообщение позже. Истекшее время обработки запроса — 504.
+func processingError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	var openErr *breaker.OpenError
+	if errors.As(err, &openErr) {
+		retryAfter(w, openErr.RetryAfter)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var limitErr *throttle.LimitError
+	if errors.As(err, &limitErr) {
+		retryAfter(w, limitErr.RetryAfter)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	var permissionErr *auth.PermissionError
+	if errors.As(err, &permissionErr) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func retryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
 // ProcessMessage обрабатывает сообщение через thread
 func (h *HTTPHandler) ProcessMessage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -73,38 +261,176 @@ func (h *HTTPHandler) ProcessMessage(w http.ResponseWriter, r *http.Request) {
 		direction = "In"
 	}
 
-	var messageData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&messageData); err != nil {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	// Формат определяется по Content-Type, иначе берется из маршрута In thread
+	format := models.FileFormat(converter.FormatFromContentType(r.Header.Get("Content-Type")))
+	if format == models.FileFormatJSON && !json.Valid(data) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	data, err := json.Marshal(messageData)
+	// Повтор сообщения с тем же ключом получает ответ первой обработки
+	var key string
+	var ttl time.Duration
+	if threadUUID, err := uuid.Parse(threadID); err == nil {
+		if settings, err := h.idempotencyService.ThreadSettings(r.Context(), threadUUID); err == nil {
+			key = h.idempotencyKey(r, settings, data)
+			ttl = time.Duration(settings.TTLSeconds) * time.Second
+		}
+	}
+
+	h.idempotent(w, r, "thread:"+threadID+":"+direction, key, data, ttl, func(w http.ResponseWriter) {
+		msg := &models.Message{
+			Data:    data,
+			Format:  format,
+			Charset: converter.CharsetFromContentType(r.Header.Get("Content-Type")),
+		}
+		response, err := h.messageService.ProcessMessage(r.Context(), threadID, models.Directions(direction), msg)
+		if response != nil {
+			// Режим запрос-ответ: ответ целевой системы передается с исходным кодом
+			if err != nil {
+				logger.ErrorContext(r.Context(), "❌ Error processing message", "error", err)
+			}
+			// Адаптеры без кода ответа (AMQP) отвечают 200
+			statusCode := response.StatusCode
+			if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+			w.Header().Set("Content-Type", converter.ContentTypeForFormat(string(response.Format), response.Charset))
+			w.WriteHeader(statusCode)
+			w.Write(response.Data)
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "❌ Error processing message", "error", err)
+			processingError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"message": "Message processed successfully",
+		})
+	})
+}
+
+// UploadProtoSchema сохраняет FileDescriptorSet из тела запроса для маршрута thread.
+// Параметры: direction (In/Out) и message — полное имя сообщения Protobuf.
+func (h *HTTPHandler) UploadProtoSchema(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = "Out"
+	}
+	message := r.URL.Query().Get("message")
+
+	descriptor, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to marshal data", http.StatusInternalServerError)
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.messageService.ProcessMessage(r.Context(), threadID, models.Directions(direction), data); err != nil {
-		log.Printf("❌ Error processing message: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.threadRouteService.SetProtoSchema(r.Context(), vars["threadId"], vars["routeId"], models.Directions(direction), descriptor, message); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "success",
-		"message": "Message processed successfully",
+		"message": message,
 	})
 }
 
+// ImportWSDL импортирует операции WSDL как маршруты системы.
+// WSDL передается в теле запроса или загружается по параметру url.
+func (h *HTTPHandler) ImportWSDL(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	document, err := readImportDocument(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.importService.ImportWSDL(r.Context(), vars["systemId"], document)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "❌ WSDL import failed", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"result": result,
+	})
+}
+
+// ImportOpenAPI импортирует операции OpenAPI 3 (YAML или JSON) как маршруты системы.
+// Документ передается в теле запроса или загружается по параметру url.
+func (h *HTTPHandler) ImportOpenAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	document, err := readImportDocument(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.importService.ImportOpenAPI(r.Context(), vars["systemId"], document)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "❌ OpenAPI import failed", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"result": result,
+	})
+}
+
+// readImportDocument читает описание сервиса из тела запроса или по параметру url
+func readImportDocument(r *http.Request) ([]byte, error) {
+	if documentURL := r.URL.Query().Get("url"); documentURL != "" {
+		// Причина ошибки только в логе: ответ не раскрывает, что находится по адресу
+		document, err := importer.Fetch(r.Context(), nil, documentURL)
+		if err != nil {
+			logger.WarnContext(r.Context(), "⚠️ Failed to fetch service description", "url", documentURL, "error", err)
+			return nil, errors.New("failed to fetch document from url")
+		}
+		return document, nil
+	}
+	document, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.New("failed to read body")
+	}
+	if len(document) == 0 {
+		return nil, errors.New("document is empty: pass it in the body or set url")
+	}
+	return document, nil
+}
+
 // OrchestrateProcess запускает бизнес-процесс
 func (h *HTTPHandler) OrchestrateProcess(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	processName := vars["processName"]
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
 	var processData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&processData); err != nil {
+	if err := json.Unmarshal(body, &processData); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
@@ -115,27 +441,36 @@ func (h *HTTPHandler) OrchestrateProcess(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.orchestrator.ExecuteProcess(r.Context(), processName, data); err != nil {
-		log.Printf("❌ Error executing process: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	settings := h.idempotencyService.ProcessSettings(processName)
+	key := h.idempotencyKey(r, settings, body)
+	h.idempotent(w, r, "process:"+processName, key, body, time.Duration(settings.TTLSeconds)*time.Second, func(w http.ResponseWriter) {
+		if err := h.orchestrator.ExecuteProcess(r.Context(), processName, data); err != nil {
+			logger.ErrorContext(r.Context(), "❌ Error executing process", "process", processName, "error", err)
+			processingError(w, err)
+			return
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "success",
-		"message": "Process executed successfully",
-		"process": processName,
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"message": "Process executed successfully",
+			"process": processName,
+		})
 	})
 }
 
 // StripeWebhook обрабатывает webhook от Stripe
 func (h *HTTPHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
-	log.Println("📥 Received Stripe webhook")
+	logger.InfoContext(r.Context(), "📥 Received Stripe webhook")
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
 	var stripeEvent map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&stripeEvent); err != nil {
-		log.Printf("❌ Failed to parse Stripe webhook: %v", err)
+	if err := json.Unmarshal(body, &stripeEvent); err != nil {
+		logger.ErrorContext(r.Context(), "❌ Failed to parse Stripe webhook", "error", err)
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
@@ -149,7 +484,7 @@ func (h *HTTPHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
 
 	// Обрабатываем только payment events
 	if eventType != "payment_intent.succeeded" && eventType != "charge.succeeded" {
-		log.Printf("ℹ️ Skipping event type: %s", eventType)
+		logger.InfoContext(r.Context(), "ℹ️ Skipping event type", "event_type", eventType)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -173,18 +508,24 @@ func (h *HTTPHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.orchestrator.ExecuteProcess(r.Context(), "order_payment_flow", processData); err != nil {
-		log.Printf("❌ Error in order payment flow: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	// Stripe повторяет webhook, пока не получит 2xx: повтор события
+	// не запускает процесс второй раз (ключ по умолчанию — id события)
+	settings := h.idempotencyService.ProcessSettings(StripeWebhookProcess)
+	key := h.idempotencyKey(r, settings, body)
+	h.idempotent(w, r, "webhook:"+StripeWebhookProcess, key, body, time.Duration(settings.TTLSeconds)*time.Second, func(w http.ResponseWriter) {
+		if err := h.orchestrator.ExecuteProcess(r.Context(), "order_payment_flow", processData); err != nil {
+			logger.ErrorContext(r.Context(), "❌ Error in order payment flow", "error", err)
+			processingError(w, err)
+			return
+		}
 
-	log.Println("✅ Stripe webhook processed successfully")
+		logger.InfoContext(r.Context(), "✅ Stripe webhook processed successfully", "event_type", eventType)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"event":  eventType,
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"event":  eventType,
+		})
 	})
 }
 
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/balancer"
+	"go-esb/internal/breaker"
+	"go-esb/internal/middleware"
+	"go-esb/internal/models"
+	"go-esb/internal/service"
+	"go-esb/internal/throttle"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestProcessingErrorStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"circuit open", fmt.Errorf("route: %w", &breaker.OpenError{System: "sap", RetryAfter: 1500 * time.Millisecond}), http.StatusServiceUnavailable, "2"},
+		{"rate limited", &throttle.LimitError{System: "sap", RetryAfter: time.Second}, http.StatusTooManyRequests, "1"},
+		{"deadline exceeded", fmt.Errorf("failed to send: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, ""},
+		{"other error", errors.New("boom"), http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			processingError(rec, tt.err)
+			if rec.Code != tt.wantStatus || rec.Header().Get("Retry-After") != tt.wantRetryAfter {
+				t.Fatalf("status %d, Retry-After %q; want %d, %q", rec.Code, rec.Header().Get("Retry-After"), tt.wantStatus, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestDeadlineCancelsSlowRequest(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	// Обработчик отправляет запрос к системе с контекстом входящего запроса
+	handler := middleware.Deadline(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			processingError(w, err)
+			return
+		}
+		resp.Body.Close()
+	}))
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusGatewayTimeout || time.Since(start) > 2*time.Second {
+		t.Fatalf("status %d after %s, want 504 at the deadline", rec.Code, time.Since(start))
+	}
+}
+
+type fakeCertificateService struct {
+	certificates []service.CertificateStatus
+}
+
+func (s *fakeCertificateService) Certificates(context.Context) ([]service.CertificateStatus, error) {
+	return s.certificates, nil
+}
+
+func TestHealthDetailsRequireAdmin(t *testing.T) {
+	authService := newFakeAuthService()
+	authService.addUser("admin", "secret", models.RoleAdmin, nil)
+	authService.addUser("operator", "secret", models.RoleOperator, nil)
+	h := &HTTPHandler{
+		authService:        authService,
+		certificateService: &fakeCertificateService{[]service.CertificateStatus{{Auth: "SAP TLS", Status: service.CertificateExpired}}},
+		breakers:           breaker.NewRegistry(),
+		endpoints:          balancer.NewRegistry(),
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+		wantFields []string
+	}{
+		{"open status", "/health", "", http.StatusOK, []string{"service", "status", "timestamp"}},
+		{"details without credentials", "/api/v1/admin/health", "", http.StatusUnauthorized, nil},
+		{"details for operator", "/api/v1/admin/health", "operator", http.StatusForbidden, nil},
+		{"details for admin", "/api/v1/admin/health", "admin", http.StatusOK,
+			[]string{"certificates", "circuit_breakers", "endpoints", "service", "status", "timestamp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.SetupRoutes().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var fields []string
+			for field := range body {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+			// Истекший сертификат отражается в общем статусе без подробностей
+			if body["status"] != "degraded" {
+				t.Errorf("status = %v, want degraded", body["status"])
+			}
+		})
+	}
+}
+
+// respondingMessageService отвечает на любое сообщение заданным ответом
+type respondingMessageService struct {
+	response *models.Response
+	err      error
+}
+
+func (s *respondingMessageService) ProcessMessage(context.Context, string, models.Directions, *models.Message) (*models.Response, error) {
+	return s.response, s.err
+}
+
+func (s *respondingMessageService) RouteMessage(context.Context, uuid.UUID, models.Directions, *models.Message) (*models.Response, error) {
+	return s.response, s.err
+}
+
+func TestProcessMessageRelaysResponse(t *testing.T) {
+	tests := []struct {
+		name            string
+		response        *models.Response
+		err             error
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "target status",
+			response:        &models.Response{Message: models.Message{Data: []byte(`{"DocumentNumber":"4500012345"}`), Format: models.FileFormatJSON}, StatusCode: http.StatusCreated},
+			wantStatus:      http.StatusCreated,
+			wantContentType: "application/json",
+			wantBody:        `{"DocumentNumber":"4500012345"}`,
+		},
+		{
+			name:            "no status from adapter",
+			response:        &models.Response{Message: models.Message{Data: []byte("<ok/>"), Format: models.FileFormatXML, Charset: "windows-1251"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/xml; charset=windows-1251",
+			wantBody:        "<ok/>",
+		},
+		{
+			name:            "error response of target",
+			response:        &models.Response{Message: models.Message{Data: []byte(`{"error":"duplicate order"}`), Format: models.FileFormatJSON}, StatusCode: http.StatusUnprocessableEntity},
+			err:             errors.New("route failed"),
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: "application/json",
+			wantBody:        `{"error":"duplicate order"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HTTPHandler{messageService: &respondingMessageService{response: tt.response, err: tt.err}}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/threads/orders/process", strings.NewReader(`{"order":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"threadId": "orders"})
+			rec := httptest.NewRecorder()
+			h.ProcessMessage(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// recordingOrchestrator запоминает запущенные процессы и возвращает заданную ошибку
+type recordingOrchestrator struct {
+	err       error
+	processes []string
+}
+
+func (o *recordingOrchestrator) ExecuteProcess(_ context.Context, processName string, _ []byte) error {
+	o.processes = append(o.processes, processName)
+	return o.err
+}
+
+func TestOrchestrateChecksGroupPermission(t *testing.T) {
+	group := uuid.New()
+	authService := newFakeAuthService()
+	authService.addUser("operator", "secret", models.RoleOperator, nil)
+	authService.addUser("partner", "secret", models.RoleReadOnly, map[uuid.UUID]models.UserRole{group: models.RoleOperator})
+	authService.addUser("viewer", "secret", models.RoleReadOnly, map[uuid.UUID]models.UserRole{group: models.RoleReadOnly})
+
+	tests := []struct {
+		name         string
+		token        string
+		processErr   error
+		wantStatus   int
+		wantExecuted bool
+	}{
+		{"operator role", "operator", nil, http.StatusOK, true},
+		{"operator in a thread group", "partner", nil, http.StatusOK, true},
+		{"no send permission in process threads", "partner",
+			&auth.PermissionError{Username: "partner", Permission: auth.PermSend, Thread: uuid.New()}, http.StatusForbidden, true},
+		{"no send permission in any group", "viewer", nil, http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orchestrator := &recordingOrchestrator{err: tt.processErr}
+			h := &HTTPHandler{
+				authService:        authService,
+				orchestrator:       orchestrator,
+				idempotencyService: service.NewIdempotencyService(nil, nil, time.Hour, nil),
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orchestrate/order_payment_flow", strings.NewReader(`{"order_id":"ORD-1"}`))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			h.SetupRoutes().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if executed := len(orchestrator.processes) > 0; executed != tt.wantExecuted {
+				t.Fatalf("process executed = %v, want %v", executed, tt.wantExecuted)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/service"
+)
+
+// IdempotentReplayedHeader отмечает ответ, сохраненный при обработке первого сообщения
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// StripeWebhookProcess имя настроек идемпотентности webhook Stripe
+const StripeWebhookProcess = "stripe_webhook"
+
+// idempotent выполняет обработчик один раз для ключа сообщения. Повторное
+// сообщение с тем же телом получает сохраненный ответ, с другим телом — 422,
+// а пока первое обрабатывается — 409. Без ключа обработчик выполняется как обычно.
+func (h *HTTPHandler) idempotent(w http.ResponseWriter, r *http.Request, scope, key string, body []byte, ttl time.Duration, handle func(w http.ResponseWriter)) {
+	if key == "" {
+		handle(w)
+		return
+	}
+	// Ключи разных пользователей не пересекаются: чужой ключ не возвращает чужой ответ
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		scope += ":user:" + principal.UserID.String()
+	}
+
+	result, replayed, err := h.idempotencyService.Execute(r.Context(), scope, key, body, ttl, func() (*models.IdempotentResult, error) {
+		recorder := newResponseRecorder()
+		handle(recorder)
+		return recorder.result(), nil
+	})
+	if errors.Is(err, service.ErrDuplicateInProgress) {
+		retryAfter(w, time.Second)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, service.ErrIdempotencyKeyReused) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "❌ Idempotency check failed", "idempotency_key", key, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for name, value := range result.Headers {
+		w.Header().Set(name, value)
+	}
+	if replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	w.WriteHeader(result.StatusCode)
+	w.Write(result.Body)
+}
+
+// idempotencyKey извлекает ключ сообщения HTTP. Если ключ не удалось получить,
+// сообщение обрабатывается без подавления повторов.
+func (h *HTTPHandler) idempotencyKey(r *http.Request, settings models.IdempotencySettings, body []byte) string {
+	headers := make(map[string]string, len(r.Header))
+	for name := range r.Header {
+		headers[name] = r.Header.Get(name)
+	}
+	key, err := h.idempotencyService.Key(settings, headers, body)
+	if err != nil {
+		logger.WarnContext(r.Context(), "⚠️ Message has no idempotency key, processing without duplicate check", "error", err)
+		return ""
+	}
+	return key
+}
+
+// responseRecorder сохраняет ответ обработчика для повторных сообщений
+type responseRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	return rec.body.Write(data)
+}
+
+func (rec *responseRecorder) result() *models.IdempotentResult {
+	statusCode := rec.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	headers := make(models.StringMap, len(rec.header))
+	for name := range rec.header {
+		headers[name] = rec.header.Get(name)
+	}
+	return &models.IdempotentResult{StatusCode: statusCode, Headers: headers, Body: rec.body.Bytes()}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// memoryIdempotencyRepo хранит ключи идемпотентности в памяти без истечения
+type memoryIdempotencyRepo struct {
+	repository.IdempotencyRepository
+	mu      sync.Mutex
+	records map[string]*models.IdempotencyRecord
+}
+
+func (r *memoryIdempotencyRepo) Reserve(_ context.Context, scope, key, fingerprint string, expiresAt time.Time) (*models.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record, ok := r.records[scope+"|"+key]; ok {
+		copied := *record
+		return &copied, false, nil
+	}
+	r.records[scope+"|"+key] = &models.IdempotencyRecord{Scope: scope, Key: key, Status: models.IdempotencyProcessing, Fingerprint: fingerprint, ExpiresAt: expiresAt}
+	return r.records[scope+"|"+key], true, nil
+}
+
+func (r *memoryIdempotencyRepo) Complete(_ context.Context, scope, key string, result *models.IdempotentResult, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.records[scope+"|"+key]
+	record.Status, record.ExpiresAt, record.IdempotentResult = models.IdempotencyCompleted, expiresAt, *result
+	return nil
+}
+
+func TestIdempotentScopesKeysByPrincipalAndBody(t *testing.T) {
+	repo := &memoryIdempotencyRepo{records: make(map[string]*models.IdempotencyRecord)}
+	h := &HTTPHandler{idempotencyService: service.NewIdempotencyService(repo, nil, time.Hour, nil)}
+	alice := &auth.Principal{UserID: uuid.New(), Username: "alice"}
+	bob := &auth.Principal{UserID: uuid.New(), Username: "bob"}
+
+	calls := 0
+	request := func(principal *auth.Principal, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/orchestrate/orders", strings.NewReader(body))
+		r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		rec := httptest.NewRecorder()
+		h.idempotent(rec, r, "process:orders", "key-1", []byte(body), 0, func(w http.ResponseWriter) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+		})
+		return rec
+	}
+
+	tests := []struct {
+		name         string
+		principal    *auth.Principal
+		body         string
+		wantStatus   int
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{name: "first message", principal: alice, body: `{"order":1}`, wantStatus: http.StatusCreated, wantCalls: 1},
+		{name: "same user and body replayed", principal: alice, body: `{"order":1}`, wantStatus: http.StatusCreated, wantReplayed: true, wantCalls: 1},
+		{name: "same user with other body", principal: alice, body: `{"order":2}`, wantStatus: http.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "other user with same key", principal: bob, body: `{"order":2}`, wantStatus: http.StatusCreated, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(tt.principal, tt.body)
+			replayed := rec.Header().Get(IdempotentReplayedHeader) == "true"
+			if rec.Code != tt.wantStatus || replayed != tt.wantReplayed || calls != tt.wantCalls {
+				t.Fatalf("status %d, replayed %v, calls %d; want %d, %v, %d", rec.Code, replayed, calls, tt.wantStatus, tt.wantReplayed, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-esb/internal/logging"
+)
+
+// GetLogLevels возвращает уровни логирования пакетов
+func (h *HTTPHandler) GetLogLevels(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, logging.Levels())
+}
+
+// SetLogLevels меняет уровни логирования пакетов без перезапуска.
+// Тело запроса: {"default": "info", "service": "debug", "adapter": "warn"}
+func (h *HTTPHandler) SetLogLevels(w http.ResponseWriter, r *http.Request) {
+	var levels map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&levels); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := logging.SetLevelMap(levels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.InfoContext(r.Context(), "🔧 Log levels changed", "levels", levels)
+	writeJSON(w, http.StatusOK, logging.Levels())
+}
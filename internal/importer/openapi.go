@@ -0,0 +1,342 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-esb/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIDocument документ OpenAPI 3 (YAML или JSON)
+type openAPIDocument struct {
+	OpenAPI string `yaml:"openapi"`
+	Servers []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths      map[string]openAPIPathItem `yaml:"paths"`
+	Components struct {
+		Schemas         map[string]*openAPISchema         `yaml:"schemas"`
+		RequestBodies   map[string]*openAPIBody           `yaml:"requestBodies"`
+		Responses       map[string]*openAPIBody           `yaml:"responses"`
+		SecuritySchemes map[string]*openAPISecurityScheme `yaml:"securitySchemes"`
+	} `yaml:"components"`
+	Security []map[string][]string `yaml:"security"`
+}
+
+type openAPIPathItem struct {
+	Get    *openAPIOperation `yaml:"get"`
+	Post   *openAPIOperation `yaml:"post"`
+	Put    *openAPIOperation `yaml:"put"`
+	Patch  *openAPIOperation `yaml:"patch"`
+	Delete *openAPIOperation `yaml:"delete"`
+}
+
+type openAPIOperation struct {
+	OperationID string                  `yaml:"operationId"`
+	RequestBody *openAPIBody            `yaml:"requestBody"`
+	Responses   map[string]*openAPIBody `yaml:"responses"`
+}
+
+// openAPIBody requestBody или response
+type openAPIBody struct {
+	Ref     string `yaml:"$ref"`
+	Content map[string]struct {
+		Schema *openAPISchema `yaml:"schema"`
+	} `yaml:"content"`
+}
+
+type openAPISchema struct {
+	Ref    string `yaml:"$ref"`
+	Format string `yaml:"format"`
+	// Type строка в OpenAPI 3.0 или список в OpenAPI 3.1 (["string", "null"])
+	Type       interface{}               `yaml:"type"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	Items      *openAPISchema            `yaml:"items"`
+	AllOf      []*openAPISchema          `yaml:"allOf"`
+	OneOf      []*openAPISchema          `yaml:"oneOf"`
+	AnyOf      []*openAPISchema          `yaml:"anyOf"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	Flows  struct {
+		ClientCredentials *openAPIOAuthFlow `yaml:"clientCredentials"`
+		AuthorizationCode *openAPIOAuthFlow `yaml:"authorizationCode"`
+		Password          *openAPIOAuthFlow `yaml:"password"`
+	} `yaml:"flows"`
+}
+
+type openAPIOAuthFlow struct {
+	TokenURL string            `yaml:"tokenUrl"`
+	Scopes   map[string]string `yaml:"scopes"`
+}
+
+// SecurityScheme схема безопасности OpenAPI, из которой создается шаблон
+// connection_authentications (секреты заполняются вручную)
+type SecurityScheme struct {
+	Name     string
+	Type     models.AuthenticationType
+	TokenURL string
+	Scope    string
+	// Default схема указана в глобальном security документа
+	Default bool
+}
+
+// ParseOpenAPI разбирает документ OpenAPI 3 в формате YAML или JSON.
+// Базовый адрес берется из первого servers.
+func ParseOpenAPI(data []byte) (*Spec, error) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", doc.OpenAPI)
+	}
+
+	spec := &Spec{}
+	basePath := ""
+	if len(doc.Servers) > 0 {
+		var err error
+		spec.Endpoint, spec.Port, basePath, err = splitEndpoint(doc.Servers[0].URL)
+		if err != nil {
+			return nil, err
+		}
+		basePath = strings.TrimSuffix(basePath, "/")
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+		for _, m := range []struct {
+			method models.RestMethod
+			op     *openAPIOperation
+		}{
+			{models.MethodGet, item.Get},
+			{models.MethodPost, item.Post},
+			{models.MethodPut, item.Put},
+			{models.MethodPatch, item.Patch},
+			{models.MethodDelete, item.Delete},
+		} {
+			if m.op == nil {
+				continue
+			}
+			name := m.op.OperationID
+			if name == "" {
+				name = strings.ToUpper(string(m.method)) + " " + path
+			}
+			operation := Operation{
+				Name:   name,
+				Path:   basePath + path,
+				Method: m.method,
+			}
+			if schema := doc.bodySchema(m.op.RequestBody); schema != nil {
+				operation.Request = doc.schemaObject(name, schema, map[string]bool{}, 0)
+			}
+			if schema := doc.bodySchema(doc.successResponse(m.op.Responses)); schema != nil {
+				operation.Response = doc.schemaObject(name, schema, map[string]bool{}, 0)
+			}
+			spec.Operations = append(spec.Operations, operation)
+		}
+	}
+
+	spec.SecuritySchemes = doc.securitySchemes()
+	return spec, nil
+}
+
+// successResponse возвращает первый ответ 2xx (или default)
+func (d *openAPIDocument) successResponse(responses map[string]*openAPIBody) *openAPIBody {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return responses[code]
+		}
+	}
+	return responses["default"]
+}
+
+// bodySchema возвращает схему тела, предпочитая JSON
+func (d *openAPIDocument) bodySchema(body *openAPIBody) *openAPISchema {
+	if body != nil && body.Ref != "" {
+		if strings.Contains(body.Ref, "/requestBodies/") {
+			body = d.Components.RequestBodies[refName(body.Ref)]
+		} else {
+			body = d.Components.Responses[refName(body.Ref)]
+		}
+	}
+	if body == nil || len(body.Content) == 0 {
+		return nil
+	}
+	mediaTypes := make([]string, 0, len(body.Content))
+	for mediaType := range body.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	for _, mediaType := range mediaTypes {
+		if strings.Contains(mediaType, "json") && body.Content[mediaType].Schema != nil {
+			return body.Content[mediaType].Schema
+		}
+	}
+	return body.Content[mediaTypes[0]].Schema
+}
+
+// schemaObject строит узел схемы сообщения. Свойства allOf, oneOf и anyOf
+// объединяются в одну структуру.
+func (d *openAPIDocument) schemaObject(name string, schema *openAPISchema, visiting map[string]bool, depth int) *Object {
+	if schema.Ref != "" {
+		ref := refName(schema.Ref)
+		resolved, ok := d.Components.Schemas[ref]
+		if !ok || visiting[ref] || depth > maxSchemaDepth {
+			// Рекурсивная или неизвестная схема не раскрывается
+			return &Object{Name: name, Type: models.ValueTypeStructure}
+		}
+		visiting[ref] = true
+		defer delete(visiting, ref)
+		schema = resolved
+	}
+
+	switch schemaType(schema) {
+	case "array":
+		obj := &Object{Name: name, Type: models.ValueTypeArray}
+		if schema.Items != nil {
+			obj.Children = append(obj.Children, d.schemaObject(name, schema.Items, visiting, depth+1))
+		}
+		return obj
+	case "string":
+		if schema.Format == "date" || schema.Format == "date-time" {
+			return &Object{Name: name, Type: models.ValueTypeDate}
+		}
+		return &Object{Name: name, Type: models.ValueTypeString}
+	case "integer":
+		return &Object{Name: name, Type: models.ValueTypeInteger}
+	case "boolean":
+		return &Object{Name: name, Type: models.ValueTypeBoolean}
+	case "number":
+		// Дробные числа передаются строкой: отдельного типа для них нет
+		return &Object{Name: name, Type: models.ValueTypeString}
+	}
+
+	obj := &Object{Name: name, Type: models.ValueTypeStructure}
+	seen := make(map[string]bool)
+	d.collectProperties(obj, schema, seen, visiting, depth)
+	return obj
+}
+
+func (d *openAPIDocument) collectProperties(obj *Object, schema *openAPISchema, seen, visiting map[string]bool, depth int) {
+	if schema.Ref != "" {
+		ref := refName(schema.Ref)
+		resolved, ok := d.Components.Schemas[ref]
+		if !ok || visiting[ref] {
+			return
+		}
+		visiting[ref] = true
+		defer delete(visiting, ref)
+		schema = resolved
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		obj.Children = append(obj.Children, d.schemaObject(name, schema.Properties[name], visiting, depth+1))
+	}
+
+	for _, group := range [][]*openAPISchema{schema.AllOf, schema.OneOf, schema.AnyOf} {
+		for _, sub := range group {
+			d.collectProperties(obj, sub, seen, visiting, depth)
+		}
+	}
+}
+
+// securitySchemes преобразует схемы безопасности в типы аутентификации ESB.
+// Схемы apiKey и openIdConnect не поддерживаются и пропускаются.
+func (d *openAPIDocument) securitySchemes() []SecurityScheme {
+	defaults := make(map[string]bool)
+	for _, requirement := range d.Security {
+		for name := range requirement {
+			defaults[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(d.Components.SecuritySchemes))
+	for name := range d.Components.SecuritySchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var schemes []SecurityScheme
+	for _, name := range names {
+		s := d.Components.SecuritySchemes[name]
+		scheme := SecurityScheme{Name: name, Default: defaults[name]}
+		switch {
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "basic"):
+			scheme.Type = models.AuthBasic
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "bearer"):
+			scheme.Type = models.AuthBearerToken
+		case s.Type == "oauth2":
+			flow := s.Flows.ClientCredentials
+			if flow == nil {
+				flow = s.Flows.AuthorizationCode
+			}
+			if flow == nil {
+				flow = s.Flows.Password
+			}
+			if flow == nil {
+				continue
+			}
+			scheme.Type = models.AuthOAuth2
+			scheme.TokenURL = flow.TokenURL
+			scopes := make([]string, 0, len(flow.Scopes))
+			for scope := range flow.Scopes {
+				scopes = append(scopes, scope)
+			}
+			sort.Strings(scopes)
+			scheme.Scope = strings.Join(scopes, " ")
+		default:
+			continue
+		}
+		schemes = append(schemes, scheme)
+	}
+	return schemes
+}
+
+// schemaType возвращает тип схемы; для списка типов OpenAPI 3.1 — первый, кроме null
+func schemaType(schema *openAPISchema) string {
+	switch t := schema.Type.(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if schema.Items != nil {
+		return "array"
+	}
+	return "object"
+}
+
+// refName возвращает имя компонента из ссылки #/components/schemas/Name
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
@@ -0,0 +1,294 @@
+package importer
+
+import (
+	"fmt"
+	"testing"
+
+	"go-esb/internal/models"
+)
+
+const ordersOpenAPI = `
+openapi: 3.0.1
+servers:
+  - url: https://api.example.com:8443/v1/
+  - url: https://sandbox.example.com/v1
+paths:
+  /orders:
+    post:
+      operationId: createOrder
+      requestBody:
+        $ref: '#/components/requestBodies/Order'
+      responses:
+        "400":
+          description: invalid
+        "201":
+          description: created
+          content:
+            application/xml:
+              schema:
+                type: string
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Order'
+  /orders/{id}:
+    get:
+      responses:
+        "200":
+          $ref: '#/components/responses/Order'
+    delete:
+      operationId: deleteOrder
+      responses:
+        default:
+          description: deleted
+components:
+  requestBodies:
+    Order:
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Order'
+  responses:
+    Order:
+      description: order
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Order'
+  schemas:
+    Order:
+      type: object
+      properties:
+        id:
+          type: integer
+        created:
+          type: string
+          format: date-time
+        total:
+          type: number
+        lines:
+          type: array
+          items:
+            $ref: '#/components/schemas/Line'
+    Line:
+      properties:
+        sku:
+          type: string
+        gift:
+          type: boolean
+`
+
+func TestParseOpenAPIOperations(t *testing.T) {
+	const order = "Structure{created:Date,id:Integer,lines:Array{lines:Structure{gift:Boolean,sku:String}},total:String}"
+
+	spec, err := ParseOpenAPI([]byte(ordersOpenAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Endpoint != "https://api.example.com:8443" || spec.Port != 8443 {
+		t.Fatalf("endpoint %q port %d, want first server https://api.example.com:8443", spec.Endpoint, spec.Port)
+	}
+
+	want := []struct {
+		name     string
+		method   models.RestMethod
+		path     string
+		request  string
+		response string
+	}{
+		{"createOrder", models.MethodPost, "/v1/orders", "createOrder:" + order, "createOrder:" + order},
+		{"GET /orders/{id}", models.MethodGet, "/v1/orders/{id}", "<nil>", "GET /orders/{id}:" + order},
+		{"deleteOrder", models.MethodDelete, "/v1/orders/{id}", "<nil>", "<nil>"},
+	}
+	if len(spec.Operations) != len(want) {
+		t.Fatalf("got %d operations, want %d", len(spec.Operations), len(want))
+	}
+	for i, w := range want {
+		got := spec.Operations[i]
+		if got.Name != w.name || got.Method != w.method || got.Path != w.path {
+			t.Errorf("operation %d = %s %s %s, want %s %s %s", i, got.Name, got.Method, got.Path, w.name, w.method, w.path)
+		}
+		if request := objectString(got.Request); request != w.request {
+			t.Errorf("%s request = %s, want %s", w.name, request, w.request)
+		}
+		if response := objectString(got.Response); response != w.response {
+			t.Errorf("%s response = %s, want %s", w.name, response, w.response)
+		}
+	}
+}
+
+func TestParseOpenAPIServers(t *testing.T) {
+	tests := []struct {
+		name         string
+		servers      string
+		wantEndpoint string
+		wantPort     int
+		wantPath     string
+	}{
+		{name: "base path", servers: "servers: [{url: 'https://api.example.com/v1'}]", wantEndpoint: "https://api.example.com", wantPath: "/v1/orders"},
+		{name: "port without path", servers: "servers: [{url: 'http://erp.local:8080'}]", wantEndpoint: "http://erp.local:8080", wantPort: 8080, wantPath: "/orders"},
+		{name: "relative server", servers: "servers: [{url: '/api/'}]", wantPath: "/api/orders"},
+		{name: "no servers", wantPath: "/orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := "openapi: 3.1.0\n" + tt.servers + "\npaths:\n  /orders:\n    get: {}\n"
+			spec, err := ParseOpenAPI([]byte(document))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.Endpoint != tt.wantEndpoint || spec.Port != tt.wantPort {
+				t.Fatalf("endpoint %q port %d, want %q port %d", spec.Endpoint, spec.Port, tt.wantEndpoint, tt.wantPort)
+			}
+			if len(spec.Operations) != 1 || spec.Operations[0].Path != tt.wantPath {
+				t.Fatalf("operations %+v, want one with path %s", spec.Operations, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestParseOpenAPISchemaRefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		schemas string
+		want    string
+	}{
+		{
+			name: "self reference in property",
+			schemas: `
+    Root:
+      properties:
+        name: {type: string}
+        parent: {$ref: '#/components/schemas/Root'}
+        children: {type: array, items: {$ref: '#/components/schemas/Root'}}`,
+			want: "body:Structure{children:Array{children:Structure},name:String,parent:Structure}",
+		},
+		{
+			name: "reference to itself",
+			schemas: `
+    Root: {$ref: '#/components/schemas/Root'}`,
+			want: "body:Structure",
+		},
+		{
+			name: "mutual references through allOf",
+			schemas: `
+    Root:
+      allOf:
+        - $ref: '#/components/schemas/Other'
+        - properties: {id: {type: integer}}
+    Other:
+      allOf:
+        - $ref: '#/components/schemas/Root'
+        - properties: {note: {type: string}}`,
+			want: "body:Structure{note:String,id:Integer}",
+		},
+		{
+			name: "composition and unknown reference",
+			schemas: `
+    Root:
+      properties:
+        id: {type: integer}
+        missing: {$ref: '#/components/schemas/Missing'}
+      oneOf:
+        - properties: {card: {type: string}}
+        - properties: {iban: {type: string}, id: {type: string}}
+      anyOf:
+        - properties: {paid: {type: [boolean, "null"]}, date: {type: string, format: date}}`,
+			want: "body:Structure{id:Integer,missing:Structure,card:String,iban:String,date:Date,paid:Boolean}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := fmt.Sprintf(`
+openapi: 3.1.0
+paths:
+  /orders:
+    post:
+      operationId: body
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Root'}
+components:
+  schemas:%s
+`, tt.schemas)
+			spec, err := ParseOpenAPI([]byte(document))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := objectString(spec.Operations[0].Request); got != tt.want {
+				t.Fatalf("request = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOpenAPISecuritySchemes(t *testing.T) {
+	document := `
+openapi: 3.0.3
+paths: {}
+security:
+  - oauth: [orders]
+components:
+  securitySchemes:
+    basic: {type: http, scheme: Basic}
+    bearer: {type: http, scheme: bearer, bearerFormat: JWT}
+    oauth:
+      type: oauth2
+      flows:
+        clientCredentials:
+          tokenUrl: https://auth.example.com/token
+          scopes: {orders.write: write, orders.read: read}
+    login:
+      type: oauth2
+      flows:
+        password:
+          tokenUrl: https://auth.example.com/login
+          scopes: {}
+    implicit:
+      type: oauth2
+      flows:
+        implicit:
+          authorizationUrl: https://auth.example.com/authorize
+          scopes: {}
+    key: {type: apiKey, in: header, name: X-API-Key}
+    oidc: {type: openIdConnect, openIdConnectUrl: https://auth.example.com/.well-known/openid-configuration}
+`
+	spec, err := ParseOpenAPI([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SecurityScheme{
+		{Name: "basic", Type: models.AuthBasic},
+		{Name: "bearer", Type: models.AuthBearerToken},
+		{Name: "login", Type: models.AuthOAuth2, TokenURL: "https://auth.example.com/login"},
+		{Name: "oauth", Type: models.AuthOAuth2, TokenURL: "https://auth.example.com/token", Scope: "orders.read orders.write", Default: true},
+	}
+	if len(spec.SecuritySchemes) != len(want) {
+		t.Fatalf("got schemes %+v, want %+v", spec.SecuritySchemes, want)
+	}
+	for i := range want {
+		if spec.SecuritySchemes[i] != want[i] {
+			t.Errorf("scheme %d = %+v, want %+v", i, spec.SecuritySchemes[i], want[i])
+		}
+	}
+}
+
+func TestParseOpenAPIMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{name: "not YAML", document: "openapi: [3.0"},
+		{name: "Swagger 2", document: "swagger: '2.0'\npaths: {}"},
+		{name: "WSDL", document: `<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/"/>`},
+		{name: "invalid server URL", document: "openapi: 3.0.0\nservers: [{url: 'http://[::1'}]\npaths: {}"},
+		{name: "recursive anchor", document: "openapi: 3.0.0\ncomponents:\n  schemas:\n    Root: &root\n      allOf: [*root]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseOpenAPI([]byte(tt.document)); err == nil {
+				t.Fatal("ParseOpenAPI succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// maxDocumentSize ограничение размера загружаемого описания сервиса
+const maxDocumentSize = 10 << 20
+
+// Spec описание сервиса, полученное из WSDL или OpenAPI
+type Spec struct {
+	// Endpoint базовый адрес сервиса (схема, хост и порт) для connection_settings
+	Endpoint string
+	// Port порт из адреса сервиса (0, если не указан)
+	Port int
+	// SOAPVersion версия SOAP для WSDL (1.1 или 1.2)
+	SOAPVersion string
+	Operations  []Operation
+	// SecuritySchemes схемы безопасности OpenAPI
+	SecuritySchemes []SecurityScheme
+}
+
+// Operation операция сервиса, из которой создается маршрут
+type Operation struct {
+	Name       string
+	Path       string
+	Method     models.RestMethod
+	SOAPAction string
+	// Request и Response схемы сообщений (nil, если сообщения нет)
+	Request  *Object
+	Response *Object
+}
+
+// Object узел схемы сообщения, соответствует записи thread_objects.
+// Name — имя поля в сообщении (атрибуты XML с префиксом @, как в конвертере).
+type Object struct {
+	Name     string
+	Type     models.ValueType
+	Children []*Object
+}
+
+// ErrForbiddenAddress возвращается при загрузке описания с внутреннего адреса
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// Fetch загружает описание сервиса по URL http или https. Клиент по умолчанию
+// не подключается к loopback, частным и link-local адресам, в том числе после
+// перенаправления: адрес передается пользователем API.
+func Fetch(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	if client == nil {
+		client = newFetchClient()
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rawURL, err)
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("document %s exceeds %d bytes", rawURL, maxDocumentSize)
+	}
+	return data, nil
+}
+
+// newFetchClient создает клиент, который проверяет адрес каждого подключения
+// после разрешения имени. Прокси не используется: иначе проверялся бы адрес прокси.
+func newFetchClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicAddressOnly}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported redirect scheme %q", req.URL.Scheme)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// publicAddressOnly запрещает подключение к loopback, частным, link-local,
+// multicast и неуказанным адресам
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// splitEndpoint разделяет адрес сервиса на базовый адрес, порт и путь (с query)
+func splitEndpoint(address string) (base string, port int, path string, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid service address %q: %w", address, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		// Относительный адрес: базовый адрес задается вручную в connection_settings
+		return "", 0, address, nil
+	}
+	if p := u.Port(); p != "" {
+		port, _ = strconv.Atoi(p)
+	}
+	path = u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return u.Scheme + "://" + u.Host, port, path, nil
+}
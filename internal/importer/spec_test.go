@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchRejectsUnsafeURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<definitions/>"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "file scheme", url: "file:///etc/passwd"},
+		{name: "gopher scheme", url: "gopher://example.com/"},
+		{name: "loopback", url: server.URL, wantErr: ErrForbiddenAddress},
+		{name: "localhost", url: strings.Replace(server.URL, "127.0.0.1", "localhost", 1), wantErr: ErrForbiddenAddress},
+		{name: "link-local metadata", url: "http://169.254.169.254/latest/meta-data/", wantErr: ErrForbiddenAddress},
+		{name: "private", url: "http://10.0.0.1/", wantErr: ErrForbiddenAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Fetch(context.Background(), nil, tt.url)
+			if err == nil {
+				t.Fatal("Fetch succeeded, want error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fetch error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchClientChecksRedirect(t *testing.T) {
+	client := newFetchClient()
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/service.wsdl", true},
+		{"http://example.com/service.wsdl", true},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com/service.wsdl", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.CheckRedirect(req, nil); (err == nil) != tt.allowed {
+				t.Fatalf("CheckRedirect = %v, allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestFetchWithClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<definitions/>"))
+	}))
+	defer server.Close()
+
+	data, err := Fetch(context.Background(), server.Client(), server.URL+"/service.wsdl")
+	if err != nil || string(data) != "<definitions/>" {
+		t.Fatalf("Fetch = %q, %v; want document", data, err)
+	}
+	if _, err := Fetch(context.Background(), server.Client(), server.URL+"/missing"); err == nil {
+		t.Fatal("Fetch of missing document succeeded")
+	}
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"224.0.0.1:80", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := publicAddressOnly("tcp", tt.address, nil)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("publicAddressOnly(%s) = %v, allowed %v", tt.address, err, tt.allowed)
+			}
+		})
+	}
+}
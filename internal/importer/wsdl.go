@@ -0,0 +1,219 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"go-esb/internal/models"
+)
+
+// wsdlDefinitions документ WSDL 1.1. Схемы XSD берутся только из wsdl:types,
+// внешние xsd:import и xsd:include не загружаются.
+type wsdlDefinitions struct {
+	XMLName   xml.Name       `xml:"http://schemas.xmlsoap.org/wsdl/ definitions"`
+	Types     wsdlTypes      `xml:"http://schemas.xmlsoap.org/wsdl/ types"`
+	Messages  []wsdlMessage  `xml:"http://schemas.xmlsoap.org/wsdl/ message"`
+	PortTypes []wsdlPortType `xml:"http://schemas.xmlsoap.org/wsdl/ portType"`
+	Bindings  []wsdlBinding  `xml:"http://schemas.xmlsoap.org/wsdl/ binding"`
+	Services  []wsdlService  `xml:"http://schemas.xmlsoap.org/wsdl/ service"`
+}
+
+type wsdlTypes struct {
+	Schemas []xsdSchema `xml:"http://www.w3.org/2001/XMLSchema schema"`
+}
+
+type wsdlMessage struct {
+	Name  string `xml:"name,attr"`
+	Parts []struct {
+		Name    string `xml:"name,attr"`
+		Element string `xml:"element,attr"`
+		Type    string `xml:"type,attr"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ part"`
+}
+
+type wsdlPortType struct {
+	Name       string `xml:"name,attr"`
+	Operations []struct {
+		Name   string          `xml:"name,attr"`
+		Input  *wsdlMessageRef `xml:"http://schemas.xmlsoap.org/wsdl/ input"`
+		Output *wsdlMessageRef `xml:"http://schemas.xmlsoap.org/wsdl/ output"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ operation"`
+}
+
+type wsdlMessageRef struct {
+	Message string `xml:"message,attr"`
+}
+
+// wsdlSOAPElement soap:binding, soap:operation и soap:address (SOAP 1.1 и 1.2)
+type wsdlSOAPElement struct {
+	Style      string `xml:"style,attr"`
+	SOAPAction string `xml:"soapAction,attr"`
+	Location   string `xml:"location,attr"`
+}
+
+type wsdlBinding struct {
+	Name       string           `xml:"name,attr"`
+	Type       string           `xml:"type,attr"`
+	SOAP11     *wsdlSOAPElement `xml:"http://schemas.xmlsoap.org/wsdl/soap/ binding"`
+	SOAP12     *wsdlSOAPElement `xml:"http://schemas.xmlsoap.org/wsdl/soap12/ binding"`
+	Operations []struct {
+		Name   string           `xml:"name,attr"`
+		SOAP11 *wsdlSOAPElement `xml:"http://schemas.xmlsoap.org/wsdl/soap/ operation"`
+		SOAP12 *wsdlSOAPElement `xml:"http://schemas.xmlsoap.org/wsdl/soap12/ operation"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ operation"`
+}
+
+type wsdlService struct {
+	Name  string `xml:"name,attr"`
+	Ports []struct {
+		Name    string           `xml:"name,attr"`
+		Binding string           `xml:"binding,attr"`
+		SOAP11  *wsdlSOAPElement `xml:"http://schemas.xmlsoap.org/wsdl/soap/ address"`
+		SOAP12  *wsdlSOAPElement `xml:"http://schemas.xmlsoap.org/wsdl/soap12/ address"`
+	} `xml:"http://schemas.xmlsoap.org/wsdl/ port"`
+}
+
+// ParseWSDL разбирает WSDL 1.1 и возвращает операции первого SOAP порта сервиса
+// (или первой SOAP привязки, если сервис не описан)
+func ParseWSDL(data []byte) (*Spec, error) {
+	var defs wsdlDefinitions
+	if err := xml.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("failed to parse WSDL: %w", err)
+	}
+
+	binding, address := defs.soapPort()
+	if binding == nil {
+		return nil, fmt.Errorf("WSDL has no SOAP binding")
+	}
+
+	var portType *wsdlPortType
+	for i := range defs.PortTypes {
+		if defs.PortTypes[i].Name == localName(binding.Type) {
+			portType = &defs.PortTypes[i]
+			break
+		}
+	}
+	if portType == nil {
+		return nil, fmt.Errorf("portType %s of binding %s not found", binding.Type, binding.Name)
+	}
+
+	spec := &Spec{SOAPVersion: models.SOAPVersion11}
+	var bindingStyle string
+	if binding.SOAP12 != nil {
+		spec.SOAPVersion = models.SOAPVersion12
+		bindingStyle = binding.SOAP12.Style
+	} else {
+		bindingStyle = binding.SOAP11.Style
+	}
+
+	path := ""
+	if address != "" {
+		var err error
+		spec.Endpoint, spec.Port, path, err = splitEndpoint(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schemas := newSchemaSet(defs.Types.Schemas)
+	for _, op := range portType.Operations {
+		operation := Operation{Name: op.Name, Path: path, Method: models.MethodPost}
+
+		style := bindingStyle
+		for _, bop := range binding.Operations {
+			if bop.Name != op.Name {
+				continue
+			}
+			soapOp := bop.SOAP11
+			if soapOp == nil {
+				soapOp = bop.SOAP12
+			}
+			if soapOp != nil {
+				operation.SOAPAction = soapOp.SOAPAction
+				if soapOp.Style != "" {
+					style = soapOp.Style
+				}
+			}
+		}
+		rpc := style == "rpc"
+
+		if op.Input != nil {
+			operation.Request = defs.messageObject(schemas, op.Input.Message, op.Name, rpc)
+		}
+		if op.Output != nil {
+			operation.Response = defs.messageObject(schemas, op.Output.Message, op.Name+"Response", rpc)
+		}
+		spec.Operations = append(spec.Operations, operation)
+	}
+
+	return spec, nil
+}
+
+// soapPort возвращает привязку и адрес первого SOAP порта
+func (d *wsdlDefinitions) soapPort() (*wsdlBinding, string) {
+	for _, service := range d.Services {
+		for _, port := range service.Ports {
+			address := port.SOAP11
+			if address == nil {
+				address = port.SOAP12
+			}
+			if address == nil {
+				continue
+			}
+			if binding := d.binding(localName(port.Binding)); binding != nil {
+				return binding, address.Location
+			}
+		}
+	}
+	for i := range d.Bindings {
+		if d.Bindings[i].SOAP11 != nil || d.Bindings[i].SOAP12 != nil {
+			return &d.Bindings[i], ""
+		}
+	}
+	return nil, ""
+}
+
+func (d *wsdlDefinitions) binding(name string) *wsdlBinding {
+	for i := range d.Bindings {
+		b := &d.Bindings[i]
+		if b.Name == name && (b.SOAP11 != nil || b.SOAP12 != nil) {
+			return b
+		}
+	}
+	return nil
+}
+
+// messageObject строит схему сообщения. В стиле document единственная часть
+// с element= является телом сообщения; в стиле rpc части оборачиваются
+// в элемент с именем операции.
+func (d *wsdlDefinitions) messageObject(schemas *schemaSet, messageName, wrapper string, rpc bool) *Object {
+	var message *wsdlMessage
+	for i := range d.Messages {
+		if d.Messages[i].Name == localName(messageName) {
+			message = &d.Messages[i]
+			break
+		}
+	}
+	if message == nil {
+		return nil
+	}
+
+	if !rpc && len(message.Parts) == 1 && message.Parts[0].Element != "" {
+		if obj := schemas.elementObject(message.Parts[0].Element); obj != nil {
+			return obj
+		}
+	}
+
+	obj := &Object{Name: wrapper, Type: models.ValueTypeStructure}
+	for _, part := range message.Parts {
+		var child *Object
+		if part.Element != "" {
+			child = schemas.elementObject(part.Element)
+		}
+		if child == nil {
+			child = schemas.typeObject(part.Name, part.Type)
+		}
+		obj.Children = append(obj.Children, child)
+	}
+	return obj
+}
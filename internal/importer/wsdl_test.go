@@ -0,0 +1,211 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-esb/internal/models"
+)
+
+// objectString записывает схему сообщения одной строкой: имя:тип{дети}
+func objectString(obj *Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	s := obj.Name + ":" + string(obj.Type)
+	if len(obj.Children) > 0 {
+		children := make([]string, len(obj.Children))
+		for i, child := range obj.Children {
+			children[i] = objectString(child)
+		}
+		s += "{" + strings.Join(children, ",") + "}"
+	}
+	return s
+}
+
+// ordersWSDL документ WSDL 1.1: схема urn:orders импортирует типы схемы
+// urn:common из того же wsdl:types. soap — префикс привязки (soap или soap12),
+// style — стиль привязки, service — включать ли wsdl:service с адресом.
+func ordersWSDL(soap, style string, service bool) string {
+	services := ""
+	if service {
+		services = fmt.Sprintf(`
+  <wsdl:service name="OrdersService">
+    <wsdl:port name="OrdersPort" binding="tns:OrdersBinding">
+      <%[1]s:address location="https://sap.example.com:8443/sap/bc/srt/orders?sap-client=100"/>
+    </wsdl:port>
+  </wsdl:service>`, soap)
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/"
+    xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/"
+    xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/"
+    xmlns:xsd="http://www.w3.org/2001/XMLSchema"
+    xmlns:tns="urn:orders" xmlns:cmn="urn:common" targetNamespace="urn:orders">
+  <wsdl:types>
+    <xsd:schema targetNamespace="urn:common">
+      <xsd:simpleType name="Amount">
+        <xsd:restriction base="xsd:long"/>
+      </xsd:simpleType>
+      <xsd:complexType name="Address">
+        <xsd:sequence>
+          <xsd:element name="City" type="xsd:string"/>
+          <xsd:element name="Zip" type="xsd:int"/>
+        </xsd:sequence>
+      </xsd:complexType>
+    </xsd:schema>
+    <xsd:schema targetNamespace="urn:orders">
+      <xsd:import namespace="urn:common"/>
+      <xsd:element name="CreateOrder">
+        <xsd:complexType>
+          <xsd:sequence>
+            <xsd:element name="Id" type="xsd:string"/>
+            <xsd:element name="Total" type="cmn:Amount"/>
+            <xsd:element name="Delivery" type="cmn:Address"/>
+            <xsd:element name="Line" type="xsd:string" maxOccurs="unbounded"/>
+          </xsd:sequence>
+          <xsd:attribute name="currency" type="xsd:string"/>
+        </xsd:complexType>
+      </xsd:element>
+      <xsd:element name="CreateOrderResponse">
+        <xsd:complexType>
+          <xsd:sequence>
+            <xsd:element name="DocumentNumber" type="xsd:string"/>
+            <xsd:element name="Created" type="xsd:dateTime"/>
+          </xsd:sequence>
+        </xsd:complexType>
+      </xsd:element>
+    </xsd:schema>
+  </wsdl:types>
+  <wsdl:message name="CreateOrderIn">
+    <wsdl:part name="parameters" element="tns:CreateOrder"/>
+  </wsdl:message>
+  <wsdl:message name="CreateOrderOut">
+    <wsdl:part name="parameters" element="tns:CreateOrderResponse"/>
+  </wsdl:message>
+  <wsdl:message name="GetStatusIn">
+    <wsdl:part name="orderId" type="xsd:int"/>
+    <wsdl:part name="address" type="cmn:Address"/>
+  </wsdl:message>
+  <wsdl:portType name="OrdersPortType">
+    <wsdl:operation name="CreateOrder">
+      <wsdl:input message="tns:CreateOrderIn"/>
+      <wsdl:output message="tns:CreateOrderOut"/>
+    </wsdl:operation>
+    <wsdl:operation name="GetStatus">
+      <wsdl:input message="tns:GetStatusIn"/>
+    </wsdl:operation>
+  </wsdl:portType>
+  <wsdl:binding name="OrdersBinding" type="tns:OrdersPortType">
+    <%[1]s:binding style="%[2]s" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <wsdl:operation name="CreateOrder">
+      <%[1]s:operation soapAction="urn:orders/CreateOrder"/>
+    </wsdl:operation>
+    <wsdl:operation name="GetStatus">
+      <%[1]s:operation soapAction="urn:orders/GetStatus" style="rpc"/>
+    </wsdl:operation>
+  </wsdl:binding>%[3]s
+</wsdl:definitions>`, soap, style, services)
+}
+
+func TestParseWSDL(t *testing.T) {
+	const (
+		createOrderRequest  = "CreateOrder:Structure{@currency:String,Id:String,Total:Integer,Delivery:Structure{City:String,Zip:Integer},Line:Array{Line:String}}"
+		createOrderResponse = "CreateOrderResponse:Structure{DocumentNumber:String,Created:Date}"
+		// В стиле rpc части сообщения оборачиваются в элемент операции
+		getStatusRequest = "GetStatus:Structure{orderId:Integer,address:Structure{City:String,Zip:Integer}}"
+	)
+
+	type operation struct {
+		name, path, soapAction, request, response string
+	}
+	tests := []struct {
+		name           string
+		document       string
+		wantVersion    string
+		wantEndpoint   string
+		wantPort       int
+		wantOperations []operation
+	}{
+		{
+			name:         "SOAP 1.1 document with service address",
+			document:     ordersWSDL("soap", "document", true),
+			wantVersion:  models.SOAPVersion11,
+			wantEndpoint: "https://sap.example.com:8443",
+			wantPort:     8443,
+			wantOperations: []operation{
+				{"CreateOrder", "/sap/bc/srt/orders?sap-client=100", "urn:orders/CreateOrder", createOrderRequest, createOrderResponse},
+				{"GetStatus", "/sap/bc/srt/orders?sap-client=100", "urn:orders/GetStatus", getStatusRequest, "<nil>"},
+			},
+		},
+		{
+			name:         "SOAP 1.2 document with service address",
+			document:     ordersWSDL("soap12", "document", true),
+			wantVersion:  models.SOAPVersion12,
+			wantEndpoint: "https://sap.example.com:8443",
+			wantPort:     8443,
+			wantOperations: []operation{
+				{"CreateOrder", "/sap/bc/srt/orders?sap-client=100", "urn:orders/CreateOrder", createOrderRequest, createOrderResponse},
+				{"GetStatus", "/sap/bc/srt/orders?sap-client=100", "urn:orders/GetStatus", getStatusRequest, "<nil>"},
+			},
+		},
+		{
+			name:        "SOAP 1.1 rpc binding without service",
+			document:    ordersWSDL("soap", "rpc", false),
+			wantVersion: models.SOAPVersion11,
+			wantOperations: []operation{
+				{"CreateOrder", "", "urn:orders/CreateOrder", "CreateOrder:Structure{" + createOrderRequest + "}", "CreateOrderResponse:Structure{" + createOrderResponse + "}"},
+				{"GetStatus", "", "urn:orders/GetStatus", getStatusRequest, "<nil>"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseWSDL([]byte(tt.document))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.SOAPVersion != tt.wantVersion || spec.Endpoint != tt.wantEndpoint || spec.Port != tt.wantPort {
+				t.Fatalf("SOAP %s at %q port %d, want SOAP %s at %q port %d", spec.SOAPVersion, spec.Endpoint, spec.Port, tt.wantVersion, tt.wantEndpoint, tt.wantPort)
+			}
+			if len(spec.Operations) != len(tt.wantOperations) {
+				t.Fatalf("got %d operations, want %d", len(spec.Operations), len(tt.wantOperations))
+			}
+			for i, want := range tt.wantOperations {
+				got := spec.Operations[i]
+				if got.Name != want.name || got.Path != want.path || got.SOAPAction != want.soapAction || got.Method != models.MethodPost {
+					t.Errorf("operation %d = %s %s %q %s, want %s Post %q %s", i, got.Name, got.Method, got.Path, got.SOAPAction, want.name, want.path, want.soapAction)
+				}
+				if request := objectString(got.Request); request != want.request {
+					t.Errorf("%s request = %s, want %s", want.name, request, want.request)
+				}
+				if response := objectString(got.Response); response != want.response {
+					t.Errorf("%s response = %s, want %s", want.name, response, want.response)
+				}
+			}
+		})
+	}
+}
+
+func TestParseWSDLMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{name: "not XML", document: "openapi: 3.0.1"},
+		{name: "truncated", document: ordersWSDL("soap", "document", true)[:500]},
+		{name: "not WSDL", document: `<definitions xmlns="urn:other"/>`},
+		{name: "no SOAP binding", document: strings.Replace(ordersWSDL("soap", "document", true),
+			`<soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>`, "", 1)},
+		{name: "unknown portType", document: strings.Replace(ordersWSDL("soap", "document", true),
+			`type="tns:OrdersPortType"`, `type="tns:Missing"`, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if spec, err := ParseWSDL([]byte(tt.document)); err == nil {
+				t.Fatalf("ParseWSDL = %d operations, want error", len(spec.Operations))
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+
+	"go-esb/internal/models"
+)
+
+// maxSchemaDepth ограничение вложенности схемы (защита от очень глубоких типов)
+const maxSchemaDepth = 32
+
+// xsdSchema схема XML Schema (подмножество, достаточное для описания сообщений)
+type xsdSchema struct {
+	TargetNamespace string           `xml:"targetNamespace,attr"`
+	Elements        []xsdElement     `xml:"http://www.w3.org/2001/XMLSchema element"`
+	ComplexTypes    []xsdComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleTypes     []xsdSimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+type xsdElement struct {
+	Name        string          `xml:"name,attr"`
+	Type        string          `xml:"type,attr"`
+	Ref         string          `xml:"ref,attr"`
+	MaxOccurs   string          `xml:"maxOccurs,attr"`
+	ComplexType *xsdComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleType  *xsdSimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+type xsdComplexType struct {
+	Name           string         `xml:"name,attr"`
+	Sequence       *xsdGroup      `xml:"http://www.w3.org/2001/XMLSchema sequence"`
+	All            *xsdGroup      `xml:"http://www.w3.org/2001/XMLSchema all"`
+	Choice         *xsdGroup      `xml:"http://www.w3.org/2001/XMLSchema choice"`
+	Attributes     []xsdAttribute `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	ComplexContent *xsdContent    `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
+	SimpleContent  *xsdContent    `xml:"http://www.w3.org/2001/XMLSchema simpleContent"`
+}
+
+// xsdGroup sequence, all или choice
+type xsdGroup struct {
+	Elements  []xsdElement `xml:"http://www.w3.org/2001/XMLSchema element"`
+	Sequences []xsdGroup   `xml:"http://www.w3.org/2001/XMLSchema sequence"`
+	Choices   []xsdGroup   `xml:"http://www.w3.org/2001/XMLSchema choice"`
+}
+
+type xsdContent struct {
+	Extension   *xsdDerivation `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	Restriction *xsdDerivation `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+}
+
+type xsdDerivation struct {
+	Base       string         `xml:"base,attr"`
+	Sequence   *xsdGroup      `xml:"http://www.w3.org/2001/XMLSchema sequence"`
+	All        *xsdGroup      `xml:"http://www.w3.org/2001/XMLSchema all"`
+	Choice     *xsdGroup      `xml:"http://www.w3.org/2001/XMLSchema choice"`
+	Attributes []xsdAttribute `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+}
+
+type xsdAttribute struct {
+	Name       string         `xml:"name,attr"`
+	Type       string         `xml:"type,attr"`
+	Ref        string         `xml:"ref,attr"`
+	SimpleType *xsdSimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+type xsdSimpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction *struct {
+		Base string `xml:"base,attr"`
+	} `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+}
+
+// schemaSet глобальные объявления всех схем документа.
+// Имена сопоставляются по локальной части QName без учета пространства имен.
+type schemaSet struct {
+	elements     map[string]*xsdElement
+	complexTypes map[string]*xsdComplexType
+	simpleTypes  map[string]*xsdSimpleType
+}
+
+func newSchemaSet(schemas []xsdSchema) *schemaSet {
+	s := &schemaSet{
+		elements:     make(map[string]*xsdElement),
+		complexTypes: make(map[string]*xsdComplexType),
+		simpleTypes:  make(map[string]*xsdSimpleType),
+	}
+	for i := range schemas {
+		schema := &schemas[i]
+		for j := range schema.Elements {
+			s.elements[schema.Elements[j].Name] = &schema.Elements[j]
+		}
+		for j := range schema.ComplexTypes {
+			s.complexTypes[schema.ComplexTypes[j].Name] = &schema.ComplexTypes[j]
+		}
+		for j := range schema.SimpleTypes {
+			s.simpleTypes[schema.SimpleTypes[j].Name] = &schema.SimpleTypes[j]
+		}
+	}
+	return s
+}
+
+// elementObject строит узел схемы для глобального элемента
+func (s *schemaSet) elementObject(name string) *Object {
+	el, ok := s.elements[localName(name)]
+	if !ok {
+		return nil
+	}
+	return s.element(el, map[string]bool{}, 0)
+}
+
+// typeObject строит узел схемы с заданным именем для типа (part type= в стиле rpc)
+func (s *schemaSet) typeObject(name, typeName string) *Object {
+	return s.typed(name, typeName, map[string]bool{}, 0)
+}
+
+func (s *schemaSet) element(el *xsdElement, visiting map[string]bool, depth int) *Object {
+	if el.Ref != "" {
+		target, ok := s.elements[localName(el.Ref)]
+		if !ok {
+			return repeated(&Object{Name: localName(el.Ref), Type: models.ValueTypeString}, el.MaxOccurs)
+		}
+		ref := *target
+		ref.MaxOccurs = el.MaxOccurs
+		el = &ref
+	}
+
+	var obj *Object
+	switch {
+	case depth > maxSchemaDepth:
+		obj = &Object{Name: el.Name, Type: models.ValueTypeStructure}
+	case el.ComplexType != nil:
+		obj = s.complex(el.Name, el.ComplexType, visiting, depth)
+	case el.SimpleType != nil:
+		obj = &Object{Name: el.Name, Type: s.simpleType(el.SimpleType)}
+	default:
+		obj = s.typed(el.Name, el.Type, visiting, depth)
+	}
+	return repeated(obj, el.MaxOccurs)
+}
+
+// typed строит узел для элемента с атрибутом type
+func (s *schemaSet) typed(name, typeName string, visiting map[string]bool, depth int) *Object {
+	local := localName(typeName)
+	if ct, ok := s.complexTypes[local]; ok {
+		if visiting[local] {
+			// Рекурсивный тип: вложенная структура не раскрывается
+			return &Object{Name: name, Type: models.ValueTypeStructure}
+		}
+		visiting[local] = true
+		defer delete(visiting, local)
+		return s.complex(name, ct, visiting, depth)
+	}
+	return &Object{Name: name, Type: s.scalarType(typeName)}
+}
+
+func (s *schemaSet) complex(name string, ct *xsdComplexType, visiting map[string]bool, depth int) *Object {
+	obj := &Object{Name: name, Type: models.ValueTypeStructure}
+
+	if content := ct.SimpleContent; content != nil {
+		// Текст с атрибутами: значение в #text, как в конвертере XML
+		if d := derivation(content); d != nil {
+			obj.Children = append(obj.Children, &Object{Name: "#text", Type: s.scalarType(d.Base)})
+			obj.Children = append(obj.Children, s.attributes(d.Attributes)...)
+		}
+		obj.Children = append(obj.Children, s.attributes(ct.Attributes)...)
+		return obj
+	}
+
+	if content := ct.ComplexContent; content != nil {
+		if content.Extension != nil {
+			// Поля базового типа идут перед полями расширения
+			if base := s.typed(name, content.Extension.Base, visiting, depth); base.Type == models.ValueTypeStructure {
+				obj.Children = append(obj.Children, base.Children...)
+			}
+		}
+		if d := derivation(content); d != nil {
+			obj.Children = append(obj.Children, s.attributes(d.Attributes)...)
+			for _, g := range []*xsdGroup{d.Sequence, d.All, d.Choice} {
+				obj.Children = append(obj.Children, s.group(g, visiting, depth)...)
+			}
+		}
+	}
+
+	obj.Children = append(obj.Children, s.attributes(ct.Attributes)...)
+	for _, g := range []*xsdGroup{ct.Sequence, ct.All, ct.Choice} {
+		obj.Children = append(obj.Children, s.group(g, visiting, depth)...)
+	}
+	return obj
+}
+
+func derivation(content *xsdContent) *xsdDerivation {
+	if content.Extension != nil {
+		return content.Extension
+	}
+	return content.Restriction
+}
+
+func (s *schemaSet) group(g *xsdGroup, visiting map[string]bool, depth int) []*Object {
+	if g == nil {
+		return nil
+	}
+	var children []*Object
+	for i := range g.Elements {
+		children = append(children, s.element(&g.Elements[i], visiting, depth+1))
+	}
+	for i := range g.Sequences {
+		children = append(children, s.group(&g.Sequences[i], visiting, depth)...)
+	}
+	for i := range g.Choices {
+		children = append(children, s.group(&g.Choices[i], visiting, depth)...)
+	}
+	return children
+}
+
+func (s *schemaSet) attributes(attrs []xsdAttribute) []*Object {
+	var children []*Object
+	for _, attr := range attrs {
+		name := attr.Name
+		if name == "" {
+			name = localName(attr.Ref)
+		}
+		valueType := s.scalarType(attr.Type)
+		if attr.SimpleType != nil {
+			valueType = s.simpleType(attr.SimpleType)
+		}
+		children = append(children, &Object{Name: "@" + name, Type: valueType})
+	}
+	return children
+}
+
+func (s *schemaSet) simpleType(st *xsdSimpleType) models.ValueType {
+	if st.Restriction == nil {
+		// list и union передаются строкой
+		return models.ValueTypeString
+	}
+	return s.scalarType(st.Restriction.Base)
+}
+
+// scalarType сопоставляет встроенный или простой тип XSD с типом значения
+func (s *schemaSet) scalarType(typeName string) models.ValueType {
+	local := localName(typeName)
+	for i := 0; i < maxSchemaDepth; i++ {
+		st, ok := s.simpleTypes[local]
+		if !ok || st.Restriction == nil {
+			break
+		}
+		local = localName(st.Restriction.Base)
+	}
+	return builtinType(local)
+}
+
+// builtinType тип значения для встроенного типа XSD.
+// Дробные числа передаются строкой: отдельного типа для них нет.
+func builtinType(local string) models.ValueType {
+	switch local {
+	case "boolean":
+		return models.ValueTypeBoolean
+	case "date", "dateTime", "time":
+		return models.ValueTypeDate
+	case "int", "integer", "long", "short", "byte",
+		"nonNegativeInteger", "nonPositiveInteger", "positiveInteger", "negativeInteger",
+		"unsignedInt", "unsignedLong", "unsignedShort", "unsignedByte":
+		return models.ValueTypeInteger
+	default:
+		return models.ValueTypeString
+	}
+}
+
+// repeated оборачивает узел в массив, если элемент может повторяться
+func repeated(obj *Object, maxOccurs string) *Object {
+	if maxOccurs == "" || maxOccurs == "1" || maxOccurs == "0" {
+		return obj
+	}
+	if n, err := strconv.Atoi(maxOccurs); err == nil && n <= 1 {
+		return obj
+	}
+	return &Object{Name: obj.Name, Type: models.ValueTypeArray, Children: []*Object{obj}}
+}
+
+// localName отбрасывает префикс QName (tns:Order → Order)
+func localName(qname string) string {
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func parseSchema(t *testing.T, body string) *schemaSet {
+	t.Helper()
+	var schema xsdSchema
+	document := `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test">` + body + `</xsd:schema>`
+	if err := xml.Unmarshal([]byte(document), &schema); err != nil {
+		t.Fatal(err)
+	}
+	return newSchemaSet([]xsdSchema{schema})
+}
+
+func TestSchemaElementObject(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			name: "recursive type is not expanded",
+			schema: `<xsd:element name="Root" type="tns:Node"/>
+				<xsd:complexType name="Node"><xsd:sequence>
+					<xsd:element name="Name" type="xsd:string"/>
+					<xsd:element name="Child" type="tns:Node" minOccurs="0"/>
+				</xsd:sequence></xsd:complexType>`,
+			want: "Root:Structure{Name:String,Child:Structure}",
+		},
+		{
+			name: "extension adds fields after base type",
+			schema: `<xsd:element name="Root" type="tns:Derived"/>
+				<xsd:complexType name="Base"><xsd:sequence><xsd:element name="Id" type="xsd:int"/></xsd:sequence></xsd:complexType>
+				<xsd:complexType name="Derived"><xsd:complexContent><xsd:extension base="tns:Base">
+					<xsd:sequence><xsd:element name="Name" type="xsd:string"/></xsd:sequence>
+					<xsd:attribute name="kind" type="xsd:string"/>
+				</xsd:extension></xsd:complexContent></xsd:complexType>`,
+			want: "Root:Structure{Id:Integer,@kind:String,Name:String}",
+		},
+		{
+			name: "simple content with attribute",
+			schema: `<xsd:element name="Root"><xsd:complexType><xsd:simpleContent>
+					<xsd:extension base="xsd:decimal"><xsd:attribute name="currency" type="xsd:string"/></xsd:extension>
+				</xsd:simpleContent></xsd:complexType></xsd:element>`,
+			want: "Root:Structure{#text:String,@currency:String}",
+		},
+		{
+			name: "element refs and occurrence",
+			schema: `<xsd:element name="Item" type="xsd:int"/>
+				<xsd:element name="Root"><xsd:complexType><xsd:sequence>
+					<xsd:element ref="tns:Item" maxOccurs="5"/>
+					<xsd:element ref="tns:Missing"/>
+					<xsd:element name="Single" type="xsd:boolean" maxOccurs="1"/>
+				</xsd:sequence></xsd:complexType></xsd:element>`,
+			want: "Root:Structure{Item:Array{Item:Integer},Missing:String,Single:Boolean}",
+		},
+		{
+			name: "nested choice and simple types",
+			schema: `<xsd:simpleType name="Code"><xsd:restriction base="tns:Short"/></xsd:simpleType>
+				<xsd:simpleType name="Short"><xsd:restriction base="xsd:short"/></xsd:simpleType>
+				<xsd:simpleType name="Codes"><xsd:list itemType="xsd:int"/></xsd:simpleType>
+				<xsd:element name="Root"><xsd:complexType><xsd:sequence>
+					<xsd:element name="Code" type="tns:Code"/>
+					<xsd:choice>
+						<xsd:element name="Date" type="xsd:date"/>
+						<xsd:element name="Codes" type="tns:Codes"/>
+					</xsd:choice>
+					<xsd:element name="Status"><xsd:simpleType><xsd:restriction base="xsd:boolean"/></xsd:simpleType></xsd:element>
+				</xsd:sequence></xsd:complexType></xsd:element>`,
+			want: "Root:Structure{Code:Integer,Status:Boolean,Date:Date,Codes:String}",
+		},
+		{
+			name:   "unknown element",
+			schema: `<xsd:element name="Other" type="xsd:string"/>`,
+			want:   "<nil>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectString(parseSchema(t, tt.schema).elementObject("tns:Root")); got != tt.want {
+				t.Fatalf("elementObject = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
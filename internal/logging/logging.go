@@ -0,0 +1,205 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CorrelationHeader заголовок идентификатора корреляции запроса
+const CorrelationHeader = "X-Correlation-ID"
+
+// DefaultPackage имя, под которым настраивается уровень по умолчанию
+// и уровень стандартного пакета log
+const DefaultPackage = "default"
+
+// Уровни логирования настраиваются для каждого пакета и меняются во время
+// работы (SetLevel). Записи пакета, для которого уровень не задан, фильтруются
+// по уровню DefaultPackage.
+var (
+	mu     sync.RWMutex
+	base   slog.Handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
+	levels              = map[string]*slog.LevelVar{DefaultPackage: new(slog.LevelVar)}
+)
+
+// Setup настраивает формат вывода (json или text) и уровни пакетов в виде
+// "info,service=debug,adapter=warn". Стандартный пакет log пишет через slog.
+func Setup(format, levelSpec string) error {
+	if err := setOutput(os.Stdout, format); err != nil {
+		return err
+	}
+	if err := SetLevels(levelSpec); err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(&handler{pkg: DefaultPackage}))
+	log.SetFlags(0)
+	return nil
+}
+
+func setOutput(w io.Writer, format string) error {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	mu.Lock()
+	defer mu.Unlock()
+	switch strings.ToLower(format) {
+	case "", "json":
+		base = slog.NewJSONHandler(w, opts)
+	case "text":
+		base = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+	return nil
+}
+
+// For возвращает логгер пакета. Уровень пакета можно изменить через SetLevel.
+func For(pkg string) *slog.Logger {
+	return slog.New(&handler{pkg: pkg}).With("package", pkg)
+}
+
+// SetLevels применяет уровни из строки "info,service=debug,adapter=warn":
+// значение без имени задает уровень по умолчанию
+func SetLevels(spec string) error {
+	values := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pkg, value, found := strings.Cut(part, "=")
+		if !found {
+			pkg, value = DefaultPackage, part
+		}
+		values[strings.TrimSpace(pkg)] = strings.TrimSpace(value)
+	}
+	return SetLevelMap(values)
+}
+
+// SetLevelMap задает уровни пакетов (debug, info, warn, error).
+// Если хотя бы один уровень неверен, ни один не меняется.
+func SetLevelMap(values map[string]string) error {
+	parsed := make(map[string]slog.Level, len(values))
+	for pkg, value := range values {
+		if pkg == "" {
+			return fmt.Errorf("empty package name for log level %q", value)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid log level %q for %s", value, pkg)
+		}
+		parsed[pkg] = level
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for pkg, level := range parsed {
+		levelVar, ok := levels[pkg]
+		if !ok {
+			levelVar = new(slog.LevelVar)
+			levels[pkg] = levelVar
+		}
+		levelVar.Set(level)
+	}
+	return nil
+}
+
+// Levels возвращает заданные уровни пакетов
+func Levels() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	result := make(map[string]string, len(levels))
+	for pkg, levelVar := range levels {
+		result[pkg] = strings.ToLower(levelVar.Level().String())
+	}
+	return result
+}
+
+func levelOf(pkg string) slog.Level {
+	mu.RLock()
+	defer mu.RUnlock()
+	if levelVar, ok := levels[pkg]; ok {
+		return levelVar.Level()
+	}
+	return levels[DefaultPackage].Level()
+}
+
+func currentBase() slog.Handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return base
+}
+
+type fieldsKey struct{}
+
+// WithFields добавляет поля (пары ключ-значение), которые попадут во все
+// записи лога с этим контекстом: correlation_id, message_id, thread, route, system
+func WithFields(ctx context.Context, args ...any) context.Context {
+	fields := append(fieldsFromContext(ctx), slog.Group("", args...).Value.Group()...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func fieldsFromContext(ctx context.Context) []slog.Attr {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	// Копия, чтобы вложенные контексты не меняли поля родителя
+	return append([]slog.Attr(nil), fields...)
+}
+
+type correlationKey struct{}
+
+// WithCorrelationID сохраняет идентификатор корреляции в контексте и в полях лога
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, correlationKey{}, id)
+	return WithFields(ctx, "correlation_id", id)
+}
+
+// CorrelationID возвращает идентификатор корреляции из контекста
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// handler фильтрует записи по уровню пакета и добавляет поля контекста
+// и идентификатор трассы
+type handler struct {
+	pkg   string
+	attrs []slog.Attr
+	group string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelOf(h.pkg)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		record.AddAttrs(fieldsFromContext(ctx)...)
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+			record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+		}
+	}
+	next := currentBase()
+	if len(h.attrs) > 0 {
+		next = next.WithAttrs(h.attrs)
+	}
+	if h.group != "" {
+		next = next.WithGroup(h.group)
+	}
+	return next.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{pkg: h.pkg, attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...), group: h.group}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	return &handler{pkg: h.pkg, attrs: h.attrs, group: name}
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// restoreLogging возвращает вывод и уровни пакетов после теста
+func restoreLogging(t *testing.T) {
+	t.Helper()
+	mu.Lock()
+	saved := levels
+	levels = map[string]*slog.LevelVar{DefaultPackage: new(slog.LevelVar)}
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		levels = saved
+		mu.Unlock()
+		setOutput(os.Stdout, "json")
+	})
+}
+
+func TestSetupLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		spec    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "default level", spec: "warn", want: map[string]string{DefaultPackage: "warn"}},
+		{name: "package levels", format: "text", spec: " info , service=debug,adapter = ERROR ,",
+			want: map[string]string{DefaultPackage: "info", "service": "debug", "adapter": "error"}},
+		{name: "empty spec keeps info", format: "JSON", spec: "", want: map[string]string{DefaultPackage: "info"}},
+		{name: "unknown level", spec: "info,service=verbose", wantErr: true},
+		{name: "empty package", spec: "=debug", wantErr: true},
+		{name: "unknown format", format: "xml", spec: "debug", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreLogging(t)
+			err := Setup(tt.format, tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				// Неверная строка уровней не меняет ни один уровень
+				if got := Levels(); !reflect.DeepEqual(got, map[string]string{DefaultPackage: "info"}) {
+					t.Fatalf("levels after error = %v, want defaults", got)
+				}
+				return
+			}
+			if got := Levels(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("levels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackageLevelAndContextFields(t *testing.T) {
+	restoreLogging(t)
+	var out bytes.Buffer
+	if err := setOutput(&out, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLevels("warn,service=debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = WithFields(WithCorrelationID(ctx, "corr-1"), "thread", "orders")
+
+	For("adapter").InfoContext(ctx, "filtered by default level")
+	For("service").DebugContext(ctx, "service debug")
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("want exactly one record, got %q: %v", out.String(), err)
+	}
+	want := map[string]any{
+		"msg": "service debug", "package": "service", "correlation_id": "corr-1",
+		"thread": "orders", "trace_id": traceID.String(),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Fatalf("record %s = %v, want %v (record %v)", key, record[key], value, record)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Метрики Prometheus шины. Метки thread, route и system содержат имена,
+// а не ссылки, чтобы графики читались без справочников.
+
+const namespace = "esb"
+
+var (
+	// MessagesReceived входящие сообщения по thread
+	MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Messages received for routing, by thread, protocol, direction and format.",
+	}, []string{"thread", "protocol", "direction", "format"})
+
+	// MessagesSent сообщения, успешно отправленные по маршрутам
+	MessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Messages delivered to target systems, by thread, route, system and protocol.",
+	}, routeLabelNames)
+
+	// MessagesFailed сообщения, которые не удалось отправить по маршрутам
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_failed_total",
+		Help:      "Messages that failed to be delivered, by thread, route, system and protocol.",
+	}, routeLabelNames)
+
+	// RouteDuration полное время обработки маршрута: конвертация, аутентификация, отправка
+	RouteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "route_duration_seconds",
+		Help:      "Time to convert and deliver a message over a thread route.",
+		Buckets:   prometheus.DefBuckets,
+	}, routeLabelNames)
+
+	// AdapterDuration время запроса адаптера к целевой системе
+	AdapterDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "adapter_request_duration_seconds",
+		Help:      "Latency of protocol adapter requests to target systems.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"protocol", "system"})
+
+	// AdapterResponses коды ответов целевых систем (error — ответа нет)
+	AdapterResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "adapter_responses_total",
+		Help:      "Responses of target systems by status code; code=error when no response was received.",
+	}, []string{"protocol", "system", "code"})
+
+	// ConversionDuration время конвертации по паре форматов
+	ConversionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "conversion_duration_seconds",
+		Help:      "Time to convert a message between formats.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"from", "to"})
+
+	// ConversionErrors ошибки конвертации по паре форматов
+	ConversionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "conversion_errors_total",
+		Help:      "Failed conversions between formats.",
+	}, []string{"from", "to"})
+
+	// OrchestrationDuration полное время выполнения бизнес-процесса
+	OrchestrationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "orchestration_duration_seconds",
+		Help:      "Duration of orchestrated business processes by result.",
+		Buckets:   []float64{.1, .25, .5, 1, 2, 3, 5, 7.5, 10, 20, 30},
+	}, []string{"process", "status"})
+
+	// OrchestrationStepDuration время шагов бизнес-процесса
+	OrchestrationStepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "orchestration_step_duration_seconds",
+		Help:      "Duration of business process steps by result.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10},
+	}, []string{"process", "step", "status"})
+
+	// OrchestrationTargetBreaches процессы, превысившие целевое время выполнения
+	OrchestrationTargetBreaches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orchestration_target_breaches_total",
+		Help:      "Business processes that took longer than their target duration.",
+	}, []string{"process"})
+
+	// CircuitBreakerState состояние выключателя подключения: 0 — замкнут, 1 — проба, 2 — разомкнут
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state by system and connection: 0 closed, 1 half-open, 2 open.",
+	}, []string{"system", "connection"})
+
+	// CircuitBreakerOpened размыкания выключателя
+	CircuitBreakerOpened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_opened_total",
+		Help:      "Times the circuit breaker of a connection has opened.",
+	}, []string{"system", "connection"})
+
+	// CircuitBreakerRejections сообщения, не отправленные из-за разомкнутого выключателя
+	CircuitBreakerRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "Requests rejected without being sent because the circuit breaker was open.",
+	}, []string{"system", "connection"})
+
+	// ThrottleWait время ожидания разрешения ограничителя исходящих запросов
+	ThrottleWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "throttle_wait_seconds",
+		Help:      "Time outbound requests waited for the rate and concurrency limits of a connection.",
+		Buckets:   []float64{.001, .01, .05, .1, .25, .5, 1, 2, 5, 10},
+	}, []string{"system", "connection"})
+
+	// ThrottleRejections запросы, отклоненные ограничителем (reason: rate, concurrency, backoff)
+	ThrottleRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "throttle_rejections_total",
+		Help:      "Outbound requests rejected by connection limits, by reason.",
+	}, []string{"system", "connection", "reason"})
+
+	// ThrottleInFlight одновременные запросы подключения с ограничением max_in_flight
+	ThrottleInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "throttle_in_flight_requests",
+		Help:      "Outbound requests in flight for connections with a concurrency limit.",
+	}, []string{"system", "connection"})
+
+	// EndpointHealthy доступность подключения по активной проверке (1 — в ротации)
+	EndpointHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "endpoint_healthy",
+		Help:      "Whether a connection passes its health check and is in rotation (1) or not (0).",
+	}, []string{"system", "connection"})
+
+	// EndpointFailovers переключения на следующее подключение системы после ошибки
+	EndpointFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "endpoint_failovers_total",
+		Help:      "Requests retried on the next connection of a system after the connection failed.",
+	}, []string{"system", "connection"})
+
+	// RouteRetries повторные отправки маршрута после временной ошибки
+	RouteRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "route_retries_total",
+		Help:      "Route sends retried after a transient failure of the target system.",
+	}, []string{"thread", "route"})
+
+	// IdempotencyDuplicates повторно полученные входящие сообщения
+	// (kind: thread, process, webhook; result: replayed, in_progress, mismatch)
+	IdempotencyDuplicates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "idempotency_duplicates_total",
+		Help:      "Inbound messages with an already seen idempotency key, by scope kind and result.",
+	}, []string{"kind", "result"})
+
+	// AMQPConnectionUp состояние соединения с брокером подключения системы
+	// (1 — подключено): публикация и чтение очередей threads
+	AMQPConnectionUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "amqp_connection_up",
+		Help:      "Whether the connection to the AMQP broker of a system connection is open (1) or not (0).",
+	}, []string{"system", "connection"})
+)
+
+var routeLabelNames = []string{"thread", "route", "system", "protocol"}
+
+// RouteLabels метки метрик маршрута
+type RouteLabels struct {
+	Thread   string
+	Route    string
+	System   string
+	Protocol string
+}
+
+func (l RouteLabels) values() []string {
+	return []string{l.Thread, l.Route, l.System, l.Protocol}
+}
+
+// Handler обработчик endpoint /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRoute учитывает результат и время обработки маршрута
+func ObserveRoute(labels RouteLabels, err error, duration time.Duration) {
+	values := labels.values()
+	RouteDuration.WithLabelValues(values...).Observe(duration.Seconds())
+	if err != nil {
+		MessagesFailed.WithLabelValues(values...).Inc()
+		return
+	}
+	MessagesSent.WithLabelValues(values...).Inc()
+}
+
+// ObserveAdapterRequest учитывает время запроса адаптера и код ответа (0 — ответа нет)
+func ObserveAdapterRequest(protocol, system string, statusCode int, duration time.Duration) {
+	AdapterDuration.WithLabelValues(protocol, system).Observe(duration.Seconds())
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	AdapterResponses.WithLabelValues(protocol, system, code).Inc()
+}
+
+// ObserveConversion учитывает время и результат конвертации
+func ObserveConversion(from, to string, err error, duration time.Duration) {
+	ConversionDuration.WithLabelValues(from, to).Observe(duration.Seconds())
+	if err != nil {
+		ConversionErrors.WithLabelValues(from, to).Inc()
+	}
+}
+
+// ObserveOrchestration учитывает время и результат бизнес-процесса
+func ObserveOrchestration(process string, err error, duration time.Duration) {
+	OrchestrationDuration.WithLabelValues(process, status(err)).Observe(duration.Seconds())
+}
+
+// ObserveOrchestrationStep учитывает время и результат шага бизнес-процесса
+func ObserveOrchestrationStep(process, step string, err error, duration time.Duration) {
+	OrchestrationStepDuration.WithLabelValues(process, step, status(err)).Observe(duration.Seconds())
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
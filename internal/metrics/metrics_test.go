@@ -0,0 +1,274 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-esb/internal/adapter"
+	"go-esb/internal/balancer"
+	"go-esb/internal/breaker"
+	"go-esb/internal/metrics"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+	"go-esb/internal/service"
+	"go-esb/internal/throttle"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// routing конфигурация одного REST thread с маршрутами в систему sap;
+// методы репозиториев, не нужные маршрутизации, не реализованы
+type routing struct {
+	thread      models.Thread
+	system      models.System
+	routes      map[uuid.UUID]*models.Route
+	threadRoute []models.ThreadRoute
+	connection  models.ConnectionSetting
+}
+
+type (
+	threadRouteRepo struct {
+		repository.ThreadRouteRepository
+		*routing
+	}
+	routeRepo struct {
+		repository.RouteRepository
+		*routing
+	}
+	systemRepo struct {
+		repository.SystemRepository
+		*routing
+	}
+	connectionRepo struct {
+		repository.ConnectionRepository
+		*routing
+	}
+)
+
+func (r threadRouteRepo) GetThreadWithGroup(context.Context, uuid.UUID) (*models.Thread, *models.ThreadGroup, error) {
+	return &r.thread, &models.ThreadGroup{Ref: uuid.New(), Protocol: models.ProtocolREST}, nil
+}
+
+func (r threadRouteRepo) GetThreadRouteByDirection(_ context.Context, _ uuid.UUID, direction models.Directions) ([]models.ThreadRoute, error) {
+	if direction != models.DirectionOut {
+		return nil, nil
+	}
+	return r.threadRoute, nil
+}
+
+func (r threadRouteRepo) GetThreadsWithAMQPConsumer(context.Context) ([]models.Thread, error) {
+	return []models.Thread{r.thread}, nil
+}
+
+func (r routeRepo) GetByID(_ context.Context, id uuid.UUID) (*models.Route, error) {
+	route, ok := r.routes[id]
+	if !ok {
+		return nil, errors.New("route not found")
+	}
+	return route, nil
+}
+
+func (r systemRepo) GetByID(context.Context, uuid.UUID) (*models.System, error) {
+	return &r.system, nil
+}
+
+func (r connectionRepo) GetConnectionSettingsBySystem(context.Context, uuid.UUID) ([]models.ConnectionSetting, error) {
+	return []models.ConnectionSetting{r.connection}, nil
+}
+
+func (r *routing) addRoute(name string, format models.FileFormat) {
+	route := &models.Route{Ref: uuid.New(), Name: name, Path: "/" + name, System: r.system.Ref, Method: "POST"}
+	r.routes[route.Ref] = route
+	r.threadRoute = append(r.threadRoute, models.ThreadRoute{
+		Thread: r.thread.Ref, Direction: models.DirectionOut, Route: route.Ref, FileFormat: format,
+	})
+}
+
+func TestRouteMessageMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rejected" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	system := models.System{Ref: uuid.New(), Name: "sap"}
+	cfg := &routing{
+		thread:     models.Thread{Ref: uuid.New(), Name: "orders"},
+		system:     system,
+		routes:     make(map[uuid.UUID]*models.Route),
+		connection: models.ConnectionSetting{Ref: uuid.New(), Name: "primary", System: system.Ref, Path: server.URL},
+	}
+	cfg.addRoute("delivered", models.FileFormatYAML)
+	cfg.addRoute("rejected", models.FileFormatJSON)
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics.MessagesReceived, metrics.MessagesSent, metrics.MessagesFailed, metrics.RouteDuration,
+		metrics.AdapterDuration, metrics.AdapterResponses, metrics.ConversionDuration, metrics.ConversionErrors)
+
+	svc := service.NewMessageService(threadRouteRepo{routing: cfg}, routeRepo{routing: cfg}, connectionRepo{routing: cfg},
+		systemRepo{routing: cfg}, nil, nil, secrets.NewResolver(0), breaker.NewRegistry(), throttle.NewRegistry(), balancer.NewRegistry())
+	_, err := svc.RouteMessage(context.Background(), cfg.thread.Ref, models.DirectionOut,
+		&models.Message{Data: []byte(`{"order":{"id":1}}`), Format: models.FileFormatJSON})
+	if err == nil {
+		t.Fatal("RouteMessage succeeded, want error of the rejected route")
+	}
+
+	counters := []struct {
+		name      string
+		collector prometheus.Collector
+		want      float64
+	}{
+		{"received", metrics.MessagesReceived.WithLabelValues("orders", "REST", "Out", "JSON"), 1},
+		{"sent", metrics.MessagesSent.WithLabelValues("orders", "delivered", "sap", "REST"), 1},
+		{"failed", metrics.MessagesFailed.WithLabelValues("orders", "rejected", "sap", "REST"), 1},
+		{"responses 200", metrics.AdapterResponses.WithLabelValues("REST", "sap", "200"), 1},
+		{"responses 400", metrics.AdapterResponses.WithLabelValues("REST", "sap", "400"), 1},
+		{"conversion errors", metrics.ConversionErrors.WithLabelValues("JSON", "YAML"), 0},
+	}
+	for _, c := range counters {
+		if got := testutil.ToFloat64(c.collector); got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	histograms := []struct {
+		name   string
+		labels map[string]string
+		want   uint64
+	}{
+		{"esb_route_duration_seconds", map[string]string{"thread": "orders", "route": "delivered", "system": "sap", "protocol": "REST"}, 1},
+		{"esb_route_duration_seconds", map[string]string{"thread": "orders", "route": "rejected", "system": "sap", "protocol": "REST"}, 1},
+		{"esb_adapter_request_duration_seconds", map[string]string{"protocol": "REST", "system": "sap"}, 2},
+		{"esb_conversion_duration_seconds", map[string]string{"from": "JSON", "to": "YAML"}, 1},
+		{"esb_conversion_duration_seconds", map[string]string{"from": "JSON", "to": "JSON"}, 1},
+	}
+	for _, h := range histograms {
+		if got := sampleCount(families, h.name, h.labels); got != h.want {
+			t.Errorf("%s%v observed %d times, want %d", h.name, h.labels, got, h.want)
+		}
+	}
+}
+
+// sampleCount возвращает число наблюдений гистограммы с точным набором меток
+func sampleCount(families []*dto.MetricFamily, name string, labels map[string]string) uint64 {
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metric
+				}
+			}
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+// gaugeValue возвращает значение gauge с точным набором меток
+func gaugeValue(families []*dto.MetricFamily, name string, labels map[string]string) (float64, bool) {
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metric
+				}
+			}
+			return m.GetGauge().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+// unreachableBroker возвращает адрес amqp://, на котором никто не слушает
+func unreachableBroker(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "amqp://guest:guest@" + addr + "/"
+}
+
+func TestAMQPConnectionUp(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics.AMQPConnectionUp)
+	connectionUp := func(connection string) (float64, bool) {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gaugeValue(families, "esb_amqp_connection_up", map[string]string{"system": "rabbit", "connection": connection})
+	}
+
+	t.Run("publish to unreachable broker", func(t *testing.T) {
+		connection := models.ConnectionSetting{Ref: uuid.New(), Name: "publisher", Path: unreachableBroker(t)}
+		metrics.AMQPConnectionUp.WithLabelValues("rabbit", connection.Name).Set(1)
+
+		ctx := adapter.WithConnection(context.Background(), adapter.Connection{Settings: &connection, System: "rabbit"})
+		if _, _, err := adapter.NewAMQPAdapter().Send(ctx, connection.Path+"orders", "", nil, []byte(`{}`)); err == nil {
+			t.Fatal("Send succeeded without a broker")
+		}
+		if got, ok := connectionUp(connection.Name); !ok || got != 0 {
+			t.Fatalf("esb_amqp_connection_up = %v (collected %v), want 0", got, ok)
+		}
+	})
+
+	t.Run("consumer reconnect", func(t *testing.T) {
+		system := models.System{Ref: uuid.New(), Name: "rabbit"}
+		cfg := &routing{
+			thread: models.Thread{Ref: uuid.New(), Name: "orders",
+				AMQPConsumer: models.AMQPConsumerSettings{System: system.Ref, Queue: "orders.in"}},
+			system:     system,
+			connection: models.ConnectionSetting{Ref: uuid.New(), Name: "consumer", System: system.Ref, Path: unreachableBroker(t)},
+		}
+		metrics.AMQPConnectionUp.WithLabelValues("rabbit", "consumer").Set(1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		consumer := service.NewAMQPConsumer(threadRouteRepo{routing: cfg}, connectionRepo{routing: cfg}, systemRepo{routing: cfg},
+			secrets.NewResolver(0), nil, nil)
+		go consumer.Run(ctx)
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			got, ok := connectionUp("consumer")
+			if ok && got == 0 {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("esb_amqp_connection_up = %v (collected %v), want 0 after the connection failed", got, ok)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
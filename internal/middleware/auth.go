@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go-esb/internal/auth"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Authenticator проверяет учетные данные запроса
+type Authenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (*auth.Principal, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+// Auth middleware аутентификации HTTP API: JWT в заголовке
+// Authorization: Bearer или API ключ в X-API-Key (либо Authorization: Bearer esb_...)
+func Auth(authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			credential, isAPIKey := requestCredential(r)
+			if credential == "" {
+				unauthorized(w)
+				return
+			}
+
+			var principal *auth.Principal
+			var err error
+			if isAPIKey {
+				principal, err = authenticator.AuthenticateAPIKey(r.Context(), credential)
+			} else {
+				principal, err = authenticator.AuthenticateToken(r.Context(), credential)
+			}
+			if err != nil {
+				logger.WarnContext(r.Context(), "🚫 Authentication failed", "method", r.Method, "path", r.URL.Path, "error", err)
+				unauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RequirePermission middleware проверки разрешения по роли пользователя
+func RequirePermission(perm auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.PrincipalFromContext(r.Context())
+			if principal == nil || !principal.Can(perm) {
+				forbidden(w, r, principal, perm)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireGroupPermission middleware проверки разрешения, которое дает роль
+// пользователя или его роль хотя бы в одной группе threads. Разрешение для
+// threads, которые затрагивает запрос, проверяет обработчик.
+func RequireGroupPermission(perm auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.PrincipalFromContext(r.Context())
+			if principal == nil || !principal.CanInAnyGroup(perm) {
+				forbidden(w, r, principal, perm)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireThreadPermission middleware проверки разрешения для thread из
+// параметра пути {threadId}: учитываются роли пользователя в группе thread
+func RequireThreadPermission(perm auth.Permission, threadGroup func(ctx context.Context, threadID string) (uuid.UUID, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.PrincipalFromContext(r.Context())
+			if principal == nil {
+				unauthorized(w)
+				return
+			}
+			if !principal.Can(perm) {
+				groupID, err := threadGroup(r.Context(), mux.Vars(r)["threadId"])
+				if err != nil || !principal.CanInGroup(groupID, perm) {
+					forbidden(w, r, principal, perm)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestCredential возвращает токен или API ключ из заголовков запроса
+func requestCredential(r *http.Request) (credential string, isAPIKey bool) {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key, true
+	}
+	scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, auth.IsAPIKey(token)
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="go-esb"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, principal *auth.Principal, perm auth.Permission) {
+	username := ""
+	if principal != nil {
+		username = principal.Username
+	}
+	logger.WarnContext(r.Context(), "🚫 Permission denied", "user", username, "permission", perm, "method", r.Method, "path", r.URL.Path)
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-esb/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+// maxCorrelationIDLength ограничение длины идентификатора корреляции вызывающего
+const maxCorrelationIDLength = 128
+
+// CorrelationID middleware берет идентификатор корреляции из заголовка
+// X-Correlation-ID (или создает новый), возвращает его в ответе и сохраняет
+// в контексте: он попадает во все записи лога и в заголовки исходящих сообщений.
+func CorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.CorrelationHeader)
+		if id == "" || len(id) > maxCorrelationIDLength {
+			id = uuid.NewString()
+		}
+		w.Header().Set(logging.CorrelationHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithCorrelationID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-esb/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+func TestCorrelationID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{name: "caller id kept", header: "order-42", wantKept: true},
+		{name: "missing id generated"},
+		{name: "too long id replaced", header: strings.Repeat("x", maxCorrelationIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inContext string
+			handler := CorrelationID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = logging.CorrelationID(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/messages/process/1", nil)
+			if tt.header != "" {
+				req.Header.Set(logging.CorrelationHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			returned := rec.Header().Get(logging.CorrelationHeader)
+			if returned == "" || returned != inContext {
+				t.Fatalf("response id %q, context id %q; want the same id", returned, inContext)
+			}
+			if tt.wantKept && returned != tt.header {
+				t.Fatalf("id %q, want caller id %q", returned, tt.header)
+			}
+			if !tt.wantKept {
+				if _, err := uuid.Parse(returned); err != nil {
+					t.Fatalf("generated id %q is not a UUID", returned)
+				}
+			}
+		})
+	}
+}
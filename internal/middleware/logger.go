@@ -1,11 +1,20 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"go-esb/internal/logging"
+	"go-esb/internal/models"
 )
 
+var logger = logging.For("middleware")
+
+// sensitiveParams части имен query параметров, значения которых не пишутся в лог
+var sensitiveParams = []string{"password", "secret", "token", "key", "signature", "auth"}
+
 // Logger middleware для логирования HTTP запросов
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -16,18 +25,34 @@ func Logger(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		duration := time.Since(start)
-		log.Printf(
-			"%s %s %s %d %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			wrapped.statusCode,
-			duration,
+		logger.InfoContext(r.Context(), "🌐 HTTP request",
+			"method", r.Method,
+			"path", redactURI(r.URL),
+			"remote_addr", r.RemoteAddr,
+			"status", wrapped.statusCode,
+			"duration_ms", time.Since(start).Milliseconds(),
 		)
 	})
 }
 
+// redactURI возвращает путь запроса со скрытыми значениями секретных параметров
+func redactURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		for _, sensitive := range sensitiveParams {
+			if strings.Contains(lower, sensitive) {
+				query[name] = []string{models.RedactedSecret}
+				break
+			}
+		}
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
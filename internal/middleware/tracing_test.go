@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-esb/internal/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans записывает spans до конца теста
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), tracing.ExporterNone); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTracing(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name        string
+		traceparent string
+		status      int
+		wantStatus  codes.Code
+	}{
+		{name: "continues caller trace", traceparent: traceparent, status: http.StatusOK, wantStatus: codes.Unset},
+		{name: "starts new trace", status: http.StatusAccepted, wantStatus: codes.Unset},
+		{name: "client error is not span error", status: http.StatusBadRequest, wantStatus: codes.Unset},
+		{name: "server error", traceparent: traceparent, status: http.StatusBadGateway, wantStatus: codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			var handlerSpan trace.SpanContext
+			router := mux.NewRouter()
+			router.Use(Tracing)
+			router.HandleFunc("/api/v1/messages/process/{threadId}", func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tt.status)
+			}).Methods("POST")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/messages/process/42", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != "POST /api/v1/messages/process/{threadId}" || span.SpanKind() != trace.SpanKindServer {
+				t.Fatalf("span %q of kind %v, want server span named by route template", span.Name(), span.SpanKind())
+			}
+			// Обработчик выполняется внутри span сервера
+			if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+				t.Fatalf("handler span %s, want %s", handlerSpan.SpanID(), span.SpanContext().SpanID())
+			}
+			parent := span.Parent()
+			if tt.traceparent != "" && (parent.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || parent.SpanID().String() != "00f067aa0ba902b7") {
+				t.Fatalf("span parent %s/%s, want caller traceparent", parent.TraceID(), parent.SpanID())
+			}
+			if tt.traceparent == "" && parent.IsValid() {
+				t.Fatalf("span has parent %s without traceparent", parent.SpanID())
+			}
+			if span.Status().Code != tt.wantStatus {
+				t.Fatalf("span status %v, want %v", span.Status().Code, tt.wantStatus)
+			}
+			if !hasAttribute(span.Attributes(), attribute.Int("http.response.status_code", tt.status)) {
+				t.Fatalf("span attributes %v lack status code %d", span.Attributes(), tt.status)
+			}
+		})
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
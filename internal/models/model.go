@@ -1,6 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -35,6 +40,15 @@ const (
 	FileFormatDBF  FileFormat = "DBF"
 	FileFormatCSV  FileFormat = "CSV"
 	FileFormatTXT  FileFormat = "TXT"
+	// FileFormatCommerceML формат обмена 1С:Предприятие (CommerceML 2.x)
+	FileFormatCommerceML  FileFormat = "CommerceML"
+	FileFormatYAML        FileFormat = "YAML"
+	FileFormatMessagePack FileFormat = "MessagePack"
+	// FileFormatProtobuf требует схему (proto_descriptor и proto_message) в маршруте
+	FileFormatProtobuf FileFormat = "Protobuf"
+	// FileFormatEDIFACT и FileFormatX12 форматы EDI, настройки в edi_settings маршрута
+	FileFormatEDIFACT FileFormat = "EDIFACT"
+	FileFormatX12     FileFormat = "X12"
 )
 
 type RoutineType string
@@ -73,6 +87,18 @@ type AuthenticationType string
 const (
 	AuthBasic       AuthenticationType = "Basic"
 	AuthBearerToken AuthenticationType = "BearerToken"
+	// AuthOAuth2 токен OAuth2 (client_credentials или refresh_token) из token_url
+	AuthOAuth2 AuthenticationType = "OAuth2"
+	// AuthWSSecurity заголовок WS-Security для SOAP: UsernameToken, Timestamp, подпись XML-DSig
+	AuthWSSecurity AuthenticationType = "WSSecurity"
+	// AuthCertificate сертификат клиента TLS (mTLS) для REST, SOAP и AMQP (amqps)
+	AuthCertificate AuthenticationType = "Certificate"
+)
+
+// Типы пароля WS-Security UsernameToken
+const (
+	WSPasswordText   = "PasswordText"
+	WSPasswordDigest = "PasswordDigest"
 )
 
 type RestMethod string
@@ -85,6 +111,20 @@ const (
 	MethodDelete RestMethod = "Delete"
 )
 
+// UserRole роль пользователя HTTP API шины
+type UserRole string
+
+const (
+	// RoleAdmin полный доступ, включая управление пользователями и API ключами
+	RoleAdmin UserRole = "admin"
+	// RoleIntegrator настройка интеграций (импорт, схемы) и отправка сообщений
+	RoleIntegrator UserRole = "integrator"
+	// RoleOperator отправка сообщений и запуск процессов
+	RoleOperator UserRole = "operator"
+	// RoleReadOnly только чтение
+	RoleReadOnly UserRole = "read_only"
+)
+
 //
 // === Основные сущности ===
 //
@@ -92,7 +132,34 @@ const (
 type User struct {
 	Ref      uuid.UUID `db:"ref" json:"ref"`
 	Username string    `db:"username" json:"username"`
-	Password string    `db:"password" json:"password"`
+	// Password хэш bcrypt пароля
+	Password string   `db:"password" json:"-"`
+	Role     UserRole `db:"role" json:"role"`
+	// Active отключенный пользователь не входит, его токены и API ключи не действуют
+	Active bool `db:"active" json:"active"`
+	// TokenVersion номер, записываемый в токены доступа; увеличивается при
+	// смене пароля и отключении, чтобы выданные раньше токены не действовали
+	TokenVersion int `db:"token_version" json:"-"`
+}
+
+// UserThreadGroup роль пользователя в группе threads, дополняет роль пользователя
+type UserThreadGroup struct {
+	User        uuid.UUID `db:"user_ref" json:"user"`
+	ThreadGroup uuid.UUID `db:"thread_group" json:"thread_group"`
+	Role        UserRole  `db:"role" json:"role"`
+}
+
+// APIKey ключ доступа к HTTP API для машинных клиентов. Ключ действует
+// с правами пользователя, в БД хранится только его хэш SHA-256.
+type APIKey struct {
+	Ref        uuid.UUID  `db:"ref" json:"ref"`
+	User       uuid.UUID  `db:"user_ref" json:"user"`
+	Name       string     `db:"name" json:"name"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	KeyHash    string     `db:"key_hash" json:"-"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
 }
 
 type Global struct {
@@ -103,14 +170,34 @@ type Global struct {
 type System struct {
 	Ref  uuid.UUID `db:"ref" json:"ref"`
 	Name string    `db:"name" json:"name"`
+	// Выбор подключения, если у системы их несколько
+	LoadBalancing LoadBalancing `db:"load_balancing" json:"load_balancing"`
 }
 
+// LoadBalancing стратегия выбора подключения системы среди подключений
+// с наименьшим priority; подключения с большим priority — резервные
+type LoadBalancing string
+
+const (
+	// BalancingFailover всегда первое доступное подключение (по weight, затем по имени)
+	BalancingFailover LoadBalancing = "failover"
+	// BalancingRoundRobin подключения по очереди
+	BalancingRoundRobin LoadBalancing = "round_robin"
+	// BalancingWeighted подключения по очереди пропорционально weight
+	BalancingWeighted LoadBalancing = "weighted"
+)
+
 type Route struct {
 	Ref    uuid.UUID  `db:"ref" json:"ref"`
 	Name   string     `db:"name" json:"name"`
 	Path   string     `db:"path" json:"path"`
 	System uuid.UUID  `db:"system" json:"system"`
 	Method RestMethod `db:"method" json:"method"`
+	// SOAPAction операции SOAP (для маршрутов, импортированных из WSDL)
+	SOAPAction string `db:"soap_action" json:"soap_action"`
+	// Корневые thread_objects схем запроса и ответа (для импортированных маршрутов)
+	RequestObject  *uuid.UUID `db:"request_object" json:"request_object,omitempty"`
+	ResponseObject *uuid.UUID `db:"response_object" json:"response_object,omitempty"`
 }
 
 type ConnectionSetting struct {
@@ -120,8 +207,173 @@ type ConnectionSetting struct {
 	Path    string    `db:"path" json:"path"`
 	Port    int       `db:"port" json:"port"`
 	AuthRef uuid.UUID `db:"auth" json:"auth"`
+	// Кодировки, в которых система отправляет и принимает данные
+	SourceCharset string `db:"source_charset" json:"source_charset"`
+	TargetCharset string `db:"target_charset" json:"target_charset"`
+	// Версия SOAP: 1.1 (по умолчанию) или 1.2
+	SOAPVersion string `db:"soap_version" json:"soap_version"`
+	// Параметры автоматического выключателя (circuit breaker) подключения
+	CircuitBreaker CircuitBreakerSettings `db:"circuit_breaker" json:"circuit_breaker"`
+	// Ограничения частоты и числа одновременных исходящих запросов
+	RateLimit RateLimitSettings `db:"rate_limit" json:"rate_limit"`
+	// Таймауты, пул соединений, прокси и TLS клиента HTTP (REST и SOAP)
+	HTTPClient HTTPClientSettings `db:"http_client" json:"http_client"`
+	// Порядок подключений системы: меньший priority используется первым,
+	// weight — доля запросов при стратегии weighted
+	Priority int `db:"priority" json:"priority"`
+	Weight   int `db:"weight" json:"weight"`
+	// Активная проверка доступности подключения
+	HealthCheck HealthCheckSettings `db:"health_check" json:"health_check"`
+	// Профиль окружения (ESB_ENV), в котором действует подключение; пустой — во всех
+	Environment string `db:"environment" json:"environment"`
+}
+
+// HealthCheckSettings параметры активной проверки подключения (хранятся в JSONB).
+// Проверка выключена, пока не задан interval_ms.
+type HealthCheckSettings struct {
+	// IntervalMs период проверки
+	IntervalMs int `json:"interval_ms,omitempty"`
+	// TimeoutMs время ожидания ответа (по умолчанию 5 с)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// Path адрес GET запроса проверки: абсолютный или относительно path подключения.
+	// Если не задан, проверяется установка TCP соединения с хостом подключения.
+	Path string `json:"path,omitempty"`
+	// ExpectedStatus ожидаемый код ответа (по умолчанию любой код меньше 400)
+	ExpectedStatus int `json:"expected_status,omitempty"`
+	// UnhealthyThreshold число неудачных проверок подряд, после которого подключение
+	// выводится из ротации (по умолчанию 2)
+	UnhealthyThreshold int `json:"unhealthy_threshold,omitempty"`
+	// HealthyThreshold число успешных проверок подряд для возврата в ротацию (по умолчанию 2)
+	HealthyThreshold int `json:"healthy_threshold,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *HealthCheckSettings) Scan(src interface{}) error {
+	*s = HealthCheckSettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s HealthCheckSettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// HTTPClientSettings настройки клиента HTTP подключения (хранятся в JSONB).
+// Нулевые значения заменяются значениями по умолчанию.
+type HTTPClientSettings struct {
+	// TimeoutMs общее время запроса, включая чтение ответа (по умолчанию 30 с)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// ConnectTimeoutMs время установки TCP соединения (по умолчанию 10 с)
+	ConnectTimeoutMs int `json:"connect_timeout_ms,omitempty"`
+	// ReadTimeoutMs время ожидания заголовков ответа после отправки запроса (0 — без ограничения)
+	ReadTimeoutMs int `json:"read_timeout_ms,omitempty"`
+	// KeepAliveMs период TCP keep-alive (по умолчанию 30 с)
+	KeepAliveMs int `json:"keep_alive_ms,omitempty"`
+	// DisableKeepAlives открывать новое соединение на каждый запрос
+	DisableKeepAlives bool `json:"disable_keep_alives,omitempty"`
+	// IdleConnTimeoutMs время жизни простаивающего соединения (по умолчанию 90 с)
+	IdleConnTimeoutMs int `json:"idle_conn_timeout_ms,omitempty"`
+	// MaxIdleConns и MaxIdleConnsPerHost размер пула простаивающих соединений
+	// (по умолчанию 100 и 10), MaxConnsPerHost — предел соединений с хостом
+	MaxIdleConns        int `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost     int `json:"max_conns_per_host,omitempty"`
+	// DisableHTTP2 отключает HTTP/2 (по умолчанию используется, если сервер поддерживает)
+	DisableHTTP2 bool `json:"disable_http2,omitempty"`
+	// ProxyURL адрес прокси; по умолчанию HTTP_PROXY/HTTPS_PROXY/NO_PROXY окружения
+	ProxyURL string `json:"proxy_url,omitempty"`
+	// TLS параметры проверки сервера
+	TLS TLSSettings `json:"tls,omitempty"`
+}
+
+// TLSSettings параметры TLS подключения
+type TLSSettings struct {
+	// CACertificate сертификаты доверенных УЦ в PEM (дополняют системные)
+	CACertificate string `json:"ca_certificate,omitempty"`
+	// ServerName имя сервера для проверки сертификата и SNI
+	ServerName string `json:"server_name,omitempty"`
+	// MinVersion минимальная версия TLS: 1.2 (по умолчанию) или 1.3
+	MinVersion string `json:"min_version,omitempty"`
+	// InsecureSkipVerify отключает проверку сертификата сервера (только для тестовых стендов)
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *TLSSettings) Scan(src interface{}) error {
+	*s = TLSSettings{}
+	return scanJSONB(src, s)
 }
 
+// Value сериализует настройки в JSONB
+func (s TLSSettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// Scan читает настройки из JSONB
+func (s *HTTPClientSettings) Scan(src interface{}) error {
+	*s = HTTPClientSettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s HTTPClientSettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// RateLimitSettings ограничения исходящих запросов подключения (хранятся в JSONB).
+// Нулевые значения снимают соответствующее ограничение.
+type RateLimitSettings struct {
+	// RequestsPerSecond средняя частота запросов (token bucket)
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	// Burst размер корзины токенов (по умолчанию — частота, но не меньше 1)
+	Burst int `json:"burst,omitempty"`
+	// MaxInFlight максимальное число одновременных запросов
+	MaxInFlight int `json:"max_in_flight,omitempty"`
+	// MaxWaitMs время ожидания в очереди в миллисекундах (0 — сразу отклонять)
+	MaxWaitMs int `json:"max_wait_ms,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *RateLimitSettings) Scan(src interface{}) error {
+	*s = RateLimitSettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s RateLimitSettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// CircuitBreakerSettings параметры автоматического выключателя подключения
+// (хранятся в JSONB). Нулевые значения заменяются значениями по умолчанию.
+type CircuitBreakerSettings struct {
+	// FailureThreshold число ошибок подряд, после которого выключатель размыкается
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+	// OpenSeconds время в секундах, в течение которого запросы не отправляются
+	OpenSeconds int `json:"open_seconds,omitempty"`
+	// HalfOpenRequests число пробных запросов после размыкания
+	HalfOpenRequests int `json:"half_open_requests,omitempty"`
+	// Disabled отключает выключатель для подключения
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *CircuitBreakerSettings) Scan(src interface{}) error {
+	*s = CircuitBreakerSettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s CircuitBreakerSettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// Версии протокола SOAP
+const (
+	SOAPVersion11 = "1.1"
+	SOAPVersion12 = "1.2"
+)
+
 type ConnectionAuthentication struct {
 	Ref      uuid.UUID          `db:"ref" json:"ref"`
 	Name     string             `db:"name" json:"name"`
@@ -130,6 +382,81 @@ type ConnectionAuthentication struct {
 	Username string             `db:"username" json:"username"`
 	Password string             `db:"password" json:"password"`
 	Token    string             `db:"token" json:"token"`
+	// Параметры OAuth2
+	TokenURL     string `db:"token_url" json:"token_url"`
+	ClientID     string `db:"client_id" json:"client_id"`
+	ClientSecret string `db:"client_secret" json:"client_secret"`
+	Scope        string `db:"scope" json:"scope"`
+	RefreshToken string `db:"refresh_token" json:"refresh_token"`
+	// Сертификат X.509 и закрытый ключ в PEM для подписи
+	Certificate string `db:"certificate" json:"certificate"`
+	PrivateKey  string `db:"private_key" json:"private_key"`
+	// Настройки WS-Security
+	WSSecurity WSSecuritySettings `db:"ws_security" json:"ws_security"`
+	// Параметры TLS для аутентификации сертификатом: УЦ, имя сервера, версия TLS.
+	// Дополняют настройки tls клиента HTTP подключения.
+	TLS TLSSettings `db:"tls" json:"tls"`
+	// Профиль окружения (ESB_ENV), в котором действует аутентификация; пустой — во всех
+	Environment string `db:"environment" json:"environment"`
+}
+
+// WSSecuritySettings настройки заголовка WS-Security (хранятся в JSONB).
+// UsernameToken добавляется, если задан username аутентификации.
+type WSSecuritySettings struct {
+	// PasswordType PasswordText (по умолчанию) или PasswordDigest
+	PasswordType string `json:"password_type,omitempty"`
+	// TimestampTTL срок действия wsu:Timestamp в секундах (0 — без Timestamp)
+	TimestampTTL int `json:"timestamp_ttl,omitempty"`
+	// SignBody подписывать Body и Timestamp (XML-DSig) ключом certificate/private_key
+	SignBody bool `json:"sign_body,omitempty"`
+	// VerifyResponse проверять подпись ответа сертификатом ServerCertificate (PEM)
+	VerifyResponse    bool   `json:"verify_response,omitempty"`
+	ServerCertificate string `json:"server_certificate,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *WSSecuritySettings) Scan(src interface{}) error {
+	*s = WSSecuritySettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s WSSecuritySettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// RedactedSecret значение, которым заменяются секреты в ответах API и логах
+const RedactedSecret = "********"
+
+// Secrets возвращает указатели на секретные поля (шифруются в БД, скрываются в ответах)
+func (a *ConnectionAuthentication) Secrets() []*string {
+	return []*string{&a.Password, &a.Token, &a.ClientSecret, &a.RefreshToken, &a.PrivateKey}
+}
+
+// Redacted возвращает копию с замененными секретами
+func (a ConnectionAuthentication) Redacted() ConnectionAuthentication {
+	for _, secret := range a.Secrets() {
+		if *secret != "" {
+			*secret = RedactedSecret
+		}
+	}
+	return a
+}
+
+// MarshalJSON сериализует аутентификацию без секретов
+func (a ConnectionAuthentication) MarshalJSON() ([]byte, error) {
+	type plain ConnectionAuthentication
+	return json.Marshal(plain(a.Redacted()))
+}
+
+// String форматирует аутентификацию для логов без секретов
+func (a ConnectionAuthentication) String() string {
+	return fmt.Sprintf("%s (%s, user=%q, token_url=%q, client_id=%q)", a.Name, a.Type, a.Username, a.TokenURL, a.ClientID)
+}
+
+// GoString форматирует аутентификацию для %#v без секретов
+func (a ConnectionAuthentication) GoString() string {
+	return a.String()
 }
 
 type ThreadObject struct {
@@ -160,6 +487,91 @@ type Thread struct {
 	Name               string             `db:"name" json:"name"`
 	Group              uuid.UUID          `db:"group" json:"group"`
 	MessageConvertType MessageConvertType `db:"message_convert_type" json:"message_convert_type"`
+	// RequestResponse возвращать ответ целевой системы вызывающему
+	RequestResponse bool `db:"request_response" json:"request_response"`
+	// Подавление повторно полученных входящих сообщений
+	Idempotency IdempotencySettings `db:"idempotency" json:"idempotency"`
+	// Очередь AMQP, из которой thread получает входящие сообщения
+	AMQPConsumer AMQPConsumerSettings `db:"amqp_consumer" json:"amqp_consumer"`
+}
+
+// AMQPConsumerSettings очередь входящих сообщений thread (хранится в JSONB).
+// Брокер берется из подключений системы с адресом amqp:// или amqps://
+// (по priority); пока queue не задана, thread не читает очередь.
+type AMQPConsumerSettings struct {
+	System uuid.UUID `json:"system,omitempty"`
+	Queue  string    `json:"queue,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *AMQPConsumerSettings) Scan(src interface{}) error {
+	*s = AMQPConsumerSettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s AMQPConsumerSettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// Источники ключа идемпотентности входящего сообщения
+const (
+	// IdempotencyHeader заголовок сообщения (по умолчанию Idempotency-Key)
+	IdempotencyHeader = "header"
+	// IdempotencyJSONPath значение поля сообщения JSON, например $.id события Stripe
+	IdempotencyJSONPath = "json_path"
+	// IdempotencyHash хэш SHA-256 тела сообщения
+	IdempotencyHash = "hash"
+)
+
+// DefaultIdempotencyHeader заголовок ключа идемпотентности по умолчанию
+const DefaultIdempotencyHeader = "Idempotency-Key"
+
+// IdempotencySettings источник ключа идемпотентности (хранится в JSONB).
+// Пока source не задан, повторные сообщения обрабатываются заново.
+type IdempotencySettings struct {
+	Source   string `json:"source,omitempty"`
+	Header   string `json:"header,omitempty"`
+	JSONPath string `json:"json_path,omitempty"`
+	// TTLSeconds время хранения результата (по умолчанию ESB_IDEMPOTENCY_TTL)
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *IdempotencySettings) Scan(src interface{}) error {
+	*s = IdempotencySettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s IdempotencySettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// IdempotentResult результат обработки входящего сообщения,
+// который возвращается повторно полученным сообщениям с тем же ключом
+type IdempotentResult struct {
+	StatusCode int       `db:"status_code" json:"status_code"`
+	Headers    StringMap `db:"headers" json:"headers,omitempty"`
+	Body       []byte    `db:"body" json:"body,omitempty"`
+}
+
+// Состояния ключа идемпотентности
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord ключ идемпотентности входящего сообщения
+type IdempotencyRecord struct {
+	Scope     string    `db:"scope" json:"scope"`
+	Key       string    `db:"key" json:"key"`
+	Status    string    `db:"status" json:"status"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	// Fingerprint SHA-256 тела сообщения, с которым ключ был занят
+	Fingerprint string `db:"fingerprint" json:"fingerprint,omitempty"`
+	IdempotentResult
 }
 
 type ThreadRoute struct {
@@ -169,4 +581,128 @@ type ThreadRoute struct {
 	FileFormat FileFormat `db:"file_format" json:"file_format"`
 	Object     uuid.UUID  `db:"object" json:"object"`
 	Routine    uuid.UUID  `db:"routine" json:"routine"`
+	// Кодировки маршрута, переопределяют настройки подключения
+	SourceCharset string `db:"source_charset" json:"source_charset"`
+	TargetCharset string `db:"target_charset" json:"target_charset"`
+	// Схема Protobuf: FileDescriptorSet и полное имя сообщения
+	ProtoDescriptor []byte `db:"proto_descriptor" json:"-"`
+	ProtoMessage    string `db:"proto_message" json:"proto_message"`
+	// Настройки EDI: разделители, участники обмена, квитанции
+	EDISettings EDISettings `db:"edi_settings" json:"edi_settings"`
+	// Заголовки и параметры запроса с плейсхолдерами {...}
+	Headers StringMap `db:"headers" json:"headers"`
+	Query   StringMap `db:"query" json:"query"`
+	// TimeoutMs время обработки маршрута в миллисекундах, включая ожидание
+	// ограничителя и повторную отправку (0 — только таймауты подключения)
+	TimeoutMs int `db:"timeout_ms" json:"timeout_ms"`
+	// Повторная отправка маршрута при временной ошибке целевой системы
+	Retry RetrySettings `db:"retry" json:"retry"`
+}
+
+// RetrySettings повторная отправка маршрута (хранится в JSONB). Повторяются
+// SOAP Fault Server/Receiver, отсутствие ответа, 429, 502, 503 и 504. Без
+// max_attempts сообщение отправляется один раз.
+type RetrySettings struct {
+	// MaxAttempts число попыток отправки, включая первую
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// BackoffMs пауза перед второй попыткой, затем удваивается (по умолчанию 500 мс)
+	BackoffMs int `json:"backoff_ms,omitempty"`
+	// MaxBackoffMs наибольшая пауза между попытками (по умолчанию 30 с)
+	MaxBackoffMs int `json:"max_backoff_ms,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *RetrySettings) Scan(src interface{}) error {
+	*s = RetrySettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s RetrySettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// StringMap словарь строк, хранящийся в JSONB
+type StringMap map[string]string
+
+// Scan читает словарь из JSONB
+func (m *StringMap) Scan(src interface{}) error {
+	*m = StringMap{}
+	return scanJSONB(src, m)
+}
+
+// Value сериализует словарь в JSONB
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return valueJSONB(m)
+}
+
+// EDISettings настройки EDI маршрута (хранятся в JSONB).
+// Пустые разделители заменяются значениями по умолчанию стандарта.
+type EDISettings struct {
+	SegmentTerminator   string `json:"segment_terminator,omitempty"`
+	ElementSeparator    string `json:"element_separator,omitempty"`
+	ComponentSeparator  string `json:"component_separator,omitempty"`
+	ReleaseCharacter    string `json:"release_character,omitempty"`
+	RepetitionSeparator string `json:"repetition_separator,omitempty"`
+	// Участники обмена для конвертов UNB/ISA
+	SenderID          string `json:"sender_id,omitempty"`
+	SenderQualifier   string `json:"sender_qualifier,omitempty"`
+	ReceiverID        string `json:"receiver_id,omitempty"`
+	ReceiverQualifier string `json:"receiver_qualifier,omitempty"`
+	TestIndicator     bool   `json:"test_indicator,omitempty"`
+	// Acknowledge — отправлять CONTRL/997 на входящие обмены
+	Acknowledge bool `json:"acknowledge,omitempty"`
+}
+
+// Scan читает настройки из JSONB
+func (s *EDISettings) Scan(src interface{}) error {
+	*s = EDISettings{}
+	return scanJSONB(src, s)
+}
+
+// Value сериализует настройки в JSONB
+func (s EDISettings) Value() (driver.Value, error) {
+	return valueJSONB(s)
+}
+
+// scanJSONB разбирает значение колонки JSONB (NULL оставляет dest пустым)
+func scanJSONB(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	}
+	return fmt.Errorf("unsupported JSONB type %T", src)
+}
+
+func valueJSONB(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+//
+// === Сообщения ===
+//
+
+// Message сообщение, проходящее через шину, с форматом полезной нагрузки
+type Message struct {
+	Data    []byte     `json:"data"`
+	Format  FileFormat `json:"format"`
+	Charset string     `json:"charset,omitempty"`
+}
+
+// Response ответ целевой системы, сконвертированный в формат входящего сообщения
+type Response struct {
+	Message
+	// StatusCode код ответа целевой системы
+	StatusCode int `json:"status_code"`
 }
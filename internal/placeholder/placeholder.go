@@ -0,0 +1,200 @@
+package placeholder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Шаблоны маршрутов: плейсхолдеры {выражение} в пути, параметрах запроса
+// и заголовках. Выражение разрешается функцией Resolver, например:
+//
+//	{$.order.id}   JSONPath по полям сообщения
+//	{OrderId}      сокращение для {$.OrderId}
+//	{global.name}  значение из таблицы global
+//	{ctx.name}     переменная процесса из контекста (WithVars)
+
+// Resolver возвращает значение выражения плейсхолдера
+type Resolver func(expr string) (string, error)
+
+// Render подставляет значения плейсхолдеров, escape применяется к каждому значению
+func Render(tmpl string, resolve Resolver, escape func(string) string) (string, error) {
+	var sb strings.Builder
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			sb.WriteString(tmpl)
+			return sb.String(), nil
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed placeholder in %q", tmpl)
+		}
+		expr := strings.TrimSpace(tmpl[open+1 : open+end])
+		if expr == "" {
+			return "", fmt.Errorf("empty placeholder in %q", tmpl)
+		}
+		value, err := resolve(expr)
+		if err != nil {
+			return "", fmt.Errorf("placeholder {%s}: %w", expr, err)
+		}
+		if escape != nil {
+			value = escape(value)
+		}
+		sb.WriteString(tmpl[:open])
+		sb.WriteString(value)
+		tmpl = tmpl[open+end+1:]
+	}
+}
+
+// RenderURL подставляет значения в путь и query: в пути значения экранируются
+// как сегмент пути, в query — как значение параметра
+func RenderURL(tmpl string, resolve Resolver) (string, error) {
+	path, query, hasQuery := strings.Cut(tmpl, "?")
+	path, err := Render(path, resolve, url.PathEscape)
+	if err != nil {
+		return "", err
+	}
+	if !hasQuery {
+		return path, nil
+	}
+	query, err = Render(query, resolve, url.QueryEscape)
+	if err != nil {
+		return "", err
+	}
+	return path + "?" + query, nil
+}
+
+// EscapeHeader заменяет управляющие символы (CR, LF и др.) пробелом, чтобы
+// значение не могло разорвать заголовок или добавить новый
+func EscapeHeader(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && (r < 0x20 || r == 0x7f) {
+			return ' '
+		}
+		return r
+	}, value)
+}
+
+// AppendQuery добавляет параметры к URL (значения экранируются)
+func AppendQuery(rawURL string, params map[string]string) string {
+	if len(params) == 0 {
+		return rawURL
+	}
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + values.Encode()
+}
+
+// Lookup возвращает значение по JSONPath: $, .name, ['name'], [index]
+func Lookup(data interface{}, path string) (interface{}, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath must start with $: %s", path)
+	}
+	current := data
+	rest := path[1:]
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %s", path)
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %s", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				key = inner[1 : len(inner)-1]
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath index %s", inner)
+				}
+				index = n
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %s", path)
+		}
+
+		if index >= 0 {
+			arr, ok := current.([]interface{})
+			if !ok || index >= len(arr) {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+			current = arr[index]
+			continue
+		}
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s not found", path)
+		}
+		if current, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("%s not found", path)
+		}
+	}
+	return current, nil
+}
+
+// Format преобразует значение JSON в строку для подстановки
+func Format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+type varsKey struct{}
+
+// WithVars добавляет переменные процесса, доступные как {ctx.name}.
+// Переменные объединяются с уже заданными в контексте.
+func WithVars(ctx context.Context, vars map[string]string) context.Context {
+	merged := make(map[string]string)
+	for key, value := range VarsFromContext(ctx) {
+		merged[key] = value
+	}
+	for key, value := range vars {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, varsKey{}, merged)
+}
+
+// VarsFromContext возвращает переменные процесса из контекста
+func VarsFromContext(ctx context.Context) map[string]string {
+	vars, _ := ctx.Value(varsKey{}).(map[string]string)
+	return vars
+}
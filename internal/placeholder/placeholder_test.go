@@ -0,0 +1,200 @@
+package placeholder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testResolver разрешает выражения из vars, для остальных возвращает ошибку
+func testResolver(vars map[string]string) Resolver {
+	return func(expr string) (string, error) {
+		value, ok := vars[expr]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return value, nil
+	}
+}
+
+var testVars = map[string]string{
+	"id":     "42",
+	"ctx.id": "PO-1",
+	"slash":  "a/b",
+	"query":  "x?admin=1&y=2",
+	"space":  "John Smith",
+	"crlf":   "v\r\nX-Injected: 1",
+	"empty":  "",
+}
+
+func TestRenderURL(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"no placeholders", "/orders?limit=10", "/orders?limit=10"},
+		{"path and query", "/orders/{id}?source={ctx.id}", "/orders/42?source=PO-1"},
+		{"spaces in braces", "/orders/{ id }", "/orders/42"},
+		{"slash in path", "/files/{slash}/content", "/files/a%2Fb/content"},
+		{"question mark in path", "/orders/{query}", "/orders/x%3Fadmin=1&y=2"},
+		{"query value", "/search?q={query}&name={space}", "/search?q=x%3Fadmin%3D1%26y%3D2&name=John+Smith"},
+		{"slash in query", "/files?path={slash}", "/files?path=a%2Fb"},
+		{"CRLF in path", "/orders/{crlf}", "/orders/v%0D%0AX-Injected:%201"},
+		{"CRLF in query", "/orders?id={crlf}", "/orders?id=v%0D%0AX-Injected%3A+1"},
+		{"empty value", "/orders/{empty}", "/orders/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderURL(tt.tmpl, testResolver(testVars))
+			if err != nil {
+				t.Fatalf("RenderURL: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("RenderURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"substitution", "Bearer {ctx.id}", "Bearer PO-1"},
+		{"slash and question mark kept", "{slash}?{query}", "a/b?x?admin=1&y=2"},
+		{"CRLF replaced", "{crlf}", "v  X-Injected: 1"},
+		{"control characters replaced", "{ctl}", "a b\tc d"},
+	}
+	vars := map[string]string{"ctl": "a\x00b\tc\x7fd"}
+	for key, value := range testVars {
+		vars[key] = value
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.tmpl, testResolver(vars), EscapeHeader)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Render = %q, want %q", got, tt.want)
+			}
+			if strings.ContainsAny(got, "\r\n") {
+				t.Fatalf("header value %q contains CR or LF", got)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr string
+	}{
+		{"missing variable in path", "/orders/{missing}", "placeholder {missing}: not found"},
+		{"missing variable in query", "/orders?id={ctx.missing}", "placeholder {ctx.missing}: not found"},
+		{"unclosed placeholder", "/orders/{id", "unclosed placeholder"},
+		{"empty placeholder", "/orders/{ }", "empty placeholder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RenderURL(tt.tmpl, testResolver(testVars))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("RenderURL error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Render("{missing}", testResolver(testVars), EscapeHeader); err == nil {
+		t.Fatal("Render with missing variable succeeded")
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		params map[string]string
+		want   string
+	}{
+		{"no params", "https://api.example.com/orders", nil, "https://api.example.com/orders"},
+		{"new query", "https://api.example.com/orders", map[string]string{"id": "x?admin=1&y=2"}, "https://api.example.com/orders?id=x%3Fadmin%3D1%26y%3D2"},
+		{"existing query", "https://api.example.com/orders?limit=10", map[string]string{"path": "a/b", "crlf": "\r\n"}, "https://api.example.com/orders?limit=10&crlf=%0D%0A&path=a%2Fb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AppendQuery(tt.url, tt.params); got != tt.want {
+				t.Fatalf("AppendQuery = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupAndFormat(t *testing.T) {
+	data := map[string]interface{}{
+		"order":    map[string]interface{}{"id": "PO-1", "total": 12.5, "paid": true},
+		"items":    []interface{}{map[string]interface{}{"sku": "A-1", "qty": float64(2)}},
+		"Order Id": "with space",
+		"note":     nil,
+	}
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "$.order.id", want: "PO-1"},
+		{path: "$.order.total", want: "12.5"},
+		{path: "$.order.paid", want: "true"},
+		{path: "$.items[0].sku", want: "A-1"},
+		{path: "$.items[0]['qty']", want: "2"},
+		{path: `$["Order Id"]`, want: "with space"},
+		{path: "$.note", want: ""},
+		{path: "$.items[0]", want: `{"qty":2,"sku":"A-1"}`},
+		{path: "$.items[1].sku", wantErr: "not found"},
+		{path: "$.order.missing", wantErr: "not found"},
+		{path: "$.items.sku", wantErr: "not found"},
+		{path: "$.items[x]", wantErr: "invalid JSONPath index"},
+		{path: "order.id", wantErr: "must start with $"},
+		{path: "$..id", wantErr: "invalid JSONPath"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, err := Lookup(data, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Lookup error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+			if got := Format(value); got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithVars(t *testing.T) {
+	ctx := WithVars(context.Background(), map[string]string{"order_id": "PO-1", "step": "1"})
+	ctx = WithVars(ctx, map[string]string{"step": "2", "sap_doc": "4500"})
+
+	vars := VarsFromContext(ctx)
+	want := map[string]string{"order_id": "PO-1", "step": "2", "sap_doc": "4500"}
+	if len(vars) != len(want) {
+		t.Fatalf("vars = %v, want %v", vars, want)
+	}
+	for key, value := range want {
+		if vars[key] != value {
+			t.Fatalf("vars[%s] = %q, want %q", key, vars[key], value)
+		}
+	}
+	if VarsFromContext(context.Background()) != nil {
+		t.Fatal("empty context has vars")
+	}
+}
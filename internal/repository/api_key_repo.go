@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// APIKeyRepository хранит API ключи машинных клиентов (только хэши ключей)
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepository(db *sqlx.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// apiKeyColumns колонки api_keys в порядке полей models.APIKey
+const apiKeyColumns = `ref, user_ref, name, prefix, key_hash, created_at, expires_at, last_used_at`
+
+func (r *apiKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	k.Ref = uuid.New()
+	return r.db.GetContext(ctx, &k.CreatedAt, `
+        INSERT INTO api_keys (ref, user_ref, name, prefix, key_hash, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING created_at
+    `, k.Ref, k.User, k.Name, k.Prefix, k.KeyHash, k.ExpiresAt)
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.GetContext(ctx, &key, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.SelectContext(ctx, &keys, `
+        SELECT `+apiKeyColumns+` FROM api_keys WHERE user_ref = $1 ORDER BY created_at
+    `, userID)
+	return keys, err
+}
+
+// MarkUsed обновляет время последнего использования ключа
+func (r *apiKeyRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = now() WHERE ref = $1`, id)
+	return err
+}
+
+func (r *apiKeyRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE ref = $1`, id)
+	return err
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
+	"go-esb/internal/encryption"
 	"go-esb/internal/models"
 
 	"github.com/google/uuid"
@@ -11,61 +14,284 @@ import (
 
 type ConnectionRepository interface {
 	GetConnectionSettings(ctx context.Context, systemID uuid.UUID) (*models.ConnectionSetting, error)
+	GetConnectionSettingsBySystem(ctx context.Context, systemID uuid.UUID) ([]models.ConnectionSetting, error)
+	GetConnectionSettingsWithHealthCheck(ctx context.Context) ([]models.ConnectionSetting, error)
 	GetConnectionAuth(ctx context.Context, authID uuid.UUID) (*models.ConnectionAuthentication, error)
+	GetConnectionAuthsBySystem(ctx context.Context, systemID uuid.UUID) ([]models.ConnectionAuthentication, error)
+	GetConnectionAuthsWithCertificates(ctx context.Context) ([]models.ConnectionAuthentication, error)
 	CreateConnectionSetting(ctx context.Context, setting *models.ConnectionSetting) error
 	CreateConnectionAuth(ctx context.Context, auth *models.ConnectionAuthentication) error
+	ReencryptConnectionAuths(ctx context.Context) (int, error)
+	UpdateRefreshToken(ctx context.Context, authID uuid.UUID, refreshToken string) error
 }
 
+// connectionAuthColumns колонки connection_authentications в порядке полей models.ConnectionAuthentication.
+// username, password и token не заполняются для OAuth2 и могут быть NULL.
+const connectionAuthColumns = `ref, name, system, type, username, password, token,
+        token_url, client_id, client_secret, scope, refresh_token, certificate, private_key, ws_security, tls, environment`
+
+// connectionSettingColumns колонки connection_settings в порядке полей models.ConnectionSetting
+const connectionSettingColumns = `ref, name, system, path, port, auth, source_charset, target_charset, soap_version, circuit_breaker, rate_limit, http_client,
+        priority, weight, health_check, environment`
+
+// settingsInEnvironment условие выборки подключений профиля окружения ($1):
+// подключения окружения системы заменяют ее общие подключения (с пустым environment)
+const settingsInEnvironment = `(environment = $1 OR (environment = '' AND NOT EXISTS (
+            SELECT 1 FROM connection_settings scoped
+            WHERE scoped.system = connection_settings.system AND scoped.environment = $1)))`
+
+// authsInEnvironment условие выборки аутентификаций профиля окружения ($1) и общих
+const authsInEnvironment = `environment IN ('', $1)`
+
+// connectionSettingOrder порядок подключений системы: основные, затем резервные
+const connectionSettingOrder = `ORDER BY priority, weight DESC, name`
+
+// connectionAuthSelect выборка connection_authentications с заменой NULL на пустые строки
+const connectionAuthSelect = `
+        SELECT ref, name, system, type,
+            COALESCE(username, '') AS username, COALESCE(password, '') AS password, COALESCE(token, '') AS token,
+            token_url, client_id, client_secret, scope, refresh_token, certificate, private_key, ws_security, tls, environment
+        FROM connection_authentications`
+
 type connectionRepository struct {
-	db *sqlx.DB
+	db          *sqlx.DB
+	keyring     *encryption.Keyring
+	environment string
 }
 
-func NewConnectionRepository(db *sqlx.DB) ConnectionRepository {
-	return &connectionRepository{db: db}
+// NewConnectionRepository создает репозиторий подключений. Если keyring не nil,
+// секреты аутентификации шифруются при записи и расшифровываются при чтении.
+// Подключения и аутентификации читаются для профиля environment (ESB_ENV)
+// и общие для всех профилей.
+func NewConnectionRepository(db *sqlx.DB, keyring *encryption.Keyring, environment string) ConnectionRepository {
+	return &connectionRepository{db: db, keyring: keyring, environment: environment}
 }
 
+// GetConnectionSettings возвращает основное подключение системы
 func (r *connectionRepository) GetConnectionSettings(ctx context.Context, systemID uuid.UUID) (*models.ConnectionSetting, error) {
 	var setting models.ConnectionSetting
 	err := r.db.GetContext(ctx, &setting, `
-        SELECT ref, name, system, path, port, auth 
+        SELECT `+connectionSettingColumns+`
         FROM connection_settings 
-        WHERE system = $1
+        WHERE system = $2 AND `+settingsInEnvironment+`
+        `+connectionSettingOrder+`
         LIMIT 1
-    `, systemID)
+    `, r.environment, systemID)
 	if err != nil {
 		return nil, err
 	}
 	return &setting, nil
 }
 
+// GetConnectionSettingsBySystem возвращает все подключения системы по priority
+func (r *connectionRepository) GetConnectionSettingsBySystem(ctx context.Context, systemID uuid.UUID) ([]models.ConnectionSetting, error) {
+	var settings []models.ConnectionSetting
+	err := r.db.SelectContext(ctx, &settings, `
+        SELECT `+connectionSettingColumns+`
+        FROM connection_settings
+        WHERE system = $2 AND `+settingsInEnvironment+`
+        `+connectionSettingOrder, r.environment, systemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return settings, nil
+}
+
+// GetConnectionSettingsWithHealthCheck возвращает подключения с активной проверкой
+func (r *connectionRepository) GetConnectionSettingsWithHealthCheck(ctx context.Context) ([]models.ConnectionSetting, error) {
+	var settings []models.ConnectionSetting
+	err := r.db.SelectContext(ctx, &settings, `
+        SELECT `+connectionSettingColumns+`
+        FROM connection_settings
+        WHERE COALESCE((health_check->>'interval_ms')::INTEGER, 0) > 0 AND `+settingsInEnvironment+`
+        ORDER BY system, priority, name
+    `, r.environment)
+	return settings, err
+}
+
 func (r *connectionRepository) GetConnectionAuth(ctx context.Context, authID uuid.UUID) (*models.ConnectionAuthentication, error) {
 	var auth models.ConnectionAuthentication
-	err := r.db.GetContext(ctx, &auth, `
-        SELECT ref, name, system, type, username, password, token 
-        FROM connection_authentications 
-        WHERE ref = $1
-    `, authID)
+	err := r.db.GetContext(ctx, &auth, connectionAuthSelect+`
+        WHERE ref = $2 AND `+authsInEnvironment+`
+    `, r.environment, authID)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.decryptSecrets(&auth); err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials of %s: %w", auth.Name, err)
+	}
 	return &auth, nil
 }
 
+func (r *connectionRepository) GetConnectionAuthsBySystem(ctx context.Context, systemID uuid.UUID) ([]models.ConnectionAuthentication, error) {
+	var auths []models.ConnectionAuthentication
+	err := r.db.SelectContext(ctx, &auths, connectionAuthSelect+`
+        WHERE system = $2 AND `+authsInEnvironment+`
+        ORDER BY name
+    `, r.environment, systemID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range auths {
+		if err := r.decryptSecrets(&auths[i]); err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials of %s: %w", auths[i].Name, err)
+		}
+	}
+	return auths, nil
+}
+
+// GetConnectionAuthsWithCertificates возвращает аутентификации с сертификатом
+// (Certificate и WS-Security) для проверки срока действия
+func (r *connectionRepository) GetConnectionAuthsWithCertificates(ctx context.Context) ([]models.ConnectionAuthentication, error) {
+	var auths []models.ConnectionAuthentication
+	err := r.db.SelectContext(ctx, &auths, connectionAuthSelect+`
+        WHERE certificate <> '' AND `+authsInEnvironment+`
+        ORDER BY name
+    `, r.environment)
+	if err != nil {
+		return nil, err
+	}
+	for i := range auths {
+		if err := r.decryptSecrets(&auths[i]); err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials of %s: %w", auths[i].Name, err)
+		}
+	}
+	return auths, nil
+}
+
 func (r *connectionRepository) CreateConnectionSetting(ctx context.Context, setting *models.ConnectionSetting) error {
 	setting.Ref = uuid.New()
+	if setting.Weight <= 0 {
+		setting.Weight = 1
+	}
 	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO connection_settings (ref, name, system, path, port, auth)
-        VALUES ($1, $2, $3, $4, $5, $6)
-    `, setting.Ref, setting.Name, setting.System, setting.Path, setting.Port, setting.AuthRef)
+        INSERT INTO connection_settings (`+connectionSettingColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+    `, setting.Ref, setting.Name, setting.System, setting.Path, setting.Port, nullUUID(setting.AuthRef),
+		setting.SourceCharset, setting.TargetCharset, setting.SOAPVersion, setting.CircuitBreaker, setting.RateLimit, setting.HTTPClient,
+		setting.Priority, setting.Weight, setting.HealthCheck, setting.Environment)
 	return err
 }
 
+// nullUUID возвращает NULL для пустого идентификатора (подключение без аутентификации)
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
 func (r *connectionRepository) CreateConnectionAuth(ctx context.Context, auth *models.ConnectionAuthentication) error {
 	auth.Ref = uuid.New()
+	stored := *auth
+	if err := r.encryptSecrets(&stored); err != nil {
+		return err
+	}
 	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO connection_authentications (ref, name, system, type, username, password, token)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `, auth.Ref, auth.Name, auth.System, auth.Type, auth.Username, auth.Password, auth.Token)
+        INSERT INTO connection_authentications (`+connectionAuthColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+    `, stored.Ref, stored.Name, stored.System, stored.Type, stored.Username, stored.Password, stored.Token,
+		stored.TokenURL, stored.ClientID, stored.ClientSecret, stored.Scope, stored.RefreshToken,
+		stored.Certificate, stored.PrivateKey, stored.WSSecurity, stored.TLS, stored.Environment)
+	return err
+}
+
+// UpdateRefreshToken сохраняет refresh token OAuth2 (зашифрованным, если задан мастер-ключ)
+func (r *connectionRepository) UpdateRefreshToken(ctx context.Context, authID uuid.UUID, refreshToken string) error {
+	if r.keyring != nil {
+		encrypted, err := r.keyring.Encrypt(refreshToken)
+		if err != nil {
+			return err
+		}
+		refreshToken = encrypted
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE connection_authentications SET refresh_token = $2 WHERE ref = $1`, authID, refreshToken)
 	return err
 }
 
+// ReencryptConnectionAuths перешифровывает секреты всех записей (всех профилей) основным мастер-ключом
+// (в том числе открытые значения, сохраненные до включения шифрования).
+// Возвращает число измененных записей.
+func (r *connectionRepository) ReencryptConnectionAuths(ctx context.Context) (int, error) {
+	if r.keyring == nil {
+		return 0, fmt.Errorf("master key is not configured")
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var auths []models.ConnectionAuthentication
+	if err := tx.SelectContext(ctx, &auths, connectionAuthSelect+` FOR UPDATE`); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for i := range auths {
+		auth := &auths[i]
+		rotate := false
+		for _, secret := range auth.Secrets() {
+			if r.keyring.NeedsRotation(*secret) {
+				rotate = true
+			}
+		}
+		if !rotate {
+			continue
+		}
+
+		if err := r.decryptSecrets(auth); err != nil {
+			return 0, fmt.Errorf("failed to decrypt credentials of %s: %w", auth.Name, err)
+		}
+		if err := r.encryptSecrets(auth); err != nil {
+			return 0, fmt.Errorf("failed to encrypt credentials of %s: %w", auth.Name, err)
+		}
+		_, err := tx.ExecContext(ctx, `
+            UPDATE connection_authentications
+            SET password = $2, token = $3, client_secret = $4, refresh_token = $5, private_key = $6
+            WHERE ref = $1
+        `, auth.Ref, auth.Password, auth.Token, auth.ClientSecret, auth.RefreshToken, auth.PrivateKey)
+		if err != nil {
+			return 0, err
+		}
+		updated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+func (r *connectionRepository) encryptSecrets(auth *models.ConnectionAuthentication) error {
+	if r.keyring == nil {
+		return nil
+	}
+	for _, secret := range auth.Secrets() {
+		encrypted, err := r.keyring.Encrypt(*secret)
+		if err != nil {
+			return err
+		}
+		*secret = encrypted
+	}
+	return nil
+}
+
+func (r *connectionRepository) decryptSecrets(auth *models.ConnectionAuthentication) error {
+	for _, secret := range auth.Secrets() {
+		if !encryption.IsEncrypted(*secret) {
+			continue
+		}
+		if r.keyring == nil {
+			return fmt.Errorf("credentials are encrypted but master key is not configured")
+		}
+		decrypted, err := r.keyring.Decrypt(*secret)
+		if err != nil {
+			return err
+		}
+		*secret = decrypted
+	}
+	return nil
+}
+
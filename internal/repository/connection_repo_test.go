@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// testDatabaseEnv строка подключения к PostgreSQL для тестов репозиториев;
+// без нее тесты пропускаются
+const testDatabaseEnv = "ESB_TEST_DATABASE_URL"
+
+// testDB создает отдельную схему с примененными миграциями и удаляет ее после теста
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	// Одно соединение, чтобы search_path действовал для всех запросов теста
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	schema := "esb_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		db.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		db.Close()
+	})
+	if _, err := db.Exec(`SET search_path TO ` + schema + `, public`); err != nil {
+		t.Fatalf("set search_path: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("migrations not found: %v", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		// Как и database.RunMigrations, ошибки отдельных миграций не прерывают применение
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Logf("migration %s: %v", filepath.Base(file), err)
+		}
+	}
+	return db
+}
+
+func createTestSystem(t *testing.T, db *sqlx.DB, name string) uuid.UUID {
+	t.Helper()
+	var ref uuid.UUID
+	if err := db.Get(&ref, `INSERT INTO systems (name) VALUES ($1) RETURNING ref`, name); err != nil {
+		t.Fatalf("create system %s: %v", name, err)
+	}
+	return ref
+}
+
+// TestConnectionsInEnvironment проверяет правило профилей окружения: подключения
+// профиля заменяют общие подключения системы, аутентификации профиля
+// добавляются к общим, записи других профилей не читаются
+func TestConnectionsInEnvironment(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seed := NewConnectionRepository(db, nil, "")
+
+	tests := []struct {
+		name         string
+		environments []string
+		settings     []string
+		auths        []string
+	}{
+		{"shared only", []string{""}, []string{"shared"}, []string{"shared"}},
+		{"shared with prod override", []string{"", "prod"}, []string{"prod"}, []string{"prod", "shared"}},
+		{"other profile excluded", []string{"", "stage"}, []string{"shared"}, []string{"shared"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system := createTestSystem(t, db, tt.name)
+			auths := map[string]uuid.UUID{}
+			for _, environment := range tt.environments {
+				name := environment
+				if name == "" {
+					name = "shared"
+				}
+				auth := models.ConnectionAuthentication{Name: name, System: system, Type: models.AuthBasic, Username: "esb", Environment: environment}
+				if err := seed.CreateConnectionAuth(ctx, &auth); err != nil {
+					t.Fatalf("create auth %s: %v", name, err)
+				}
+				auths[name] = auth.Ref
+				setting := models.ConnectionSetting{Name: name, System: system, Path: "http://" + name + ".example", Environment: environment}
+				if err := seed.CreateConnectionSetting(ctx, &setting); err != nil {
+					t.Fatalf("create setting %s: %v", name, err)
+				}
+			}
+
+			repo := NewConnectionRepository(db, nil, "prod")
+
+			settings, err := repo.GetConnectionSettingsBySystem(ctx, system)
+			if err != nil {
+				t.Fatalf("GetConnectionSettingsBySystem: %v", err)
+			}
+			var settingNames []string
+			for _, setting := range settings {
+				settingNames = append(settingNames, setting.Name)
+			}
+			if strings.Join(settingNames, ",") != strings.Join(tt.settings, ",") {
+				t.Errorf("settings = %v, want %v", settingNames, tt.settings)
+			}
+
+			primary, err := repo.GetConnectionSettings(ctx, system)
+			if err != nil {
+				t.Fatalf("GetConnectionSettings: %v", err)
+			}
+			if primary.Name != tt.settings[0] {
+				t.Errorf("primary setting = %s, want %s", primary.Name, tt.settings[0])
+			}
+
+			found, err := repo.GetConnectionAuthsBySystem(ctx, system)
+			if err != nil {
+				t.Fatalf("GetConnectionAuthsBySystem: %v", err)
+			}
+			var authNames []string
+			for _, auth := range found {
+				authNames = append(authNames, auth.Name)
+			}
+			if strings.Join(authNames, ",") != strings.Join(tt.auths, ",") {
+				t.Errorf("auths = %v, want %v", authNames, tt.auths)
+			}
+
+			if ref, ok := auths["stage"]; ok {
+				if _, err := repo.GetConnectionAuth(ctx, ref); !errors.Is(err, sql.ErrNoRows) {
+					t.Errorf("GetConnectionAuth of stage profile: err = %v, want sql.ErrNoRows", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EDIRepository хранит контрольные номера обменов EDI
+type EDIRepository interface {
+	NextControlNumber(ctx context.Context, standard, sender, receiver string) (int64, error)
+}
+
+type ediRepository struct {
+	db *sqlx.DB
+}
+
+func NewEDIRepository(db *sqlx.DB) EDIRepository {
+	return &ediRepository{db: db}
+}
+
+// NextControlNumber атомарно выдает следующий контрольный номер для пары отправитель/получатель
+func (r *ediRepository) NextControlNumber(ctx context.Context, standard, sender, receiver string) (int64, error) {
+	var number int64
+	err := r.db.GetContext(ctx, &number, `
+        INSERT INTO edi_control_numbers (standard, sender, receiver, last_number)
+        VALUES ($1, $2, $3, 1)
+        ON CONFLICT (standard, sender, receiver)
+        DO UPDATE SET last_number = edi_control_numbers.last_number + 1
+        RETURNING last_number
+    `, standard, sender, receiver)
+	return number, err
+}
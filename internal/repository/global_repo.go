@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type GlobalRepository interface {
+	Get(ctx context.Context, name string) (interface{}, error)
+}
+
+type globalRepository struct {
+	db *sqlx.DB
+}
+
+func NewGlobalRepository(db *sqlx.DB) GlobalRepository {
+	return &globalRepository{db: db}
+}
+
+// Get возвращает значение глобальной настройки (JSONB) в виде значения JSON
+func (r *globalRepository) Get(ctx context.Context, name string) (interface{}, error) {
+	var raw []byte
+	err := r.db.GetContext(ctx, &raw, `SELECT COALESCE(value, 'null'::jsonb) FROM global WHERE name = $1`, name)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
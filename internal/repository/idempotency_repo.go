@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotencyRepository хранит ключи идемпотентности входящих сообщений
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, scope, key, fingerprint string, expiresAt time.Time) (*models.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, scope, key string, result *models.IdempotentResult, expiresAt time.Time) error
+	Release(ctx context.Context, scope, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// idempotencyColumns колонки idempotency_keys в порядке полей models.IdempotencyRecord
+const idempotencyColumns = `scope, key, status, created_at, expires_at, fingerprint, status_code, headers, body`
+
+// Reserve занимает ключ для обработки сообщения с отпечатком fingerprint. Если
+// ключ уже занят и не истек, возвращает существующую запись и false.
+func (r *idempotencyRepository) Reserve(ctx context.Context, scope, key, fingerprint string, expiresAt time.Time) (*models.IdempotencyRecord, bool, error) {
+	var record models.IdempotencyRecord
+	err := r.db.GetContext(ctx, &record, `
+        INSERT INTO idempotency_keys (scope, key, status, fingerprint, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (scope, key) DO UPDATE
+            SET status = EXCLUDED.status, fingerprint = EXCLUDED.fingerprint,
+                status_code = 0, headers = '{}', body = NULL,
+                created_at = now(), expires_at = EXCLUDED.expires_at
+            WHERE idempotency_keys.expires_at < now()
+        RETURNING `+idempotencyColumns, scope, key, models.IdempotencyProcessing, fingerprint, expiresAt)
+	if err == nil {
+		return &record, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	err = r.db.GetContext(ctx, &record, `
+        SELECT `+idempotencyColumns+` FROM idempotency_keys WHERE scope = $1 AND key = $2
+    `, scope, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Ключ освобожден между запросами: занимаем его заново
+		return r.Reserve(ctx, scope, key, fingerprint, expiresAt)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+// Complete сохраняет результат обработки сообщения до expiresAt
+func (r *idempotencyRepository) Complete(ctx context.Context, scope, key string, result *models.IdempotentResult, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE idempotency_keys
+        SET status = $3, status_code = $4, headers = $5, body = $6, expires_at = $7
+        WHERE scope = $1 AND key = $2
+    `, scope, key, models.IdempotencyCompleted, result.StatusCode, result.Headers, result.Body, expiresAt)
+	return err
+}
+
+// Release освобождает ключ, чтобы повторное сообщение было обработано заново
+func (r *idempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+        DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status = $3
+    `, scope, key, models.IdempotencyProcessing)
+	return err
+}
+
+// DeleteExpired удаляет истекшие ключи
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -14,9 +14,13 @@ type RouteRepository interface {
 	GetAll(ctx context.Context) ([]models.Route, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Route, error)
 	GetBySystem(ctx context.Context, systemID uuid.UUID) ([]models.Route, error)
+	Update(ctx context.Context, route *models.Route) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// routeColumns колонки routes в порядке полей models.Route
+const routeColumns = `ref, name, path, system, method, soap_action, request_object, response_object`
+
 type routeRepository struct {
 	db *sqlx.DB
 }
@@ -28,16 +32,26 @@ func NewRouteRepository(db *sqlx.DB) RouteRepository {
 func (r *routeRepository) Create(ctx context.Context, route *models.Route) error {
 	route.Ref = uuid.New()
 	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO routes (ref, name, path, system, method)
-        VALUES ($1, $2, $3, $4, $5)
-    `, route.Ref, route.Name, route.Path, route.System, route.Method)
+        INSERT INTO routes (`+routeColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, route.Ref, route.Name, route.Path, route.System, route.Method, route.SOAPAction,
+		route.RequestObject, route.ResponseObject)
+	return err
+}
+
+func (r *routeRepository) Update(ctx context.Context, route *models.Route) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE routes
+        SET name = $2, path = $3, method = $4, soap_action = $5, request_object = $6, response_object = $7
+        WHERE ref = $1
+    `, route.Ref, route.Name, route.Path, route.Method, route.SOAPAction, route.RequestObject, route.ResponseObject)
 	return err
 }
 
 func (r *routeRepository) GetAll(ctx context.Context) ([]models.Route, error) {
 	var routes []models.Route
 	err := r.db.SelectContext(ctx, &routes, `
-        SELECT ref, name, path, system, method FROM routes ORDER BY name
+        SELECT `+routeColumns+` FROM routes ORDER BY name
     `)
 	return routes, err
 }
@@ -45,7 +59,7 @@ func (r *routeRepository) GetAll(ctx context.Context) ([]models.Route, error) {
 func (r *routeRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Route, error) {
 	var route models.Route
 	err := r.db.GetContext(ctx, &route, `
-        SELECT ref, name, path, system, method FROM routes WHERE ref = $1
+        SELECT `+routeColumns+` FROM routes WHERE ref = $1
     `, id)
 	if err != nil {
 		return nil, err
@@ -56,7 +70,7 @@ func (r *routeRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Ro
 func (r *routeRepository) GetBySystem(ctx context.Context, systemID uuid.UUID) ([]models.Route, error) {
 	var routes []models.Route
 	err := r.db.SelectContext(ctx, &routes, `
-        SELECT ref, name, path, system, method FROM routes WHERE system = $1
+        SELECT `+routeColumns+` FROM routes WHERE system = $1
     `, systemID)
 	return routes, err
 }
@@ -27,22 +27,25 @@ func NewSystemRepository(db *sqlx.DB) SystemRepository {
 
 func (r *systemRepository) Create(ctx context.Context, s *models.System) error {
 	s.Ref = uuid.New()
+	if s.LoadBalancing == "" {
+		s.LoadBalancing = models.BalancingFailover
+	}
 	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO systems (ref, name)
-        VALUES ($1, $2)
-    `, s.Ref, s.Name)
+        INSERT INTO systems (ref, name, load_balancing)
+        VALUES ($1, $2, $3)
+    `, s.Ref, s.Name, s.LoadBalancing)
 	return err
 }
 
 func (r *systemRepository) GetAll(ctx context.Context) ([]models.System, error) {
 	var systems []models.System
-	err := r.db.SelectContext(ctx, &systems, `SELECT ref, name FROM systems ORDER BY name`)
+	err := r.db.SelectContext(ctx, &systems, `SELECT ref, name, load_balancing FROM systems ORDER BY name`)
 	return systems, err
 }
 
 func (r *systemRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.System, error) {
 	var system models.System
-	err := r.db.GetContext(ctx, &system, `SELECT ref, name FROM systems WHERE ref = $1`, id)
+	err := r.db.GetContext(ctx, &system, `SELECT ref, name, load_balancing FROM systems WHERE ref = $1`, id)
 	if err != nil {
 		return nil, err
 	}
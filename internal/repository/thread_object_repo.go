@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type ThreadObjectRepository interface {
+	Create(ctx context.Context, object *models.ThreadObject) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.ThreadObject, error)
+	GetChildren(ctx context.Context, parent uuid.UUID) ([]models.ThreadObject, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// threadObjectColumns колонки thread_objects, name_object может быть NULL
+const threadObjectColumns = `ref, name, COALESCE(name_object, '') AS name_object, type, parent`
+
+type threadObjectRepository struct {
+	db *sqlx.DB
+}
+
+func NewThreadObjectRepository(db *sqlx.DB) ThreadObjectRepository {
+	return &threadObjectRepository{db: db}
+}
+
+func (r *threadObjectRepository) Create(ctx context.Context, object *models.ThreadObject) error {
+	object.Ref = uuid.New()
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_objects (ref, name, name_object, type, parent)
+        VALUES ($1, $2, $3, $4, $5)
+    `, object.Ref, object.Name, object.NameObject, object.Type, object.Parent)
+	return err
+}
+
+func (r *threadObjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ThreadObject, error) {
+	var object models.ThreadObject
+	err := r.db.GetContext(ctx, &object, `
+        SELECT `+threadObjectColumns+` FROM thread_objects WHERE ref = $1
+    `, id)
+	if err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+func (r *threadObjectRepository) GetChildren(ctx context.Context, parent uuid.UUID) ([]models.ThreadObject, error) {
+	var objects []models.ThreadObject
+	err := r.db.SelectContext(ctx, &objects, `
+        SELECT `+threadObjectColumns+` FROM thread_objects WHERE parent = $1 ORDER BY name
+    `, parent)
+	return objects, err
+}
+
+// Delete удаляет объект вместе с дочерними (ON DELETE CASCADE)
+func (r *threadObjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM thread_objects WHERE ref = $1`, id)
+	return err
+}
@@ -16,6 +16,9 @@ type ThreadRepository interface {
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// threadColumns колонки threads в порядке полей models.Thread
+const threadColumns = `ref, name, "group", message_convert_type, request_response, idempotency, amqp_consumer`
+
 type threadRepository struct {
 	db *sqlx.DB
 }
@@ -27,16 +30,16 @@ func NewThreadRepository(db *sqlx.DB) ThreadRepository {
 func (r *threadRepository) Create(ctx context.Context, t *models.Thread) error {
 	t.Ref = uuid.New()
 	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO threads (ref, name, "group", message_convert_type)
-        VALUES ($1, $2, $3, $4)
-    `, t.Ref, t.Name, t.Group, t.MessageConvertType)
+        INSERT INTO threads (`+threadColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, t.Ref, t.Name, t.Group, t.MessageConvertType, t.RequestResponse, t.Idempotency, t.AMQPConsumer)
 	return err
 }
 
 func (r *threadRepository) GetAll(ctx context.Context) ([]models.Thread, error) {
 	var threads []models.Thread
 	err := r.db.SelectContext(ctx, &threads, `
-        SELECT ref, name, "group", message_convert_type FROM threads ORDER BY name
+        SELECT `+threadColumns+` FROM threads ORDER BY name
     `)
 	return threads, err
 }
@@ -44,7 +47,7 @@ func (r *threadRepository) GetAll(ctx context.Context) ([]models.Thread, error)
 func (r *threadRepository) GetByGroup(ctx context.Context, groupID uuid.UUID) ([]models.Thread, error) {
 	var threads []models.Thread
 	err := r.db.SelectContext(ctx, &threads, `
-        SELECT ref, name, "group", message_convert_type FROM threads WHERE "group" = $1
+        SELECT `+threadColumns+` FROM threads WHERE "group" = $1
     `, groupID)
 	return threads, err
 }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"go-esb/internal/models"
 
@@ -15,8 +16,14 @@ type ThreadRouteRepository interface {
 	GetThreadRouteByRouteID(ctx context.Context, routeID uuid.UUID) (*models.ThreadRoute, error)
 	CreateThreadRoute(ctx context.Context, tr *models.ThreadRoute) error
 	GetThreadWithGroup(ctx context.Context, threadID uuid.UUID) (*models.Thread, *models.ThreadGroup, error)
+	GetThreadsWithAMQPConsumer(ctx context.Context) ([]models.Thread, error)
+	SetProtoSchema(ctx context.Context, threadID uuid.UUID, direction models.Directions, routeID uuid.UUID, descriptor []byte, message string) error
 }
 
+// threadRouteColumns колонки thread_routes в порядке полей models.ThreadRoute
+const threadRouteColumns = `thread, direction, route, file_format, object, routine, source_charset, target_charset,
+        proto_descriptor, proto_message, edi_settings, headers, query, timeout_ms, retry`
+
 type threadRouteRepository struct {
 	db *sqlx.DB
 }
@@ -28,7 +35,7 @@ func NewThreadRouteRepository(db *sqlx.DB) ThreadRouteRepository {
 func (r *threadRouteRepository) GetThreadRoutes(ctx context.Context, threadID uuid.UUID) ([]models.ThreadRoute, error) {
 	var routes []models.ThreadRoute
 	err := r.db.SelectContext(ctx, &routes, `
-        SELECT thread, direction, route, file_format, object, routine 
+        SELECT `+threadRouteColumns+`
         FROM thread_routes 
         WHERE thread = $1
     `, threadID)
@@ -38,7 +45,7 @@ func (r *threadRouteRepository) GetThreadRoutes(ctx context.Context, threadID uu
 func (r *threadRouteRepository) GetThreadRouteByDirection(ctx context.Context, threadID uuid.UUID, direction models.Directions) ([]models.ThreadRoute, error) {
 	var routes []models.ThreadRoute
 	err := r.db.SelectContext(ctx, &routes, `
-        SELECT thread, direction, route, file_format, object, routine 
+        SELECT `+threadRouteColumns+`
         FROM thread_routes 
         WHERE thread = $1 AND direction = $2
     `, threadID, direction)
@@ -47,17 +54,18 @@ func (r *threadRouteRepository) GetThreadRouteByDirection(ctx context.Context, t
 
 func (r *threadRouteRepository) CreateThreadRoute(ctx context.Context, tr *models.ThreadRoute) error {
 	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO thread_routes (thread, direction, route, file_format, object, routine)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO thread_routes (`+threadRouteColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
         ON CONFLICT (thread, direction, route) DO NOTHING
-    `, tr.Thread, tr.Direction, tr.Route, tr.FileFormat, tr.Object, tr.Routine)
+    `, tr.Thread, tr.Direction, tr.Route, tr.FileFormat, tr.Object, tr.Routine, tr.SourceCharset, tr.TargetCharset,
+		tr.ProtoDescriptor, tr.ProtoMessage, tr.EDISettings, tr.Headers, tr.Query, tr.TimeoutMs, tr.Retry)
 	return err
 }
 
 func (r *threadRouteRepository) GetThreadWithGroup(ctx context.Context, threadID uuid.UUID) (*models.Thread, *models.ThreadGroup, error) {
 	var thread models.Thread
 	err := r.db.GetContext(ctx, &thread, `
-        SELECT ref, name, "group", message_convert_type 
+        SELECT `+threadColumns+`
         FROM threads 
         WHERE ref = $1
     `, threadID)
@@ -78,3 +86,28 @@ func (r *threadRouteRepository) GetThreadWithGroup(ctx context.Context, threadID
 	return &thread, &group, nil
 }
 
+// GetThreadsWithAMQPConsumer возвращает threads, получающие сообщения из очереди AMQP
+func (r *threadRouteRepository) GetThreadsWithAMQPConsumer(ctx context.Context) ([]models.Thread, error) {
+	var threads []models.Thread
+	err := r.db.SelectContext(ctx, &threads, `
+        SELECT `+threadColumns+`
+        FROM threads
+        WHERE COALESCE(amqp_consumer->>'queue', '') <> ''
+        ORDER BY name
+    `)
+	return threads, err
+}
+
+func (r *threadRouteRepository) SetProtoSchema(ctx context.Context, threadID uuid.UUID, direction models.Directions, routeID uuid.UUID, descriptor []byte, message string) error {
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE thread_routes SET proto_descriptor = $4, proto_message = $5
+        WHERE thread = $1 AND direction = $2 AND route = $3
+    `, threadID, direction, routeID, descriptor, message)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
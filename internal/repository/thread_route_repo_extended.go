@@ -12,7 +12,7 @@ import (
 func (r *threadRouteRepository) GetThreadRouteByRouteID(ctx context.Context, routeID uuid.UUID) (*models.ThreadRoute, error) {
 	var route models.ThreadRoute
 	err := r.db.GetContext(ctx, &route, `
-        SELECT thread, direction, route, file_format, object, routine 
+        SELECT `+threadRouteColumns+`
         FROM thread_routes 
         WHERE route = $1
         LIMIT 1
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// UserRepository хранит пользователей HTTP API и их роли в группах threads
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	GetAll(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	Count(ctx context.Context) (int, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error
+	UpdateActive(ctx context.Context, id uuid.UUID, active bool) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetThreadGroups(ctx context.Context, userID uuid.UUID) ([]models.UserThreadGroup, error)
+	SetThreadGroups(ctx context.Context, userID uuid.UUID, groups []models.UserThreadGroup) error
+}
+
+type userRepository struct {
+	db *sqlx.DB
+}
+
+func NewUserRepository(db *sqlx.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+// userColumns колонки users в порядке полей models.User
+const userColumns = `ref, username, password, role, active, token_version`
+
+func (r *userRepository) Create(ctx context.Context, u *models.User) error {
+	u.Ref = uuid.New()
+	return r.db.QueryRowxContext(ctx, `
+        INSERT INTO users (ref, username, password, role)
+        VALUES ($1, $2, $3, $4)
+        RETURNING active, token_version
+    `, u.Ref, u.Username, u.Password, u.Role).Scan(&u.Active, &u.TokenVersion)
+}
+
+func (r *userRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := r.db.SelectContext(ctx, &users, `SELECT `+userColumns+` FROM users ORDER BY username`)
+	return users, err
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := r.db.GetContext(ctx, &user, `SELECT `+userColumns+` FROM users WHERE ref = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	err := r.db.GetContext(ctx, &user, `SELECT `+userColumns+` FROM users WHERE username = $1`, username)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM users`)
+	return count, err
+}
+
+// UpdatePassword меняет пароль и отзывает выданные пользователю токены
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE users SET password = $2, token_version = token_version + 1 WHERE ref = $1
+    `, id, passwordHash)
+	return err
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET role = $2 WHERE ref = $1`, id, role)
+	return err
+}
+
+// UpdateActive включает или отключает пользователя; отключение отзывает его токены
+func (r *userRepository) UpdateActive(ctx context.Context, id uuid.UUID, active bool) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE users SET active = $2,
+            token_version = token_version + CASE WHEN active AND NOT $2 THEN 1 ELSE 0 END
+        WHERE ref = $1
+    `, id, active)
+	return err
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE ref = $1`, id)
+	return err
+}
+
+// GetThreadGroups возвращает роли пользователя в группах threads
+func (r *userRepository) GetThreadGroups(ctx context.Context, userID uuid.UUID) ([]models.UserThreadGroup, error) {
+	var groups []models.UserThreadGroup
+	err := r.db.SelectContext(ctx, &groups, `
+        SELECT user_ref, thread_group, role FROM user_thread_groups WHERE user_ref = $1
+    `, userID)
+	return groups, err
+}
+
+// SetThreadGroups заменяет роли пользователя в группах threads
+func (r *userRepository) SetThreadGroups(ctx context.Context, userID uuid.UUID, groups []models.UserThreadGroup) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_thread_groups WHERE user_ref = $1`, userID); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO user_thread_groups (user_ref, thread_group, role)
+            VALUES ($1, $2, $3)
+        `, userID, group.ThreadGroup, group.Role)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
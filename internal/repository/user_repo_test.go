@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"go-esb/internal/models"
+)
+
+// TestUserTokenVersion проверяет, что смена пароля и отключение увеличивают
+// номер токенов пользователя, а включение и повторное отключение — нет
+func TestUserTokenVersion(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewUserRepository(db)
+
+	user := models.User{Username: "operator", Password: "hash", Role: models.RoleOperator}
+	if err := repo.Create(ctx, &user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !user.Active || user.TokenVersion != 1 {
+		t.Fatalf("created user active %v, token version %d; want true, 1", user.Active, user.TokenVersion)
+	}
+
+	steps := []struct {
+		name        string
+		update      func() error
+		wantActive  bool
+		wantVersion int
+	}{
+		{"password change", func() error { return repo.UpdatePassword(ctx, user.Ref, "new-hash") }, true, 2},
+		{"deactivation", func() error { return repo.UpdateActive(ctx, user.Ref, false) }, false, 3},
+		{"repeated deactivation", func() error { return repo.UpdateActive(ctx, user.Ref, false) }, false, 3},
+		{"activation", func() error { return repo.UpdateActive(ctx, user.Ref, true) }, true, 3},
+	}
+	for _, step := range steps {
+		if err := step.update(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		got, err := repo.GetByID(ctx, user.Ref)
+		if err != nil {
+			t.Fatalf("GetByID after %s: %v", step.name, err)
+		}
+		if got.Active != step.wantActive || got.TokenVersion != step.wantVersion {
+			t.Errorf("after %s: active %v, token version %d; want %v, %d", step.name, got.Active, got.TokenVersion, step.wantActive, step.wantVersion)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// EnvProvider читает секреты из переменных окружения (env:SAP_PASSWORD)
+type EnvProvider struct{}
+
+// Resolve возвращает значение переменной окружения
+func (EnvProvider) Resolve(_ context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable is not set")
+	}
+	return value, nil
+}
+
+// FileProvider читает секреты из файлов (file:/run/secrets/sf_token),
+// например Docker и Kubernetes secrets. Завершающий перевод строки отбрасывается.
+type FileProvider struct{}
+
+// Resolve возвращает содержимое файла
+func (FileProvider) Resolve(_ context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultProvider читает секреты из HashiCorp Vault (или совместимого API) по HTTP.
+// Ссылка имеет вид "<путь>#<ключ>", например "kv/data/sap#password";
+// поддерживаются KV v2 (data.data) и KV v1 (data).
+type VaultProvider struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider создает провайдер Vault. addr — адрес сервера (VAULT_ADDR),
+// client может быть nil.
+func NewVaultProvider(addr, token, namespace string, client *http.Client) *VaultProvider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &VaultProvider{
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    client,
+	}
+}
+
+// vaultResponse ответ Vault на чтение секрета
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// Resolve читает секрет из Vault
+func (v *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	path, key, _ := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("vault path is empty")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", v.addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault response: %w", err)
+	}
+
+	var vaultResp vaultResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return "", fmt.Errorf("failed to parse vault response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("vault error %d: %s", resp.StatusCode, strings.Join(vaultResp.Errors, "; "))
+	}
+
+	data := vaultResp.Data
+	// KV v2 хранит значения во вложенном data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	if key == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("vault secret has %d keys, specify one with #key", len(data))
+		}
+		for _, value := range data {
+			return stringValue(value), nil
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in vault secret", key)
+	}
+	return stringValue(value), nil
+}
+
+func stringValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
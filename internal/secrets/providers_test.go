@@ -0,0 +1,167 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// vaultStub отвечает как Vault: секреты по пути, проверка токена и namespace
+func vaultStub(t *testing.T, token, namespace string, secrets map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"errors":["method not allowed"]}`))
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != token || r.Header.Get("X-Vault-Namespace") != namespace {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		body, ok := secrets[strings.TrimPrefix(r.URL.Path, "/v1/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultProviderResolve(t *testing.T) {
+	server := vaultStub(t, "s.token", "team", map[string]string{
+		"kv/data/sap": `{"data":{"data":{"password":"sap-secret","user":"esb","port":3300},"metadata":{"version":2}}}`,
+		"secret/sf":   `{"data":{"token":"sf-token"}}`,
+		"kv/data/one": `{"data":{"data":{"value":"only"},"metadata":{"version":1}}}`,
+		"kv/v1/data":  `{"data":{"data":"not nested"}}`,
+		"kv/broken":   `not json`,
+	})
+	vault := NewVaultProvider(server.URL+"/", "s.token", "team", server.Client())
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{name: "kv v2", ref: "kv/data/sap#password", want: "sap-secret"},
+		{name: "kv v2 leading slash", ref: "/kv/data/sap#user", want: "esb"},
+		{name: "kv v2 number as json", ref: "kv/data/sap#port", want: "3300"},
+		{name: "kv v1", ref: "secret/sf#token", want: "sf-token"},
+		{name: "single key without #key", ref: "kv/data/one", want: "only"},
+		{name: "kv v1 field named data", ref: "kv/v1/data#data", want: "not nested"},
+		{name: "several keys without #key", ref: "kv/data/sap", wantErr: "specify one with #key"},
+		{name: "missing key", ref: "kv/data/sap#token", wantErr: `key "token" not found`},
+		{name: "missing secret", ref: "kv/data/none#password", wantErr: "vault error 404"},
+		{name: "empty path", ref: "#password", wantErr: "vault path is empty"},
+		{name: "invalid response", ref: "kv/broken#x", wantErr: "failed to parse vault response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vault.Resolve(context.Background(), tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve = %q, %v; want error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultProviderCredentials(t *testing.T) {
+	server := vaultStub(t, "s.token", "team", map[string]string{
+		"secret/sf": `{"data":{"token":"sf-token"}}`,
+	})
+
+	tests := []struct {
+		name      string
+		token     string
+		namespace string
+		wantErr   bool
+	}{
+		{"valid token and namespace", "s.token", "team", false},
+		{"wrong token", "s.other", "team", true},
+		{"no token", "", "team", true},
+		{"wrong namespace", "s.token", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := NewVaultProvider(server.URL, tt.token, tt.namespace, server.Client())
+			_, err := vault.Resolve(context.Background(), "secret/sf#token")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "permission denied") {
+				t.Fatalf("Resolve error = %v, want vault errors in message", err)
+			}
+		})
+	}
+}
+
+func TestVaultProviderUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	vault := NewVaultProvider(server.URL, "s.token", "", nil)
+	if _, err := vault.Resolve(context.Background(), "secret/sf#token"); err == nil || !strings.Contains(err.Error(), "vault request failed") {
+		t.Fatalf("Resolve error = %v, want request failure", err)
+	}
+}
+
+func TestEnvProviderResolve(t *testing.T) {
+	t.Setenv("ESB_TEST_SECRET", "env-secret")
+	t.Setenv("ESB_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"set", "ESB_TEST_SECRET", "env-secret", false},
+		{"set to empty", "ESB_TEST_EMPTY", "", false},
+		{"not set", "ESB_TEST_MISSING", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EnvProvider{}.Resolve(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderResolve(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("file-secret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FileProvider{}.Resolve(context.Background(), path)
+	if err != nil || got != "file-secret" {
+		t.Fatalf("Resolve = %q, %v; want file-secret without line break", got, err)
+	}
+	if _, err := (FileProvider{}).Resolve(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("Resolve of missing file returned no error")
+	}
+}
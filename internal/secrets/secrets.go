@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// SecretProvider получает значение секрета по ссылке без схемы,
+// например "SAP_PASSWORD" для env: или "kv/data/sap#password" для vault:
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver разрешает ссылки вида "<схема>:<ссылка>" через зарегистрированные
+// провайдеры и кэширует значения на TTL. Значения без известной схемы
+// возвращаются как есть.
+type Resolver struct {
+	providers map[string]SecretProvider
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewResolver создает Resolver. ttl = 0 отключает кэширование.
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{
+		providers: make(map[string]SecretProvider),
+		ttl:       ttl,
+		cache:     make(map[string]cachedSecret),
+	}
+}
+
+// Register регистрирует провайдер для схемы (env, file, vault)
+func (r *Resolver) Register(scheme string, provider SecretProvider) {
+	r.providers[scheme] = provider
+}
+
+// IsReference проверяет, что значение является ссылкой на секрет
+func (r *Resolver) IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, ":")
+	if !ok {
+		return false
+	}
+	_, known := r.providers[scheme]
+	return known
+}
+
+// Resolve возвращает значение секрета для ссылки или само значение
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+	provider, known := r.providers[scheme]
+	if !known {
+		return value, nil
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		cached, found := r.cache[value]
+		r.mu.Unlock()
+		if found && time.Now().Before(cached.expiresAt) {
+			return cached.value, nil
+		}
+	}
+
+	secret, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s secret %q: %w", scheme, ref, err)
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[value] = cachedSecret{value: secret, expiresAt: time.Now().Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return secret, nil
+}
+
+// ResolveAuth возвращает копию аутентификации с разрешенными ссылками на секреты
+func (r *Resolver) ResolveAuth(ctx context.Context, auth *models.ConnectionAuthentication) (*models.ConnectionAuthentication, error) {
+	resolved := *auth
+	fields := append([]*string{&resolved.Username, &resolved.ClientID, &resolved.Certificate}, resolved.Secrets()...)
+	for _, field := range fields {
+		value, err := r.Resolve(ctx, *field)
+		if err != nil {
+			return nil, err
+		}
+		*field = value
+	}
+	return &resolved, nil
+}
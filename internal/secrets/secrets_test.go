@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// countingProvider считает обращения к хранилищу секретов
+type countingProvider struct {
+	values map[string]string
+	calls  int
+}
+
+func (p *countingProvider) Resolve(_ context.Context, ref string) (string, error) {
+	p.calls++
+	value, ok := p.values[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestResolverResolve(t *testing.T) {
+	t.Setenv("ESB_TEST_PASSWORD", "env-password")
+	resolver := NewResolver(time.Minute)
+	resolver.Register("env", EnvProvider{})
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"env reference", "env:ESB_TEST_PASSWORD", "env-password", false},
+		{"plain value", "password", "password", false},
+		{"unknown scheme", "https://example.com", "https://example.com", false},
+		{"missing variable", "env:ESB_TEST_MISSING", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.Resolve(context.Background(), tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int
+	}{
+		{"cached", time.Minute, 1},
+		{"cache disabled", 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &countingProvider{values: map[string]string{"kv/sap#password": "secret"}}
+			resolver := NewResolver(tt.ttl)
+			resolver.Register("vault", provider)
+			for i := 0; i < 3; i++ {
+				if _, err := resolver.Resolve(context.Background(), "vault:kv/sap#password"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if provider.calls != tt.wantCalls {
+				t.Fatalf("provider called %d times, want %d", provider.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResolverResolveAuth(t *testing.T) {
+	t.Setenv("ESB_TEST_PASSWORD", "env-password")
+	resolver := NewResolver(0)
+	resolver.Register("env", EnvProvider{})
+
+	auth := &models.ConnectionAuthentication{Username: "esb", Password: "env:ESB_TEST_PASSWORD"}
+	resolved, err := resolver.ResolveAuth(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("ResolveAuth: %v", err)
+	}
+	if resolved.Username != "esb" || resolved.Password != "env-password" {
+		t.Fatalf("ResolveAuth = %s/%s, want esb/env-password", resolved.Username, resolved.Password)
+	}
+	if auth.Password != "env:ESB_TEST_PASSWORD" {
+		t.Fatal("ResolveAuth changed the stored authentication")
+	}
+
+	auth.Password = "env:ESB_TEST_MISSING"
+	if _, err := resolver.ResolveAuth(context.Background(), auth); err == nil || !strings.Contains(err.Error(), "ESB_TEST_MISSING") {
+		t.Fatalf("ResolveAuth error = %v, want missing variable", err)
+	}
+}
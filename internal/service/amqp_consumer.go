@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-esb/internal/adapter"
+	"go-esb/internal/converter"
+	"go-esb/internal/logging"
+	"go-esb/internal/metrics"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+	"go-esb/internal/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// amqpConsumerReload период, с которым перечитываются очереди threads
+	amqpConsumerReload = 30 * time.Second
+	// amqpReconnectDelay и amqpMaxReconnectDelay пауза перед повторным
+	// подключением к брокеру (удваивается после каждой неудачи)
+	amqpReconnectDelay    = time.Second
+	amqpMaxReconnectDelay = time.Minute
+	// amqpInProgressDelay пауза перед возвратом в очередь сообщения, ключ
+	// которого еще обрабатывается
+	amqpInProgressDelay = time.Second
+)
+
+// AMQPConsumer читает очереди AMQP threads (threads.amqp_consumer) и передает
+// сообщения в thread как входящие (In). Повторно доставленные сообщения
+// подавляются по ключу идемпотентности thread, как и в HTTP API.
+type AMQPConsumer struct {
+	threadRouteRepo    repository.ThreadRouteRepository
+	connectionRepo     repository.ConnectionRepository
+	systemRepo         repository.SystemRepository
+	secrets            *secrets.Resolver
+	messageService     MessageService
+	idempotencyService IdempotencyService
+	amqp               *adapter.AMQPAdapter
+
+	// running подписки по thread; изменяется только в Run
+	running map[uuid.UUID]amqpSubscription
+}
+
+type amqpSubscription struct {
+	thread models.Thread
+	cancel context.CancelFunc
+}
+
+// NewAMQPConsumer создает потребителя входящих сообщений AMQP
+func NewAMQPConsumer(
+	threadRouteRepo repository.ThreadRouteRepository,
+	connectionRepo repository.ConnectionRepository,
+	systemRepo repository.SystemRepository,
+	secretResolver *secrets.Resolver,
+	messageService MessageService,
+	idempotencyService IdempotencyService,
+) *AMQPConsumer {
+	return &AMQPConsumer{
+		threadRouteRepo:    threadRouteRepo,
+		connectionRepo:     connectionRepo,
+		systemRepo:         systemRepo,
+		secrets:            secretResolver,
+		messageService:     messageService,
+		idempotencyService: idempotencyService,
+		amqp:               adapter.NewAMQPAdapter(),
+		running:            make(map[uuid.UUID]amqpSubscription),
+	}
+}
+
+// Run читает очереди до отмены контекста. Изменения amqp_consumer
+// применяются без перезапуска.
+func (c *AMQPConsumer) Run(ctx context.Context) {
+	ticker := time.NewTicker(amqpConsumerReload)
+	defer ticker.Stop()
+	for {
+		c.reload(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reload запускает подписки новых и измененных threads и останавливает
+// подписки threads, у которых очередь больше не задана
+func (c *AMQPConsumer) reload(ctx context.Context) {
+	threads, err := c.threadRouteRepo.GetThreadsWithAMQPConsumer(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "⚠️ Failed to get threads with AMQP consumer", "error", err)
+		return
+	}
+
+	active := make(map[uuid.UUID]bool, len(threads))
+	for _, thread := range threads {
+		active[thread.Ref] = true
+		if subscription, ok := c.running[thread.Ref]; ok {
+			if subscription.thread == thread {
+				continue
+			}
+			subscription.cancel()
+		}
+		subscriptionCtx, cancel := context.WithCancel(ctx)
+		c.running[thread.Ref] = amqpSubscription{thread: thread, cancel: cancel}
+		go c.consume(subscriptionCtx, thread)
+	}
+	for ref, subscription := range c.running {
+		if !active[ref] {
+			subscription.cancel()
+			delete(c.running, ref)
+		}
+	}
+}
+
+// consume читает очередь thread, переподключаясь к брокеру после разрыва
+func (c *AMQPConsumer) consume(ctx context.Context, thread models.Thread) {
+	ctx = logging.WithFields(ctx, "thread", thread.Name, "queue", thread.AMQPConsumer.Queue)
+	delay := amqpReconnectDelay
+	for {
+		started := time.Now()
+		err := c.consumeQueue(ctx, thread)
+		if ctx.Err() != nil {
+			return
+		}
+		// После долгой работы соединения пауза начинается заново
+		if time.Since(started) > amqpMaxReconnectDelay {
+			delay = amqpReconnectDelay
+		}
+		logger.WarnContext(ctx, "⚠️ AMQP consumer disconnected, reconnecting", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > amqpMaxReconnectDelay {
+			delay = amqpMaxReconnectDelay
+		}
+	}
+}
+
+// consumeQueue подключается к брокерам системы по priority и читает очередь
+// через первое доступное подключение. Разорванное соединение отражается
+// в метрике AMQPConnectionUp.
+func (c *AMQPConsumer) consumeQueue(ctx context.Context, thread models.Thread) error {
+	system, err := c.systemRepo.GetByID(ctx, thread.AMQPConsumer.System)
+	if err != nil {
+		return fmt.Errorf("failed to get system: %w", err)
+	}
+	connections, err := c.connectionRepo.GetConnectionSettingsBySystem(ctx, thread.AMQPConsumer.System)
+	if err != nil {
+		return fmt.Errorf("failed to get connections: %w", err)
+	}
+
+	lastErr := fmt.Errorf("system %s has no AMQP broker connection", thread.AMQPConsumer.System)
+	for i := range connections {
+		connection := &connections[i]
+		if !strings.HasPrefix(connection.Path, "amqp://") && !strings.HasPrefix(connection.Path, "amqps://") {
+			continue
+		}
+		var auth *models.ConnectionAuthentication
+		if connection.AuthRef != uuid.Nil {
+			stored, err := c.connectionRepo.GetConnectionAuth(ctx, connection.AuthRef)
+			if err != nil {
+				return fmt.Errorf("failed to get authentication: %w", err)
+			}
+			if auth, err = c.secrets.ResolveAuth(ctx, stored); err != nil {
+				return fmt.Errorf("failed to resolve credentials: %w", err)
+			}
+		}
+
+		logger.InfoContext(ctx, "📥 Consuming AMQP queue", "connection", connection.Name)
+		lastErr = c.amqp.Consume(ctx, adapter.Connection{Settings: connection, Auth: auth, System: system.Name}, thread.AMQPConsumer.Queue, func(delivery *adapter.AMQPDelivery) {
+			c.handle(ctx, thread, delivery)
+		})
+		metrics.AMQPConnectionUp.WithLabelValues(system.Name, connection.Name).Set(0)
+		if ctx.Err() != nil {
+			return lastErr
+		}
+		logger.WarnContext(ctx, "⚠️ AMQP connection failed", "connection", connection.Name, "error", lastErr)
+	}
+	return lastErr
+}
+
+// handle обрабатывает сообщение и подтверждает его. Сообщение, ключ которого
+// еще обрабатывается, возвращается в очередь; сообщение с ошибкой обработки
+// возвращается в очередь один раз, затем отклоняется (dead letter exchange).
+func (c *AMQPConsumer) handle(ctx context.Context, thread models.Thread, delivery *adapter.AMQPDelivery) {
+	if correlationID := delivery.Headers[logging.CorrelationHeader]; correlationID != "" {
+		ctx = logging.WithCorrelationID(ctx, correlationID)
+	}
+
+	// Контекст трассировки отправителя (traceparent) становится родителем span получателя
+	ctx = tracing.Extract(ctx, propagation.MapCarrier(delivery.Headers))
+	ctx, span := tracing.Start(ctx, thread.AMQPConsumer.Queue+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationReceive,
+			semconv.MessagingDestinationName(thread.AMQPConsumer.Queue),
+		),
+	)
+	defer span.End()
+
+	process := func() (*models.IdempotentResult, error) {
+		msg := &models.Message{
+			Data:    delivery.Body,
+			Format:  models.FileFormat(converter.FormatFromContentType(delivery.ContentType)),
+			Charset: converter.CharsetFromContentType(delivery.ContentType),
+		}
+		if _, err := c.messageService.RouteMessage(ctx, thread.Ref, models.DirectionIn, msg); err != nil {
+			return nil, err
+		}
+		return &models.IdempotentResult{StatusCode: http.StatusOK}, nil
+	}
+
+	// Повтор сообщения с тем же ключом подтверждается без повторной обработки
+	key, err := c.idempotencyService.Key(thread.Idempotency, delivery.Headers, delivery.Body)
+	if err != nil {
+		logger.WarnContext(ctx, "⚠️ Message has no idempotency key, processing without duplicate check", "error", err)
+	}
+	if key == "" {
+		_, err = process()
+	} else {
+		scope := "thread:" + thread.Ref.String() + ":" + string(models.DirectionIn)
+		_, _, err = c.idempotencyService.Execute(ctx, scope, key, delivery.Body, time.Duration(thread.Idempotency.TTLSeconds)*time.Second, process)
+	}
+
+	switch {
+	case err == nil:
+		err = delivery.Ack()
+	case errors.Is(err, ErrDuplicateInProgress):
+		select {
+		case <-ctx.Done():
+		case <-time.After(amqpInProgressDelay):
+		}
+		err = delivery.Nack(true)
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		logger.ErrorContext(ctx, "❌ Error processing AMQP message", "idempotency_key", key, "redelivered", delivery.Redelivered, "error", err)
+		err = delivery.Nack(!delivery.Redelivered)
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "⚠️ Failed to acknowledge AMQP message", "error", err)
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-esb/internal/adapter"
+	"go-esb/internal/models"
+	"go-esb/internal/tracing"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingAcknowledger запоминает подтверждение доставки
+type recordingAcknowledger struct {
+	acked   int
+	nacked  int
+	requeue bool
+}
+
+func (a *recordingAcknowledger) Ack(uint64, bool) error {
+	a.acked++
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	a.nacked++
+	a.requeue = requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(_ uint64, requeue bool) error {
+	return a.Nack(0, false, requeue)
+}
+
+// routingMessageService считает входящие сообщения thread
+type routingMessageService struct {
+	MessageService
+	routed int
+	err    error
+	// span контекст трассировки последнего сообщения
+	span trace.SpanContext
+}
+
+func (s *routingMessageService) RouteMessage(ctx context.Context, _ uuid.UUID, direction models.Directions, msg *models.Message) (*models.Response, error) {
+	if direction != models.DirectionIn {
+		return nil, errors.New("AMQP message routed as " + string(direction))
+	}
+	s.routed++
+	s.span = trace.SpanContextFromContext(ctx)
+	return nil, s.err
+}
+
+// recordSpans записывает spans шины до конца теста
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), tracing.ExporterNone); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func newTestAMQPConsumer(messages MessageService, repo *memoryIdempotencyRepo) *AMQPConsumer {
+	return &AMQPConsumer{
+		messageService:     messages,
+		idempotencyService: NewIdempotencyService(repo, nil, time.Hour, nil),
+	}
+}
+
+func amqpThread() models.Thread {
+	return models.Thread{
+		Ref:         uuid.New(),
+		Name:        "orders",
+		Idempotency: models.IdempotencySettings{Source: models.IdempotencyHeader, Header: adapter.AMQPMessageIDHeader},
+	}
+}
+
+func testDelivery(messageID string, redelivered bool) (*adapter.AMQPDelivery, *recordingAcknowledger) {
+	ack := &recordingAcknowledger{}
+	delivery := adapter.NewAMQPDelivery(amqp.Delivery{
+		Acknowledger: ack,
+		ContentType:  "application/json",
+		MessageId:    messageID,
+		Redelivered:  redelivered,
+		Body:         []byte(`{"order":1}`),
+	})
+	return delivery, ack
+}
+
+func TestAMQPConsumerAcksDuplicateWithoutReprocessing(t *testing.T) {
+	messages := &routingMessageService{}
+	consumer := newTestAMQPConsumer(messages, newMemoryIdempotencyRepo())
+	thread := amqpThread()
+
+	for i, redelivered := range []bool{false, true, false} {
+		delivery, ack := testDelivery("msg-1", redelivered)
+		consumer.handle(context.Background(), thread, delivery)
+		if ack.acked != 1 || ack.nacked != 0 {
+			t.Fatalf("delivery %d: acked %d, nacked %d; want ack", i+1, ack.acked, ack.nacked)
+		}
+	}
+	if messages.routed != 1 {
+		t.Fatalf("message routed %d times, want 1", messages.routed)
+	}
+
+	delivery, ack := testDelivery("msg-2", false)
+	consumer.handle(context.Background(), thread, delivery)
+	if ack.acked != 1 || messages.routed != 2 {
+		t.Fatalf("new message: acked %d, routed %d; want ack and routing", ack.acked, messages.routed)
+	}
+}
+
+func TestAMQPConsumerRequeuesFailureAndReleasesKey(t *testing.T) {
+	messages := &routingMessageService{err: errors.New("route failed")}
+	repo := newMemoryIdempotencyRepo()
+	consumer := newTestAMQPConsumer(messages, repo)
+	thread := amqpThread()
+
+	// Первая ошибка возвращает сообщение в очередь
+	delivery, ack := testDelivery("msg-1", false)
+	consumer.handle(context.Background(), thread, delivery)
+	if ack.nacked != 1 || !ack.requeue || ack.acked != 0 {
+		t.Fatalf("first failure: nacked %d (requeue %v), acked %d; want nack with requeue", ack.nacked, ack.requeue, ack.acked)
+	}
+	if repo.released != 1 {
+		t.Fatalf("released %d keys after failure, want 1", repo.released)
+	}
+
+	// Повторная ошибка отклоняет сообщение без возврата в очередь
+	delivery, ack = testDelivery("msg-1", true)
+	consumer.handle(context.Background(), thread, delivery)
+	if ack.nacked != 1 || ack.requeue {
+		t.Fatalf("redelivered failure: nacked %d (requeue %v); want nack without requeue", ack.nacked, ack.requeue)
+	}
+	if repo.released != 2 || messages.routed != 2 {
+		t.Fatalf("released %d keys, routed %d times; want 2 and 2", repo.released, messages.routed)
+	}
+
+	// Освобожденный ключ не подавляет следующую доставку
+	messages.err = nil
+	delivery, ack = testDelivery("msg-1", true)
+	consumer.handle(context.Background(), thread, delivery)
+	if ack.acked != 1 || messages.routed != 3 {
+		t.Fatalf("after recovery: acked %d, routed %d; want ack and routing", ack.acked, messages.routed)
+	}
+}
+
+func TestAMQPConsumerRequeuesInProgressDuplicate(t *testing.T) {
+	messages := &routingMessageService{}
+	repo := newMemoryIdempotencyRepo()
+	consumer := newTestAMQPConsumer(messages, repo)
+	thread := amqpThread()
+
+	scope := "thread:" + thread.Ref.String() + ":In"
+	if _, _, err := repo.Reserve(context.Background(), scope, "msg-1", "", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Отмененный контекст пропускает паузу перед возвратом в очередь
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	delivery, ack := testDelivery("msg-1", false)
+	consumer.handle(ctx, thread, delivery)
+	if ack.nacked != 1 || !ack.requeue || messages.routed != 0 {
+		t.Fatalf("nacked %d (requeue %v), routed %d; want requeue without routing", ack.nacked, ack.requeue, messages.routed)
+	}
+}
+
+func TestAMQPConsumerWithoutKeyProcessesEveryDelivery(t *testing.T) {
+	messages := &routingMessageService{}
+	repo := newMemoryIdempotencyRepo()
+	consumer := newTestAMQPConsumer(messages, repo)
+	thread := amqpThread()
+
+	for i := 0; i < 2; i++ {
+		delivery, ack := testDelivery("", false)
+		consumer.handle(context.Background(), thread, delivery)
+		if ack.acked != 1 {
+			t.Fatalf("delivery %d not acked", i+1)
+		}
+	}
+	if messages.routed != 2 || len(repo.records) != 0 {
+		t.Fatalf("routed %d times with %d stored keys, want 2 and none", messages.routed, len(repo.records))
+	}
+}
+
+func TestAMQPConsumerContinuesSenderTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	messages := &routingMessageService{}
+	consumer := newTestAMQPConsumer(messages, newMemoryIdempotencyRepo())
+	thread := amqpThread()
+	thread.AMQPConsumer.Queue = "orders.in"
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	ack := &recordingAcknowledger{}
+	delivery := adapter.NewAMQPDelivery(amqp.Delivery{
+		Acknowledger: ack,
+		ContentType:  "application/json",
+		MessageId:    "msg-1",
+		Headers:      amqp.Table{"traceparent": "00-" + traceID + "-" + parentID + "-01"},
+		Body:         []byte(`{"order":1}`),
+	})
+	consumer.handle(context.Background(), thread, delivery)
+	if ack.acked != 1 {
+		t.Fatalf("acked %d, want 1", ack.acked)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.SpanKind() != trace.SpanKindConsumer || span.Name() != "orders.in receive" {
+		t.Fatalf("span %q of kind %v, want consumer span %q", span.Name(), span.SpanKind(), "orders.in receive")
+	}
+	if parent := span.Parent(); parent.TraceID().String() != traceID || parent.SpanID().String() != parentID || !parent.IsRemote() {
+		t.Fatalf("span parent %s/%s, want remote %s/%s", parent.TraceID(), parent.SpanID(), traceID, parentID)
+	}
+	// Сообщение маршрутизируется внутри span получателя
+	if messages.span.SpanID() != span.SpanContext().SpanID() {
+		t.Fatalf("message routed in span %s, want %s", messages.span.SpanID(), span.SpanContext().SpanID())
+	}
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCredentials неверный логин, пароль, токен или API ключ
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// AuthService аутентифицирует пользователей HTTP API (пароль → JWT, API ключи)
+// и управляет пользователями, их ролями и API ключами
+type AuthService interface {
+	Login(ctx context.Context, username, password string) (*Token, error)
+	AuthenticateToken(ctx context.Context, token string) (*auth.Principal, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error)
+	// ThreadGroup возвращает группу thread для проверки прав в группе
+	ThreadGroup(ctx context.Context, threadID string) (uuid.UUID, error)
+
+	CreateUser(ctx context.Context, username, password string, role models.UserRole) (*models.User, error)
+	GetUsers(ctx context.Context) ([]models.User, error)
+	UpdateUser(ctx context.Context, userID string, update UserUpdate) error
+	DeleteUser(ctx context.Context, userID string) error
+	GetUserThreadGroups(ctx context.Context, userID string) ([]models.UserThreadGroup, error)
+	SetUserThreadGroups(ctx context.Context, userID string, groups []models.UserThreadGroup) error
+
+	CreateAPIKey(ctx context.Context, userID, name string, expiresAt *time.Time) (*NewAPIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, keyID string) error
+
+	// Bootstrap хэширует пароли, сохраненные в открытом виде, и создает
+	// администратора, если пользователей еще нет
+	Bootstrap(ctx context.Context, adminUsername, adminPassword string) error
+}
+
+// Token токен доступа, выданный при входе
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// UserUpdate изменяемые поля пользователя (пустые поля не меняются).
+// Смена пароля и отключение отзывают выданные пользователю токены.
+type UserUpdate struct {
+	Password string          `json:"password,omitempty"`
+	Role     models.UserRole `json:"role,omitempty"`
+	Active   *bool           `json:"active,omitempty"`
+}
+
+// NewAPIKey созданный API ключ. Key возвращается только при создании.
+type NewAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+type authService struct {
+	userRepo        repository.UserRepository
+	apiKeyRepo      repository.APIKeyRepository
+	threadRouteRepo repository.ThreadRouteRepository
+	tokens          *auth.TokenIssuer
+	// dummyHash сравнивается с паролем неизвестного пользователя, чтобы время
+	// ответа не выдавало существование логина
+	dummyHash string
+}
+
+func NewAuthService(
+	userRepo repository.UserRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	threadRouteRepo repository.ThreadRouteRepository,
+	tokens *auth.TokenIssuer,
+) AuthService {
+	dummyHash, _ := auth.HashPassword(uuid.NewString())
+	return &authService{
+		userRepo:        userRepo,
+		apiKeyRepo:      apiKeyRepo,
+		threadRouteRepo: threadRouteRepo,
+		tokens:          tokens,
+		dummyHash:       dummyHash,
+	}
+}
+
+// Login проверяет логин и пароль и выдает токен доступа
+func (s *authService) Login(ctx context.Context, username, password string) (*Token, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		auth.CheckPassword(s.dummyHash, password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !auth.CheckPassword(user.Password, password) || !user.Active {
+		return nil, ErrInvalidCredentials
+	}
+
+	accessToken, expiresAt, err := s.tokens.Issue(user)
+	if err != nil {
+		return nil, err
+	}
+	logger.InfoContext(ctx, "🔑 User logged in", "user", user.Username)
+	return &Token{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// AuthenticateToken проверяет JWT и возвращает текущие права пользователя.
+// Токен, выпущенный до смены пароля или отключения пользователя, не действует.
+func (s *authService) AuthenticateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	userID, version, err := s.tokens.Verify(token)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TokenVersion != version {
+		return nil, ErrInvalidCredentials
+	}
+	return s.principal(ctx, user, nil)
+}
+
+// AuthenticateAPIKey проверяет API ключ и возвращает права его пользователя
+func (s *authService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	if !auth.IsAPIKey(key) {
+		return nil, ErrInvalidCredentials
+	}
+	apiKey, err := s.apiKeyRepo.GetByHash(ctx, auth.HashAPIKey(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return nil, ErrInvalidCredentials
+	}
+	user, err := s.activeUser(ctx, apiKey.User)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apiKeyRepo.MarkUsed(ctx, apiKey.Ref); err != nil {
+		logger.WarnContext(ctx, "⚠️ Failed to update API key usage", "api_key", apiKey.Prefix, "error", err)
+	}
+	return s.principal(ctx, user, &apiKey.Ref)
+}
+
+// activeUser загружает пользователя; удаленный или отключенный пользователь
+// не аутентифицируется
+func (s *authService) activeUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.Active {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// principal загружает роли пользователя в группах threads
+func (s *authService) principal(ctx context.Context, user *models.User, apiKey *uuid.UUID) (*auth.Principal, error) {
+	groups, err := s.userRepo.GetThreadGroups(ctx, user.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user thread groups: %w", err)
+	}
+
+	principal := &auth.Principal{
+		UserID:   user.Ref,
+		Username: user.Username,
+		Role:     user.Role,
+		APIKey:   apiKey,
+	}
+	if len(groups) > 0 {
+		principal.GroupRoles = make(map[uuid.UUID]models.UserRole, len(groups))
+		for _, group := range groups {
+			principal.GroupRoles[group.ThreadGroup] = group.Role
+		}
+	}
+	return principal, nil
+}
+
+func (s *authService) ThreadGroup(ctx context.Context, threadID string) (uuid.UUID, error) {
+	id, err := parseUUID(threadID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	thread, _, err := s.threadRouteRepo.GetThreadWithGroup(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, errors.New("thread not found")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get thread: %w", err)
+	}
+	return thread.Group, nil
+}
+
+func (s *authService) CreateUser(ctx context.Context, username, password string, role models.UserRole) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if role == "" {
+		role = models.RoleReadOnly
+	}
+	if !auth.ValidRole(role) {
+		return nil, fmt.Errorf("unknown role: %s", role)
+	}
+	if err := auth.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Username: username, Password: hash, Role: role}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	logger.InfoContext(ctx, "👤 User created", "user", user.Username, "role", user.Role)
+	return user, nil
+}
+
+func (s *authService) GetUsers(ctx context.Context) ([]models.User, error) {
+	return s.userRepo.GetAll(ctx)
+}
+
+func (s *authService) UpdateUser(ctx context.Context, userID string, update UserUpdate) error {
+	id, err := parseUUID(userID)
+	if err != nil {
+		return err
+	}
+	if update.Role != "" {
+		if !auth.ValidRole(update.Role) {
+			return fmt.Errorf("unknown role: %s", update.Role)
+		}
+		if err := s.userRepo.UpdateRole(ctx, id, update.Role); err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+	}
+	if update.Password != "" {
+		if err := auth.ValidatePassword(update.Password); err != nil {
+			return err
+		}
+		hash, err := auth.HashPassword(update.Password)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.UpdatePassword(ctx, id, hash); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+	}
+	if update.Active != nil {
+		if err := s.userRepo.UpdateActive(ctx, id, *update.Active); err != nil {
+			return fmt.Errorf("failed to update active: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *authService) DeleteUser(ctx context.Context, userID string) error {
+	id, err := parseUUID(userID)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.Delete(ctx, id)
+}
+
+func (s *authService) GetUserThreadGroups(ctx context.Context, userID string) ([]models.UserThreadGroup, error) {
+	id, err := parseUUID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetThreadGroups(ctx, id)
+}
+
+func (s *authService) SetUserThreadGroups(ctx context.Context, userID string, groups []models.UserThreadGroup) error {
+	id, err := parseUUID(userID)
+	if err != nil {
+		return err
+	}
+	for i := range groups {
+		if !auth.ValidRole(groups[i].Role) {
+			return fmt.Errorf("unknown role: %s", groups[i].Role)
+		}
+		groups[i].User = id
+	}
+	if err := s.userRepo.SetThreadGroups(ctx, id, groups); err != nil {
+		return fmt.Errorf("failed to set thread groups: %w", err)
+	}
+	return nil
+}
+
+// CreateAPIKey создает API ключ пользователя. Ключ возвращается один раз,
+// в БД сохраняется только его хэш.
+func (s *authService) CreateAPIKey(ctx context.Context, userID, name string, expiresAt *time.Time) (*NewAPIKey, error) {
+	id, err := parseUUID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("API key name is required")
+	}
+	if _, err := s.userRepo.GetByID(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	apiKey := models.APIKey{User: id, Name: name, Prefix: prefix, KeyHash: auth.HashAPIKey(key), ExpiresAt: expiresAt}
+	if err := s.apiKeyRepo.Create(ctx, &apiKey); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	logger.InfoContext(ctx, "🔑 API key created", "api_key", apiKey.Prefix, "name", apiKey.Name, "user_id", id)
+	return &NewAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+func (s *authService) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	id, err := parseUUID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.apiKeyRepo.GetByUser(ctx, id)
+}
+
+func (s *authService) DeleteAPIKey(ctx context.Context, keyID string) error {
+	id, err := parseUUID(keyID)
+	if err != nil {
+		return err
+	}
+	return s.apiKeyRepo.Delete(ctx, id)
+}
+
+func (s *authService) Bootstrap(ctx context.Context, adminUsername, adminPassword string) error {
+	users, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get users: %w", err)
+	}
+
+	for _, user := range users {
+		if auth.IsPasswordHash(user.Password) {
+			continue
+		}
+		hash, err := auth.HashPassword(user.Password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password of %s: %w", user.Username, err)
+		}
+		if auth.ValidatePassword(user.Password) != nil {
+			logger.WarnContext(ctx, "⚠️ User has a weak password, please change it", "user", user.Username)
+		}
+		if err := s.userRepo.UpdatePassword(ctx, user.Ref, hash); err != nil {
+			return fmt.Errorf("failed to update password of %s: %w", user.Username, err)
+		}
+		logger.InfoContext(ctx, "🔐 Plain text password replaced with bcrypt hash", "user", user.Username)
+	}
+
+	if len(users) > 0 || adminUsername == "" {
+		if len(users) == 0 {
+			logger.WarnContext(ctx, "⚠️ No API users: set ESB_ADMIN_USER and ESB_ADMIN_PASSWORD to create an administrator")
+		}
+		return nil
+	}
+	if _, err := s.CreateUser(ctx, adminUsername, adminPassword, models.RoleAdmin); err != nil {
+		return fmt.Errorf("failed to create administrator: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// memoryUserRepo хранит пользователей в памяти и, как БД, увеличивает номер
+// токенов при смене пароля и отключении
+type memoryUserRepo struct {
+	repository.UserRepository
+	users map[uuid.UUID]*models.User
+}
+
+func (r *memoryUserRepo) GetByID(_ context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memoryUserRepo) GetByUsername(_ context.Context, username string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memoryUserRepo) UpdatePassword(_ context.Context, id uuid.UUID, passwordHash string) error {
+	r.users[id].Password = passwordHash
+	r.users[id].TokenVersion++
+	return nil
+}
+
+func (r *memoryUserRepo) UpdateActive(_ context.Context, id uuid.UUID, active bool) error {
+	if r.users[id].Active && !active {
+		r.users[id].TokenVersion++
+	}
+	r.users[id].Active = active
+	return nil
+}
+
+func (r *memoryUserRepo) GetThreadGroups(context.Context, uuid.UUID) ([]models.UserThreadGroup, error) {
+	return nil, nil
+}
+
+type memoryAPIKeyRepo struct {
+	repository.APIKeyRepository
+	keys map[string]*models.APIKey
+}
+
+func (r *memoryAPIKeyRepo) GetByHash(_ context.Context, keyHash string) (*models.APIKey, error) {
+	key, ok := r.keys[keyHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func (r *memoryAPIKeyRepo) MarkUsed(context.Context, uuid.UUID) error {
+	return nil
+}
+
+func TestTokensRevokedOnPasswordChangeAndDeactivation(t *testing.T) {
+	ctx := context.Background()
+	hash, err := auth.HashPassword("first-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Ref: uuid.New(), Username: "operator", Password: hash, Role: models.RoleOperator, Active: true, TokenVersion: 1}
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUserRepo{users: map[uuid.UUID]*models.User{user.Ref: user}}
+	apiKeys := &memoryAPIKeyRepo{keys: map[string]*models.APIKey{
+		auth.HashAPIKey(key): {Ref: uuid.New(), User: user.Ref, Prefix: prefix},
+	}}
+	svc := NewAuthService(users, apiKeys, nil, auth.NewTokenIssuer([]byte("secret"), time.Hour))
+
+	login := func(password string) string {
+		t.Helper()
+		token, err := svc.Login(ctx, "operator", password)
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		return token.AccessToken
+	}
+	checkToken := func(token string, wantValid bool) {
+		t.Helper()
+		_, err := svc.AuthenticateToken(ctx, token)
+		if wantValid && err != nil {
+			t.Fatalf("AuthenticateToken: %v", err)
+		}
+		if !wantValid && !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("AuthenticateToken error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	first := login("first-password")
+	checkToken(first, true)
+
+	// Смена пароля отзывает выданный токен, новый токен действует
+	if err := svc.UpdateUser(ctx, user.Ref.String(), UserUpdate{Password: "second-password"}); err != nil {
+		t.Fatalf("UpdateUser password: %v", err)
+	}
+	checkToken(first, false)
+	second := login("second-password")
+	checkToken(second, true)
+
+	// Отключенный пользователь не входит, его токены и API ключи не действуют
+	inactive := false
+	if err := svc.UpdateUser(ctx, user.Ref.String(), UserUpdate{Active: &inactive}); err != nil {
+		t.Fatalf("UpdateUser active: %v", err)
+	}
+	checkToken(second, false)
+	if _, err := svc.Login(ctx, "operator", "second-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login of inactive user error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := svc.AuthenticateAPIKey(ctx, key); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("AuthenticateAPIKey of inactive user error = %v, want ErrInvalidCredentials", err)
+	}
+
+	// После включения токены, выданные до отключения, не возвращаются
+	active := true
+	if err := svc.UpdateUser(ctx, user.Ref.String(), UserUpdate{Active: &active}); err != nil {
+		t.Fatalf("UpdateUser active: %v", err)
+	}
+	checkToken(second, false)
+	checkToken(login("second-password"), true)
+	if _, err := svc.AuthenticateAPIKey(ctx, key); err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+}
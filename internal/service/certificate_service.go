@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"time"
+
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+
+	"github.com/google/uuid"
+)
+
+// CertificateExpiryWarning срок до окончания действия сертификата, с которого он считается истекающим
+const CertificateExpiryWarning = 30 * 24 * time.Hour
+
+// Состояния сертификата аутентификации
+const (
+	CertificateOK       = "ok"
+	CertificateExpiring = "expiring"
+	CertificateExpired  = "expired"
+	CertificateInvalid  = "invalid"
+)
+
+// CertificateStatus срок действия сертификата аутентификации подключения
+type CertificateStatus struct {
+	Auth     string                    `json:"auth"`
+	System   uuid.UUID                 `json:"system"`
+	Type     models.AuthenticationType `json:"type"`
+	Subject  string                    `json:"subject,omitempty"`
+	NotAfter time.Time                 `json:"not_after,omitempty"`
+	DaysLeft int                       `json:"days_left"`
+	Status   string                    `json:"status"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+// CertificateService проверяет сроки действия сертификатов подключений
+type CertificateService interface {
+	Certificates(ctx context.Context) ([]CertificateStatus, error)
+}
+
+type certificateService struct {
+	connectionRepo repository.ConnectionRepository
+	secrets        *secrets.Resolver
+}
+
+// NewCertificateService создает сервис сертификатов
+func NewCertificateService(connectionRepo repository.ConnectionRepository, secretResolver *secrets.Resolver) CertificateService {
+	return &certificateService{connectionRepo: connectionRepo, secrets: secretResolver}
+}
+
+// Certificates возвращает состояние сертификатов аутентификаций (Certificate и WS-Security)
+func (s *certificateService) Certificates(ctx context.Context) ([]CertificateStatus, error) {
+	auths, err := s.connectionRepo.GetConnectionAuthsWithCertificates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificates: %w", err)
+	}
+
+	now := time.Now()
+	statuses := make([]CertificateStatus, 0, len(auths))
+	for i := range auths {
+		status := CertificateStatus{Auth: auths[i].Name, System: auths[i].System, Type: auths[i].Type}
+		cert, err := s.certificate(ctx, &auths[i])
+		if err != nil {
+			status.Status = CertificateInvalid
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+
+		status.Subject = cert.Subject.String()
+		status.NotAfter = cert.NotAfter
+		left := cert.NotAfter.Sub(now)
+		status.DaysLeft = int(math.Floor(left.Hours() / 24))
+		switch {
+		case left <= 0:
+			status.Status = CertificateExpired
+		case left <= CertificateExpiryWarning:
+			status.Status = CertificateExpiring
+		default:
+			status.Status = CertificateOK
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// certificate разбирает первый сертификат PEM аутентификации
+func (s *certificateService) certificate(ctx context.Context, auth *models.ConnectionAuthentication) (*x509.Certificate, error) {
+	certPEM := auth.Certificate
+	if s.secrets != nil {
+		value, err := s.secrets.Resolve(ctx, certPEM)
+		if err != nil {
+			return nil, err
+		}
+		certPEM = value
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+)
+
+type certificateAuthRepo struct {
+	repository.ConnectionRepository
+	auths []models.ConnectionAuthentication
+}
+
+func (r certificateAuthRepo) GetConnectionAuthsWithCertificates(context.Context) ([]models.ConnectionAuthentication, error) {
+	return r.auths, nil
+}
+
+// testCertificatePEM самоподписанный сертификат, действующий до notAfter
+func testCertificatePEM(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "esb-test"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCertificatesExpiry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		certificate  string
+		wantStatus   string
+		wantDaysLeft int
+	}{
+		{"valid", testCertificatePEM(t, now.Add(90*24*time.Hour+time.Hour)), CertificateOK, 90},
+		{"expiring within warning period", testCertificatePEM(t, now.Add(10*24*time.Hour+time.Hour)), CertificateExpiring, 10},
+		{"expired", testCertificatePEM(t, now.Add(-2*24*time.Hour+time.Hour)), CertificateExpired, -2},
+		{"not a certificate", "not a certificate", CertificateInvalid, 0},
+	}
+
+	repo := certificateAuthRepo{}
+	for _, tt := range tests {
+		repo.auths = append(repo.auths, models.ConnectionAuthentication{Name: tt.name, Type: models.AuthCertificate, Certificate: tt.certificate})
+	}
+	statuses, err := NewCertificateService(repo, nil).Certificates(context.Background())
+	if err != nil {
+		t.Fatalf("Certificates: %v", err)
+	}
+	if len(statuses) != len(tests) {
+		t.Fatalf("got %d statuses, want %d", len(statuses), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := statuses[i]
+			if status.Auth != tt.name || status.Status != tt.wantStatus || status.DaysLeft != tt.wantDaysLeft {
+				t.Fatalf("status = %s %s, %d days left; want %s, %d", status.Auth, status.Status, status.DaysLeft, tt.wantStatus, tt.wantDaysLeft)
+			}
+			if tt.wantStatus == CertificateInvalid {
+				if status.Error == "" {
+					t.Error("invalid certificate has no error")
+				}
+				return
+			}
+			if status.Subject != "CN=esb-test" || status.NotAfter.IsZero() {
+				t.Errorf("subject %q, not after %v", status.Subject, status.NotAfter)
+			}
+		})
+	}
+}
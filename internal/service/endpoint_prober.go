@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-esb/internal/adapter"
+	"go-esb/internal/balancer"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+
+	"github.com/google/uuid"
+)
+
+// probeTick период, с которым проверяется, каким подключениям пора на проверку
+const probeTick = time.Second
+
+// Порты по умолчанию для проверки установки TCP соединения
+var defaultPorts = map[string]string{"http": "80", "https": "443", "amqp": "5672", "amqps": "5671"}
+
+// EndpointProber периодически проверяет подключения с настройкой health_check
+// и выводит недоступные из ротации (balancer.Registry)
+type EndpointProber struct {
+	connectionRepo repository.ConnectionRepository
+	systemRepo     repository.SystemRepository
+	secrets        *secrets.Resolver
+	endpoints      *balancer.Registry
+	clients        *adapter.ClientCache
+
+	mu      sync.Mutex
+	next    map[uuid.UUID]time.Time
+	running map[uuid.UUID]bool
+}
+
+// NewEndpointProber создает проверку подключений
+func NewEndpointProber(
+	connectionRepo repository.ConnectionRepository,
+	systemRepo repository.SystemRepository,
+	secretResolver *secrets.Resolver,
+	endpoints *balancer.Registry,
+) *EndpointProber {
+	return &EndpointProber{
+		connectionRepo: connectionRepo,
+		systemRepo:     systemRepo,
+		secrets:        secretResolver,
+		endpoints:      endpoints,
+		clients:        adapter.NewClientCache(),
+		next:           make(map[uuid.UUID]time.Time),
+		running:        make(map[uuid.UUID]bool),
+	}
+}
+
+// Run проверяет подключения до отмены контекста. Изменения health_check
+// применяются без перезапуска.
+func (p *EndpointProber) Run(ctx context.Context) {
+	ticker := time.NewTicker(probeTick)
+	defer ticker.Stop()
+	for {
+		p.probeDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeDue запускает проверки подключений, у которых истек interval_ms
+func (p *EndpointProber) probeDue(ctx context.Context) {
+	connections, err := p.connectionRepo.GetConnectionSettingsWithHealthCheck(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "⚠️ Failed to get connections for health check", "error", err)
+		return
+	}
+
+	now := time.Now()
+	checked := make(map[uuid.UUID]bool, len(connections))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range connections {
+		connection := connections[i]
+		checked[connection.Ref] = true
+		if p.running[connection.Ref] || now.Before(p.next[connection.Ref]) {
+			continue
+		}
+		p.running[connection.Ref] = true
+		p.next[connection.Ref] = now.Add(time.Duration(connection.HealthCheck.IntervalMs) * time.Millisecond)
+		go p.probeConnection(ctx, &connection)
+	}
+	for ref := range p.next {
+		if !checked[ref] {
+			delete(p.next, ref)
+		}
+	}
+	p.endpoints.Retain(checked)
+}
+
+func (p *EndpointProber) probeConnection(ctx context.Context, connection *models.ConnectionSetting) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.running, connection.Ref)
+		p.mu.Unlock()
+	}()
+
+	systemName := connection.System.String()
+	if system, err := p.systemRepo.GetByID(ctx, connection.System); err == nil {
+		systemName = system.Name
+	}
+
+	err := p.probe(ctx, connection)
+	if ctx.Err() != nil {
+		return
+	}
+	if !p.endpoints.Report(connection, systemName, err) {
+		return
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "🔴 Connection is unhealthy, taken out of rotation",
+			"system", systemName, "connection", connection.Name, "error", err)
+	} else {
+		logger.InfoContext(ctx, "🟢 Connection is healthy, back in rotation",
+			"system", systemName, "connection", connection.Name)
+	}
+}
+
+// probe выполняет GET запрос проверки или, если path не задан, устанавливает
+// TCP соединение с хостом подключения
+func (p *EndpointProber) probe(ctx context.Context, connection *models.ConnectionSetting) error {
+	timeout := balancer.DefaultCheckTimeout
+	if connection.HealthCheck.TimeoutMs > 0 {
+		timeout = time.Duration(connection.HealthCheck.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	base, err := connectionURL(connection)
+	if err != nil {
+		return err
+	}
+	if connection.HealthCheck.Path == "" {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", base.Host)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		return conn.Close()
+	}
+
+	target := connection.HealthCheck.Path
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(target, "/")
+		target = base.String()
+	}
+	return p.probeHTTP(ctx, connection, target)
+}
+
+func (p *EndpointProber) probeHTTP(ctx context.Context, connection *models.ConnectionSetting, target string) error {
+	// Для аутентификации Certificate проверка тоже предъявляет сертификат клиента
+	var auth *models.ConnectionAuthentication
+	if connection.AuthRef != uuid.Nil {
+		stored, err := p.connectionRepo.GetConnectionAuth(ctx, connection.AuthRef)
+		if err != nil {
+			return fmt.Errorf("failed to get authentication: %w", err)
+		}
+		if auth, err = p.secrets.ResolveAuth(ctx, stored); err != nil {
+			return fmt.Errorf("failed to resolve credentials: %w", err)
+		}
+	}
+	client, err := p.clients.Get(connection, auth)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	expected := connection.HealthCheck.ExpectedStatus
+	if (expected > 0 && resp.StatusCode != expected) || (expected == 0 && resp.StatusCode >= http.StatusBadRequest) {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// connectionURL разбирает path подключения (URL или host:port) и дополняет
+// хост портом подключения или портом схемы по умолчанию
+func connectionURL(connection *models.ConnectionSetting) (*url.URL, error) {
+	path := connection.Path
+	if !strings.Contains(path, "://") {
+		path = "tcp://" + path
+	}
+	parsed, err := url.Parse(path)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid connection path %q", connection.Path)
+	}
+	if parsed.Port() == "" {
+		port := defaultPorts[parsed.Scheme]
+		if connection.Port > 0 {
+			port = strconv.Itoa(connection.Port)
+		}
+		if port == "" {
+			return nil, fmt.Errorf("no port in connection path %q", connection.Path)
+		}
+		parsed.Host = net.JoinHostPort(parsed.Hostname(), port)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"go-esb/internal/balancer"
+	"go-esb/internal/models"
+	"go-esb/internal/secrets"
+)
+
+func newTestProber(repos *testRepos, endpoints *balancer.Registry) *EndpointProber {
+	return NewEndpointProber(testConnectionRepo{testRepos: repos}, testSystemRepo{testRepos: repos}, secrets.NewResolver(0), endpoints)
+}
+
+func TestEndpointProberMarksConnection(t *testing.T) {
+	// Система недоступна две проверки, затем восстанавливается
+	ps, server := newPathServer(map[string][]int{"/health": {503, 503, 200, 200}})
+	defer server.Close()
+	repos := newTestRepos(server)
+	connection := &repos.connections[0]
+	connection.HealthCheck = models.HealthCheckSettings{IntervalMs: 1000, Path: "/health"}
+	endpoints := balancer.NewRegistry()
+	prober := newTestProber(repos, endpoints)
+
+	healthy := func() bool {
+		statuses := endpoints.Statuses()
+		return len(statuses) == 1 && statuses[0].Healthy
+	}
+	for i, want := range []bool{true, false, false, true} {
+		prober.probeConnection(context.Background(), connection)
+		if healthy() != want {
+			t.Fatalf("check %d: healthy %v, want %v (%+v)", i+1, healthy(), want, endpoints.Statuses())
+		}
+	}
+	if ps.count("/health") != 4 {
+		t.Fatalf("health requests %d, want 4", ps.count("/health"))
+	}
+}
+
+func TestEndpointProberProbe(t *testing.T) {
+	_, server := newPathServer(map[string][]int{"/health": {200}, "/busy": {503}, "/created": {201}})
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		check   models.HealthCheckSettings
+		wantErr string
+	}{
+		{name: "relative path", check: models.HealthCheckSettings{Path: "/health"}},
+		{name: "absolute path", check: models.HealthCheckSettings{Path: server.URL + "/health"}},
+		{name: "error status", check: models.HealthCheckSettings{Path: "/busy"}, wantErr: "status 503"},
+		{name: "expected status", check: models.HealthCheckSettings{Path: "/created", ExpectedStatus: 200}, wantErr: "status 201"},
+		{name: "tcp connect", check: models.HealthCheckSettings{}},
+		{name: "tcp connect refused", path: "tcp://" + closedAddr, wantErr: "failed to connect"},
+		{name: "no port", path: "sap.internal", wantErr: "no port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := newTestRepos(server)
+			connection := &repos.connections[0]
+			connection.HealthCheck = tt.check
+			if tt.path != "" {
+				connection.Path = tt.path
+			}
+			err := newTestProber(repos, balancer.NewRegistry()).probe(context.Background(), connection)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("probe: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("probe = %v, want error %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-esb/internal/metrics"
+	"go-esb/internal/models"
+	"go-esb/internal/placeholder"
+	"go-esb/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// idempotencyLease время, на которое ключ занимается обработкой сообщения.
+// Если ESB остановился во время обработки, повтор будет обработан после его истечения.
+const idempotencyLease = 5 * time.Minute
+
+// idempotencyCleanupInterval период удаления истекших ключей
+const idempotencyCleanupInterval = 10 * time.Minute
+
+// ErrDuplicateInProgress возвращается повтору сообщения, которое еще обрабатывается
+var ErrDuplicateInProgress = errors.New("message with the same idempotency key is being processed")
+
+// ErrIdempotencyKeyReused возвращается сообщению, ключ которого уже использован
+// сообщением с другим телом
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different message")
+
+// IdempotencyService подавляет повторно полученные входящие сообщения: сообщение
+// с уже обработанным ключом получает сохраненный результат без повторной обработки.
+// Не зависит от транспорта: ключ извлекается из заголовков и тела сообщения.
+type IdempotencyService interface {
+	// Key возвращает ключ сообщения или пустую строку, если ключ не настроен или не передан
+	Key(settings models.IdempotencySettings, headers map[string]string, body []byte) (string, error)
+	// ThreadSettings возвращает настройки идемпотентности thread
+	ThreadSettings(ctx context.Context, threadID uuid.UUID) (models.IdempotencySettings, error)
+	// ProcessSettings возвращает настройки идемпотентности бизнес-процесса или webhook
+	ProcessSettings(name string) models.IdempotencySettings
+	// Execute выполняет обработку один раз для ключа scope. Повтор с тем же телом
+	// body получает сохраненный результат (replayed = true), с другим телом —
+	// ErrIdempotencyKeyReused. Результат с ошибкой, кодом 5xx или 429 не
+	// сохраняется: повтор обрабатывается заново.
+	Execute(ctx context.Context, scope, key string, body []byte, ttl time.Duration, process func() (*models.IdempotentResult, error)) (result *models.IdempotentResult, replayed bool, err error)
+	// Run удаляет истекшие ключи до отмены контекста
+	Run(ctx context.Context)
+}
+
+type idempotencyService struct {
+	repo            repository.IdempotencyRepository
+	threadRouteRepo repository.ThreadRouteRepository
+	ttl             time.Duration
+	processes       map[string]models.IdempotencySettings
+}
+
+// NewIdempotencyService создает сервис идемпотентности. ttl — время хранения
+// результатов по умолчанию, processes — настройки бизнес-процессов и webhook
+// (без настройки ключ берется из заголовка Idempotency-Key).
+func NewIdempotencyService(
+	repo repository.IdempotencyRepository,
+	threadRouteRepo repository.ThreadRouteRepository,
+	ttl time.Duration,
+	processes map[string]models.IdempotencySettings,
+) IdempotencyService {
+	return &idempotencyService{
+		repo:            repo,
+		threadRouteRepo: threadRouteRepo,
+		ttl:             ttl,
+		processes:       processes,
+	}
+}
+
+func (s *idempotencyService) Key(settings models.IdempotencySettings, headers map[string]string, body []byte) (string, error) {
+	switch settings.Source {
+	case "":
+		return "", nil
+	case models.IdempotencyHeader:
+		name := settings.Header
+		if name == "" {
+			name = models.DefaultIdempotencyHeader
+		}
+		for key, value := range headers {
+			if strings.EqualFold(key, name) {
+				return strings.TrimSpace(value), nil
+			}
+		}
+		return "", nil
+	case models.IdempotencyJSONPath:
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return "", fmt.Errorf("failed to parse message for idempotency key: %w", err)
+		}
+		value, err := placeholder.Lookup(data, settings.JSONPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		return placeholder.Format(value), nil
+	case models.IdempotencyHash:
+		sum := sha256.Sum256(body)
+		return hex.EncodeToString(sum[:]), nil
+	default:
+		return "", fmt.Errorf("unknown idempotency key source %q", settings.Source)
+	}
+}
+
+func (s *idempotencyService) ThreadSettings(ctx context.Context, threadID uuid.UUID) (models.IdempotencySettings, error) {
+	thread, _, err := s.threadRouteRepo.GetThreadWithGroup(ctx, threadID)
+	if err != nil {
+		return models.IdempotencySettings{}, fmt.Errorf("failed to get thread: %w", err)
+	}
+	return thread.Idempotency, nil
+}
+
+func (s *idempotencyService) ProcessSettings(name string) models.IdempotencySettings {
+	if settings, ok := s.processes[name]; ok {
+		return settings
+	}
+	return models.IdempotencySettings{Source: models.IdempotencyHeader}
+}
+
+func (s *idempotencyService) Execute(
+	ctx context.Context,
+	scope, key string,
+	body []byte,
+	ttl time.Duration,
+	process func() (*models.IdempotentResult, error),
+) (*models.IdempotentResult, bool, error) {
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	kind, _, _ := strings.Cut(scope, ":")
+	sum := sha256.Sum256(body)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	record, reserved, err := s.repo.Reserve(ctx, scope, key, fingerprint, time.Now().Add(idempotencyLease))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if !reserved {
+		// Ключи, сохраненные до появления отпечатка, не проверяются
+		if record.Fingerprint != "" && record.Fingerprint != fingerprint {
+			metrics.IdempotencyDuplicates.WithLabelValues(kind, "mismatch").Inc()
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		if record.Status == models.IdempotencyCompleted {
+			metrics.IdempotencyDuplicates.WithLabelValues(kind, "replayed").Inc()
+			logger.InfoContext(ctx, "🔁 Duplicate message, returning stored result", "idempotency_key", key)
+			return &record.IdempotentResult, true, nil
+		}
+		metrics.IdempotencyDuplicates.WithLabelValues(kind, "in_progress").Inc()
+		return nil, false, ErrDuplicateInProgress
+	}
+
+	result, err := process()
+	// Ключ освобождается и сохраняется и после отмены запроса вызывающим
+	ctx = context.WithoutCancel(ctx)
+	if err != nil || result == nil || result.StatusCode >= http.StatusInternalServerError || result.StatusCode == http.StatusTooManyRequests {
+		if releaseErr := s.repo.Release(ctx, scope, key); releaseErr != nil {
+			logger.WarnContext(ctx, "⚠️ Failed to release idempotency key", "idempotency_key", key, "error", releaseErr)
+		}
+		return result, false, err
+	}
+	if err := s.repo.Complete(ctx, scope, key, result, time.Now().Add(ttl)); err != nil {
+		logger.WarnContext(ctx, "⚠️ Failed to store idempotent result", "idempotency_key", key, "error", err)
+	}
+	return result, false, nil
+}
+
+func (s *idempotencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deleted, err := s.repo.DeleteExpired(ctx)
+		if err != nil {
+			logger.WarnContext(ctx, "⚠️ Failed to delete expired idempotency keys", "error", err)
+			continue
+		}
+		if deleted > 0 {
+			logger.DebugContext(ctx, "🧹 Deleted expired idempotency keys", "count", deleted)
+		}
+	}
+}
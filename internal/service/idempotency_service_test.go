@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+)
+
+// memoryIdempotencyRepo хранит ключи в памяти с той же семантикой, что и
+// idempotency_keys: истекший ключ можно занять заново
+type memoryIdempotencyRepo struct {
+	mu         sync.Mutex
+	records    map[string]*models.IdempotencyRecord
+	reserveErr error
+	released   int
+}
+
+func newMemoryIdempotencyRepo() *memoryIdempotencyRepo {
+	return &memoryIdempotencyRepo{records: make(map[string]*models.IdempotencyRecord)}
+}
+
+func (r *memoryIdempotencyRepo) Reserve(_ context.Context, scope, key, fingerprint string, expiresAt time.Time) (*models.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reserveErr != nil {
+		return nil, false, r.reserveErr
+	}
+	if record, ok := r.records[scope+"|"+key]; ok && record.ExpiresAt.After(time.Now()) {
+		copied := *record
+		return &copied, false, nil
+	}
+	record := &models.IdempotencyRecord{Scope: scope, Key: key, Status: models.IdempotencyProcessing, CreatedAt: time.Now(), ExpiresAt: expiresAt, Fingerprint: fingerprint}
+	r.records[scope+"|"+key] = record
+	copied := *record
+	return &copied, true, nil
+}
+
+func (r *memoryIdempotencyRepo) Complete(_ context.Context, scope, key string, result *models.IdempotentResult, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[scope+"|"+key]
+	if !ok {
+		return errors.New("key is not reserved")
+	}
+	record.Status, record.ExpiresAt, record.IdempotentResult = models.IdempotencyCompleted, expiresAt, *result
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) Release(_ context.Context, scope, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.released++
+	delete(r.records, scope+"|"+key)
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) DeleteExpired(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, record := range r.records {
+		if !record.ExpiresAt.After(time.Now()) {
+			delete(r.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryIdempotencyRepo) expire(scope, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[scope+"|"+key].ExpiresAt = time.Now().Add(-time.Second)
+}
+
+func TestIdempotencyExecuteStoresOrReleases(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       *models.IdempotentResult
+		err          error
+		wantReplayed bool
+		wantReleased int
+	}{
+		{name: "success stored", result: &models.IdempotentResult{StatusCode: http.StatusOK, Body: []byte(`{"ok":true}`)}, wantReplayed: true},
+		{name: "client error stored", result: &models.IdempotentResult{StatusCode: http.StatusBadRequest}, wantReplayed: true},
+		{name: "server error released", result: &models.IdempotentResult{StatusCode: http.StatusServiceUnavailable}, wantReleased: 1},
+		{name: "too many requests released", result: &models.IdempotentResult{StatusCode: http.StatusTooManyRequests}, wantReleased: 1},
+		{name: "processing error released", err: errors.New("route failed"), wantReleased: 1},
+		{name: "no result released", wantReleased: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryIdempotencyRepo()
+			svc := NewIdempotencyService(repo, nil, time.Hour, nil)
+			ctx := context.Background()
+
+			calls := 0
+			process := func() (*models.IdempotentResult, error) {
+				calls++
+				return tt.result, tt.err
+			}
+
+			_, replayed, err := svc.Execute(ctx, "thread:t1", "key-1", nil, 0, process)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("first Execute error = %v, want %v", err, tt.err)
+			}
+			if replayed {
+				t.Fatal("first Execute replayed")
+			}
+			if repo.released != tt.wantReleased {
+				t.Fatalf("released %d keys, want %d", repo.released, tt.wantReleased)
+			}
+
+			result, replayed, err := svc.Execute(ctx, "thread:t1", "key-1", nil, 0, process)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("second Execute error = %v, want %v", err, tt.err)
+			}
+			if replayed != tt.wantReplayed {
+				t.Fatalf("second Execute replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			wantCalls := 2
+			if tt.wantReplayed {
+				wantCalls = 1
+				if result.StatusCode != tt.result.StatusCode || string(result.Body) != string(tt.result.Body) {
+					t.Fatalf("replayed result = %d %s, want %d %s", result.StatusCode, result.Body, tt.result.StatusCode, tt.result.Body)
+				}
+			}
+			if calls != wantCalls {
+				t.Fatalf("message processed %d times, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyExecuteDuplicateInProgress(t *testing.T) {
+	repo := newMemoryIdempotencyRepo()
+	svc := NewIdempotencyService(repo, nil, time.Hour, nil)
+	ctx := context.Background()
+
+	_, _, err := svc.Execute(ctx, "thread:t1", "key-1", nil, 0, func() (*models.IdempotentResult, error) {
+		_, replayed, err := svc.Execute(ctx, "thread:t1", "key-1", nil, 0, func() (*models.IdempotentResult, error) {
+			t.Fatal("duplicate processed while the first message is in progress")
+			return nil, nil
+		})
+		if !errors.Is(err, ErrDuplicateInProgress) || replayed {
+			t.Fatalf("duplicate Execute = replayed %v, %v; want ErrDuplicateInProgress", replayed, err)
+		}
+		return &models.IdempotentResult{StatusCode: http.StatusOK}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdempotencyExecuteScopesAndExpiry(t *testing.T) {
+	repo := newMemoryIdempotencyRepo()
+	svc := NewIdempotencyService(repo, nil, time.Hour, nil)
+	ctx := context.Background()
+	ok := func() (*models.IdempotentResult, error) {
+		return &models.IdempotentResult{StatusCode: http.StatusOK}, nil
+	}
+
+	if _, _, err := svc.Execute(ctx, "thread:t1", "key-1", nil, 0, ok); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		scope        string
+		key          string
+		expire       bool
+		wantReplayed bool
+	}{
+		{name: "same scope and key", scope: "thread:t1", key: "key-1", wantReplayed: true},
+		{name: "other key", scope: "thread:t1", key: "key-2"},
+		{name: "other scope", scope: "thread:t2", key: "key-1"},
+		{name: "expired key", scope: "thread:t1", key: "key-1", expire: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expire {
+				repo.expire(tt.scope, tt.key)
+			}
+			_, replayed, err := svc.Execute(ctx, tt.scope, tt.key, nil, 0, ok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replayed != tt.wantReplayed {
+				t.Fatalf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+		})
+	}
+}
+
+func TestIdempotencyExecuteRejectsReusedKey(t *testing.T) {
+	repo := newMemoryIdempotencyRepo()
+	svc := NewIdempotencyService(repo, nil, time.Hour, nil)
+	ctx := context.Background()
+	calls := 0
+	ok := func() (*models.IdempotentResult, error) {
+		calls++
+		return &models.IdempotentResult{StatusCode: http.StatusOK}, nil
+	}
+
+	if _, _, err := svc.Execute(ctx, "thread:t1", "key-1", []byte(`{"order":1}`), 0, ok); err != nil {
+		t.Fatal(err)
+	}
+	if _, replayed, err := svc.Execute(ctx, "thread:t1", "key-1", []byte(`{"order":1}`), 0, ok); err != nil || !replayed {
+		t.Fatalf("same body: replayed %v, %v; want replay", replayed, err)
+	}
+	if _, replayed, err := svc.Execute(ctx, "thread:t1", "key-1", []byte(`{"order":2}`), 0, ok); !errors.Is(err, ErrIdempotencyKeyReused) || replayed {
+		t.Fatalf("other body: replayed %v, %v; want ErrIdempotencyKeyReused", replayed, err)
+	}
+
+	// Ключ, сохраненный без отпечатка, повторяется для любого тела
+	repo.records["thread:t1|key-1"].Fingerprint = ""
+	if _, replayed, err := svc.Execute(ctx, "thread:t1", "key-1", []byte(`{"order":2}`), 0, ok); err != nil || !replayed {
+		t.Fatalf("key without fingerprint: replayed %v, %v; want replay", replayed, err)
+	}
+	if calls != 1 {
+		t.Fatalf("message processed %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyExecuteReserveError(t *testing.T) {
+	repo := newMemoryIdempotencyRepo()
+	repo.reserveErr = errors.New("database is down")
+	svc := NewIdempotencyService(repo, nil, time.Hour, nil)
+
+	_, _, err := svc.Execute(context.Background(), "thread:t1", "key-1", nil, 0, func() (*models.IdempotentResult, error) {
+		t.Fatal("message processed without a reserved key")
+		return nil, nil
+	})
+	if !errors.Is(err, repo.reserveErr) {
+		t.Fatalf("Execute error = %v, want reserve error", err)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	svc := NewIdempotencyService(newMemoryIdempotencyRepo(), nil, time.Hour, nil)
+	body := []byte(`{"id":"evt_1","data":{"object":{"id":"pi_1"}}}`)
+	headers := map[string]string{"idempotency-key": " key-1 ", "X-Request-Id": "req-1"}
+
+	tests := []struct {
+		name     string
+		settings models.IdempotencySettings
+		body     []byte
+		want     string
+		wantErr  bool
+	}{
+		{name: "not configured", settings: models.IdempotencySettings{}, body: body},
+		{name: "default header", settings: models.IdempotencySettings{Source: models.IdempotencyHeader}, body: body, want: "key-1"},
+		{name: "custom header", settings: models.IdempotencySettings{Source: models.IdempotencyHeader, Header: "x-request-id"}, body: body, want: "req-1"},
+		{name: "missing header", settings: models.IdempotencySettings{Source: models.IdempotencyHeader, Header: "X-Missing"}, body: body},
+		{name: "json path", settings: models.IdempotencySettings{Source: models.IdempotencyJSONPath, JSONPath: "$.id"}, body: body, want: "evt_1"},
+		{name: "nested json path", settings: models.IdempotencySettings{Source: models.IdempotencyJSONPath, JSONPath: "$.data.object.id"}, body: body, want: "pi_1"},
+		{name: "json path on invalid json", settings: models.IdempotencySettings{Source: models.IdempotencyJSONPath, JSONPath: "$.id"}, body: []byte("<xml/>"), wantErr: true},
+		{name: "body hash", settings: models.IdempotencySettings{Source: models.IdempotencyHash}, body: []byte("abc"),
+			want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{name: "unknown source", settings: models.IdempotencySettings{Source: "cookie"}, body: body, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.Key(tt.settings, headers, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Key error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
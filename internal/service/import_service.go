@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go-esb/internal/importer"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ImportService создает маршруты и схемы сообщений системы из описания сервиса.
+// Повторный импорт сопоставляется с существующими маршрутами системы.
+type ImportService interface {
+	ImportWSDL(ctx context.Context, systemID string, document []byte) (*ImportResult, error)
+	ImportOpenAPI(ctx context.Context, systemID string, document []byte) (*ImportResult, error)
+}
+
+// Статусы маршрутов в результате импорта
+const (
+	ImportCreated   = "created"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged"
+)
+
+// ImportResult результат импорта
+type ImportResult struct {
+	Routes []ImportedRoute `json:"routes"`
+	// Missing маршруты системы, которых нет в документе. Они не удаляются:
+	// на них могут ссылаться thread_routes.
+	Missing []models.Route `json:"missing,omitempty"`
+	// ConnectionSetting созданные настройки подключения (nil, если уже были)
+	ConnectionSetting *models.ConnectionSetting `json:"connection_setting,omitempty"`
+	// Authentications созданные шаблоны аутентификации (секреты заполняются вручную)
+	Authentications []models.ConnectionAuthentication `json:"authentications,omitempty"`
+}
+
+// ImportedRoute маршрут и его статус
+type ImportedRoute struct {
+	Route  models.Route `json:"route"`
+	Status string       `json:"status"`
+}
+
+type importService struct {
+	systemRepo       repository.SystemRepository
+	routeRepo        repository.RouteRepository
+	threadObjectRepo repository.ThreadObjectRepository
+	connectionRepo   repository.ConnectionRepository
+}
+
+func NewImportService(
+	systemRepo repository.SystemRepository,
+	routeRepo repository.RouteRepository,
+	threadObjectRepo repository.ThreadObjectRepository,
+	connectionRepo repository.ConnectionRepository,
+) ImportService {
+	return &importService{
+		systemRepo:       systemRepo,
+		routeRepo:        routeRepo,
+		threadObjectRepo: threadObjectRepo,
+		connectionRepo:   connectionRepo,
+	}
+}
+
+// ImportWSDL создает маршрут на каждую операцию WSDL с ее SOAPAction, схемы
+// запроса и ответа из типов XSD и настройки подключения с адресом сервиса.
+// Маршруты сопоставляются по имени операции.
+func (s *importService) ImportWSDL(ctx context.Context, systemID string, document []byte) (*ImportResult, error) {
+	system, err := s.system(ctx, systemID)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := importer.ParseWSDL(document)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.importSpec(ctx, system, spec, func(name, path string, method models.RestMethod) string {
+		return name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.ConnectionSetting, err = s.ensureConnectionSetting(ctx, system, spec, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.InfoContext(ctx, "📥 Imported operations from WSDL", "system", system.Name, "operations", len(result.Routes))
+	return result, nil
+}
+
+// ImportOpenAPI создает маршрут на каждую операцию OpenAPI 3, схемы запроса
+// и ответа, шаблоны аутентификации из securitySchemes и настройки подключения.
+// Маршруты сопоставляются по методу и пути.
+func (s *importService) ImportOpenAPI(ctx context.Context, systemID string, document []byte) (*ImportResult, error) {
+	system, err := s.system(ctx, systemID)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := importer.ParseOpenAPI(document)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.importSpec(ctx, system, spec, func(name, path string, method models.RestMethod) string {
+		return string(method) + " " + path
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var defaultAuth uuid.UUID
+	result.Authentications, defaultAuth, err = s.createAuthTemplates(ctx, system, spec.SecuritySchemes)
+	if err != nil {
+		return nil, err
+	}
+
+	result.ConnectionSetting, err = s.ensureConnectionSetting(ctx, system, spec, defaultAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.InfoContext(ctx, "📥 Imported operations from OpenAPI", "system", system.Name, "operations", len(result.Routes))
+	return result, nil
+}
+
+func (s *importService) system(ctx context.Context, systemID string) (*models.System, error) {
+	sysID, err := parseUUID(systemID)
+	if err != nil {
+		return nil, err
+	}
+	system, err := s.systemRepo.GetByID(ctx, sysID)
+	if err != nil {
+		return nil, errors.New("system not found")
+	}
+	return system, nil
+}
+
+// importSpec создает или обновляет маршруты операций. key определяет,
+// какой существующий маршрут системы соответствует операции.
+func (s *importService) importSpec(
+	ctx context.Context,
+	system *models.System,
+	spec *importer.Spec,
+	key func(name, path string, method models.RestMethod) string,
+) (*ImportResult, error) {
+	existing, err := s.routeRepo.GetBySystem(ctx, system.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get routes: %w", err)
+	}
+	byKey := make(map[string]*models.Route, len(existing))
+	for i := range existing {
+		byKey[key(existing[i].Name, existing[i].Path, existing[i].Method)] = &existing[i]
+	}
+
+	result := &ImportResult{}
+	matched := make(map[uuid.UUID]bool)
+	for _, op := range spec.Operations {
+		route, ok := byKey[key(op.Name, op.Path, op.Method)]
+		if !ok || matched[route.Ref] {
+			imported, err := s.createOperation(ctx, system.Ref, op)
+			if err != nil {
+				return nil, err
+			}
+			result.Routes = append(result.Routes, *imported)
+			continue
+		}
+
+		matched[route.Ref] = true
+		imported, err := s.updateOperation(ctx, route, op)
+		if err != nil {
+			return nil, err
+		}
+		result.Routes = append(result.Routes, *imported)
+	}
+
+	for _, route := range existing {
+		if !matched[route.Ref] {
+			result.Missing = append(result.Missing, route)
+		}
+	}
+	return result, nil
+}
+
+// createOperation создает маршрут и деревья thread_objects запроса и ответа
+func (s *importService) createOperation(ctx context.Context, systemID uuid.UUID, op importer.Operation) (*ImportedRoute, error) {
+	route := models.Route{
+		Name:       op.Name,
+		Path:       op.Path,
+		System:     systemID,
+		Method:     op.Method,
+		SOAPAction: op.SOAPAction,
+	}
+	var err error
+	if route.RequestObject, err = s.createObjectTree(ctx, op.Name+" Request", op.Request); err != nil {
+		return nil, err
+	}
+	if route.ResponseObject, err = s.createObjectTree(ctx, op.Name+" Response", op.Response); err != nil {
+		return nil, err
+	}
+	if err := s.routeRepo.Create(ctx, &route); err != nil {
+		return nil, fmt.Errorf("failed to create route %s: %w", op.Name, err)
+	}
+	return &ImportedRoute{Route: route, Status: ImportCreated}, nil
+}
+
+// updateOperation обновляет маршрут. Схема заменяется только если изменилась,
+// чтобы не сбрасывать ссылки thread_routes.object на прежние thread_objects.
+func (s *importService) updateOperation(ctx context.Context, route *models.Route, op importer.Operation) (*ImportedRoute, error) {
+	updated := *route
+	updated.Name = op.Name
+	updated.Path = op.Path
+	updated.Method = op.Method
+	updated.SOAPAction = op.SOAPAction
+
+	var err error
+	if updated.RequestObject, err = s.replaceObjectTree(ctx, op.Name+" Request", route.RequestObject, op.Request); err != nil {
+		return nil, err
+	}
+	if updated.ResponseObject, err = s.replaceObjectTree(ctx, op.Name+" Response", route.ResponseObject, op.Response); err != nil {
+		return nil, err
+	}
+
+	if updated.Name == route.Name && updated.Path == route.Path && updated.Method == route.Method &&
+		updated.SOAPAction == route.SOAPAction && sameRef(updated.RequestObject, route.RequestObject) &&
+		sameRef(updated.ResponseObject, route.ResponseObject) {
+		return &ImportedRoute{Route: updated, Status: ImportUnchanged}, nil
+	}
+
+	if err := s.routeRepo.Update(ctx, &updated); err != nil {
+		return nil, fmt.Errorf("failed to update route %s: %w", op.Name, err)
+	}
+	return &ImportedRoute{Route: updated, Status: ImportUpdated}, nil
+}
+
+// replaceObjectTree возвращает прежний корень, если схема не изменилась,
+// иначе удаляет прежнее дерево и создает новое
+func (s *importService) replaceObjectTree(ctx context.Context, name string, current *uuid.UUID, root *importer.Object) (*uuid.UUID, error) {
+	if current != nil {
+		stored, err := s.loadObject(ctx, *current)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to load schema %s: %w", name, err)
+		}
+		if stored != nil && root != nil && sameObject(stored, root) {
+			return current, nil
+		}
+		if stored != nil {
+			if err := s.threadObjectRepo.Delete(ctx, *current); err != nil {
+				return nil, fmt.Errorf("failed to delete schema %s: %w", name, err)
+			}
+		}
+	}
+	return s.createObjectTree(ctx, name, root)
+}
+
+// createObjectTree сохраняет схему сообщения. Корню дается имя операции,
+// name_object узлов — имена полей сообщения.
+func (s *importService) createObjectTree(ctx context.Context, name string, root *importer.Object) (*uuid.UUID, error) {
+	if root == nil {
+		return nil, nil
+	}
+	ref, err := s.createObject(ctx, name, root, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema %s: %w", name, err)
+	}
+	return &ref, nil
+}
+
+func (s *importService) createObject(ctx context.Context, name string, obj *importer.Object, parent *uuid.UUID) (uuid.UUID, error) {
+	object := models.ThreadObject{
+		Name:       name,
+		NameObject: obj.Name,
+		Type:       obj.Type,
+		Parent:     parent,
+	}
+	if err := s.threadObjectRepo.Create(ctx, &object); err != nil {
+		return uuid.Nil, err
+	}
+	for _, child := range obj.Children {
+		if _, err := s.createObject(ctx, child.Name, child, &object.Ref); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return object.Ref, nil
+}
+
+// loadObject читает сохраненное дерево thread_objects
+func (s *importService) loadObject(ctx context.Context, ref uuid.UUID) (*importer.Object, error) {
+	object, err := s.threadObjectRepo.GetByID(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadChildren(ctx, object)
+}
+
+func (s *importService) loadChildren(ctx context.Context, object *models.ThreadObject) (*importer.Object, error) {
+	obj := &importer.Object{Name: object.NameObject, Type: object.Type}
+	children, err := s.threadObjectRepo.GetChildren(ctx, object.Ref)
+	if err != nil {
+		return nil, err
+	}
+	for i := range children {
+		child, err := s.loadChildren(ctx, &children[i])
+		if err != nil {
+			return nil, err
+		}
+		obj.Children = append(obj.Children, child)
+	}
+	return obj, nil
+}
+
+// sameObject сравнивает схемы без учета порядка полей
+func sameObject(a, b *importer.Object) bool {
+	if a.Name != b.Name || a.Type != b.Type || len(a.Children) != len(b.Children) {
+		return false
+	}
+	left := sortedChildren(a)
+	right := sortedChildren(b)
+	for i := range left {
+		if !sameObject(left[i], right[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedChildren(obj *importer.Object) []*importer.Object {
+	children := append([]*importer.Object(nil), obj.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+func sameRef(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// createAuthTemplates создает шаблоны connection_authentications для схем
+// безопасности, которых еще нет у системы (по имени). Возвращает созданные
+// шаблоны и ссылку на аутентификацию схемы по умолчанию.
+func (s *importService) createAuthTemplates(ctx context.Context, system *models.System, schemes []importer.SecurityScheme) ([]models.ConnectionAuthentication, uuid.UUID, error) {
+	existing, err := s.connectionRepo.GetConnectionAuthsBySystem(ctx, system.Ref)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("failed to get authentications: %w", err)
+	}
+	byName := make(map[string]uuid.UUID, len(existing))
+	for _, auth := range existing {
+		byName[auth.Name] = auth.Ref
+	}
+
+	var created []models.ConnectionAuthentication
+	var defaultAuth uuid.UUID
+	for _, scheme := range schemes {
+		name := system.Name + " " + scheme.Name
+		ref, ok := byName[name]
+		if !ok {
+			auth := models.ConnectionAuthentication{
+				Name:     name,
+				System:   system.Ref,
+				Type:     scheme.Type,
+				TokenURL: scheme.TokenURL,
+				Scope:    scheme.Scope,
+			}
+			if err := s.connectionRepo.CreateConnectionAuth(ctx, &auth); err != nil {
+				return nil, uuid.Nil, fmt.Errorf("failed to create authentication %s: %w", name, err)
+			}
+			created = append(created, auth)
+			ref = auth.Ref
+		}
+		if scheme.Default && defaultAuth == uuid.Nil {
+			defaultAuth = ref
+		}
+	}
+	return created, defaultAuth, nil
+}
+
+// ensureConnectionSetting создает настройки подключения с адресом сервиса,
+// если у системы их еще нет. Существующие настройки не изменяются.
+func (s *importService) ensureConnectionSetting(ctx context.Context, system *models.System, spec *importer.Spec, authRef uuid.UUID) (*models.ConnectionSetting, error) {
+	if spec.Endpoint == "" {
+		return nil, nil
+	}
+	_, err := s.connectionRepo.GetConnectionSettings(ctx, system.Ref)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get connection settings: %w", err)
+	}
+
+	setting := &models.ConnectionSetting{
+		Name:        system.Name,
+		System:      system.Ref,
+		Path:        spec.Endpoint,
+		Port:        spec.Port,
+		AuthRef:     authRef,
+		SOAPVersion: spec.SOAPVersion,
+	}
+	if err := s.connectionRepo.CreateConnectionSetting(ctx, setting); err != nil {
+		return nil, fmt.Errorf("failed to create connection settings: %w", err)
+	}
+	return setting, nil
+}
@@ -2,22 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	"go-esb/internal/adapter"
+	"go-esb/internal/balancer"
+	"go-esb/internal/breaker"
 	"go-esb/internal/converter"
+	"go-esb/internal/logging"
+	"go-esb/internal/metrics"
 	"go-esb/internal/models"
+	"go-esb/internal/placeholder"
 	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+	"go-esb/internal/throttle"
+	"go-esb/internal/tracing"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var logger = logging.For("service")
+
 // MessageService обрабатывает маршрутизацию и трансформацию сообщений
 type MessageService interface {
-	ProcessMessage(ctx context.Context, threadID string, direction models.Directions, messageData []byte) error
-	RouteMessage(ctx context.Context, threadID uuid.UUID, direction models.Directions, messageData []byte) error
+	// ProcessMessage и RouteMessage возвращают ответ целевой системы первого
+	// маршрута, если thread работает в режиме запрос-ответ, иначе nil
+	ProcessMessage(ctx context.Context, threadID string, direction models.Directions, msg *models.Message) (*models.Response, error)
+	RouteMessage(ctx context.Context, threadID uuid.UUID, direction models.Directions, msg *models.Message) (*models.Response, error)
 }
 
 type messageService struct {
@@ -25,8 +42,19 @@ type messageService struct {
 	routeRepo        repository.RouteRepository
 	connectionRepo   repository.ConnectionRepository
 	systemRepo       repository.SystemRepository
-	adapterFactory   *adapter.AdapterFactory
+	ediRepo          repository.EDIRepository
+	globalRepo       repository.GlobalRepository
+	secrets          *secrets.Resolver
+	adapterFactory   adapterProvider
 	formatConverter  *converter.Converter
+	breakers         *breaker.Registry
+	limiters         *throttle.Registry
+	endpoints        *balancer.Registry
+}
+
+// adapterProvider выбирает адаптер протокола thread group
+type adapterProvider interface {
+	GetAdapter(protocol models.ProtocolType) (adapter.ProtocolAdapter, error)
 }
 
 func NewMessageService(
@@ -34,63 +62,219 @@ func NewMessageService(
 	routeRepo repository.RouteRepository,
 	connectionRepo repository.ConnectionRepository,
 	systemRepo repository.SystemRepository,
+	ediRepo repository.EDIRepository,
+	globalRepo repository.GlobalRepository,
+	secretResolver *secrets.Resolver,
+	breakers *breaker.Registry,
+	limiters *throttle.Registry,
+	endpoints *balancer.Registry,
 ) MessageService {
 	return &messageService{
 		threadRouteRepo:  threadRouteRepo,
 		routeRepo:        routeRepo,
 		connectionRepo:   connectionRepo,
 		systemRepo:       systemRepo,
-		adapterFactory:   adapter.NewAdapterFactory(),
+		ediRepo:          ediRepo,
+		globalRepo:       globalRepo,
+		secrets:          secretResolver,
+		adapterFactory:   adapter.NewAdapterFactory(&refreshTokenStore{connectionRepo: connectionRepo, secrets: secretResolver}),
 		formatConverter:  converter.NewConverter(),
+		breakers:         breakers,
+		limiters:         limiters,
+		endpoints:        endpoints,
 	}
 }
 
 // ProcessMessage обрабатывает входящее сообщение через thread
-func (s *messageService) ProcessMessage(ctx context.Context, threadID string, direction models.Directions, messageData []byte) error {
+func (s *messageService) ProcessMessage(ctx context.Context, threadID string, direction models.Directions, msg *models.Message) (*models.Response, error) {
 	threadUUID, err := uuid.Parse(threadID)
 	if err != nil {
-		return fmt.Errorf("invalid thread ID: %w", err)
+		return nil, fmt.Errorf("invalid thread ID: %w", err)
 	}
 
-	return s.RouteMessage(ctx, threadUUID, direction, messageData)
+	return s.RouteMessage(ctx, threadUUID, direction, msg)
+}
+
+// RouteMessage маршрутизирует сообщение по конфигурации thread.
+// Каждое сообщение получает message_id в записях лога; если вызывающий не передал
+// идентификатор корреляции (AMQP, оркестрация), создается новый.
+func (s *messageService) RouteMessage(ctx context.Context, threadID uuid.UUID, direction models.Directions, msg *models.Message) (*models.Response, error) {
+	if logging.CorrelationID(ctx) == "" {
+		ctx = logging.WithCorrelationID(ctx, uuid.NewString())
+	}
+	ctx = logging.WithFields(ctx, "message_id", uuid.NewString(), "thread_id", threadID.String(), "direction", string(direction))
+	ctx, span := tracing.Start(ctx, "RouteMessage", trace.WithAttributes(
+		attribute.String("esb.thread.id", threadID.String()),
+		attribute.String("esb.direction", string(direction)),
+	))
+	response, err := s.routeMessage(ctx, threadID, direction, msg)
+	tracing.End(span, err)
+	return response, err
 }
 
-// RouteMessage маршрутизирует сообщение по конфигурации thread
-func (s *messageService) RouteMessage(ctx context.Context, threadID uuid.UUID, direction models.Directions, messageData []byte) error {
+func (s *messageService) routeMessage(ctx context.Context, threadID uuid.UUID, direction models.Directions, msg *models.Message) (*models.Response, error) {
 	// Получаем thread и group для определения протокола
 	thread, group, err := s.threadRouteRepo.GetThreadWithGroup(ctx, threadID)
 	if err != nil {
-		return fmt.Errorf("failed to get thread: %w", err)
+		return nil, fmt.Errorf("failed to get thread: %w", err)
 	}
+	ctx = logging.WithFields(ctx, "thread", thread.Name)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("esb.thread.name", thread.Name),
+		attribute.String("esb.protocol", string(group.Protocol)),
+	)
 
 	// Получаем маршруты для данного направления
 	routes, err := s.threadRouteRepo.GetThreadRouteByDirection(ctx, threadID, direction)
 	if err != nil {
-		return fmt.Errorf("failed to get routes: %w", err)
+		return nil, fmt.Errorf("failed to get routes: %w", err)
 	}
 
 	if len(routes) == 0 {
-		return fmt.Errorf("no routes found for thread %s with direction %s", threadID, direction)
+		return nil, fmt.Errorf("no routes found for thread %s with direction %s", threadID, direction)
 	}
 
+	// Формат и кодировка входящего сообщения по умолчанию берутся из маршрута In
+	inRoute, err := s.inboundRoute(ctx, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inbound route: %w", err)
+	}
+	if msg.Format == "" {
+		msg.Format = models.FileFormatJSON
+		if inRoute != nil && inRoute.FileFormat != "" {
+			msg.Format = inRoute.FileFormat
+		}
+	}
+	if msg.Charset == "" && inRoute != nil {
+		msg.Charset = inRoute.SourceCharset
+	}
+	metrics.MessagesReceived.WithLabelValues(thread.Name, string(group.Protocol), string(direction), string(msg.Format)).Inc()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("esb.format", string(msg.Format)))
+
 	// Обрабатываем каждый маршрут
+	var routeErrs []error
+	var response *models.Response
+	for i, threadRoute := range routes {
+		routeResponse, err := s.processRoute(ctx, thread, group, inRoute, threadRoute, msg)
+		// В режиме запрос-ответ вызывающему возвращается ответ первого маршрута
+		if i == 0 {
+			response = routeResponse
+		}
+		if err != nil {
+			logger.WarnContext(ctx, "⚠️ Error processing route", "route_id", threadRoute.Route.String(), "error", err)
+			// Продолжаем обработку других маршрутов, ошибки возвращаются вызывающему
+			routeErrs = append(routeErrs, fmt.Errorf("route %s: %w", threadRoute.Route, err))
+		}
+	}
+
+	// Функциональная квитанция на входящий обмен EDI: если сообщение не удалось
+	// доставить по всем маршрутам, квитанция сообщает, что обмен отклонен
+	if direction == models.DirectionIn && inRoute != nil && inRoute.EDISettings.Acknowledge && isEDIFormat(msg.Format) {
+		if err := s.sendEDIAcknowledgement(ctx, thread, group, inRoute, msg, len(routeErrs) == 0); err != nil {
+			logger.WarnContext(ctx, "⚠️ Failed to send acknowledgement", "format", msg.Format, "error", err)
+		}
+	}
+
+	return response, errors.Join(routeErrs...)
+}
+
+// sendEDIAcknowledgement формирует CONTRL/997 на входящий обмен и отправляет ее
+// обратно по маршрутам Out того же thread с тем же форматом EDI
+func (s *messageService) sendEDIAcknowledgement(
+	ctx context.Context,
+	thread *models.Thread,
+	group *models.ThreadGroup,
+	inRoute *models.ThreadRoute,
+	msg *models.Message,
+	accepted bool,
+) error {
+	routes, err := s.threadRouteRepo.GetThreadRouteByDirection(ctx, thread.Ref, models.DirectionOut)
+	if err != nil {
+		return fmt.Errorf("failed to get routes: %w", err)
+	}
+
+	opts := ediOptions(inRoute.EDISettings)
+	opts.ControlNumber, err = s.ediRepo.NextControlNumber(ctx, string(msg.Format), opts.SenderID, opts.ReceiverID)
+	if err != nil {
+		return fmt.Errorf("failed to get control number: %w", err)
+	}
+
+	data, err := converter.DecodeCharset(msg.Data, msg.Charset)
+	if err != nil {
+		return fmt.Errorf("failed to decode charset: %w", err)
+	}
+	ack, err := converter.EDIAcknowledgement(data, string(msg.Format), opts, accepted)
+	if err != nil {
+		return err
+	}
+
+	ackMsg := &models.Message{Data: ack, Format: msg.Format}
+	sent := false
 	for _, threadRoute := range routes {
-		if err := s.processRoute(ctx, thread, group, threadRoute, messageData); err != nil {
-			log.Printf("⚠️ Error processing route %s: %v", threadRoute.Route, err)
-			// Продолжаем обработку других маршрутов
+		if threadRoute.FileFormat != msg.Format {
+			continue
+		}
+		if _, err := s.processRoute(ctx, thread, group, inRoute, threadRoute, ackMsg); err != nil {
+			logger.WarnContext(ctx, "⚠️ Error sending acknowledgement", "route_id", threadRoute.Route.String(), "error", err)
+			continue
 		}
+		sent = true
+	}
+	if !sent {
+		return fmt.Errorf("no %s routes with direction %s to send acknowledgement", msg.Format, models.DirectionOut)
 	}
 
+	logger.InfoContext(ctx, "📨 Acknowledgement sent", "format", msg.Format, "accepted", accepted)
 	return nil
 }
 
+func isEDIFormat(format models.FileFormat) bool {
+	return format == models.FileFormatEDIFACT || format == models.FileFormatX12
+}
+
+// ediOptions переводит настройки EDI маршрута в параметры конвертера
+func ediOptions(settings models.EDISettings) converter.EDIOptions {
+	return converter.EDIOptions{
+		SegmentTerminator:   settings.SegmentTerminator,
+		ElementSeparator:    settings.ElementSeparator,
+		ComponentSeparator:  settings.ComponentSeparator,
+		ReleaseCharacter:    settings.ReleaseCharacter,
+		RepetitionSeparator: settings.RepetitionSeparator,
+		SenderID:            settings.SenderID,
+		SenderQualifier:     settings.SenderQualifier,
+		ReceiverID:          settings.ReceiverID,
+		ReceiverQualifier:   settings.ReceiverQualifier,
+		TestIndicator:       settings.TestIndicator,
+	}
+}
+
 func (s *messageService) processRoute(
 	ctx context.Context,
 	thread *models.Thread,
 	group *models.ThreadGroup,
+	inRoute *models.ThreadRoute,
 	threadRoute models.ThreadRoute,
-	messageData []byte,
-) error {
+	msg *models.Message,
+) (response *models.Response, err error) {
+	start := time.Now()
+	labels := metrics.RouteLabels{Thread: thread.Name, Route: threadRoute.Route.String(), Protocol: string(group.Protocol)}
+	ctx, span := tracing.Start(ctx, "processRoute", trace.WithAttributes(
+		attribute.String("esb.route.id", threadRoute.Route.String()),
+		attribute.String("esb.format", string(threadRoute.FileFormat)),
+	))
+	defer func() {
+		metrics.ObserveRoute(labels, err, time.Since(start))
+		tracing.End(span, err)
+	}()
+
+	// Таймаут маршрута ограничивает всю обработку, включая ожидание ограничителя;
+	// таймауты отдельного запроса задаются в http_client подключения
+	if threadRoute.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(threadRoute.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
 	// Получаем route для получения информации о системе
 	routeID := threadRoute.Route
 	// Получаем route через repository (нужно добавить метод GetByID)
@@ -99,49 +283,96 @@ func (s *messageService) processRoute(
 	// Получаем информацию о системе из route
 	route, err := s.getRouteByID(ctx, routeID)
 	if err != nil {
-		return fmt.Errorf("failed to get route: %w", err)
+		return nil, fmt.Errorf("failed to get route: %w", err)
 	}
+	labels.Route = route.Name
+	system := s.system(ctx, route.System)
+	labels.System = system.Name
+	ctx = logging.WithFields(ctx, "route", labels.Route, "system", labels.System)
+	span.SetAttributes(attribute.String("esb.route.name", labels.Route), attribute.String("esb.system", labels.System))
 
-	// Получаем connection settings для системы
-	connSettings, err := s.connectionRepo.GetConnectionSettings(ctx, route.System)
+	// Получаем подключения системы в порядке попыток отправки; кодировки
+	// берутся из первого подключения
+	connections, err := s.connectionRepo.GetConnectionSettingsBySystem(ctx, route.System)
 	if err != nil {
-		return fmt.Errorf("failed to get connection settings: %w", err)
+		return nil, fmt.Errorf("failed to get connection settings: %w", err)
 	}
+	candidates := s.endpoints.Order(system, connections)
+	connSettings := candidates[0]
 
-	// Конвертируем формат данных если нужно
-	convertedData := messageData
-	if threadRoute.FileFormat != "JSON" {
-		// Конвертируем из JSON в требуемый формат
-		convertedData, err = s.formatConverter.Convert(messageData, "JSON", string(threadRoute.FileFormat))
+	// Конвертируем из формата источника в формат маршрута с перекодировкой
+	targetCharset := threadRoute.TargetCharset
+	if targetCharset == "" {
+		targetCharset = connSettings.TargetCharset
+	}
+	opts := converter.Options{
+		SourceCharset: msg.Charset,
+		TargetCharset: targetCharset,
+		TargetProto:   converter.ProtoSchema{DescriptorSet: threadRoute.ProtoDescriptor, Message: threadRoute.ProtoMessage},
+		TargetEDI:     ediOptions(threadRoute.EDISettings),
+	}
+	if inRoute != nil {
+		opts.SourceProto = converter.ProtoSchema{DescriptorSet: inRoute.ProtoDescriptor, Message: inRoute.ProtoMessage}
+		opts.SourceEDI = ediOptions(inRoute.EDISettings)
+	}
+	// Конверт UNB/ISA получает следующий контрольный номер пары отправитель/получатель
+	if isEDIFormat(threadRoute.FileFormat) && msg.Format != threadRoute.FileFormat && opts.TargetEDI.SenderID != "" {
+		opts.TargetEDI.ControlNumber, err = s.ediRepo.NextControlNumber(ctx, string(threadRoute.FileFormat),
+			opts.TargetEDI.SenderID, opts.TargetEDI.ReceiverID)
 		if err != nil {
-			return fmt.Errorf("failed to convert format: %w", err)
+			return nil, fmt.Errorf("failed to get control number: %w", err)
 		}
 	}
+	convertedData, err := s.formatConverter.ConvertContext(ctx, msg.Data, string(msg.Format), string(threadRoute.FileFormat), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert format: %w", err)
+	}
 
 	// Получаем адаптер для протокола
 	protocolAdapter, err := s.adapterFactory.GetAdapter(group.Protocol)
 	if err != nil {
-		return fmt.Errorf("unsupported protocol: %w", err)
+		return nil, fmt.Errorf("unsupported protocol: %w", err)
 	}
 
-	// Подготавливаем заголовки аутентификации
-	var headers map[string]string
-	if connSettings.AuthRef != uuid.Nil {
-		auth, err := s.connectionRepo.GetConnectionAuth(ctx, connSettings.AuthRef)
-		if err == nil {
-			headers, _ = protocolAdapter.Authenticate(auth, connSettings.Path)
-		}
+	// Заголовки маршрута дополняют заголовки аутентификации подключения
+	headers := make(map[string]string)
+	if group.Protocol == models.ProtocolREST || group.Protocol == models.ProtocolAMQP {
+		headers["Content-Type"] = converter.ContentTypeForFormat(string(threadRoute.FileFormat), targetCharset)
 	}
 
-	// Формируем endpoint
-	endpoint := s.buildEndpoint(connSettings, route)
+	// Подставляем значения полей сообщения, глобальных настроек и переменных
+	// процесса в путь, параметры запроса и заголовки маршрута
+	tmpl := &routeTemplate{ctx: ctx, globals: s.globalRepo, toJSON: func() ([]byte, error) {
+		return s.formatConverter.ConvertContext(ctx, msg.Data, string(msg.Format), string(models.FileFormatJSON), converter.Options{
+			SourceCharset: msg.Charset,
+			SourceProto:   opts.SourceProto,
+			SourceEDI:     opts.SourceEDI,
+		})
+	}}
+	if route.Path, err = placeholder.RenderURL(route.Path, tmpl.resolve); err != nil {
+		return nil, fmt.Errorf("failed to render route path: %w", err)
+	}
+	query := make(map[string]string, len(threadRoute.Query))
+	for key, value := range threadRoute.Query {
+		if query[key], err = placeholder.Render(value, tmpl.resolve, nil); err != nil {
+			return nil, fmt.Errorf("failed to render query parameter %s: %w", key, err)
+		}
+	}
+	for key, value := range threadRoute.Headers {
+		if headers[key], err = placeholder.Render(value, tmpl.resolve, placeholder.EscapeHeader); err != nil {
+			return nil, fmt.Errorf("failed to render header %s: %w", key, err)
+		}
+	}
 
 	// Отправляем сообщение
 	action := ""
 	switch group.Protocol {
 	case models.ProtocolSOAP:
-		// SOAP action может быть из route или конфигурации
-		action = route.Path
+		// SOAP action из WSDL или path маршрута
+		action = route.SOAPAction
+		if action == "" {
+			action = route.Path
+		}
 	case models.ProtocolREST:
 		// Для REST action содержит HTTP method
 		action = string(route.Method)
@@ -149,13 +380,319 @@ func (s *messageService) processRoute(
 		// Для AMQP action содержит exchange name (если нужно)
 		action = "" // или из конфигурации
 	}
-	_, statusCode, err := protocolAdapter.Send(ctx, endpoint, action, headers, convertedData)
+	// При временной ошибке повторяется отправка только этого маршрута,
+	// если для него настроен retry; остальные маршруты thread не переотправляются
+	var respBody []byte
+	var statusCode int
+	for attempt := 1; ; attempt++ {
+		respBody, statusCode, connSettings, err = s.sendWithFailover(ctx, protocolAdapter, labels, candidates, route, query, action, headers, convertedData)
+		if err == nil || attempt >= threadRoute.Retry.MaxAttempts || !isRetryable(statusCode, err) || ctx.Err() != nil {
+			break
+		}
+		delay := retryBackoff(threadRoute.Retry, attempt, err)
+		logger.WarnContext(ctx, "🔄 Route failed, retrying", "attempt", attempt, "max_attempts", threadRoute.Retry.MaxAttempts,
+			"delay_ms", delay.Milliseconds(), "status", statusCode, "error", err)
+		metrics.RouteRetries.WithLabelValues(labels.Thread, labels.Route).Inc()
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%w: %v", err, ctx.Err())
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		// Ответ целевой системы с ошибкой (например, SOAP Fault) тоже передается вызывающему
+		if thread.RequestResponse && statusCode >= http.StatusBadRequest {
+			response, _ = s.convertResponse(ctx, respBody, statusCode, threadRoute, connSettings, msg)
+		}
+		return response, fmt.Errorf("failed to send message: %w", err)
 	}
 
-	log.Printf("✅ Message sent to %s via %s (status: %d)", route.Name, group.Protocol, statusCode)
-	return nil
+	logger.InfoContext(ctx, "✅ Message sent", "protocol", group.Protocol, "status", statusCode)
+	if !thread.RequestResponse {
+		return nil, nil
+	}
+	response, convErr := s.convertResponse(ctx, respBody, statusCode, threadRoute, connSettings, msg)
+	if convErr != nil {
+		// Сообщение уже доставлено: отдаем ответ как есть
+		logger.WarnContext(ctx, "⚠️ Failed to convert response", "error", convErr)
+	}
+	return response, nil
+}
+
+// convertResponse конвертирует ответ целевой системы из формата маршрута
+// в формат и кодировку входящего сообщения. Если ответ не удалось
+// сконвертировать, возвращается исходный ответ в формате маршрута.
+func (s *messageService) convertResponse(
+	ctx context.Context,
+	body []byte,
+	statusCode int,
+	threadRoute models.ThreadRoute,
+	connSettings *models.ConnectionSetting,
+	msg *models.Message,
+) (*models.Response, error) {
+	sourceCharset := threadRoute.SourceCharset
+	if sourceCharset == "" {
+		sourceCharset = connSettings.SourceCharset
+	}
+	response := &models.Response{
+		Message:    models.Message{Data: body, Format: threadRoute.FileFormat, Charset: sourceCharset},
+		StatusCode: statusCode,
+	}
+	if len(body) == 0 {
+		response.Format, response.Charset = msg.Format, msg.Charset
+		return response, nil
+	}
+	data, err := s.formatConverter.ConvertContext(ctx, body, string(threadRoute.FileFormat), string(msg.Format), converter.Options{
+		SourceCharset: sourceCharset,
+		TargetCharset: msg.Charset,
+		SourceEDI:     ediOptions(threadRoute.EDISettings),
+	})
+	if err != nil {
+		return response, err
+	}
+	response.Message = models.Message{Data: data, Format: msg.Format, Charset: msg.Charset}
+	return response, nil
+}
+
+// sendWithFailover отправляет сообщение через подключения системы по порядку:
+// если подключение недоступно, сообщение отправляется через следующее.
+// Возвращает подключение, через которое выполнена последняя попытка.
+func (s *messageService) sendWithFailover(
+	ctx context.Context,
+	protocolAdapter adapter.ProtocolAdapter,
+	labels metrics.RouteLabels,
+	candidates []*models.ConnectionSetting,
+	route *models.Route,
+	query map[string]string,
+	action string,
+	headers map[string]string,
+	body []byte,
+) (respBody []byte, statusCode int, connSettings *models.ConnectionSetting, err error) {
+	for i, candidate := range candidates {
+		connSettings = candidate
+		respBody, statusCode, err = s.sendToConnection(ctx, protocolAdapter, labels, connSettings, route, query, action, headers, body)
+		if err == nil || i == len(candidates)-1 || !isFailoverError(statusCode, err) || ctx.Err() != nil {
+			break
+		}
+		metrics.EndpointFailovers.WithLabelValues(labels.System, connSettings.Name).Inc()
+		logger.WarnContext(ctx, "🔀 Connection failed, trying next connection",
+			"connection", connSettings.Name, "next", candidates[i+1].Name, "status", statusCode, "error", err)
+	}
+	return respBody, statusCode, connSettings, err
+}
+
+// sendToConnection отправляет сообщение через подключение системы: аутентификация,
+// ограничения подключения, выключатель и повтор после обновления токена OAuth2
+func (s *messageService) sendToConnection(
+	ctx context.Context,
+	protocolAdapter adapter.ProtocolAdapter,
+	labels metrics.RouteLabels,
+	connSettings *models.ConnectionSetting,
+	route *models.Route,
+	query map[string]string,
+	action string,
+	routeHeaders map[string]string,
+	body []byte,
+) ([]byte, int, error) {
+	ctx = logging.WithFields(ctx, "connection", connSettings.Name)
+
+	// Подготавливаем заголовки аутентификации
+	headers := make(map[string]string, len(routeHeaders))
+	var auth *models.ConnectionAuthentication
+	if connSettings.AuthRef != uuid.Nil {
+		var err error
+		// Аутентификация вне профиля ESB_ENV или секрет, который не удалось
+		// расшифровать, останавливают отправку: без них запрос ушел бы без учетных данных
+		auth, err = s.connectionRepo.GetConnectionAuth(ctx, connSettings.AuthRef)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get authentication: %w", err)
+		}
+		// Ссылки env:, file:, vault: разрешаются при каждой отправке (с кэшем)
+		auth, err = s.secrets.ResolveAuth(ctx, auth)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to resolve credentials: %w", err)
+		}
+		// Токен OAuth2 запрашивается клиентом HTTP подключения
+		authCtx := adapter.WithConnection(ctx, adapter.Connection{Settings: connSettings, Auth: auth, System: labels.System})
+		authHeaders, err := protocolAdapter.Authenticate(authCtx, auth, connSettings.Path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to authenticate: %w", err)
+		}
+		for key, value := range authHeaders {
+			headers[key] = value
+		}
+	}
+	for key, value := range routeHeaders {
+		headers[key] = value
+	}
+
+	// Формируем endpoint
+	endpoint := placeholder.AppendQuery(s.buildEndpoint(connSettings, route), query)
+
+	// Ограничения частоты и одновременных запросов системы: ждем в очереди
+	// не дольше max_wait_ms и дедлайна вызывающего
+	limiter := s.limiters.Get(connSettings, labels.System)
+	release, err := limiter.Acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	// Пока выключатель системы разомкнут, сообщение не отправляется
+	done, err := s.breakers.Get(connSettings, labels.System).Allow()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx = adapter.WithConnection(ctx, adapter.Connection{Settings: connSettings, Auth: auth, System: labels.System})
+	respBody, statusCode, err := s.send(ctx, protocolAdapter, labels, endpoint, action, headers, body)
+	// Токен OAuth2 мог быть отозван до истечения: получаем новый и повторяем один раз
+	if err != nil && statusCode == http.StatusUnauthorized && auth != nil && auth.Type == models.AuthOAuth2 {
+		if reauth, ok := protocolAdapter.(adapter.Reauthenticator); ok {
+			logger.InfoContext(ctx, "🔑 Got 401, refreshing OAuth2 token and retrying")
+			authHeaders, authErr := reauth.Reauthenticate(ctx, auth, connSettings.Path)
+			if authErr != nil {
+				// Выключатель учитывает ответ системы (401), а не ошибку token endpoint
+				done(!isSystemFailure(statusCode, err))
+				return nil, 0, fmt.Errorf("failed to authenticate: %w", authErr)
+			}
+			for key, value := range authHeaders {
+				headers[key] = value
+			}
+			respBody, statusCode, err = s.send(ctx, protocolAdapter, labels, endpoint, action, headers, body)
+		}
+	}
+	done(!isSystemFailure(statusCode, err))
+	if statusCode == http.StatusTooManyRequests {
+		limiter.Backoff(adapter.RetryAfter(err))
+	}
+	return respBody, statusCode, err
+}
+
+// isFailoverError проверяет, что сообщение можно отправить через другое подключение
+// системы: система не ответила или ответила, что недоступна, либо подключение
+// не принимает запросы (выключатель разомкнут, превышены ограничения).
+// Ответ 500 не переключает подключение: система могла обработать сообщение.
+func isFailoverError(statusCode int, err error) bool {
+	if errors.Is(err, breaker.ErrOpen) || errors.Is(err, throttle.ErrLimited) {
+		return true
+	}
+	switch statusCode {
+	case 0, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Паузы между попытками отправки маршрута по умолчанию
+const (
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// isRetryable проверяет, что отправку маршрута можно повторить: SOAP Fault
+// Server/Receiver или временная недоступность системы. SOAP Fault Client/Sender
+// и остальные ошибки в запросе не повторяются.
+func isRetryable(statusCode int, err error) bool {
+	var fault *adapter.SOAPFault
+	if errors.As(err, &fault) {
+		return fault.Retryable()
+	}
+	return isFailoverError(statusCode, err)
+}
+
+// retryBackoff возвращает паузу перед следующей попыткой: backoff_ms удваивается
+// с каждой попыткой до max_backoff_ms, но не меньше Retry-After ответа 429/503
+func retryBackoff(settings models.RetrySettings, attempt int, err error) time.Duration {
+	delay, maxDelay := defaultRetryBackoff, defaultRetryMaxBackoff
+	if settings.BackoffMs > 0 {
+		delay = time.Duration(settings.BackoffMs) * time.Millisecond
+	}
+	if settings.MaxBackoffMs > 0 {
+		maxDelay = time.Duration(settings.MaxBackoffMs) * time.Millisecond
+	}
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if retryAfter := adapter.RetryAfter(err); retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// isSystemFailure проверяет, что отправка не удалась по вине целевой системы:
+// нет ответа (таймаут, отказ соединения), 5xx или 429. Ошибки в запросе (4xx,
+// SOAP Fault Client/Sender) выключатель не размыкают.
+func isSystemFailure(statusCode int, err error) bool {
+	if err == nil {
+		return false
+	}
+	var fault *adapter.SOAPFault
+	if errors.As(err, &fault) && fault.IsSenderFault() {
+		return false
+	}
+	return statusCode == 0 || statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// inboundRoute возвращает маршрут thread с направлением In или nil, если его нет
+func (s *messageService) inboundRoute(ctx context.Context, threadID uuid.UUID) (*models.ThreadRoute, error) {
+	inRoutes, err := s.threadRouteRepo.GetThreadRouteByDirection(ctx, threadID, models.DirectionIn)
+	if err != nil {
+		return nil, err
+	}
+	if len(inRoutes) == 0 {
+		return nil, nil
+	}
+	return &inRoutes[0], nil
+}
+
+// send отправляет сообщение адаптером протокола: передает идентификатор корреляции
+// и контекст трассировки (traceparent) в заголовках и учитывает время и код ответа
+// в span и метриках
+func (s *messageService) send(
+	ctx context.Context,
+	protocolAdapter adapter.ProtocolAdapter,
+	labels metrics.RouteLabels,
+	endpoint, action string,
+	headers map[string]string,
+	body []byte,
+) ([]byte, int, error) {
+	ctx, span := tracing.Start(ctx, labels.Protocol+" send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("esb.protocol", labels.Protocol),
+		attribute.String("esb.system", labels.System),
+		attribute.String("esb.endpoint", strings.SplitN(endpoint, "?", 2)[0]),
+	))
+	tracing.Inject(ctx, headers)
+	if correlationID := logging.CorrelationID(ctx); correlationID != "" {
+		headers[logging.CorrelationHeader] = correlationID
+	}
+
+	start := time.Now()
+	respBody, statusCode, err := protocolAdapter.Send(ctx, endpoint, action, headers, body)
+	metrics.ObserveAdapterRequest(labels.Protocol, labels.System, statusCode, time.Since(start))
+	if statusCode > 0 {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	}
+	tracing.End(span, err)
+	return respBody, statusCode, err
+}
+
+// system возвращает систему маршрута; если ее не удалось получить, именем
+// системы для меток метрик служит ее идентификатор
+func (s *messageService) system(ctx context.Context, systemID uuid.UUID) *models.System {
+	system, err := s.systemRepo.GetByID(ctx, systemID)
+	if err != nil {
+		return &models.System{Ref: systemID, Name: systemID.String()}
+	}
+	return system
 }
 
 func (s *messageService) getRouteByID(ctx context.Context, routeID uuid.UUID) (*models.Route, error) {
@@ -0,0 +1,587 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-esb/internal/adapter"
+	"go-esb/internal/balancer"
+	"go-esb/internal/breaker"
+	"go-esb/internal/encryption"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+	"go-esb/internal/throttle"
+	"go-esb/internal/tracing"
+
+	"github.com/google/uuid"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testRepos хранит конфигурацию одного thread и системы в памяти
+type testRepos struct {
+	thread      models.Thread
+	group       models.ThreadGroup
+	system      models.System
+	routes      map[uuid.UUID]*models.Route
+	threadRoute map[models.Directions][]models.ThreadRoute
+	connections []models.ConnectionSetting
+	auths       map[uuid.UUID]*models.ConnectionAuthentication
+	// refreshTokens refresh token, сохраненные после ротации
+	refreshTokens []string
+	// keyring расшифровывает секреты аутентификаций, как репозиторий
+	keyring *encryption.Keyring
+}
+
+// Репозитории сервиса поверх testRepos; методы, не нужные маршрутизации,
+// не реализованы
+type (
+	testThreadRouteRepo struct {
+		repository.ThreadRouteRepository
+		*testRepos
+	}
+	testRouteRepo struct {
+		repository.RouteRepository
+		*testRepos
+	}
+	testSystemRepo struct {
+		repository.SystemRepository
+		*testRepos
+	}
+	testConnectionRepo struct {
+		repository.ConnectionRepository
+		*testRepos
+	}
+)
+
+func (r testThreadRouteRepo) GetThreadWithGroup(context.Context, uuid.UUID) (*models.Thread, *models.ThreadGroup, error) {
+	thread, group := r.thread, r.group
+	return &thread, &group, nil
+}
+
+func (r testThreadRouteRepo) GetThreadRouteByDirection(_ context.Context, _ uuid.UUID, direction models.Directions) ([]models.ThreadRoute, error) {
+	return r.threadRoute[direction], nil
+}
+
+func (r testRouteRepo) GetByID(_ context.Context, id uuid.UUID) (*models.Route, error) {
+	route, ok := r.routes[id]
+	if !ok {
+		return nil, errors.New("route not found")
+	}
+	copied := *route
+	return &copied, nil
+}
+
+func (r testConnectionRepo) GetConnectionSettingsBySystem(context.Context, uuid.UUID) ([]models.ConnectionSetting, error) {
+	return append([]models.ConnectionSetting(nil), r.connections...), nil
+}
+
+func (r testConnectionRepo) GetConnectionAuth(_ context.Context, id uuid.UUID) (*models.ConnectionAuthentication, error) {
+	auth, ok := r.auths[id]
+	if !ok {
+		return nil, errors.New("authentication not found")
+	}
+	copied := *auth
+	for _, secret := range copied.Secrets() {
+		if r.keyring == nil || !encryption.IsEncrypted(*secret) {
+			continue
+		}
+		decrypted, err := r.keyring.Decrypt(*secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials of %s: %w", copied.Name, err)
+		}
+		*secret = decrypted
+	}
+	return &copied, nil
+}
+
+func (r testConnectionRepo) UpdateRefreshToken(_ context.Context, id uuid.UUID, refreshToken string) error {
+	r.auths[id].RefreshToken = refreshToken
+	r.refreshTokens = append(r.refreshTokens, refreshToken)
+	return nil
+}
+
+func (r testSystemRepo) GetByID(context.Context, uuid.UUID) (*models.System, error) {
+	system := r.system
+	return &system, nil
+}
+
+// newTestRepos создает REST thread с подключением к server
+func newTestRepos(server *httptest.Server) *testRepos {
+	system := models.System{Ref: uuid.New(), Name: "sap"}
+	return &testRepos{
+		thread:      models.Thread{Ref: uuid.New(), Name: "orders"},
+		group:       models.ThreadGroup{Ref: uuid.New(), Protocol: models.ProtocolREST},
+		system:      system,
+		routes:      make(map[uuid.UUID]*models.Route),
+		threadRoute: make(map[models.Directions][]models.ThreadRoute),
+		connections: []models.ConnectionSetting{{Ref: uuid.New(), Name: "primary", System: system.Ref, Path: server.URL}},
+		auths:       make(map[uuid.UUID]*models.ConnectionAuthentication),
+	}
+}
+
+// addRoute добавляет маршрут Out с путем path
+func (r *testRepos) addRoute(path string, retry models.RetrySettings) uuid.UUID {
+	route := &models.Route{Ref: uuid.New(), Name: strings.TrimPrefix(path, "/"), Path: path, System: r.system.Ref, Method: "POST"}
+	r.routes[route.Ref] = route
+	r.threadRoute[models.DirectionOut] = append(r.threadRoute[models.DirectionOut], models.ThreadRoute{
+		Thread: r.thread.Ref, Direction: models.DirectionOut, Route: route.Ref, FileFormat: models.FileFormatJSON, Retry: retry,
+	})
+	return route.Ref
+}
+
+func (r *testRepos) service() MessageService {
+	return NewMessageService(testThreadRouteRepo{testRepos: r}, testRouteRepo{testRepos: r},
+		testConnectionRepo{testRepos: r}, testSystemRepo{testRepos: r}, nil, nil,
+		secrets.NewResolver(0), breaker.NewRegistry(), throttle.NewRegistry(), balancer.NewRegistry())
+}
+
+func (r *testRepos) send(svc MessageService) error {
+	_, err := svc.RouteMessage(context.Background(), r.thread.Ref, models.DirectionOut,
+		&models.Message{Data: []byte(`{"order":1}`), Format: models.FileFormatJSON})
+	return err
+}
+
+// pathServer отвечает кодами из statuses по очереди для каждого пути
+// (последний код повторяется) и считает запросы
+type pathServer struct {
+	mu       sync.Mutex
+	statuses map[string][]int
+	hits     map[string]int
+}
+
+func newPathServer(statuses map[string][]int) (*pathServer, *httptest.Server) {
+	ps := &pathServer{statuses: statuses, hits: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps.mu.Lock()
+		codes := ps.statuses[r.URL.Path]
+		hit := ps.hits[r.URL.Path]
+		ps.hits[r.URL.Path]++
+		ps.mu.Unlock()
+
+		status := http.StatusOK
+		if len(codes) > 0 {
+			status = codes[len(codes)-1]
+			if hit < len(codes) {
+				status = codes[hit]
+			}
+		}
+		w.WriteHeader(status)
+	}))
+	return ps, server
+}
+
+func (ps *pathServer) count(path string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.hits[path]
+}
+
+func TestRouteMessageRetriesOnlyFailedRoute(t *testing.T) {
+	ps, server := newPathServer(map[string][]int{"/a": {503, 502, 200}})
+	defer server.Close()
+	repos := newTestRepos(server)
+	repos.addRoute("/a", models.RetrySettings{MaxAttempts: 3, BackoffMs: 1})
+	repos.addRoute("/b", models.RetrySettings{})
+
+	if err := repos.send(repos.service()); err != nil {
+		t.Fatalf("RouteMessage = %v, want success after retries", err)
+	}
+	if ps.count("/a") != 3 || ps.count("/b") != 1 {
+		t.Fatalf("requests a=%d b=%d, want a=3 b=1", ps.count("/a"), ps.count("/b"))
+	}
+}
+
+func TestRouteMessageReturnsRouteErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retry      models.RetrySettings
+		wantHits   int
+		wantStatus int
+	}{
+		{name: "no retry settings", statuses: []int{503, 200}, wantHits: 1, wantStatus: 503},
+		{name: "attempts exhausted", statuses: []int{503}, retry: models.RetrySettings{MaxAttempts: 2, BackoffMs: 1}, wantHits: 2, wantStatus: 503},
+		{name: "client error not retried", statuses: []int{400, 200}, retry: models.RetrySettings{MaxAttempts: 3, BackoffMs: 1}, wantHits: 1, wantStatus: 400},
+		{name: "server error 500 not retried", statuses: []int{500, 200}, retry: models.RetrySettings{MaxAttempts: 3, BackoffMs: 1}, wantHits: 1, wantStatus: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, server := newPathServer(map[string][]int{"/a": tt.statuses})
+			defer server.Close()
+			repos := newTestRepos(server)
+			failed := repos.addRoute("/a", tt.retry)
+			repos.addRoute("/b", models.RetrySettings{})
+
+			err := repos.send(repos.service())
+			var statusErr *adapter.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+				t.Fatalf("RouteMessage = %v, want StatusError %d", err, tt.wantStatus)
+			}
+			if !strings.Contains(err.Error(), failed.String()) {
+				t.Fatalf("error %q does not name route %s", err, failed)
+			}
+			// Ошибка маршрута не мешает доставке по остальным маршрутам
+			if ps.count("/a") != tt.wantHits || ps.count("/b") != 1 {
+				t.Fatalf("requests a=%d b=%d, want a=%d b=1", ps.count("/a"), ps.count("/b"), tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"SOAP 1.1 server fault", 500, &adapter.SOAPFault{Code: "Server"}, true},
+		{"SOAP 1.2 receiver fault", 500, &adapter.SOAPFault{Code: "Receiver"}, true},
+		{"SOAP client fault", 500, &adapter.SOAPFault{Code: "Client"}, false},
+		{"SOAP sender fault", 400, &adapter.SOAPFault{Code: "Sender"}, false},
+		{"wrapped server fault", 500, errors.Join(errors.New("route"), &adapter.SOAPFault{Code: "Server.Timeout"}), true},
+		{"no response", 0, errors.New("connection refused"), true},
+		{"service unavailable", 503, errors.New("503"), true},
+		{"too many requests", 429, errors.New("429"), true},
+		{"internal error", 500, errors.New("500"), false},
+		{"bad request", 400, errors.New("400"), false},
+		{"circuit open", 0, breaker.ErrOpen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.status, tt.err); got != tt.want {
+				t.Fatalf("isRetryable(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	custom := models.RetrySettings{BackoffMs: 100, MaxBackoffMs: 350}
+	tests := []struct {
+		name     string
+		settings models.RetrySettings
+		attempt  int
+		err      error
+		want     time.Duration
+	}{
+		{"default first", models.RetrySettings{}, 1, nil, defaultRetryBackoff},
+		{"default doubled", models.RetrySettings{}, 3, nil, 4 * defaultRetryBackoff},
+		{"default capped", models.RetrySettings{}, 20, nil, defaultRetryMaxBackoff},
+		{"custom first", custom, 1, nil, 100 * time.Millisecond},
+		{"custom second", custom, 2, nil, 200 * time.Millisecond},
+		{"custom capped", custom, 3, nil, 350 * time.Millisecond},
+		{"retry after longer", custom, 1, &adapter.StatusError{StatusCode: 503, RetryAfter: 2 * time.Second}, 2 * time.Second},
+		{"retry after shorter", custom, 2, &adapter.StatusError{StatusCode: 429, RetryAfter: 50 * time.Millisecond}, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryBackoff(tt.settings, tt.attempt, tt.err); got != tt.want {
+				t.Fatalf("retryBackoff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRetriesOnceAfterUnauthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		accepted  string
+		wantErr   bool
+		wantCalls int
+	}{
+		// Токен a1 отозван системой до истечения, a2 принимается
+		{name: "revoked token", accepted: "Bearer a2", wantCalls: 2},
+		{name: "still unauthorized", accepted: "", wantErr: true, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			issued, calls := 0, 0
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				issued++
+				token := fmt.Sprintf(`{"access_token":"a%d","expires_in":3600,"refresh_token":"r%d"}`, issued, issued)
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, token)
+			}))
+			defer tokenServer.Close()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				if tt.accepted == "" || r.Header.Get("Authorization") != tt.accepted {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			defer server.Close()
+
+			repos := newTestRepos(server)
+			authRef := uuid.New()
+			repos.auths[authRef] = &models.ConnectionAuthentication{
+				Ref: authRef, Name: "sap-oauth", Type: models.AuthOAuth2,
+				TokenURL: tokenServer.URL, ClientID: "esb", ClientSecret: "secret",
+			}
+			repos.connections[0].AuthRef = authRef
+			repos.addRoute("/orders", models.RetrySettings{})
+
+			err := repos.send(repos.service())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RouteMessage = %v, wantErr %v", err, tt.wantErr)
+			}
+			var statusErr *adapter.StatusError
+			if tt.wantErr && (!errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized) {
+				t.Fatalf("RouteMessage = %v, want StatusError 401", err)
+			}
+			if calls != tt.wantCalls || issued != 2 {
+				t.Fatalf("requests %d with %d tokens, want %d requests and 2 tokens", calls, issued, tt.wantCalls)
+			}
+			// Выданные refresh token сохранены и переживут перезапуск
+			if len(repos.refreshTokens) != 2 || repos.auths[authRef].RefreshToken != "r2" {
+				t.Fatalf("saved refresh tokens %v, want [r1 r2]", repos.refreshTokens)
+			}
+		})
+	}
+}
+
+// TestReauthenticationFailureDoesNotOpenBreaker проверяет, что ошибка token
+// endpoint при повторной аутентификации не считается отказом системы
+func TestReauthenticationFailureDoesNotOpenBreaker(t *testing.T) {
+	var mu sync.Mutex
+	issued := 0
+	// Второй запрос токена (после 401) завершается ошибкой, следующие успешны
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		issued++
+		if issued == 2 {
+			http.Error(w, "token endpoint unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"a%d","expires_in":3600}`, issued)
+	}))
+	defer tokenServer.Close()
+	// Токен a1 отозван системой
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer a1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	repos := newTestRepos(server)
+	authRef := uuid.New()
+	repos.auths[authRef] = &models.ConnectionAuthentication{
+		Ref: authRef, Name: "sap-oauth", Type: models.AuthOAuth2,
+		TokenURL: tokenServer.URL, ClientID: "esb", ClientSecret: "secret",
+	}
+	repos.connections[0].AuthRef = authRef
+	// Выключатель размыкается после первого отказа
+	repos.connections[0].CircuitBreaker = models.CircuitBreakerSettings{FailureThreshold: 1, OpenSeconds: 60}
+	repos.addRoute("/orders", models.RetrySettings{})
+	svc := repos.service()
+
+	if err := repos.send(svc); err == nil || !strings.Contains(err.Error(), "failed to authenticate") {
+		t.Fatalf("RouteMessage = %v, want authentication error", err)
+	}
+	// Выключатель замкнут: сообщение отправляется с новым токеном
+	if err := repos.send(svc); err != nil {
+		t.Fatalf("RouteMessage after token endpoint failure = %v, want success", err)
+	}
+}
+
+func TestRouteMessageFailsWhenCredentialsCannotBeDecrypted(t *testing.T) {
+	ps, server := newPathServer(nil)
+	defer server.Close()
+
+	// Пароль зашифрован мастер-ключом, которого нет в загруженном keyring
+	// (например, предыдущий ключ не передан после ротации)
+	oldKeyring, err := encryption.NewKeyring(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := oldKeyring.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := newTestRepos(server)
+	if repos.keyring, err = encryption.NewKeyring(bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	authRef := uuid.New()
+	repos.auths[authRef] = &models.ConnectionAuthentication{
+		Ref: authRef, Name: "sap-basic", Type: models.AuthBasic, Username: "esb", Password: password,
+	}
+	repos.connections[0].AuthRef = authRef
+	repos.addRoute("/orders", models.RetrySettings{})
+
+	err = repos.send(repos.service())
+	if !errors.Is(err, encryption.ErrUnknownKey) {
+		t.Fatalf("RouteMessage = %v, want ErrUnknownKey", err)
+	}
+	// Запрос без учетных данных не отправляется
+	if ps.count("/orders") != 0 {
+		t.Fatalf("requests = %d, want 0", ps.count("/orders"))
+	}
+}
+
+func TestRouteMessageSpansAndTraceparent(t *testing.T) {
+	for _, protocol := range []models.ProtocolType{models.ProtocolREST, models.ProtocolSOAP} {
+		t.Run(string(protocol), func(t *testing.T) {
+			recorder := recordSpans(t)
+			traceparents := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				traceparents <- r.Header.Get("traceparent")
+			}))
+			defer server.Close()
+			repos := newTestRepos(server)
+			repos.group.Protocol = protocol
+			repos.addRoute("/orders", models.RetrySettings{})
+
+			// Span сервера, как его создает middleware.Tracing для запроса API
+			ctx, handlerSpan := tracing.Start(context.Background(), "POST /api/v1/messages/process/{threadId}", trace.WithSpanKind(trace.SpanKindServer))
+			_, err := repos.service().RouteMessage(ctx, repos.thread.Ref, models.DirectionOut,
+				&models.Message{Data: []byte(`{"order":1}`), Format: models.FileFormatJSON})
+			handlerSpan.End()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			spans := make(map[string]sdktrace.ReadOnlySpan)
+			for _, span := range recorder.Ended() {
+				spans[span.Name()] = span
+			}
+			sendName := string(protocol) + " send"
+			for _, link := range []struct{ child, parent string }{
+				{"RouteMessage", "POST /api/v1/messages/process/{threadId}"},
+				{"processRoute", "RouteMessage"},
+				{sendName, "processRoute"},
+			} {
+				child, parent := spans[link.child], spans[link.parent]
+				if child == nil || parent == nil {
+					t.Fatalf("spans %s and %s not recorded", link.child, link.parent)
+				}
+				if child.Parent().SpanID() != parent.SpanContext().SpanID() || child.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+					t.Fatalf("span %s has parent %s, want %s", link.child, child.Parent().SpanID(), parent.SpanContext().SpanID())
+				}
+			}
+
+			send := spans[sendName].SpanContext()
+			want := "00-" + send.TraceID().String() + "-" + send.SpanID().String() + "-01"
+			if got := <-traceparents; got != want {
+				t.Fatalf("outbound traceparent = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// fakeAdapter отвечает заданным ответом на любой протокол и запоминает
+// отправленное тело
+type fakeAdapter struct {
+	body   []byte
+	status int
+	err    error
+	sent   []byte
+}
+
+func (a *fakeAdapter) Send(_ context.Context, _, _ string, _ map[string]string, body []byte) ([]byte, int, error) {
+	a.sent = body
+	return a.body, a.status, a.err
+}
+
+func (a *fakeAdapter) Authenticate(context.Context, *models.ConnectionAuthentication, string) (map[string]string, error) {
+	return nil, nil
+}
+
+func (a *fakeAdapter) GetAdapter(models.ProtocolType) (adapter.ProtocolAdapter, error) {
+	return a, nil
+}
+
+func TestRouteMessageReturnsConvertedResponse(t *testing.T) {
+	tests := []struct {
+		name            string
+		requestResponse bool
+		adapter         *fakeAdapter
+		wantErr         bool
+		wantStatus      int
+		wantData        map[string]interface{}
+	}{
+		{
+			name:            "converted to the inbound format",
+			requestResponse: true,
+			adapter:         &fakeAdapter{body: []byte("DocumentNumber: \"4500012345\"\n"), status: http.StatusCreated},
+			wantStatus:      http.StatusCreated,
+			wantData:        map[string]interface{}{"DocumentNumber": "4500012345"},
+		},
+		{
+			name:            "error response relayed with its status",
+			requestResponse: true,
+			adapter: &fakeAdapter{body: []byte("error: duplicate order\n"), status: http.StatusUnprocessableEntity,
+				err: &adapter.StatusError{Kind: "HTTP", StatusCode: http.StatusUnprocessableEntity}},
+			wantErr:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantData:   map[string]interface{}{"error": "duplicate order"},
+		},
+		{
+			name:    "without request/response mode",
+			adapter: &fakeAdapter{body: []byte("DocumentNumber: \"4500012345\"\n"), status: http.StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.NotFoundHandler())
+			defer server.Close()
+			repos := newTestRepos(server)
+			repos.thread.RequestResponse = tt.requestResponse
+			repos.addRoute("/orders", models.RetrySettings{})
+			repos.threadRoute[models.DirectionOut][0].FileFormat = models.FileFormatYAML
+			svc := repos.service()
+			svc.(*messageService).adapterFactory = tt.adapter
+
+			response, err := svc.RouteMessage(context.Background(), repos.thread.Ref, models.DirectionOut,
+				&models.Message{Data: []byte(`{"order":1}`), Format: models.FileFormatJSON})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RouteMessage = %v, wantErr %v", err, tt.wantErr)
+			}
+			// Сообщение отправлено в формате маршрута
+			if string(tt.adapter.sent) != "order: 1\n" {
+				t.Errorf("sent %q, want YAML of the message", tt.adapter.sent)
+			}
+			if tt.wantData == nil {
+				if response != nil {
+					t.Fatalf("response = %+v, want nil", response)
+				}
+				return
+			}
+
+			if response == nil {
+				t.Fatal("response = nil")
+			}
+			if response.StatusCode != tt.wantStatus || response.Format != models.FileFormatJSON {
+				t.Fatalf("response status %d, format %s; want %d, JSON", response.StatusCode, response.Format, tt.wantStatus)
+			}
+			var data map[string]interface{}
+			if err := json.Unmarshal(response.Data, &data); err != nil {
+				t.Fatalf("response %q is not JSON: %v", response.Data, err)
+			}
+			if !reflect.DeepEqual(data, tt.wantData) {
+				t.Fatalf("response = %v, want %v", data, tt.wantData)
+			}
+		})
+	}
+}
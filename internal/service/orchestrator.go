@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
+	"go-esb/internal/auth"
+	"go-esb/internal/logging"
+	"go-esb/internal/metrics"
 	"go-esb/internal/models"
+	"go-esb/internal/placeholder"
 	"go-esb/internal/repository"
+	"go-esb/internal/tracing"
 
 	"github.com/google/uuid"
 )
@@ -48,24 +52,38 @@ type OrderProcessingFlow struct {
 	StripePaymentData map[string]interface{} `json:"stripe_payment_data"`
 	SAPOrderData      map[string]interface{} `json:"sap_order_data"`
 	SalesforceData    map[string]interface{} `json:"salesforce_data"`
+	// SAPResponse ответ SAP (если thread SAP работает в режиме запрос-ответ)
+	SAPResponse map[string]interface{} `json:"sap_response,omitempty"`
 }
 
 // ExecuteProcess выполняет бизнес-процесс
 func (o *orchestrator) ExecuteProcess(ctx context.Context, processName string, initialData []byte) error {
-	log.Printf("🎯 Starting process: %s", processName)
-
+	var flow func(ctx context.Context, data []byte) error
 	switch processName {
 	case "order_payment_flow":
-		return o.orderPaymentFlow(ctx, initialData)
+		flow = o.orderPaymentFlow
 	default:
 		return fmt.Errorf("unknown process: %s", processName)
 	}
+
+	if logging.CorrelationID(ctx) == "" {
+		ctx = logging.WithCorrelationID(ctx, uuid.NewString())
+	}
+	ctx = logging.WithFields(ctx, "process", processName)
+	logger.InfoContext(ctx, "🎯 Starting process")
+
+	ctx, span := tracing.Start(ctx, "process "+processName)
+	start := time.Now()
+	err := flow(ctx, initialData)
+	metrics.ObserveOrchestration(processName, err, time.Since(start))
+	tracing.End(span, err)
+	return err
 }
 
 // orderPaymentFlow реализует поток: Stripe → SAP → Salesforce
 func (o *orchestrator) orderPaymentFlow(ctx context.Context, stripeData []byte) error {
 	flowStartTime := time.Now()
-	log.Printf("🔄 Order Payment Flow started")
+	logger.InfoContext(ctx, "🔄 Order Payment Flow started")
 
 	flow := &OrderProcessingFlow{}
 
@@ -74,7 +92,10 @@ func (o *orchestrator) orderPaymentFlow(ctx context.Context, stripeData []byte)
 		return fmt.Errorf("failed to parse Stripe data: %w", err)
 	}
 
-	log.Printf("📥 Received payment data from Stripe: %+v", flow.StripePaymentData)
+	logger.DebugContext(ctx, "📥 Received payment data from Stripe", "data", flow.StripePaymentData)
+
+	// Поля платежа доступны в шаблонах маршрутов как {ctx.order_id}, {ctx.customer_id} и т.д.
+	ctx = placeholder.WithVars(ctx, processVars("", flow.StripePaymentData))
 
 	// Шаг 2: Находим thread для отправки в SAP
 	// Предполагаем, что thread ID известен (можно получить из конфигурации)
@@ -109,13 +130,8 @@ func (o *orchestrator) orderPaymentFlow(ctx context.Context, stripeData []byte)
 		return fmt.Errorf("failed to marshal SAP data: %w", err)
 	}
 
-	// Шаг 4: Отправляем в SAP через thread (нужно найти thread для SAP)
-	// Пока используем прямую отправку через MessageService
-	// В реальном сценарии нужно найти thread по конфигурации
-	
-	// Временно: создаем контекст с таймаутом для SAP запроса
-	sapCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	// Шаг 4: Отправляем в SAP через thread. Время ожидания ответа задается
+	// таймаутом маршрута (thread_routes.timeout_ms) и http_client подключения SAP
 
 	// Ищем thread для отправки в SAP
 	// Для примера создадим логику поиска thread
@@ -124,50 +140,127 @@ func (o *orchestrator) orderPaymentFlow(ctx context.Context, stripeData []byte)
 		return fmt.Errorf("failed to find SAP thread: %w", err)
 	}
 
-	log.Printf("📤 Sending to SAP via thread: %s", threadID)
-	if err := o.messageService.RouteMessage(sapCtx, threadID, models.DirectionOut, sapData); err != nil {
+	// Thread Salesforce ищется до отправки в SAP: права пользователя
+	// проверяются для всех threads процесса до первой отправки
+	var salesforceThreadID uuid.UUID
+	if salesforceSystemID != uuid.Nil {
+		salesforceThreadID, err = o.findThreadForSystem(ctx, salesforceSystemID, models.DirectionOut)
+		if err != nil {
+			return fmt.Errorf("failed to find Salesforce thread: %w", err)
+		}
+	}
+	if err := o.authorize(ctx, threadID, salesforceThreadID); err != nil {
+		return err
+	}
+
+	logger.InfoContext(ctx, "📤 Sending to SAP", "thread_id", threadID.String())
+	stepStart := time.Now()
+	sapResponse, err := o.messageService.RouteMessage(ctx, threadID, models.DirectionOut, &models.Message{Data: sapData, Format: models.FileFormatJSON})
+	metrics.ObserveOrchestrationStep("order_payment_flow", "sap", err, time.Since(stepStart))
+	if err != nil {
 		return fmt.Errorf("failed to send to SAP: %w", err)
 	}
 
-	log.Printf("✅ SAP confirmed order update")
+	logger.InfoContext(ctx, "✅ SAP confirmed order update")
+
+	// Поля ответа SAP доступны следующим шагам как {ctx.sap.DocumentNumber} и т.д.
+	if sapResponse != nil {
+		flow.SAPResponse = responseFields(ctx, sapResponse)
+		ctx = placeholder.WithVars(ctx, processVars("sap.", flow.SAPResponse))
+	}
 
 	// Шаг 5: После подтверждения SAP отправляем в Salesforce
 	if salesforceSystemID == uuid.Nil {
-		log.Printf("⚠️ Salesforce system not found, skipping")
+		logger.WarnContext(ctx, "⚠️ Salesforce system not found, skipping")
 		return nil
 	}
 
 	// Преобразуем данные для Salesforce
-	flow.SalesforceData = o.transformSAPToSalesforce(flow.SAPOrderData)
+	flow.SalesforceData = o.transformSAPToSalesforce(flow.SAPOrderData, flow.SAPResponse)
 	salesforceData, err := json.Marshal(flow.SalesforceData)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Salesforce data: %w", err)
 	}
 
-	// Ищем thread для Salesforce
-	salesforceThreadID, err := o.findThreadForSystem(ctx, salesforceSystemID, models.DirectionOut)
+	logger.InfoContext(ctx, "📤 Sending to Salesforce", "thread_id", salesforceThreadID.String())
+	stepStart = time.Now()
+	_, err = o.messageService.RouteMessage(ctx, salesforceThreadID, models.DirectionOut, &models.Message{Data: salesforceData, Format: models.FileFormatJSON})
+	metrics.ObserveOrchestrationStep("order_payment_flow", "salesforce", err, time.Since(stepStart))
 	if err != nil {
-		return fmt.Errorf("failed to find Salesforce thread: %w", err)
-	}
-
-	salesforceCtx, cancel2 := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel2()
-
-	log.Printf("📤 Sending to Salesforce via thread: %s", salesforceThreadID)
-	if err := o.messageService.RouteMessage(salesforceCtx, salesforceThreadID, models.DirectionOut, salesforceData); err != nil {
 		return fmt.Errorf("failed to send to Salesforce: %w", err)
 	}
 
 	duration := time.Since(flowStartTime)
-	log.Printf("🎉 Order Payment Flow completed in %v", duration)
+	logger.InfoContext(ctx, "🎉 Order Payment Flow completed", "duration_ms", duration.Milliseconds())
 
 	if duration > 5*time.Second {
-		log.Printf("⚠️ Flow took longer than 5 seconds target")
+		logger.WarnContext(ctx, "⚠️ Flow took longer than 5 seconds target", "duration_ms", duration.Milliseconds())
+		metrics.OrchestrationTargetBreaches.WithLabelValues("order_payment_flow").Inc()
 	}
 
 	return nil
 }
 
+// authorize проверяет право отправки пользователя запроса в threads процесса
+// с учетом его ролей в группах threads, как при отправке сообщения в thread.
+// Процессы, запущенные без пользователя в контексте, не проверяются.
+func (o *orchestrator) authorize(ctx context.Context, threadIDs ...uuid.UUID) error {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil || principal.Can(auth.PermSend) {
+		return nil
+	}
+	for _, threadID := range threadIDs {
+		if threadID == uuid.Nil {
+			continue
+		}
+		thread, _, err := o.threadRouteRepo.GetThreadWithGroup(ctx, threadID)
+		if err != nil {
+			return fmt.Errorf("failed to get thread %s: %w", threadID, err)
+		}
+		if !principal.CanInGroup(thread.Group, auth.PermSend) {
+			return &auth.PermissionError{Username: principal.Username, Permission: auth.PermSend, Thread: threadID}
+		}
+	}
+	return nil
+}
+
+// processVars возвращает скалярные поля данных процесса как переменные шаблонов.
+// Вложенные объекты разворачиваются в имена через точку, массивы пропускаются.
+func processVars(prefix string, data map[string]interface{}) map[string]string {
+	vars := make(map[string]string, len(data))
+	for key, value := range data {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for nestedKey, nestedValue := range processVars(prefix+key+".", v) {
+				vars[nestedKey] = nestedValue
+			}
+		case []interface{}:
+			continue
+		default:
+			vars[prefix+key] = placeholder.Format(v)
+		}
+	}
+	return vars
+}
+
+// responseFields разбирает JSON-ответ целевой системы. Обертка из единственного
+// элемента (например, OrderCreateResponse в ответе SOAP) снимается.
+func responseFields(ctx context.Context, response *models.Response) map[string]interface{} {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(response.Data, &fields); err != nil {
+		logger.WarnContext(ctx, "⚠️ Response is not a JSON object, skipping", "error", err)
+		return nil
+	}
+	if len(fields) == 1 {
+		for _, value := range fields {
+			if inner, ok := value.(map[string]interface{}); ok {
+				return inner
+			}
+		}
+	}
+	return fields
+}
+
 // transformStripeToSAP преобразует данные Stripe в формат SAP
 func (o *orchestrator) transformStripeToSAP(stripeData map[string]interface{}) map[string]interface{} {
 	sapData := make(map[string]interface{})
@@ -196,7 +289,7 @@ func (o *orchestrator) transformStripeToSAP(stripeData map[string]interface{}) m
 }
 
 // transformSAPToSalesforce преобразует данные SAP в формат Salesforce
-func (o *orchestrator) transformSAPToSalesforce(sapData map[string]interface{}) map[string]interface{} {
+func (o *orchestrator) transformSAPToSalesforce(sapData, sapResponse map[string]interface{}) map[string]interface{} {
 	salesforceData := make(map[string]interface{})
 	
 	if orderNum, ok := sapData["OrderNumber"].(string); ok {
@@ -219,6 +312,10 @@ func (o *orchestrator) transformSAPToSalesforce(sapData map[string]interface{})
 	if customerID, ok := sapData["CustomerID"].(string); ok {
 		salesforceData["AccountId"] = customerID
 	}
+	// Номер документа, присвоенный SAP
+	if docNumber, ok := sapResponse["DocumentNumber"]; ok {
+		salesforceData["SAPDocumentNumber"] = placeholder.Format(docNumber)
+	}
 
 	salesforceData["LastModifiedDate"] = time.Now().Format(time.RFC3339)
 
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go-esb/internal/auth"
+	"go-esb/internal/models"
+	"go-esb/internal/placeholder"
+	"go-esb/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// processSystems хранит системы процесса, thread исходящего маршрута каждой
+// и группы threads
+type processSystems struct {
+	systems []models.System
+	threads map[uuid.UUID]uuid.UUID
+	groups  map[uuid.UUID]uuid.UUID
+}
+
+type (
+	processSystemRepo struct {
+		repository.SystemRepository
+		*processSystems
+	}
+	processRouteRepo struct {
+		repository.RouteRepository
+		*processSystems
+	}
+	processThreadRouteRepo struct {
+		repository.ThreadRouteRepository
+		*processSystems
+	}
+)
+
+func (r processSystemRepo) GetAll(context.Context) ([]models.System, error) {
+	return r.systems, nil
+}
+
+// GetBySystem возвращает маршрут с ref системы, чтобы по нему найти thread
+func (r processRouteRepo) GetBySystem(_ context.Context, systemID uuid.UUID) ([]models.Route, error) {
+	return []models.Route{{Ref: systemID, System: systemID}}, nil
+}
+
+func (r processThreadRouteRepo) GetThreadRouteByRouteID(_ context.Context, routeID uuid.UUID) (*models.ThreadRoute, error) {
+	thread, ok := r.threads[routeID]
+	if !ok {
+		return nil, errors.New("thread route not found")
+	}
+	return &models.ThreadRoute{Thread: thread, Route: routeID, Direction: models.DirectionOut}, nil
+}
+
+func (r processThreadRouteRepo) GetThreadWithGroup(_ context.Context, threadID uuid.UUID) (*models.Thread, *models.ThreadGroup, error) {
+	group := r.groups[threadID]
+	return &models.Thread{Ref: threadID, Group: group}, &models.ThreadGroup{Ref: group}, nil
+}
+
+// routedMessage сообщение, отправленное оркестратором, с переменными шаблонов
+type routedMessage struct {
+	threadID uuid.UUID
+	data     map[string]interface{}
+	vars     map[string]string
+}
+
+// recordingMessageService запоминает отправленные сообщения и отвечает
+// заданным ответом для thread
+type recordingMessageService struct {
+	responses map[uuid.UUID]*models.Response
+	sent      []routedMessage
+}
+
+func (s *recordingMessageService) ProcessMessage(context.Context, string, models.Directions, *models.Message) (*models.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *recordingMessageService) RouteMessage(ctx context.Context, threadID uuid.UUID, _ models.Directions, msg *models.Message) (*models.Response, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return nil, err
+	}
+	s.sent = append(s.sent, routedMessage{threadID: threadID, data: data, vars: placeholder.VarsFromContext(ctx)})
+	return s.responses[threadID], nil
+}
+
+// TestOrderPaymentFlowBindsSAPResponse проверяет, что поля ответа SAP
+// передаются в данные Salesforce и в переменные шаблонов следующего шага
+func TestOrderPaymentFlowBindsSAPResponse(t *testing.T) {
+	sap, salesforce := uuid.New(), uuid.New()
+	sapThread, salesforceThread := uuid.New(), uuid.New()
+	systems := &processSystems{
+		systems: []models.System{{Ref: sap, Name: "SAP"}, {Ref: salesforce, Name: "Salesforce"}},
+		threads: map[uuid.UUID]uuid.UUID{sap: sapThread, salesforce: salesforceThread},
+	}
+	messages := &recordingMessageService{responses: map[uuid.UUID]*models.Response{
+		sapThread: {
+			Message: models.Message{
+				Data:   []byte(`{"OrderCreateResponse":{"DocumentNumber":"4500012345","Status":"Created"}}`),
+				Format: models.FileFormatJSON,
+			},
+			StatusCode: 200,
+		},
+	}}
+	o := NewOrchestrator(messages, processThreadRouteRepo{processSystems: systems},
+		processRouteRepo{processSystems: systems}, nil, processSystemRepo{processSystems: systems})
+
+	payment := []byte(`{"order_id":"ORD-1","amount":12550,"currency":"usd","status":"succeeded","customer_id":"cus_1"}`)
+	if err := o.ExecuteProcess(context.Background(), "order_payment_flow", payment); err != nil {
+		t.Fatalf("ExecuteProcess: %v", err)
+	}
+
+	if len(messages.sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(messages.sent))
+	}
+	sapMessage, salesforceMessage := messages.sent[0], messages.sent[1]
+	if sapMessage.threadID != sapThread || salesforceMessage.threadID != salesforceThread {
+		t.Fatalf("threads = %s, %s; want SAP then Salesforce", sapMessage.threadID, salesforceMessage.threadID)
+	}
+	if sapMessage.vars["order_id"] != "ORD-1" {
+		t.Errorf("SAP step var order_id = %q, want ORD-1", sapMessage.vars["order_id"])
+	}
+	if _, ok := sapMessage.vars["sap.DocumentNumber"]; ok {
+		t.Error("SAP step sees sap.DocumentNumber before the response")
+	}
+
+	if got := salesforceMessage.data["SAPDocumentNumber"]; got != "4500012345" {
+		t.Errorf("Salesforce SAPDocumentNumber = %v, want 4500012345", got)
+	}
+	if got := salesforceMessage.data["Status"]; got != "Paid" {
+		t.Errorf("Salesforce Status = %v, want Paid", got)
+	}
+	for name, want := range map[string]string{"sap.DocumentNumber": "4500012345", "sap.Status": "Created", "order_id": "ORD-1"} {
+		if got := salesforceMessage.vars[name]; got != want {
+			t.Errorf("Salesforce step var %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestOrderPaymentFlowChecksThreadGroups проверяет право отправки во все threads
+// процесса с учетом ролей пользователя в группах до первой отправки
+func TestOrderPaymentFlowChecksThreadGroups(t *testing.T) {
+	sap, salesforce := uuid.New(), uuid.New()
+	sapThread, salesforceThread := uuid.New(), uuid.New()
+	sapGroup, salesforceGroup := uuid.New(), uuid.New()
+	systems := &processSystems{
+		systems: []models.System{{Ref: sap, Name: "SAP"}, {Ref: salesforce, Name: "Salesforce"}},
+		threads: map[uuid.UUID]uuid.UUID{sap: sapThread, salesforce: salesforceThread},
+		groups:  map[uuid.UUID]uuid.UUID{sapThread: sapGroup, salesforceThread: salesforceGroup},
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		// denied thread без права отправки; uuid.Nil — процесс выполняется
+		denied uuid.UUID
+	}{
+		{"without user", nil, uuid.Nil},
+		{"operator role", &auth.Principal{Username: "operator", Role: models.RoleOperator}, uuid.Nil},
+		{"operator in both groups", &auth.Principal{Username: "partner", Role: models.RoleReadOnly,
+			GroupRoles: map[uuid.UUID]models.UserRole{sapGroup: models.RoleOperator, salesforceGroup: models.RoleIntegrator}}, uuid.Nil},
+		{"operator in SAP group only", &auth.Principal{Username: "partner", Role: models.RoleReadOnly,
+			GroupRoles: map[uuid.UUID]models.UserRole{sapGroup: models.RoleOperator}}, salesforceThread},
+		{"read only in groups", &auth.Principal{Username: "viewer", Role: models.RoleReadOnly,
+			GroupRoles: map[uuid.UUID]models.UserRole{sapGroup: models.RoleReadOnly, salesforceGroup: models.RoleReadOnly}}, sapThread},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := &recordingMessageService{}
+			o := NewOrchestrator(messages, processThreadRouteRepo{processSystems: systems},
+				processRouteRepo{processSystems: systems}, nil, processSystemRepo{processSystems: systems})
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tt.principal)
+			}
+
+			err := o.ExecuteProcess(ctx, "order_payment_flow", []byte(`{"order_id":"ORD-1"}`))
+			if tt.denied == uuid.Nil {
+				if err != nil {
+					t.Fatalf("ExecuteProcess: %v", err)
+				}
+				if len(messages.sent) != 2 {
+					t.Fatalf("sent %d messages, want 2", len(messages.sent))
+				}
+				return
+			}
+
+			var permissionErr *auth.PermissionError
+			if !errors.As(err, &permissionErr) || permissionErr.Thread != tt.denied {
+				t.Fatalf("ExecuteProcess error = %v, want permission error for thread %s", err, tt.denied)
+			}
+			if len(messages.sent) != 0 {
+				t.Fatalf("sent %d messages before the permission check", len(messages.sent))
+			}
+		})
+	}
+}
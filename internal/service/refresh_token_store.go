@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+	"go-esb/internal/secrets"
+)
+
+// refreshTokenStore сохраняет refresh token OAuth2 после ротации в записи
+// аутентификации подключения
+type refreshTokenStore struct {
+	connectionRepo repository.ConnectionRepository
+	secrets        *secrets.Resolver
+}
+
+// SaveRefreshToken заменяет сохраненный refresh token новым. Токен, заданный
+// ссылкой на секрет (env:, file:, vault:), не перезаписывается: его
+// обновляет владелец хранилища секретов.
+func (s *refreshTokenStore) SaveRefreshToken(ctx context.Context, auth *models.ConnectionAuthentication, refreshToken string) error {
+	stored, err := s.connectionRepo.GetConnectionAuth(ctx, auth.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to get authentication: %w", err)
+	}
+	if s.secrets.IsReference(stored.RefreshToken) {
+		logger.WarnContext(ctx, "⚠️ OAuth2 refresh token was rotated but is a secret reference, update the secret", "auth", auth.Name)
+		return nil
+	}
+	if err := s.connectionRepo.UpdateRefreshToken(ctx, auth.Ref, refreshToken); err != nil {
+		return fmt.Errorf("failed to update refresh token: %w", err)
+	}
+	logger.InfoContext(ctx, "🔑 OAuth2 refresh token rotated and saved", "auth", auth.Name)
+	return nil
+}
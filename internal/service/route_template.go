@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-esb/internal/placeholder"
+	"go-esb/internal/repository"
+)
+
+// routeTemplate разрешает плейсхолдеры маршрута для одного сообщения.
+// Сообщение конвертируется в JSON только при первом обращении к его полям.
+type routeTemplate struct {
+	ctx     context.Context
+	globals repository.GlobalRepository
+	toJSON  func() ([]byte, error)
+
+	message interface{}
+	parsed  bool
+}
+
+// resolve возвращает значение выражения: global.<имя>, ctx.<имя>,
+// JSONPath ($.a.b) или имя поля верхнего уровня сообщения
+func (t *routeTemplate) resolve(expr string) (string, error) {
+	switch {
+	case strings.HasPrefix(expr, "global."):
+		name := strings.TrimPrefix(expr, "global.")
+		value, err := t.globals.Get(t.ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("global %s not found", name)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get global %s: %w", name, err)
+		}
+		return placeholder.Format(value), nil
+
+	case strings.HasPrefix(expr, "ctx."):
+		name := strings.TrimPrefix(expr, "ctx.")
+		value, ok := placeholder.VarsFromContext(t.ctx)[name]
+		if !ok {
+			return "", fmt.Errorf("context variable %s is not set", name)
+		}
+		return value, nil
+	}
+
+	if !strings.HasPrefix(expr, "$") {
+		expr = "$." + expr
+	}
+	if !t.parsed {
+		data, err := t.toJSON()
+		if err != nil {
+			return "", fmt.Errorf("failed to convert message to JSON: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.message); err != nil {
+			return "", fmt.Errorf("failed to parse message: %w", err)
+		}
+		t.parsed = true
+	}
+	value, err := placeholder.Lookup(t.message, expr)
+	if err != nil {
+		return "", err
+	}
+	return placeholder.Format(value), nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-esb/internal/converter"
+	"go-esb/internal/models"
+	"go-esb/internal/repository"
+)
+
+type ThreadRouteService interface {
+	SetProtoSchema(ctx context.Context, threadID, routeID string, direction models.Directions, descriptor []byte, message string) error
+}
+
+type threadRouteService struct {
+	repo repository.ThreadRouteRepository
+}
+
+func NewThreadRouteService(repo repository.ThreadRouteRepository) ThreadRouteService {
+	return &threadRouteService{repo: repo}
+}
+
+// SetProtoSchema сохраняет FileDescriptorSet и имя сообщения Protobuf для маршрута thread
+func (s *threadRouteService) SetProtoSchema(ctx context.Context, threadID, routeID string, direction models.Directions, descriptor []byte, message string) error {
+	if direction != models.DirectionIn && direction != models.DirectionOut {
+		return fmt.Errorf("invalid direction: %s", direction)
+	}
+
+	thrID, err := parseUUID(threadID)
+	if err != nil {
+		return err
+	}
+	rtID, err := parseUUID(routeID)
+	if err != nil {
+		return err
+	}
+
+	schema := converter.ProtoSchema{DescriptorSet: descriptor, Message: message}
+	if err := converter.ValidateProtoSchema(schema); err != nil {
+		return err
+	}
+
+	err = s.repo.SetProtoSchema(ctx, thrID, direction, rtID, descriptor, message)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("thread route not found")
+	}
+	return err
+}
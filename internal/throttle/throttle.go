@@ -0,0 +1,238 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go-esb/internal/metrics"
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+// DefaultBackoff пауза после ответа 429 без заголовка Retry-After
+const DefaultBackoff = time.Second
+
+// ErrLimited возвращается, если запрос не дождался разрешения ограничителя
+var ErrLimited = errors.New("outbound limit exceeded")
+
+// Причины отказа в отправке
+const (
+	ReasonRate        = "rate"
+	ReasonConcurrency = "concurrency"
+	ReasonBackoff     = "backoff"
+)
+
+// LimitError отказ в отправке запроса в систему из-за ограничений
+type LimitError struct {
+	System     string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v for %s (%s), retry after %s", ErrLimited, e.System, e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrLimited)
+func (e *LimitError) Unwrap() error {
+	return ErrLimited
+}
+
+// Limiter ограничивает исходящие запросы подключения: частоту (token bucket)
+// и число одновременных запросов. Запрос ждет разрешения не дольше max_wait_ms
+// и дедлайна контекста; если ожидание дольше, он сразу отклоняется.
+// После ответа 429 запросы приостанавливаются на время Retry-After.
+type Limiter struct {
+	mu          sync.Mutex
+	name        string
+	system      string
+	maxWait     time.Duration
+	rate        *rate.Limiter
+	inFlight    chan struct{}
+	pausedUntil time.Time
+}
+
+// Acquire ждет разрешения на отправку запроса. Функция release освобождает
+// место одновременного запроса и вызывается после получения ответа.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	start := time.Now()
+	l.mu.Lock()
+	system, name := l.system, l.name
+	deadline := start.Add(l.maxWait)
+	pausedUntil, rateLimiter, inFlight := l.pausedUntil, l.rate, l.inFlight
+	l.mu.Unlock()
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	defer func() {
+		if err == nil {
+			metrics.ThrottleWait.WithLabelValues(system, name).Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	// Система попросила подождать (429 Retry-After)
+	if wait := pausedUntil.Sub(start); wait > 0 {
+		if pausedUntil.After(deadline) {
+			return nil, l.reject(ReasonBackoff, wait)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+
+	if rateLimiter != nil {
+		reservation := rateLimiter.Reserve()
+		if !reservation.OK() {
+			return nil, l.reject(ReasonRate, time.Second)
+		}
+		if delay := reservation.Delay(); delay > 0 {
+			if time.Now().Add(delay).After(deadline) {
+				reservation.Cancel()
+				return nil, l.reject(ReasonRate, delay)
+			}
+			if err := sleep(ctx, delay); err != nil {
+				reservation.Cancel()
+				return nil, err
+			}
+		}
+	}
+
+	if inFlight == nil {
+		return func() {}, nil
+	}
+	if err := l.acquireSlot(ctx, inFlight, deadline); err != nil {
+		return nil, err
+	}
+	metrics.ThrottleInFlight.WithLabelValues(system, name).Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-inFlight
+			metrics.ThrottleInFlight.WithLabelValues(system, name).Dec()
+		})
+	}, nil
+}
+
+func (l *Limiter) acquireSlot(ctx context.Context, inFlight chan struct{}, deadline time.Time) error {
+	select {
+	case inFlight <- struct{}{}:
+		return nil
+	default:
+	}
+
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return l.reject(ReasonConcurrency, time.Second)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case inFlight <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return l.reject(ReasonConcurrency, time.Second)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Backoff приостанавливает отправку после ответа 429 на время Retry-After
+func (l *Limiter) Backoff(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = DefaultBackoff
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *Limiter) reject(reason string, retryAfter time.Duration) error {
+	l.mu.Lock()
+	system, name := l.system, l.name
+	l.mu.Unlock()
+	metrics.ThrottleRejections.WithLabelValues(system, name, reason).Inc()
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &LimitError{System: system, Reason: reason, RetryAfter: retryAfter}
+}
+
+// configure применяет параметры подключения. Изменение max_in_flight создает
+// новый семафор: запросы, отправленные до изменения, освобождают старый.
+func (l *Limiter) configure(settings models.RateLimitSettings) {
+	l.maxWait = time.Duration(settings.MaxWaitMs) * time.Millisecond
+
+	if settings.RequestsPerSecond > 0 {
+		burst := settings.Burst
+		if burst <= 0 {
+			burst = int(math.Max(1, math.Ceil(settings.RequestsPerSecond)))
+		}
+		limit := rate.Limit(settings.RequestsPerSecond)
+		if l.rate == nil {
+			l.rate = rate.NewLimiter(limit, burst)
+		} else {
+			if l.rate.Limit() != limit {
+				l.rate.SetLimit(limit)
+			}
+			if l.rate.Burst() != burst {
+				l.rate.SetBurst(burst)
+			}
+		}
+	} else {
+		l.rate = nil
+	}
+
+	switch {
+	case settings.MaxInFlight <= 0:
+		l.inFlight = nil
+	case l.inFlight == nil || cap(l.inFlight) != settings.MaxInFlight:
+		l.inFlight = make(chan struct{}, settings.MaxInFlight)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Registry хранит ограничители подключений к системам
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[uuid.UUID]*Limiter
+}
+
+// NewRegistry создает реестр ограничителей
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[uuid.UUID]*Limiter)}
+}
+
+// Get возвращает ограничитель подключения с текущими параметрами connection_settings
+func (r *Registry) Get(connection *models.ConnectionSetting, system string) *Limiter {
+	r.mu.Lock()
+	l, ok := r.limiters[connection.Ref]
+	if !ok {
+		l = &Limiter{}
+		r.limiters[connection.Ref] = l
+	}
+	r.mu.Unlock()
+
+	l.mu.Lock()
+	l.name, l.system = connection.Name, system
+	l.configure(connection.RateLimit)
+	l.mu.Unlock()
+	return l
+}
@@ -0,0 +1,176 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-esb/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func newTestLimiter(settings models.RateLimitSettings) (*Registry, *models.ConnectionSetting, *Limiter) {
+	registry := NewRegistry()
+	connection := &models.ConnectionSetting{Ref: uuid.New(), Name: "primary", RateLimit: settings}
+	return registry, connection, registry.Get(connection, "sap")
+}
+
+func mustAcquire(t *testing.T, l *Limiter) func() {
+	t.Helper()
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	return release
+}
+
+func expectLimited(t *testing.T, err error, reason string) {
+	t.Helper()
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimited) {
+		t.Fatalf("Acquire = %v, want *LimitError", err)
+	}
+	if limitErr.Reason != reason || limitErr.System != "sap" || limitErr.RetryAfter < time.Second {
+		t.Fatalf("LimitError = %+v, want reason %s for sap with retry after >= 1s", limitErr, reason)
+	}
+}
+
+func TestLimiterWithoutLimits(t *testing.T) {
+	_, _, l := newTestLimiter(models.RateLimitSettings{})
+	for i := 0; i < 100; i++ {
+		mustAcquire(t, l)()
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	t.Run("rejects without waiting", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{RequestsPerSecond: 1, Burst: 2})
+		mustAcquire(t, l)()
+		mustAcquire(t, l)()
+		_, err := l.Acquire(context.Background())
+		expectLimited(t, err, ReasonRate)
+	})
+
+	t.Run("waits for token", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{RequestsPerSecond: 20, Burst: 1, MaxWaitMs: 1000})
+		mustAcquire(t, l)()
+		start := time.Now()
+		mustAcquire(t, l)()
+		if waited := time.Since(start); waited < 30*time.Millisecond {
+			t.Fatalf("second request waited %s, want about 50ms", waited)
+		}
+	})
+
+	t.Run("wait longer than max wait", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{RequestsPerSecond: 1, Burst: 1, MaxWaitMs: 100})
+		mustAcquire(t, l)()
+		start := time.Now()
+		_, err := l.Acquire(context.Background())
+		expectLimited(t, err, ReasonRate)
+		if time.Since(start) > 50*time.Millisecond {
+			t.Fatalf("rejected after %s, want immediate rejection", time.Since(start))
+		}
+	})
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	t.Run("rejects without waiting", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{MaxInFlight: 1})
+		release := mustAcquire(t, l)
+		_, err := l.Acquire(context.Background())
+		expectLimited(t, err, ReasonConcurrency)
+
+		// Повторный вызов release не освобождает чужое место
+		release()
+		release()
+		second := mustAcquire(t, l)
+		_, err = l.Acquire(context.Background())
+		expectLimited(t, err, ReasonConcurrency)
+		second()
+	})
+
+	t.Run("waits for release", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{MaxInFlight: 1, MaxWaitMs: 1000})
+		release := mustAcquire(t, l)
+		time.AfterFunc(30*time.Millisecond, release)
+		mustAcquire(t, l)()
+	})
+
+	t.Run("context deadline shorter than max wait", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{MaxInFlight: 1, MaxWaitMs: 10000})
+		defer mustAcquire(t, l)()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := l.Acquire(ctx)
+		expectLimited(t, err, ReasonConcurrency)
+		if time.Since(start) > time.Second {
+			t.Fatalf("waited %s past the context deadline", time.Since(start))
+		}
+	})
+
+	t.Run("max in flight changed", func(t *testing.T) {
+		registry, connection, l := newTestLimiter(models.RateLimitSettings{MaxInFlight: 1})
+		old := mustAcquire(t, l)
+		connection.RateLimit.MaxInFlight = 2
+		if registry.Get(connection, "sap") != l {
+			t.Fatal("registry returned another limiter for the connection")
+		}
+		// Новый семафор не учитывает запросы, отправленные до изменения
+		mustAcquire(t, l)
+		mustAcquire(t, l)
+		_, err := l.Acquire(context.Background())
+		expectLimited(t, err, ReasonConcurrency)
+		old()
+	})
+}
+
+func TestLimiterBackoff(t *testing.T) {
+	t.Run("rejects during pause", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{})
+		l.Backoff(5 * time.Second)
+		_, err := l.Acquire(context.Background())
+		expectLimited(t, err, ReasonBackoff)
+		var limitErr *LimitError
+		errors.As(err, &limitErr)
+		if limitErr.RetryAfter < 4*time.Second || limitErr.RetryAfter > 5*time.Second {
+			t.Fatalf("RetryAfter = %s, want the remaining pause", limitErr.RetryAfter)
+		}
+	})
+
+	t.Run("waits for pause within max wait", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{MaxWaitMs: 1000})
+		l.Backoff(50 * time.Millisecond)
+		start := time.Now()
+		mustAcquire(t, l)()
+		if waited := time.Since(start); waited < 40*time.Millisecond {
+			t.Fatalf("waited %s, want the 50ms pause", waited)
+		}
+	})
+
+	t.Run("default and shorter pauses", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{})
+		l.Backoff(0)
+		if pause := time.Until(l.pausedUntil); pause <= DefaultBackoff-100*time.Millisecond || pause > DefaultBackoff {
+			t.Fatalf("pause %s, want DefaultBackoff", pause)
+		}
+		// Короткий Retry-After не сокращает уже назначенную паузу
+		l.Backoff(3 * time.Second)
+		l.Backoff(10 * time.Millisecond)
+		if pause := time.Until(l.pausedUntil); pause < 2*time.Second {
+			t.Fatalf("pause shortened to %s", pause)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		_, _, l := newTestLimiter(models.RateLimitSettings{MaxWaitMs: 10000})
+		l.Backoff(time.Second)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Acquire = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		exporter string
+		wantErr  bool
+	}{
+		{exporter: ""},
+		{exporter: ExporterNone},
+		{exporter: ExporterStdout},
+		{exporter: "jaeger", wantErr: true},
+	}
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	for _, tt := range tests {
+		t.Run(tt.exporter, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.exporter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	if _, err := Setup(context.Background(), ExporterNone); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, span := Start(context.Background(), "REST send", trace.WithSpanKind(trace.SpanKindClient))
+	headers := map[string]string{}
+	Inject(ctx, headers)
+	End(span, errors.New("connection refused"))
+
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if headers["traceparent"] != want {
+		t.Fatalf("traceparent = %q, want %q", headers["traceparent"], want)
+	}
+
+	// Получатель продолжает трассировку отправителя
+	_, child := Start(Extract(context.Background(), propagation.MapCarrier(headers)), "RouteMessage")
+	End(child, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error || spans[0].Status().Description != "connection refused" || len(spans[0].Events()) != 1 {
+		t.Fatalf("failed span status %v with %d events, want error with recorded exception", spans[0].Status(), len(spans[0].Events()))
+	}
+	if spans[1].Status().Code != codes.Unset || spans[1].Parent().SpanID() != span.SpanContext().SpanID() || !spans[1].Parent().IsRemote() {
+		t.Fatalf("child span status %v, parent %s; want unset status and remote parent %s", spans[1].Status(), spans[1].Parent().SpanID(), span.SpanContext().SpanID())
+	}
+}
@@ -0,0 +1,11 @@
+-- ===========================
+-- CHARSETS
+-- ===========================
+
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS source_charset VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS target_charset VARCHAR(50) NOT NULL DEFAULT '';
+
+ALTER TABLE thread_routes
+    ADD COLUMN IF NOT EXISTS source_charset VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS target_charset VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- ===========================
+-- COMMERCEML (1C EXCHANGE)
+-- ===========================
+
+ALTER TYPE file_format ADD VALUE IF NOT EXISTS 'CommerceML';
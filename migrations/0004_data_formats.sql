@@ -0,0 +1,12 @@
+-- ===========================
+-- YAML, MESSAGEPACK, PROTOBUF
+-- ===========================
+
+ALTER TYPE file_format ADD VALUE IF NOT EXISTS 'YAML';
+ALTER TYPE file_format ADD VALUE IF NOT EXISTS 'MessagePack';
+ALTER TYPE file_format ADD VALUE IF NOT EXISTS 'Protobuf';
+
+-- Схема Protobuf маршрута: FileDescriptorSet и полное имя сообщения
+ALTER TABLE thread_routes
+    ADD COLUMN IF NOT EXISTS proto_descriptor BYTEA,
+    ADD COLUMN IF NOT EXISTS proto_message VARCHAR(200) NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+-- ===========================
+-- EDI (EDIFACT, ANSI X12)
+-- ===========================
+
+ALTER TYPE file_format ADD VALUE IF NOT EXISTS 'EDIFACT';
+ALTER TYPE file_format ADD VALUE IF NOT EXISTS 'X12';
+
+-- Настройки EDI маршрута: разделители, участники обмена, квитанции
+ALTER TABLE thread_routes
+    ADD COLUMN IF NOT EXISTS edi_settings JSONB NOT NULL DEFAULT '{}';
+
+-- Последние контрольные номера обменов по паре отправитель/получатель
+CREATE TABLE IF NOT EXISTS edi_control_numbers (
+    standard VARCHAR(20) NOT NULL,
+    sender VARCHAR(100) NOT NULL,
+    receiver VARCHAR(100) NOT NULL,
+    last_number BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (standard, sender, receiver)
+);
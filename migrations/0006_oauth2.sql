@@ -0,0 +1,15 @@
+-- ===========================
+-- OAUTH2
+-- ===========================
+
+ALTER TYPE authentication_type ADD VALUE IF NOT EXISTS 'OAuth2';
+
+-- Токены OAuth2 и JWT длиннее 100 символов
+ALTER TABLE connection_authentications ALTER COLUMN token TYPE TEXT;
+
+ALTER TABLE connection_authentications
+    ADD COLUMN IF NOT EXISTS token_url VARCHAR(300) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_id VARCHAR(200) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_secret TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS scope VARCHAR(300) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS refresh_token TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- ===========================
+-- ENCRYPTED CREDENTIALS
+-- ===========================
+
+-- Зашифрованные значения (enc:v1:...) не помещаются в VARCHAR(50)
+ALTER TABLE connection_authentications ALTER COLUMN password TYPE TEXT;
@@ -0,0 +1,11 @@
+-- ===========================
+-- WS-SECURITY
+-- ===========================
+
+ALTER TYPE authentication_type ADD VALUE IF NOT EXISTS 'WSSecurity';
+
+-- Сертификат X.509 и закрытый ключ (PEM) для подписи, настройки WS-Security
+ALTER TABLE connection_authentications
+    ADD COLUMN IF NOT EXISTS certificate TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS private_key TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ws_security JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,7 @@
+-- ===========================
+-- SOAP 1.2
+-- ===========================
+
+-- Версия SOAP подключения: 1.1 или 1.2
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS soap_version VARCHAR(3) NOT NULL DEFAULT '1.1';
@@ -0,0 +1,7 @@
+-- ===========================
+-- WSDL IMPORT
+-- ===========================
+
+-- SOAPAction операции; если пусто, в качестве action используется path
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS soap_action VARCHAR(300) NOT NULL DEFAULT '';
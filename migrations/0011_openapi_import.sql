@@ -0,0 +1,8 @@
+-- ===========================
+-- OPENAPI IMPORT
+-- ===========================
+
+-- Корневые thread_objects схем запроса и ответа маршрута (заполняются при импорте)
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS request_object UUID REFERENCES thread_objects(ref) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS response_object UUID REFERENCES thread_objects(ref) ON DELETE SET NULL;
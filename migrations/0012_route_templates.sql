@@ -0,0 +1,8 @@
+-- ===========================
+-- ROUTE TEMPLATES
+-- ===========================
+
+-- Заголовки и параметры запроса маршрута thread с плейсхолдерами {...}
+ALTER TABLE thread_routes
+    ADD COLUMN IF NOT EXISTS headers JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS query JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+-- ===========================
+-- REQUEST/RESPONSE THREADS
+-- ===========================
+
+-- Режим запрос-ответ: ответ целевой системы конвертируется в формат
+-- входящего сообщения и возвращается вызывающему
+ALTER TABLE threads
+    ADD COLUMN IF NOT EXISTS request_response BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,36 @@
+-- ===========================
+-- USERS, ROLES AND API KEYS
+-- ===========================
+
+DO $$
+BEGIN
+    CREATE TYPE user_role AS ENUM ('admin', 'integrator', 'operator', 'read_only');
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END $$;
+
+-- Пароли хранятся как хэш bcrypt (пароли в открытом виде хэшируются при запуске сервера)
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(100);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role user_role NOT NULL DEFAULT 'read_only';
+
+-- Роли пользователя в отдельных группах threads (дополняют роль пользователя)
+CREATE TABLE IF NOT EXISTS user_thread_groups (
+    user_ref UUID NOT NULL REFERENCES users(ref) ON DELETE CASCADE,
+    thread_group UUID NOT NULL REFERENCES threads_groups(ref) ON DELETE CASCADE,
+    role user_role NOT NULL,
+    PRIMARY KEY (user_ref, thread_group)
+);
+
+-- API ключи машинных клиентов: хранится только SHA-256 ключа
+CREATE TABLE IF NOT EXISTS api_keys (
+    ref UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_ref UUID NOT NULL REFERENCES users(ref) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ
+);
@@ -0,0 +1,8 @@
+-- ===========================
+-- CIRCUIT BREAKER
+-- ===========================
+
+-- Параметры автоматического выключателя подключения:
+-- {"failure_threshold": 5, "open_seconds": 30, "half_open_requests": 1, "disabled": false}
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS circuit_breaker JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+-- ===========================
+-- OUTBOUND RATE LIMITS
+-- ===========================
+
+-- Ограничения исходящих запросов подключения:
+-- {"requests_per_second": 20, "burst": 20, "max_in_flight": 10, "max_wait_ms": 2000}
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS rate_limit JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,14 @@
+-- ===========================
+-- HTTP CLIENT SETTINGS
+-- ===========================
+
+-- Таймауты, пул соединений, HTTP/2, прокси и TLS клиента HTTP подключения:
+-- {"timeout_ms": 10000, "connect_timeout_ms": 2000, "read_timeout_ms": 8000,
+--  "max_idle_conns_per_host": 20, "proxy_url": "http://proxy:3128",
+--  "tls": {"ca_certificate": "-----BEGIN CERTIFICATE-----...", "min_version": "1.3"}}
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS http_client JSONB NOT NULL DEFAULT '{}';
+
+-- Время обработки маршрута в миллисекундах (0 — только таймауты подключения)
+ALTER TABLE thread_routes
+    ADD COLUMN IF NOT EXISTS timeout_ms INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- ===========================
+-- CLIENT CERTIFICATES (mTLS)
+-- ===========================
+
+ALTER TYPE authentication_type ADD VALUE IF NOT EXISTS 'Certificate';
+
+-- Параметры TLS аутентификации сертификатом (certificate и private_key в PEM):
+-- {"ca_certificate": "-----BEGIN CERTIFICATE-----...", "server_name": "sap.internal", "min_version": "1.3"}
+ALTER TABLE connection_authentications
+    ADD COLUMN IF NOT EXISTS tls JSONB NOT NULL DEFAULT '{}';
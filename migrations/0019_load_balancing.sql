@@ -0,0 +1,22 @@
+-- ===========================
+-- LOAD BALANCING
+-- ===========================
+
+-- Стратегия выбора подключения системы: failover, round_robin, weighted
+ALTER TABLE systems
+    ADD COLUMN IF NOT EXISTS load_balancing VARCHAR(20) NOT NULL DEFAULT 'failover';
+
+-- Порядок подключений системы: меньший priority используется первым,
+-- подключения с большим priority — резервные; weight — доля запросов (weighted)
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS weight INTEGER NOT NULL DEFAULT 1;
+
+-- Активная проверка подключения:
+-- {"interval_ms": 10000, "timeout_ms": 2000, "path": "/sap/public/ping",
+--  "expected_status": 200, "unhealthy_threshold": 2, "healthy_threshold": 2}
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS health_check JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_connection_settings_system_priority
+    ON connection_settings (system, priority);
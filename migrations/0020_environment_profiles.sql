@@ -0,0 +1,14 @@
+-- ===========================
+-- ENVIRONMENT PROFILES
+-- ===========================
+
+-- Профиль окружения (ESB_ENV), в котором действуют подключение и аутентификация.
+-- Пустой профиль — общие записи; подключения профиля заменяют общие подключения системы.
+ALTER TABLE connection_settings
+    ADD COLUMN IF NOT EXISTS environment VARCHAR(50) NOT NULL DEFAULT '';
+
+ALTER TABLE connection_authentications
+    ADD COLUMN IF NOT EXISTS environment VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_connection_settings_system_environment
+    ON connection_settings (system, environment);
@@ -0,0 +1,26 @@
+-- ===========================
+-- IDEMPOTENCY
+-- ===========================
+
+-- Источник ключа идемпотентности входящих сообщений thread:
+-- {"source": "header", "header": "Idempotency-Key", "ttl_seconds": 86400}
+-- {"source": "json_path", "json_path": "$.id"}
+-- {"source": "hash"}
+ALTER TABLE threads
+    ADD COLUMN IF NOT EXISTS idempotency JSONB NOT NULL DEFAULT '{}';
+
+-- Ключи обработанных входящих сообщений и их результаты. Пока сообщение
+-- обрабатывается, status = 'processing'; expires_at — окончание хранения.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(150) NOT NULL,
+    key TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    headers JSONB NOT NULL DEFAULT '{}',
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,9 @@
+-- ===========================
+-- AMQP CONSUMER
+-- ===========================
+
+-- Очередь AMQP, из которой thread получает входящие сообщения. Брокер берется
+-- из подключений системы с адресом amqp:// или amqps://:
+-- {"system": "<systems.ref>", "queue": "orders.in"}
+ALTER TABLE threads
+    ADD COLUMN IF NOT EXISTS amqp_consumer JSONB NOT NULL DEFAULT '{}';
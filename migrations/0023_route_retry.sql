@@ -0,0 +1,8 @@
+-- ===========================
+-- ROUTE RETRY
+-- ===========================
+
+-- Повторная отправка маршрута thread при временной ошибке целевой системы:
+-- {"max_attempts": 3, "backoff_ms": 500, "max_backoff_ms": 5000}
+ALTER TABLE thread_routes
+    ADD COLUMN IF NOT EXISTS retry JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,9 @@
+-- ===========================
+-- IDEMPOTENCY FINGERPRINT
+-- ===========================
+
+-- SHA-256 тела первого сообщения с ключом: повтор ключа с другим телом
+-- отклоняется, а не получает сохраненный ответ. Пустой у ключей,
+-- сохраненных до появления колонки.
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(64) NOT NULL DEFAULT '';
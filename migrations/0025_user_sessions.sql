@@ -0,0 +1,10 @@
+-- ===========================
+-- USER SESSIONS
+-- ===========================
+
+-- active: отключенный пользователь не входит, его токены и API ключи не действуют.
+-- token_version: номер в токенах доступа; увеличивается при смене пароля и
+-- отключении, и выданные раньше токены перестают действовать.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 1;